@@ -0,0 +1,131 @@
+package auth
+
+// JWTのクレームと、トークンの発行・検証を実装
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// トークンの種類
+const (
+	TokenTypeAccess       = "access"        // 通常のログインセッション
+	TokenTypeMFAPending   = "mfa_pending"   // パスワード認証済みで二要素認証待ちの状態
+	TokenTypePAT          = "pat"           // パーソナルアクセストークン（jwtではなく、検証結果からクレームを組み立てる）
+	TokenTypeEmailConfirm = "email_confirm" // メールアドレス変更の確認リンク（jtiに申請のnonceを入れる）
+	TokenTypeEmailUndo    = "email_undo"    // メールアドレス変更の取り消しリンク
+)
+
+// ロール
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// パーソナルアクセストークンのスコープ
+const (
+	ScopeReadCuisines  = "read:cuisines"
+	ScopeWriteCuisines = "write:cuisines"
+)
+
+// Scopes は発行できるスコープの一覧
+var Scopes = []string{ScopeReadCuisines, ScopeWriteCuisines}
+
+var (
+	ErrUnauthenticated  = errors.New("unauthenticated")
+	ErrMalformedClaims  = errors.New("malformed token claims")
+	ErrInvalidTokenType = errors.New("invalid token type")
+)
+
+// Claims はこのアプリが発行するJWTのクレーム
+type Claims struct {
+	UserID    uint     `json:"user_id"`
+	SessionID string   `json:"sid,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+	TokenType string   `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// Validate はjwtのパース時に呼ばれ、必須のクレームが揃っているかを確認する
+func (c *Claims) Validate() error {
+	if c.UserID == 0 {
+		return fmt.Errorf("%w: user_id is missing", ErrMalformedClaims)
+	}
+	if c.TokenType == "" {
+		return fmt.Errorf("%w: typ is missing", ErrMalformedClaims)
+	}
+	if c.ExpiresAt == nil {
+		return fmt.Errorf("%w: exp is missing", ErrMalformedClaims)
+	}
+	return nil
+}
+
+// HasRole は指定したロールを持っているかを返す
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope は指定したスコープの操作が許可されているかを返す
+// ログインセッションはすべての操作が許可され、パーソナルアクセストークンは付与されたスコープのみ許可される
+func (c *Claims) HasScope(scope string) bool {
+	if c.TokenType == TokenTypeAccess {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NewClaims は指定した種類のトークン用のクレームを生成する
+func NewClaims(userID uint, tokenType string, ttl time.Duration) *Claims {
+	now := time.Now()
+	return &Claims{
+		UserID:    userID,
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+}
+
+// NewAccessClaims はログインセッション用のクレームを生成する
+func NewAccessClaims(userID uint, sessionID string, roles []string, ttl time.Duration) *Claims {
+	claims := NewClaims(userID, TokenTypeAccess, ttl)
+	claims.SessionID = sessionID
+	claims.Roles = roles
+	return claims
+}
+
+// Sign はクレームをHS256で署名してトークン文字列にする
+func Sign(claims *Claims, secret string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(secret))
+}
+
+// Parse はトークン文字列を検証し、指定した種類のトークンであればクレームを返す
+func Parse(tokenString string, secret string, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(_ *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != tokenType {
+		return nil, ErrInvalidTokenType
+	}
+	return claims, nil
+}
@@ -0,0 +1,172 @@
+package auth
+
+// 認証ミドルウェアと、ハンドラーから認証済みユーザーを取り出すヘルパー
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	cookieName   = "token"
+	contextKey   = "auth.claims"
+	bearerPrefix = "Bearer "
+)
+
+var (
+	ErrInsufficientScope = errors.New("insufficient token scope")
+	ErrForbidden         = errors.New("forbidden")
+)
+
+// TokenVerifier はAuthorizationヘッダーのパーソナルアクセストークンを検証する
+type TokenVerifier interface {
+	VerifyToken(token string) (*Claims, error)
+}
+
+// SessionChecker はjwtの検証後に、セッションが失効していないか・アカウントが無効化されていないかを確認する
+// ロールの変更をすぐに反映するため、クレームのロールを更新してもよい
+type SessionChecker interface {
+	CheckSession(claims *Claims) error
+}
+
+// Authenticator はcookieのセッションとBearerトークンを検証するミドルウェアを生成する
+type Authenticator struct {
+	secret   string
+	sessions SessionChecker // nilの場合はjwtの検証のみ行う
+	tokens   TokenVerifier  // nilの場合はBearerトークンを受け付けない
+}
+
+func NewAuthenticator(secret string, sessions SessionChecker, tokens TokenVerifier) *Authenticator {
+	return &Authenticator{secret, sessions, tokens}
+}
+
+// Session はcookieのセッションのみを受け付けるミドルウェアを返す
+func (a *Authenticator) Session() echo.MiddlewareFunc {
+	return a.middleware(false)
+}
+
+// SessionOrToken はcookieのセッションとBearerトークンを受け付けるミドルウェアを返す
+// Bearerトークンがある場合はcookieを使わない（CSRFの検証を省略しているため）
+func (a *Authenticator) SessionOrToken() echo.MiddlewareFunc {
+	return a.middleware(true)
+}
+
+// Optional はcookieのセッションで認証できた場合のみクレームを設定し、できなくても次の処理へ進むミドルウェアを返す
+// ログアウトのように、未ログインでも成功させたいエンドポイントで使用する
+func (a *Authenticator) Optional() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, err := a.authenticate(c, false)
+			if err == nil && (a.sessions == nil || a.sessions.CheckSession(claims) == nil) {
+				SetClaims(c, claims)
+			}
+			return next(c)
+		}
+	}
+}
+
+func (a *Authenticator) middleware(acceptTokens bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, err := a.authenticate(c, acceptTokens)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, ErrUnauthenticated.Error())
+			}
+			if a.sessions != nil {
+				if err := a.sessions.CheckSession(claims); err != nil {
+					return c.JSON(http.StatusUnauthorized, ErrUnauthenticated.Error())
+				}
+			}
+			SetClaims(c, claims)
+			return next(c)
+		}
+	}
+}
+
+func (a *Authenticator) authenticate(c echo.Context, acceptTokens bool) (*Claims, error) {
+	if token, ok := BearerToken(c); ok {
+		if !acceptTokens || a.tokens == nil {
+			return nil, ErrUnauthenticated
+		}
+		return a.tokens.VerifyToken(token)
+	}
+	cookie, err := c.Cookie(cookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, ErrUnauthenticated
+	}
+	return Parse(cookie.Value, a.secret, TokenTypeAccess)
+}
+
+// RequireRole は認証済みのユーザーが指定したロールを持っていなければ403を返す
+func RequireRole(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, err := ClaimsFrom(c)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, err.Error())
+			}
+			if !claims.HasRole(role) {
+				return c.JSON(http.StatusForbidden, ErrForbidden.Error())
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireScope は認証済みのトークンに指定したスコープがなければ403を返す
+func RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, err := ClaimsFrom(c)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, err.Error())
+			}
+			if !claims.HasScope(scope) {
+				return c.JSON(http.StatusForbidden, ErrInsufficientScope.Error())
+			}
+			return next(c)
+		}
+	}
+}
+
+// BearerToken はAuthorizationヘッダーのBearerトークンを返す
+func BearerToken(c echo.Context) (string, bool) {
+	header := c.Request().Header.Get(echo.HeaderAuthorization)
+	if len(header) <= len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(bearerPrefix):]), true
+}
+
+// HasBearerToken はBearerトークンによるリクエストかを返す（CSRFミドルウェアのSkipperに使う）
+// ブラウザは他サイトからAuthorizationヘッダーを付けられないため、CSRFの検証は不要になる
+func HasBearerToken(c echo.Context) bool {
+	_, ok := BearerToken(c)
+	return ok
+}
+
+// SetClaims はクレームをコンテキストに格納する
+func SetClaims(c echo.Context, claims *Claims) {
+	c.Set(contextKey, claims)
+}
+
+// ClaimsFrom はミドルウェアが格納したクレームを取り出す
+func ClaimsFrom(c echo.Context) (*Claims, error) {
+	claims, ok := c.Get(contextKey).(*Claims)
+	if !ok || claims == nil || claims.UserID == 0 {
+		return nil, ErrUnauthenticated
+	}
+	return claims, nil
+}
+
+// UserID は認証済みユーザーのIDを返す
+func UserID(c echo.Context) (uint, error) {
+	claims, err := ClaimsFrom(c)
+	if err != nil {
+		return 0, err
+	}
+	return claims.UserID, nil
+}
@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "test_secret"
+
+func signMap(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestAuthenticatorSession(t *testing.T) {
+	valid, err := Sign(NewAccessClaims(1, "sid", []string{RoleUser}, time.Hour), testSecret)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	expired, err := Sign(NewAccessClaims(1, "sid", []string{RoleUser}, -time.Hour), testSecret)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	otherType := NewAccessClaims(1, "sid", nil, time.Hour)
+	otherType.TokenType = "other"
+	wrongType, err := Sign(otherType, testSecret)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	testCases := []struct {
+		name         string
+		token        string
+		expectStatus int
+		expectUserID uint
+	}{
+		{name: "正常なトークン", token: valid, expectStatus: http.StatusOK, expectUserID: 1},
+		{name: "トークンなし", token: "", expectStatus: http.StatusUnauthorized},
+		{name: "期限切れ", token: expired, expectStatus: http.StatusUnauthorized},
+		{name: "種類が違う", token: wrongType, expectStatus: http.StatusUnauthorized},
+		{
+			name:         "user_idが文字列",
+			token:        signMap(t, jwt.MapClaims{"user_id": "1", "typ": TokenTypeAccess, "exp": exp}),
+			expectStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "user_idがない",
+			token:        signMap(t, jwt.MapClaims{"typ": TokenTypeAccess, "exp": exp}),
+			expectStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "旧形式のトークン",
+			token:        signMap(t, jwt.MapClaims{"user_id": 1, "exp": exp}),
+			expectStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.token != "" {
+				req.AddCookie(&http.Cookie{Name: "token", Value: tc.token})
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			var gotUserID uint
+			handler := NewAuthenticator(testSecret, nil, nil).Session()(func(c echo.Context) error {
+				id, err := UserID(c)
+				if err != nil {
+					return err
+				}
+				gotUserID = id
+				return c.NoContent(http.StatusOK)
+			})
+
+			assert.NotPanics(t, func() {
+				assert.NoError(t, handler(c))
+			})
+			assert.Equal(t, tc.expectStatus, rec.Code)
+			assert.Equal(t, tc.expectUserID, gotUserID)
+		})
+	}
+}
+
+func TestUserIDWithoutMiddleware(t *testing.T) {
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	c.Set(contextKey, "not claims")
+
+	_, err := UserID(c)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+}
+
+type stubVerifier map[string]*Claims
+
+func (s stubVerifier) VerifyToken(token string) (*Claims, error) {
+	claims, ok := s[token]
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	return claims, nil
+}
+
+func TestAuthenticatorSessionOrToken(t *testing.T) {
+	session, err := Sign(NewAccessClaims(1, "sid", []string{RoleUser}, time.Hour), testSecret)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	verifier := stubVerifier{
+		"cmpat_read": {UserID: 2, TokenType: TokenTypePAT, Scopes: []string{ScopeReadCuisines}},
+	}
+
+	testCases := []struct {
+		name         string
+		verifier     TokenVerifier
+		sessionOnly  bool // Session()のルート
+		header       string
+		cookie       string
+		expectStatus int
+		expectUserID uint
+	}{
+		{name: "Bearerトークン", verifier: verifier, header: "Bearer cmpat_read", expectStatus: http.StatusOK, expectUserID: 2},
+		{name: "小文字のbearer", verifier: verifier, header: "bearer cmpat_read", expectStatus: http.StatusOK, expectUserID: 2},
+		{name: "不正なBearerトークン", verifier: verifier, header: "Bearer cmpat_unknown", expectStatus: http.StatusUnauthorized},
+		{name: "cookieのみ", verifier: verifier, cookie: session, expectStatus: http.StatusOK, expectUserID: 1},
+		// CSRFの検証を省略しているため、Bearerトークンが不正な場合にcookieを使ってはならない
+		{name: "不正なBearerトークンとcookie", verifier: verifier, header: "Bearer cmpat_unknown", cookie: session, expectStatus: http.StatusUnauthorized},
+		{name: "トークンを受け付けないルート", verifier: verifier, sessionOnly: true, header: "Bearer cmpat_read", cookie: session, expectStatus: http.StatusUnauthorized},
+		{name: "トークンを検証できない", verifier: nil, header: "Bearer cmpat_read", cookie: session, expectStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, tc.header)
+			}
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "token", Value: tc.cookie})
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			authn := NewAuthenticator(testSecret, nil, tc.verifier)
+			middleware := authn.SessionOrToken()
+			if tc.sessionOnly {
+				middleware = authn.Session()
+			}
+			var gotUserID uint
+			handler := middleware(func(c echo.Context) error {
+				gotUserID, _ = UserID(c)
+				return c.NoContent(http.StatusOK)
+			})
+			assert.NoError(t, handler(c))
+			assert.Equal(t, tc.expectStatus, rec.Code)
+			assert.Equal(t, tc.expectUserID, gotUserID)
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	testCases := []struct {
+		name         string
+		claims       *Claims
+		expectStatus int
+	}{
+		{name: "ログインセッション", claims: NewAccessClaims(1, "sid", []string{RoleUser}, time.Hour), expectStatus: http.StatusOK},
+		{name: "スコープあり", claims: &Claims{UserID: 1, TokenType: TokenTypePAT, Scopes: []string{ScopeWriteCuisines}}, expectStatus: http.StatusOK},
+		{name: "スコープなし", claims: &Claims{UserID: 1, TokenType: TokenTypePAT, Scopes: []string{ScopeReadCuisines}}, expectStatus: http.StatusForbidden},
+		{name: "未認証", claims: nil, expectStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+			if tc.claims != nil {
+				SetClaims(c, tc.claims)
+			}
+
+			handler := RequireScope(ScopeWriteCuisines)(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+			assert.NoError(t, handler(c))
+			assert.Equal(t, tc.expectStatus, rec.Code)
+		})
+	}
+}
+
+type stubSessionChecker struct {
+	revoked map[string]bool
+	roles   []string
+}
+
+func (s stubSessionChecker) CheckSession(claims *Claims) error {
+	if s.revoked[claims.SessionID] {
+		return ErrUnauthenticated
+	}
+	claims.Roles = s.roles
+	return nil
+}
+
+func TestAuthenticatorSessionCheck(t *testing.T) {
+	checker := stubSessionChecker{revoked: map[string]bool{"revoked": true}, roles: []string{RoleUser, RoleAdmin}}
+	authn := NewAuthenticator(testSecret, checker, nil)
+
+	testCases := []struct {
+		name         string
+		sessionID    string
+		expectStatus int
+	}{
+		{name: "有効なセッション", sessionID: "sid", expectStatus: http.StatusOK},
+		{name: "失効したセッション", sessionID: "revoked", expectStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			token, err := Sign(NewAccessClaims(1, tc.sessionID, []string{RoleUser}, time.Hour), testSecret)
+			if err != nil {
+				t.Fatalf("failed to sign token: %v", err)
+			}
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: "token", Value: token})
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			// ロールの変更はトークンの再発行を待たずに反映される
+			handler := authn.Session()(RequireRole(RoleAdmin)(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			}))
+			assert.NoError(t, handler(c))
+			assert.Equal(t, tc.expectStatus, rec.Code)
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	testCases := []struct {
+		name         string
+		claims       *Claims
+		expectStatus int
+	}{
+		{name: "管理者", claims: NewAccessClaims(1, "sid", []string{RoleUser, RoleAdmin}, time.Hour), expectStatus: http.StatusOK},
+		{name: "一般ユーザー", claims: NewAccessClaims(1, "sid", []string{RoleUser}, time.Hour), expectStatus: http.StatusForbidden},
+		{name: "未認証", claims: nil, expectStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+			if tc.claims != nil {
+				SetClaims(c, tc.claims)
+			}
+
+			handler := RequireRole(RoleAdmin)(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})
+			assert.NoError(t, handler(c))
+			assert.Equal(t, tc.expectStatus, rec.Code)
+		})
+	}
+}
+
+func TestAuthenticatorOptional(t *testing.T) {
+	valid, err := Sign(NewAccessClaims(1, "sid", []string{RoleUser}, time.Hour), testSecret)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	revoked, err := Sign(NewAccessClaims(1, "revoked", []string{RoleUser}, time.Hour), testSecret)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	authn := NewAuthenticator(testSecret, stubSessionChecker{revoked: map[string]bool{"revoked": true}}, nil)
+
+	testCases := []struct {
+		name         string
+		cookie       string
+		expectUserID uint
+	}{
+		{name: "ログイン中", cookie: valid, expectUserID: 1},
+		{name: "未ログイン", cookie: ""},
+		{name: "失効したセッション", cookie: revoked},
+		{name: "不正なトークン", cookie: "invalid"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "token", Value: tc.cookie})
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			var gotUserID uint
+			handler := authn.Optional()(func(c echo.Context) error {
+				gotUserID, _ = UserID(c)
+				return c.NoContent(http.StatusOK)
+			})
+			assert.NoError(t, handler(c))
+			assert.Equal(t, http.StatusOK, rec.Code, "認証できなくても次の処理へ進む")
+			assert.Equal(t, tc.expectUserID, gotUserID)
+		})
+	}
+}
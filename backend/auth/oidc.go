@@ -0,0 +1,232 @@
+package auth
+
+// OpenID Connectプロバイダーとの連携（認可コードフロー + PKCE、state、nonce）
+// プロバイダーはissuer URLで設定し、エンドポイントはディスカバリーで取得する
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown oidc provider")
+	ErrNonceMismatch   = errors.New("oidc nonce mismatch")
+	ErrInvalidState    = errors.New("invalid oidc state")
+)
+
+// OIDCProviderConfig はプロバイダーごとの設定
+type OIDCProviderConfig struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// OIDCIdentity はIDトークンから取り出した外部アカウントの情報
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// OIDCProvider はディスカバリー済みのプロバイダー
+type OIDCProvider struct {
+	name     string
+	verifier *oidc.IDTokenVerifier
+	oauth2   oauth2.Config
+}
+
+// NewOIDCProvider はissuerのディスカバリーを行ってプロバイダーを生成する
+func NewOIDCProvider(ctx context.Context, cfg OIDCProviderConfig) (*OIDCProvider, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery failed (%s): %w", cfg.Name, err)
+	}
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	return &OIDCProvider{
+		name:     cfg.Name,
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+	}, nil
+}
+
+func (p *OIDCProvider) Name() string {
+	return p.name
+}
+
+// AuthCodeURL は認可エンドポイントへのURLを返す（PKCEはS256）
+func (p *OIDCProvider) AuthCodeURL(state string, nonce string, verifier string) string {
+	return p.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange は認可コードをトークンに交換し、IDトークンの署名・audience・nonceを検証する
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, verifier string, nonce string) (OIDCIdentity, error) {
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return OIDCIdentity{}, fmt.Errorf("oidc code exchange failed: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return OIDCIdentity{}, errors.New("oidc token response has no id_token")
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return OIDCIdentity{}, fmt.Errorf("oidc id_token verification failed: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return OIDCIdentity{}, ErrNonceMismatch
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return OIDCIdentity{}, err
+	}
+	return OIDCIdentity{
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// OIDCRegistry は設定済みのプロバイダーを保持する
+// ディスカバリーは初回利用時に行う（起動時にプロバイダーへ接続できなくてもサーバーは起動する）
+type OIDCRegistry struct {
+	mu        sync.Mutex
+	configs   map[string]OIDCProviderConfig
+	providers map[string]*OIDCProvider
+}
+
+func NewOIDCRegistry(configs []OIDCProviderConfig) *OIDCRegistry {
+	r := &OIDCRegistry{
+		configs:   map[string]OIDCProviderConfig{},
+		providers: map[string]*OIDCProvider{},
+	}
+	for _, cfg := range configs {
+		r.configs[cfg.Name] = cfg
+	}
+	return r
+}
+
+// Names は設定済みのプロバイダー名を返す
+func (r *OIDCRegistry) Names() []string {
+	names := make([]string, 0, len(r.configs))
+	for name := range r.configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get はプロバイダーを返す（未ディスカバリーであればディスカバリーを行う）
+func (r *OIDCRegistry) Get(ctx context.Context, name string) (*OIDCProvider, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.providers[name]; ok {
+		return p, nil
+	}
+	cfg, ok := r.configs[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	// プロバイダーは公開鍵の取得にctxを使い続けるため、キャンセルされないctxを渡す
+	pctx := context.WithoutCancel(ctx)
+	if _, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); !ok {
+		pctx = oidc.ClientContext(pctx, &http.Client{Timeout: 10 * time.Second})
+	}
+	p, err := NewOIDCProvider(pctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	r.providers[name] = p
+	return p, nil
+}
+
+// OIDCState は認可リクエストからコールバックまでの間、ブラウザのcookieに保持する値
+// 改ざんされないようHS256で署名したjwtにする
+type OIDCState struct {
+	State      string `json:"state"`
+	Nonce      string `json:"nonce"`
+	Verifier   string `json:"verifier"`               // PKCEのcode_verifier
+	Provider   string `json:"provider"`               // 開始したプロバイダー（別プロバイダーのコールバックでの使用を防ぐ）
+	LinkUserID uint   `json:"link_user_id,omitempty"` // ログイン中のユーザーへの連携であれば、そのユーザーID
+	jwt.RegisteredClaims
+}
+
+// NewOIDCState はstate・nonce・code_verifierを生成する
+func NewOIDCState(provider string, linkUserID uint, ttl time.Duration) (*OIDCState, error) {
+	state, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCState{
+		State:      state,
+		Nonce:      nonce,
+		Verifier:   oauth2.GenerateVerifier(),
+		Provider:   provider,
+		LinkUserID: linkUserID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		},
+	}, nil
+}
+
+// SignOIDCState はstateをcookieに保存できる文字列にする
+func SignOIDCState(st *OIDCState, secret string) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, st).SignedString([]byte(secret))
+}
+
+// ParseOIDCState はcookieの値を検証し、コールバックのプロバイダー・stateと一致すればstateを返す
+func ParseOIDCState(token string, secret string, provider string, state string) (*OIDCState, error) {
+	st := &OIDCState{}
+	_, err := jwt.ParseWithClaims(token, st, func(_ *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidState, err)
+	}
+	if st.Provider != provider || state == "" || subtle.ConstantTimeCompare([]byte(st.State), []byte(state)) != 1 {
+		return nil, ErrInvalidState
+	}
+	return st, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOIDCState(t *testing.T) {
+	st, err := NewOIDCState("google", 1, time.Minute)
+	assert.NoError(t, err)
+	token, err := SignOIDCState(st, testSecret)
+	assert.NoError(t, err)
+
+	parsed, err := ParseOIDCState(token, testSecret, "google", st.State)
+	assert.NoError(t, err)
+	assert.Equal(t, st.Nonce, parsed.Nonce)
+	assert.Equal(t, st.Verifier, parsed.Verifier)
+	assert.Equal(t, uint(1), parsed.LinkUserID)
+
+	testCases := []struct {
+		name     string
+		token    string
+		secret   string
+		provider string
+		state    string
+	}{
+		{name: "stateの不一致", token: token, secret: testSecret, provider: "google", state: "forged"},
+		{name: "stateが空", token: token, secret: testSecret, provider: "google", state: ""},
+		{name: "別のプロバイダー", token: token, secret: testSecret, provider: "github", state: st.State},
+		{name: "署名の不一致", token: token, secret: "other-secret", provider: "google", state: st.State},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseOIDCState(tc.token, tc.secret, tc.provider, tc.state)
+			assert.ErrorIs(t, err, ErrInvalidState)
+		})
+	}
+
+	t.Run("期限切れ", func(t *testing.T) {
+		expired, err := NewOIDCState("google", 0, -time.Minute)
+		assert.NoError(t, err)
+		token, err := SignOIDCState(expired, testSecret)
+		assert.NoError(t, err)
+		_, err = ParseOIDCState(token, testSecret, "google", expired.State)
+		assert.ErrorIs(t, err, ErrInvalidState)
+	})
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// テストでは計算量を抑えたパラメーターを使う
+var testArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2Hasher(t *testing.T) {
+	h := NewArgon2Hasher(testArgon2Params)
+
+	encoded, err := h.Hash("password123")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	other, err := h.Hash("password123")
+	assert.NoError(t, err)
+	assert.NotEqual(t, encoded, other, "ソルトはハッシュごとに異なる")
+
+	ok, rehash, err := h.Verify(encoded, "password123")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, rehash)
+
+	ok, _, err = h.Verify(encoded, "password124")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestArgon2HasherRehash(t *testing.T) {
+	old := NewArgon2Hasher(testArgon2Params)
+	encoded, err := old.Hash("password123")
+	assert.NoError(t, err)
+
+	// パラメーターを引き上げた後も古いハッシュで検証でき、再ハッシュが必要と判定する
+	stronger := testArgon2Params
+	stronger.Iterations = 2
+	ok, rehash, err := NewArgon2Hasher(stronger).Verify(encoded, "password123")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+
+	// 一致しない場合は再ハッシュしない
+	ok, rehash, err = NewArgon2Hasher(stronger).Verify(encoded, "wrong")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, rehash)
+}
+
+func TestArgon2HasherBcrypt(t *testing.T) {
+	h := NewArgon2Hasher(testArgon2Params)
+	legacy, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	ok, rehash, err := h.Verify(string(legacy), "password123")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+
+	ok, rehash, err = h.Verify(string(legacy), "wrong")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, rehash)
+}
+
+func TestArgon2HasherInvalid(t *testing.T) {
+	h := NewArgon2Hasher(testArgon2Params)
+	for _, encoded := range []string{
+		"",
+		"plaintext",
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!$aGFzaA",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+	} {
+		ok, _, err := h.Verify(encoded, "password")
+		assert.False(t, ok, encoded)
+		assert.ErrorIs(t, err, ErrUnknownHashFormat, encoded)
+	}
+}
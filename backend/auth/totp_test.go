@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC 6238 付録Bのテストベクタ（SHA1、下6桁）
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+
+func TestTOTPCode(t *testing.T) {
+	testCases := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+	}
+
+	for _, tc := range testCases {
+		got, err := TOTPCode(rfcSecret, time.Unix(tc.unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, got, "time=%d", tc.unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+
+	step, ok := ValidateTOTP(rfcSecret, "050471", now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, current, step)
+
+	// 1ステップ前のコードも許容する
+	prev, err := TOTPCode(rfcSecret, now.Add(-30*time.Second))
+	assert.NoError(t, err)
+	_, ok = ValidateTOTP(rfcSecret, prev, now, 0)
+	assert.True(t, ok)
+
+	// 使用済みのステップは拒否する
+	_, ok = ValidateTOTP(rfcSecret, "050471", now, current)
+	assert.False(t, ok)
+
+	// 大きくずれたコードは拒否する
+	old, err := TOTPCode(rfcSecret, now.Add(-5*time.Minute))
+	assert.NoError(t, err)
+	_, ok = ValidateTOTP(rfcSecret, old, now, 0)
+	assert.False(t, ok)
+
+	_, ok = ValidateTOTP(rfcSecret, "12345", now, 0)
+	assert.False(t, ok)
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	code, err := TOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, code, 6)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("CookMeet", "test@example.com", "ABC")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/CookMeet:test@example.com?"))
+	assert.Contains(t, uri, "secret=ABC")
+	assert.Contains(t, uri, "issuer=CookMeet")
+}
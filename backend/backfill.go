@@ -0,0 +1,40 @@
+package main
+
+// 以前に保存した料理画像の補完
+// サブコマンド: backend backfill-placeholders [-dry-run] [-batch 100]
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"backend/usecase"
+)
+
+// runPlaceholderBackfillCommand はbackfill-placeholdersサブコマンドを実行する
+func runPlaceholderBackfillCommand(args []string, bu usecase.IPlaceholderBackfillUsecase, out io.Writer) error {
+	fs := flag.NewFlagSet("backfill-placeholders", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "保存せずに対象を表示する")
+	batch := fs.Int("batch", usecase.DefaultPlaceholderBackfillBatchSize, "一度に取得する料理の数")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := bu.Run(context.Background(), usecase.PlaceholderBackfillOptions{DryRun: *dryRun, BatchSize: *batch})
+	if err != nil {
+		return err
+	}
+	mode := "updated"
+	if report.DryRun {
+		mode = "dry-run"
+	}
+	fmt.Fprintf(out, "%s: scanned=%d updated=%d skipped=%d failed=%d\n", mode, report.Scanned, report.Updated, report.Skipped, len(report.Failed))
+	for _, id := range report.Failed {
+		fmt.Fprintf(out, "failed: cuisine %d\n", id)
+	}
+	if len(report.Failed) > 0 {
+		return fmt.Errorf("failed to backfill %d cuisines", len(report.Failed))
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+package config
+
+// アプリケーションの設定の読み込み
+// 優先順位は 環境変数 > .env.<GO_ENV> > .env > YAMLファイル（CONFIG_FILE） > 既定値
+// 値の形式・範囲はすべてここで確認し、各パッケージの設定（storage.Configなど）もここで組み立てる
+// 読み込んだ設定はmainから各パッケージへ明示的に渡す（プロセスの環境変数は変更しない）
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/auth"
+	"backend/db"
+	"backend/imageproc"
+	"backend/mail"
+	"backend/storage"
+	"backend/usecase"
+	"backend/validator"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config はアプリケーションの設定
+type Config struct {
+	Env         string // GO_ENV
+	Port        string
+	FrontendURL string // FE_URL
+	APIDomain   string // cookieのドメイン
+	Secret      string // jwtの署名に使う鍵
+
+	LogFormat       string        // json / text
+	ShutdownTimeout time.Duration // 停止時に処理中のリクエストなどを待つ時間
+
+	DB                db.Config
+	MigrateOnStart    bool
+	LoginAttemptStore string // postgres / memory
+
+	Storage           storage.Config
+	StorageGCInterval time.Duration // 0の場合はサーバーと同時に実行しない
+	StorageQuotaBytes int64         // 0は無制限
+
+	ImageLimits    imageproc.Limits
+	ImageURL       usecase.ImageURLConfig
+	PasswordPolicy validator.PasswordPolicyConfig
+	Argon2         auth.Argon2Params
+	SMTP           mail.SMTPConfig
+	OIDC           []auth.OIDCProviderConfig
+}
+
+// Error は設定の誤りをすべてまとめたエラー
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Load は作業ディレクトリの.envファイルとCONFIG_FILEを読み込み、設定を検証して返す
+func Load() (*Config, error) {
+	return LoadDir(".")
+}
+
+// LoadDir はdirの.envファイルを読み込む（CONFIG_FILEの相対パスもdirからのパスとする）
+// ファイルの値はプロセスの環境変数に反映せず、読み込んだ設定にのみ使う
+func LoadDir(dir string) (*Config, error) {
+	env := os.Getenv("GO_ENV")
+	if env == "" {
+		env = "dev"
+	}
+	files := []map[string]string{}
+	for _, name := range []string{".env." + env, ".env"} {
+		values, err := godotenv.Read(filepath.Join(dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		files = append(files, values)
+	}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		values, err := readYAML(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, values)
+	}
+
+	// 設定済みの環境変数を優先し、次に先に読み込んだファイルを優先する
+	lookup := func(key string) (string, bool) {
+		if v, ok := os.LookupEnv(key); ok {
+			return v, true
+		}
+		for _, values := range files {
+			if v, ok := values[key]; ok {
+				return v, true
+			}
+		}
+		return "", false
+	}
+	return parse(env, lookup)
+}
+
+// readYAML はキーを環境変数の名前にしたYAMLファイルを読み込む
+//
+//	SECRET: change-me
+//	POSTGRES_PORT: 5432
+func readYAML(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	values := map[string]string{}
+	for key, v := range raw {
+		switch v := v.(type) {
+		case nil:
+			values[key] = ""
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("%s: %s must be a scalar value", path, key)
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
+
+// values は確認済みの設定値（未設定の項目は既定値）
+type values map[string]string
+
+func (v values) int(key string) int64 {
+	n, _ := strconv.ParseInt(v[key], 10, 64)
+	return n
+}
+
+func (v values) bool(key string) bool {
+	b, _ := strconv.ParseBool(v[key])
+	return b
+}
+
+func (v values) duration(key string) time.Duration {
+	d, _ := time.ParseDuration(v[key])
+	return d
+}
+
+// or はkeyが空の場合にfallbackの項目の値を返す
+func (v values) or(key string, fallback string) string {
+	if v[key] != "" {
+		return v[key]
+	}
+	return v[fallback]
+}
+
+// parse は設定値を確認して設定を組み立てる（誤りはすべてまとめて返す）
+func parse(env string, lookup func(key string) (string, bool)) (*Config, error) {
+	problems := []string{}
+	v := values{}
+	for _, s := range settings {
+		raw, ok := lookup(s.Key)
+		if !ok || raw == "" {
+			if s.Required {
+				problems = append(problems, s.Key+" is required")
+			}
+			v[s.Key] = s.Default
+			continue
+		}
+		if err := check(s, raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", s.Key, err))
+			raw = s.Default
+		}
+		v[s.Key] = raw
+	}
+
+	cfg := &Config{
+		Env:               env,
+		Port:              v["PORT"],
+		FrontendURL:       strings.TrimRight(v["FE_URL"], "/"),
+		APIDomain:         v["API_DOMAIN"],
+		Secret:            v["SECRET"],
+		LogFormat:         v["LOG_FORMAT"],
+		ShutdownTimeout:   v.duration("SHUTDOWN_TIMEOUT"),
+		MigrateOnStart:    v.bool("MIGRATE_ON_START"),
+		LoginAttemptStore: v["LOGIN_ATTEMPT_STORE"],
+		DB: db.Config{
+			Host:            v["POSTGRES_HOST"],
+			Port:            v["POSTGRES_PORT"],
+			User:            v["POSTGRES_USER"],
+			Password:        v["POSTGRES_PW"],
+			Name:            v["POSTGRES_DB"],
+			SSLMode:         v["POSTGRES_SSLMODE"],
+			TimeZone:        v["POSTGRES_TIMEZONE"],
+			MaxOpenConns:    int(v.int("POSTGRES_MAX_OPEN_CONNS")),
+			MaxIdleConns:    int(v.int("POSTGRES_MAX_IDLE_CONNS")),
+			ConnMaxLifetime: v.duration("POSTGRES_CONN_MAX_LIFETIME"),
+		},
+		Storage: storage.Config{
+			Backend: v["STORAGE_BACKEND"],
+			Bucket:  v["STORAGE_BUCKET"],
+			Local: storage.LocalConfig{
+				Dir:        v["STORAGE_LOCAL_DIR"],
+				PublicURL:  v["STORAGE_PUBLIC_URL"],
+				SigningKey: v.or("STORAGE_SIGNING_KEY", "SECRET"),
+			},
+			S3: storage.S3Config{
+				Endpoint:        v["S3_ENDPOINT"],
+				Region:          v["S3_REGION"],
+				AccessKeyID:     v["S3_ACCESS_KEY_ID"],
+				SecretAccessKey: v["S3_SECRET_ACCESS_KEY"],
+				UseSSL:          v.bool("S3_USE_SSL"),
+			},
+		},
+		StorageGCInterval: v.duration("STORAGE_GC_INTERVAL"),
+		StorageQuotaBytes: v.int("STORAGE_QUOTA_BYTES"),
+		ImageLimits: imageproc.Limits{
+			MaxBytes:  v.int("IMAGE_MAX_BYTES"),
+			MaxPixels: int(v.int("IMAGE_MAX_PIXELS")),
+		},
+		ImageURL: usecase.ImageURLConfig{
+			BaseURL:       v.or("IMAGE_BASE_URL", "STORAGE_PUBLIC_URL"),
+			SigningKey:    v.or("IMAGE_SIGNING_KEY", "SECRET"),
+			CacheMaxBytes: v.int("IMAGE_CACHE_MAX_BYTES"),
+		},
+		PasswordPolicy: validator.PasswordPolicyConfig{
+			MinLength:          int(v.int("PASSWORD_MIN_LENGTH")),
+			MaxLength:          int(v.int("PASSWORD_MAX_LENGTH")),
+			MinStrength:        int(v.int("PASSWORD_MIN_STRENGTH")),
+			RejectPersonalInfo: v.bool("PASSWORD_REJECT_PERSONAL_INFO"),
+			RejectCommon:       v.bool("PASSWORD_REJECT_COMMON"),
+		},
+		SMTP: mail.SMTPConfig{
+			Host:     v["SMTP_HOST"],
+			Port:     v["SMTP_PORT"],
+			Username: v["SMTP_USERNAME"],
+			Password: v["SMTP_PASSWORD"],
+			From:     v["MAIL_FROM"],
+		},
+		OIDC: oidcProviders(v, lookup),
+	}
+	cfg.Argon2 = auth.DefaultArgon2Params()
+	cfg.Argon2.Memory = uint32(v.int("ARGON2_MEMORY_KIB"))
+	cfg.Argon2.Iterations = uint32(v.int("ARGON2_ITERATIONS"))
+	cfg.Argon2.Parallelism = uint8(v.int("ARGON2_PARALLELISM"))
+
+	problems = append(problems, cfg.validate(v)...)
+	if len(problems) > 0 {
+		return nil, &Error{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// oidcProviders はOIDC_PROVIDERS=google,example のように列挙したプロバイダーの設定を組み立てる
+// 名前ごとにOIDC_<NAME>_ISSUER / OIDC_<NAME>_CLIENT_ID / OIDC_<NAME>_CLIENT_SECRETを読み込み、
+// コールバックURLは OIDC_REDIRECT_BASE_URL + /auth/<name>/callback とする
+func oidcProviders(v values, lookup func(key string) (string, bool)) []auth.OIDCProviderConfig {
+	get := func(key string) string {
+		value, _ := lookup(key)
+		return value
+	}
+	var providers []auth.OIDCProviderConfig
+	base := strings.TrimRight(v["OIDC_REDIRECT_BASE_URL"], "/")
+	for _, name := range strings.Split(v["OIDC_PROVIDERS"], ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers = append(providers, auth.OIDCProviderConfig{
+			Name:         name,
+			IssuerURL:    get(prefix + "ISSUER"),
+			ClientID:     get(prefix + "CLIENT_ID"),
+			ClientSecret: get(prefix + "CLIENT_SECRET"),
+			RedirectURL:  base + "/auth/" + name + "/callback",
+		})
+	}
+	return providers
+}
+
+// check は値が設定項目の形式・範囲・指定できる値に合っているかを確認する
+func check(s Setting, v string) error {
+	switch s.Kind {
+	case KindInt:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", s.Kind, v)
+		}
+		if n < s.Min || (s.Max > 0 && n > s.Max) {
+			if s.Max > 0 {
+				return fmt.Errorf("must be between %d and %d: %d", s.Min, s.Max, n)
+			}
+			return fmt.Errorf("must be at least %d: %d", s.Min, n)
+		}
+	case KindBool:
+		if _, err := strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("invalid %s %q", s.Kind, v)
+		}
+	case KindDuration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", s.Kind, v)
+		}
+		if d < 0 {
+			return fmt.Errorf("must not be negative: %s", v)
+		}
+	}
+	if len(s.Values) > 0 {
+		for _, allowed := range s.Values {
+			if v == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s: %q", strings.Join(s.Values, ", "), v)
+	}
+	return nil
+}
+
+// validate は項目をまたがる条件を確認する
+func (c *Config) validate(v values) []string {
+	problems := []string{}
+	if c.ShutdownTimeout <= 0 {
+		problems = append(problems, "SHUTDOWN_TIMEOUT must be positive")
+	}
+	if c.PasswordPolicy.MaxLength < c.PasswordPolicy.MinLength {
+		problems = append(problems, "PASSWORD_MAX_LENGTH must be at least PASSWORD_MIN_LENGTH")
+	}
+	if c.Argon2.Memory < 8*uint32(c.Argon2.Parallelism) {
+		problems = append(problems, "ARGON2_MEMORY_KIB must be at least 8 times ARGON2_PARALLELISM")
+	}
+	if c.Storage.Backend == "s3" && c.Storage.S3.Endpoint == "" {
+		problems = append(problems, "S3_ENDPOINT is required when STORAGE_BACKEND is s3")
+	}
+	if len(c.OIDC) > 0 && v["OIDC_REDIRECT_BASE_URL"] == "" {
+		problems = append(problems, "OIDC_REDIRECT_BASE_URL is required when OIDC_PROVIDERS is set")
+	}
+	for _, p := range c.OIDC {
+		prefix := "OIDC_" + strings.ToUpper(p.Name) + "_"
+		if p.IssuerURL == "" {
+			problems = append(problems, prefix+"ISSUER is required")
+		}
+		if p.ClientID == "" {
+			problems = append(problems, prefix+"CLIENT_ID is required")
+		}
+	}
+	return problems
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"backend/auth"
+	"backend/imageproc"
+	"backend/usecase"
+	"backend/validator"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// isolateEnv はテストの間だけ設定項目の環境変数を未設定にする（終了時に元に戻す）
+func isolateEnv(t *testing.T, extra ...string) {
+	t.Helper()
+	keys := extra
+	for _, s := range settings {
+		keys = append(keys, s.Key)
+	}
+	for _, key := range keys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func writeFile(t *testing.T, dir string, name string, body string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600))
+}
+
+func TestLoadDir(t *testing.T) {
+	t.Run("環境変数・.env.<GO_ENV>・.env・YAMLの順に優先する", func(t *testing.T) {
+		isolateEnv(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "config.yaml", "SECRET: from-yaml\nPOSTGRES_HOST: yaml-host\nPOSTGRES_USER: yaml-user\nPOSTGRES_DB: yaml-db\nPOSTGRES_PORT: 6432\nFE_URL: https://yaml.example.com\n")
+		writeFile(t, dir, ".env", "POSTGRES_USER=env-user\nPOSTGRES_DB=env-db\n")
+		writeFile(t, dir, ".env.test", "POSTGRES_DB=test-db\n")
+		t.Setenv("GO_ENV", "test")
+		t.Setenv("CONFIG_FILE", "config.yaml")
+		t.Setenv("SECRET", "from-env")
+
+		cfg, err := LoadDir(dir)
+		require.NoError(t, err)
+		assert.Equal(t, "test", cfg.Env)
+		assert.Equal(t, "from-env", cfg.Secret)
+		assert.Equal(t, "yaml-host", cfg.DB.Host)
+		assert.Equal(t, "6432", cfg.DB.Port, "YAMLの数値も文字列として読み込む")
+		assert.Equal(t, "env-user", cfg.DB.User)
+		assert.Equal(t, "test-db", cfg.DB.Name)
+		assert.Equal(t, "https://yaml.example.com", cfg.FrontendURL)
+		_, ok := os.LookupEnv("FE_URL")
+		assert.False(t, ok, "ファイルの値はプロセスの環境変数に反映しない")
+	})
+
+	t.Run("既定値", func(t *testing.T) {
+		isolateEnv(t)
+		t.Setenv("SECRET", "secret")
+		t.Setenv("POSTGRES_HOST", "db")
+		t.Setenv("POSTGRES_USER", "hato")
+		t.Setenv("POSTGRES_DB", "hato")
+
+		cfg, err := LoadDir(t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, "dev", cfg.Env)
+		assert.Equal(t, "8081", cfg.Port)
+		assert.Equal(t, "5432", cfg.DB.Port)
+		assert.Equal(t, "disable", cfg.DB.SSLMode)
+		assert.Equal(t, 10, cfg.DB.MaxOpenConns)
+		assert.Equal(t, time.Hour, cfg.DB.ConnMaxLifetime)
+		assert.Equal(t, "postgres", cfg.LoginAttemptStore)
+		assert.Equal(t, "json", cfg.LogFormat)
+		assert.Equal(t, 8*time.Second, cfg.ShutdownTimeout)
+		assert.False(t, cfg.MigrateOnStart)
+		assert.Equal(t, time.Duration(0), cfg.StorageGCInterval)
+		assert.Equal(t, "gcs", cfg.Storage.Backend)
+		assert.Equal(t, "cookmeet", cfg.Storage.Bucket)
+		assert.True(t, cfg.Storage.S3.UseSSL)
+		assert.Equal(t, "secret", cfg.Storage.Local.SigningKey, "未設定ならSECRET")
+		assert.Equal(t, int64(1<<30), cfg.StorageQuotaBytes)
+		assert.Equal(t, imageproc.DefaultLimits(), cfg.ImageLimits)
+		assert.Equal(t, usecase.ImageURLConfig{BaseURL: "http://localhost:8081", SigningKey: "secret", CacheMaxBytes: 1 << 30}, cfg.ImageURL)
+		assert.Equal(t, validator.DefaultPasswordPolicyConfig(), cfg.PasswordPolicy)
+		assert.Equal(t, auth.DefaultArgon2Params(), cfg.Argon2)
+		assert.Equal(t, "587", cfg.SMTP.Port)
+		assert.Empty(t, cfg.OIDC)
+	})
+
+	t.Run("項目ごとの値", func(t *testing.T) {
+		isolateEnv(t, "OIDC_GOOGLE_ISSUER", "OIDC_GOOGLE_CLIENT_ID", "OIDC_EXAMPLE_ISSUER", "OIDC_EXAMPLE_CLIENT_ID")
+		dir := t.TempDir()
+		writeFile(t, dir, ".env", "OIDC_GOOGLE_ISSUER=https://accounts.google.com\nOIDC_GOOGLE_CLIENT_ID=google-client\n")
+		t.Setenv("SECRET", "secret")
+		t.Setenv("POSTGRES_HOST", "db")
+		t.Setenv("POSTGRES_USER", "hato")
+		t.Setenv("POSTGRES_DB", "hato")
+		t.Setenv("STORAGE_PUBLIC_URL", "https://api.example.com")
+		t.Setenv("STORAGE_QUOTA_BYTES", "0")
+		t.Setenv("ARGON2_PARALLELISM", "2")
+		t.Setenv("PASSWORD_REJECT_COMMON", "false")
+		t.Setenv("OIDC_PROVIDERS", "Google, example")
+		t.Setenv("OIDC_REDIRECT_BASE_URL", "https://api.example.com/")
+		t.Setenv("OIDC_EXAMPLE_ISSUER", "https://id.example.com")
+		t.Setenv("OIDC_EXAMPLE_CLIENT_ID", "example-client")
+
+		cfg, err := LoadDir(dir)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), cfg.StorageQuotaBytes, "0は無制限")
+		assert.Equal(t, "https://api.example.com", cfg.ImageURL.BaseURL, "未設定ならSTORAGE_PUBLIC_URL")
+		assert.Equal(t, uint8(2), cfg.Argon2.Parallelism)
+		assert.False(t, cfg.PasswordPolicy.RejectCommon)
+		require.Len(t, cfg.OIDC, 2)
+		assert.Equal(t, auth.OIDCProviderConfig{
+			Name:        "google",
+			IssuerURL:   "https://accounts.google.com",
+			ClientID:    "google-client",
+			RedirectURL: "https://api.example.com/auth/google/callback",
+		}, cfg.OIDC[0], "プロバイダーごとの項目もファイルから読み込む")
+		assert.Equal(t, "example", cfg.OIDC[1].Name)
+	})
+
+	t.Run("誤りをすべてまとめて返す", func(t *testing.T) {
+		isolateEnv(t, "OIDC_EXAMPLE_ISSUER", "OIDC_EXAMPLE_CLIENT_ID")
+		t.Setenv("POSTGRES_PORT", "five")
+		t.Setenv("MIGRATE_ON_START", "yes please")
+		t.Setenv("STORAGE_GC_INTERVAL", "daily")
+		t.Setenv("LOGIN_ATTEMPT_STORE", "redis")
+		t.Setenv("STORAGE_BACKEND", "s3")
+		t.Setenv("OIDC_PROVIDERS", "example")
+		t.Setenv("STORAGE_QUOTA_BYTES", "abc")
+		t.Setenv("ARGON2_MEMORY_KIB", "0")
+		t.Setenv("PASSWORD_MIN_LENGTH", "20")
+		t.Setenv("PASSWORD_MAX_LENGTH", "10")
+		t.Setenv("SHUTDOWN_TIMEOUT", "-1s")
+
+		_, err := LoadDir(t.TempDir())
+		var cfgErr *Error
+		require.ErrorAs(t, err, &cfgErr)
+		assert.Equal(t, []string{
+			"SECRET is required",
+			"SHUTDOWN_TIMEOUT: must not be negative: -1s",
+			"POSTGRES_HOST is required",
+			`POSTGRES_PORT: invalid int "five"`,
+			"POSTGRES_USER is required",
+			"POSTGRES_DB is required",
+			`MIGRATE_ON_START: invalid bool "yes please"`,
+			`LOGIN_ATTEMPT_STORE: must be one of postgres, memory: "redis"`,
+			`STORAGE_GC_INTERVAL: invalid duration "daily"`,
+			`STORAGE_QUOTA_BYTES: invalid int "abc"`,
+			"ARGON2_MEMORY_KIB: must be between 8 and 4294967295: 0",
+			"PASSWORD_MAX_LENGTH must be at least PASSWORD_MIN_LENGTH",
+			"S3_ENDPOINT is required when STORAGE_BACKEND is s3",
+			"OIDC_REDIRECT_BASE_URL is required when OIDC_PROVIDERS is set",
+			"OIDC_EXAMPLE_ISSUER is required",
+			"OIDC_EXAMPLE_CLIENT_ID is required",
+		}, cfgErr.Problems)
+		assert.Contains(t, err.Error(), "invalid configuration:\n  - SECRET is required\n  - SHUTDOWN_TIMEOUT: must not be negative: -1s")
+	})
+
+	t.Run("YAMLの値は入れ子にできない", func(t *testing.T) {
+		isolateEnv(t)
+		dir := t.TempDir()
+		writeFile(t, dir, "config.yaml", "POSTGRES:\n  HOST: db\n")
+		t.Setenv("CONFIG_FILE", filepath.Join(dir, "config.yaml"))
+
+		_, err := LoadDir(dir)
+		assert.ErrorContains(t, err, "POSTGRES must be a scalar value")
+	})
+
+	t.Run("CONFIG_FILEが存在しない場合はエラー", func(t *testing.T) {
+		isolateEnv(t)
+		t.Setenv("CONFIG_FILE", "missing.yaml")
+		_, err := LoadDir(t.TempDir())
+		assert.ErrorContains(t, err, "failed to read config file")
+	})
+}
+
+func TestSettingsDocumented(t *testing.T) {
+	readme, err := os.ReadFile("../README.md")
+	require.NoError(t, err)
+	for _, s := range settings {
+		assert.Contains(t, string(readme), "`"+s.Key+"`", "READMEの設定の一覧に記載する")
+	}
+}
+
+func TestSettingsDefaults(t *testing.T) {
+	for _, s := range settings {
+		if s.Default == "" {
+			continue
+		}
+		assert.NoError(t, check(s, s.Default), s.Key)
+	}
+}
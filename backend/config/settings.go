@@ -0,0 +1,107 @@
+package config
+
+// 設定項目の一覧
+// 既定値・読み込み時の型と範囲の確認・必須項目の確認はこの一覧で行い、READMEの表もこの一覧に合わせる
+
+import "backend/validator"
+
+// Kind は設定値の形式
+type Kind string
+
+const (
+	KindString   Kind = "string"
+	KindInt      Kind = "int"
+	KindBool     Kind = "bool"
+	KindDuration Kind = "duration" // 24h・30m など
+)
+
+// Setting は一つの設定項目
+type Setting struct {
+	Key         string
+	Kind        Kind
+	Default     string   // 未設定の場合の値（空の場合は説明のとおり他の項目の値を使う）
+	Required    bool     // 未設定の場合は読み込みをエラーにする
+	Values      []string // 指定できる値（空の場合は制限しない）
+	Min         int64    // KindIntの下限（KindDurationは常に0以上）
+	Max         int64    // KindIntの上限（0の場合は制限しない）
+	Description string
+}
+
+const (
+	maxPort   = 65535
+	maxUint32 = 1<<32 - 1
+)
+
+// OIDCの設定はプロバイダーごとにOIDC_<NAME>_ISSUER / OIDC_<NAME>_CLIENT_ID / OIDC_<NAME>_CLIENT_SECRETで指定する
+var settings = []Setting{
+	// アプリケーション
+	{Key: "GO_ENV", Kind: KindString, Default: "dev", Description: "実行環境（.env.<GO_ENV>を読み込む）"},
+	{Key: "CONFIG_FILE", Kind: KindString, Description: "設定を読み込むYAMLファイル"},
+	{Key: "PORT", Kind: KindInt, Default: "8081", Min: 1, Max: maxPort, Description: "待ち受けるポート"},
+	{Key: "FE_URL", Kind: KindString, Description: "フロントエンドのURL（CORS・メールのリンク・OIDCのリダイレクト先）"},
+	{Key: "API_DOMAIN", Kind: KindString, Description: "cookieのドメイン"},
+	{Key: "SECRET", Kind: KindString, Required: true, Description: "jwtの署名に使う鍵"},
+	{Key: "LOG_FORMAT", Kind: KindString, Default: "json", Values: []string{"json", "text"}, Description: "ログの形式（jsonはCloud Loggingで読める形式）"},
+	{Key: "SHUTDOWN_TIMEOUT", Kind: KindDuration, Default: "8s", Description: "停止のシグナルを受けてから処理中のリクエストなどを待つ時間（Cloud Runは10秒後に強制終了する）"},
+
+	// データベース
+	{Key: "POSTGRES_HOST", Kind: KindString, Required: true, Description: "Postgresのホスト（Cloud SQLのUnixソケットは/cloudsql/<インスタンス>）"},
+	{Key: "POSTGRES_PORT", Kind: KindInt, Default: "5432", Min: 1, Max: maxPort, Description: "Postgresのポート"},
+	{Key: "POSTGRES_USER", Kind: KindString, Required: true, Description: "Postgresのユーザー"},
+	{Key: "POSTGRES_PW", Kind: KindString, Description: "Postgresのパスワード"},
+	{Key: "POSTGRES_DB", Kind: KindString, Required: true, Description: "データベース名"},
+	{Key: "POSTGRES_SSLMODE", Kind: KindString, Default: "disable", Description: "sslmode（require・verify-fullなど）"},
+	{Key: "POSTGRES_TIMEZONE", Kind: KindString, Default: "Asia/Tokyo", Description: "接続のタイムゾーン"},
+	{Key: "POSTGRES_MAX_OPEN_CONNS", Kind: KindInt, Default: "10", Description: "同時に開く接続の最大数（0は無制限）"},
+	{Key: "POSTGRES_MAX_IDLE_CONNS", Kind: KindInt, Default: "5", Description: "アイドル状態で保持する接続の最大数"},
+	{Key: "POSTGRES_CONN_MAX_LIFETIME", Kind: KindDuration, Default: "1h", Description: "接続の最大寿命（0は無制限）"},
+	{Key: "MIGRATE_ON_START", Kind: KindBool, Default: "false", Description: "起動時に未適用のマイグレーションを適用する"},
+	{Key: "LOGIN_ATTEMPT_STORE", Kind: KindString, Default: "postgres", Values: []string{"postgres", "memory"}, Description: "ログイン失敗回数の保存先"},
+
+	// 画像などの保存先
+	{Key: "STORAGE_BACKEND", Kind: KindString, Default: "gcs", Values: []string{"gcs", "local", "s3", "memory"}, Description: "保存先"},
+	{Key: "STORAGE_BUCKET", Kind: KindString, Default: "cookmeet", Description: "gcs・s3のバケット"},
+	{Key: "STORAGE_LOCAL_DIR", Kind: KindString, Default: "./storage_data", Description: "localの保存先のディレクトリ"},
+	{Key: "STORAGE_PUBLIC_URL", Kind: KindString, Default: "http://localhost:8081", Description: "localの署名付きURLのAPIのURL"},
+	{Key: "STORAGE_SIGNING_KEY", Kind: KindString, Description: "localの署名付きURLの鍵（未設定ならSECRET）"},
+	{Key: "S3_ENDPOINT", Kind: KindString, Description: "S3互換の保存先のエンドポイント（s3の場合は必須）"},
+	{Key: "S3_REGION", Kind: KindString, Description: "S3のリージョン"},
+	{Key: "S3_ACCESS_KEY_ID", Kind: KindString, Description: "S3のアクセスキー"},
+	{Key: "S3_SECRET_ACCESS_KEY", Kind: KindString, Description: "S3のシークレットキー"},
+	{Key: "S3_USE_SSL", Kind: KindBool, Default: "true", Description: "S3にHTTPSで接続する"},
+	{Key: "STORAGE_GC_INTERVAL", Kind: KindDuration, Default: "0s", Description: "サーバーと同時に使われていないオブジェクトを削除する間隔（0なら実行しない）"},
+	{Key: "STORAGE_QUOTA_BYTES", Kind: KindInt, Default: "1073741824", Description: "ユーザーごとの使用量の上限（1GiB、0は無制限）"},
+
+	// 画像
+	{Key: "IMAGE_MAX_BYTES", Kind: KindInt, Default: "10485760", Min: 1, Description: "アップロードできる画像の大きさの上限（10MiB）"},
+	{Key: "IMAGE_MAX_PIXELS", Kind: KindInt, Default: "50000000", Min: 1, Description: "アップロードできる画像の画素数の上限"},
+	{Key: "IMAGE_BASE_URL", Kind: KindString, Description: "リサイズした画像を配信するAPIのURL（未設定ならSTORAGE_PUBLIC_URL）"},
+	{Key: "IMAGE_SIGNING_KEY", Kind: KindString, Description: "リサイズした画像のURLの署名の鍵（未設定ならSECRET）"},
+	{Key: "IMAGE_CACHE_MAX_BYTES", Kind: KindInt, Default: "1073741824", Min: 1, Description: "リサイズした画像のキャッシュの上限（1GiB）"},
+
+	// パスワード
+	{Key: "ARGON2_MEMORY_KIB", Kind: KindInt, Default: "65536", Min: 8, Max: maxUint32, Description: "Argon2idのメモリ（並列数の8倍以上）"},
+	{Key: "ARGON2_ITERATIONS", Kind: KindInt, Default: "3", Min: 1, Max: maxUint32, Description: "Argon2idの反復回数"},
+	{Key: "ARGON2_PARALLELISM", Kind: KindInt, Default: "4", Min: 1, Max: 255, Description: "Argon2idの並列数"},
+	{Key: "PASSWORD_MIN_LENGTH", Kind: KindInt, Default: "8", Min: 1, Max: validator.MaxPasswordLength, Description: "パスワードの最小の長さ"},
+	{Key: "PASSWORD_MAX_LENGTH", Kind: KindInt, Default: "128", Min: 1, Max: validator.MaxPasswordLength, Description: "パスワードの最大の長さ（PASSWORD_MIN_LENGTH以上）"},
+	{Key: "PASSWORD_MIN_STRENGTH", Kind: KindInt, Default: "2", Max: validator.MaxPasswordStrength, Description: "パスワードの強度の下限（0は確認しない）"},
+	{Key: "PASSWORD_REJECT_PERSONAL_INFO", Kind: KindBool, Default: "true", Description: "名前・メールアドレスを含むパスワードを拒否する"},
+	{Key: "PASSWORD_REJECT_COMMON", Kind: KindBool, Default: "true", Description: "よく使われるパスワードを拒否する"},
+
+	// メール
+	{Key: "SMTP_HOST", Kind: KindString, Description: "SMTPサーバー（未設定ならメールをログに出力する）"},
+	{Key: "SMTP_PORT", Kind: KindInt, Default: "587", Min: 1, Max: maxPort, Description: "SMTPのポート"},
+	{Key: "SMTP_USERNAME", Kind: KindString, Description: "SMTPのユーザー"},
+	{Key: "SMTP_PASSWORD", Kind: KindString, Description: "SMTPのパスワード"},
+	{Key: "MAIL_FROM", Kind: KindString, Description: "送信元のアドレス"},
+
+	// OIDC
+	{Key: "OIDC_PROVIDERS", Kind: KindString, Description: "OIDCプロバイダーの名前（カンマ区切り）"},
+	{Key: "OIDC_REDIRECT_BASE_URL", Kind: KindString, Description: "コールバックURLのAPIのURL（プロバイダーを設定する場合は必須）"},
+}
+
+// Settings は設定項目の一覧を返す
+func Settings() []Setting {
+	return append([]Setting(nil), settings...)
+}
@@ -0,0 +1,127 @@
+package controller
+
+// 管理者向けAPI（ルーターでadminロールを要求する）
+// ListUsers:ユーザーの検索と一覧（ページング）
+// GetUser:ユーザーの詳細と、料理の数・画像の数
+// DisableUser / EnableUser:アカウントの無効化と再有効化
+// ForceLogout:ユーザーのすべてのセッションを失効させる
+// Metrics:DAU・MAUと日ごとの登録数
+
+import (
+	"backend/auth"
+	"backend/usecase"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type IAdminController interface {
+	ListUsers(c echo.Context) error
+	GetUser(c echo.Context) error
+	DisableUser(c echo.Context) error
+	EnableUser(c echo.Context) error
+	ForceLogout(c echo.Context) error
+	Metrics(c echo.Context) error
+}
+
+type adminController struct {
+	au usecase.IAdminUsecase
+}
+
+func NewAdminController(au usecase.IAdminUsecase) IAdminController {
+	return &adminController{au}
+}
+
+// adminError は管理者向けAPIのエラーをステータスコードに変換する
+func adminError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, usecase.ErrUserNotFound):
+		return c.JSON(http.StatusNotFound, err.Error())
+	case errors.Is(err, usecase.ErrCannotModifySelf):
+		return c.JSON(http.StatusBadRequest, err.Error())
+	default:
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (ac *adminController) ListUsers(c echo.Context) error {
+	actorID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	perPage, _ := strconv.Atoi(c.QueryParam("per_page"))
+	list, err := ac.au.ListUsers(actorID, c.QueryParam("q"), page, perPage, clientInfo(c))
+	if err != nil {
+		return adminError(c, err)
+	}
+	return c.JSON(http.StatusOK, list)
+}
+
+func (ac *adminController) GetUser(c echo.Context) error {
+	actorID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	userID, err := strconv.ParseUint(c.Param("userID"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid user ID")
+	}
+	detail, err := ac.au.GetUser(actorID, uint(userID), clientInfo(c))
+	if err != nil {
+		return adminError(c, err)
+	}
+	return c.JSON(http.StatusOK, detail)
+}
+
+func (ac *adminController) DisableUser(c echo.Context) error {
+	return ac.setDisabled(c, true)
+}
+
+func (ac *adminController) EnableUser(c echo.Context) error {
+	return ac.setDisabled(c, false)
+}
+
+func (ac *adminController) setDisabled(c echo.Context, disabled bool) error {
+	actorID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	userID, err := strconv.ParseUint(c.Param("userID"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid user ID")
+	}
+	if err := ac.au.SetUserDisabled(actorID, uint(userID), disabled, clientInfo(c)); err != nil {
+		return adminError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (ac *adminController) ForceLogout(c echo.Context) error {
+	actorID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	userID, err := strconv.ParseUint(c.Param("userID"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid user ID")
+	}
+	if err := ac.au.ForceLogout(actorID, uint(userID), clientInfo(c)); err != nil {
+		return adminError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (ac *adminController) Metrics(c echo.Context) error {
+	actorID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	metrics, err := ac.au.Metrics(actorID, clientInfo(c))
+	if err != nil {
+		return adminError(c, err)
+	}
+	return c.JSON(http.StatusOK, metrics)
+}
@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/model"
+	"backend/usecase"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockAdminUsecase struct {
+	mock.Mock
+}
+
+func (m *mockAdminUsecase) ListUsers(actorID uint, query string, page int, perPage int, client model.ClientInfo) (model.AdminUserList, error) {
+	args := m.Called(actorID, query, page, perPage)
+	return args.Get(0).(model.AdminUserList), args.Error(1)
+}
+
+func (m *mockAdminUsecase) GetUser(actorID uint, userID uint, client model.ClientInfo) (model.AdminUserDetail, error) {
+	args := m.Called(actorID, userID)
+	return args.Get(0).(model.AdminUserDetail), args.Error(1)
+}
+
+func (m *mockAdminUsecase) SetUserDisabled(actorID uint, userID uint, disabled bool, client model.ClientInfo) error {
+	args := m.Called(actorID, userID, disabled)
+	return args.Error(0)
+}
+
+func (m *mockAdminUsecase) ForceLogout(actorID uint, userID uint, client model.ClientInfo) error {
+	args := m.Called(actorID, userID)
+	return args.Error(0)
+}
+
+func (m *mockAdminUsecase) Metrics(actorID uint, client model.ClientInfo) (model.AdminMetrics, error) {
+	args := m.Called(actorID)
+	return args.Get(0).(model.AdminMetrics), args.Error(1)
+}
+
+func TestAdminListUsers(t *testing.T) {
+	e := echo.New()
+	mockUsecase := new(mockAdminUsecase)
+	controller := NewAdminController(mockUsecase)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/users?q=alice&page=2&per_page=10", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	setAuthUser(c, 1)
+
+	mockUsecase.On("ListUsers", uint(1), "alice", 2, 10).Return(model.AdminUserList{Total: 1, Page: 2, PerPage: 10}, nil)
+
+	err := controller.ListUsers(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"total":1`)
+}
+
+func TestAdminGetUser(t *testing.T) {
+	e := echo.New()
+
+	testCases := []struct {
+		name         string
+		param        string
+		mockError    error
+		expectStatus int
+	}{
+		{name: "success", param: "2", expectStatus: http.StatusOK},
+		{name: "存在しないユーザー", param: "2", mockError: usecase.ErrUserNotFound, expectStatus: http.StatusNotFound},
+		{name: "不正なID", param: "abc", expectStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUsecase := new(mockAdminUsecase)
+			controller := NewAdminController(mockUsecase)
+
+			req := httptest.NewRequest(http.MethodGet, "/admin/users/"+tc.param, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("userID")
+			c.SetParamValues(tc.param)
+			setAuthUser(c, 1)
+
+			mockUsecase.On("GetUser", uint(1), uint(2)).Return(model.AdminUserDetail{}, tc.mockError)
+
+			err := controller.GetUser(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectStatus, rec.Code)
+		})
+	}
+}
+
+func TestAdminDisableUser(t *testing.T) {
+	e := echo.New()
+
+	testCases := []struct {
+		name         string
+		mockError    error
+		expectStatus int
+	}{
+		{name: "success", expectStatus: http.StatusNoContent},
+		{name: "自分自身", mockError: usecase.ErrCannotModifySelf, expectStatus: http.StatusBadRequest},
+		{name: "存在しないユーザー", mockError: usecase.ErrUserNotFound, expectStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUsecase := new(mockAdminUsecase)
+			controller := NewAdminController(mockUsecase)
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/users/2/disable", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("userID")
+			c.SetParamValues("2")
+			setAuthUser(c, 1)
+
+			mockUsecase.On("SetUserDisabled", uint(1), uint(2), true).Return(tc.mockError)
+
+			err := controller.DisableUser(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectStatus, rec.Code)
+		})
+	}
+}
+
+func TestAdminForceLogout(t *testing.T) {
+	e := echo.New()
+	mockUsecase := new(mockAdminUsecase)
+	controller := NewAdminController(mockUsecase)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/2/logout", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("userID")
+	c.SetParamValues("2")
+	setAuthUser(c, 1)
+
+	mockUsecase.On("ForceLogout", uint(1), uint(2)).Return(nil)
+
+	err := controller.ForceLogout(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	mockUsecase.AssertExpectations(t)
+}
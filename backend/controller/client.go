@@ -0,0 +1,15 @@
+package controller
+
+import (
+	"backend/model"
+
+	"github.com/labstack/echo/v4"
+)
+
+// clientInfo はリクエスト元のIPとUser-Agentを取り出す
+func clientInfo(c echo.Context) model.ClientInfo {
+	return model.ClientInfo{
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+	}
+}
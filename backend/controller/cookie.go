@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// setTokenCookie はセッションのjwtをcookieに設定する（空文字の場合は削除）
+// domainはAPIのドメイン（API_DOMAIN）
+func setTokenCookie(c echo.Context, token string, domain string) {
+	cookie := new(http.Cookie)
+	cookie.Name = "token"
+	cookie.Value = token
+	if token == "" {
+		cookie.Expires = time.Now()
+	} else {
+		cookie.Expires = time.Now().Add(24 * time.Hour)
+	}
+	cookie.Path = "/"
+	cookie.Domain = domain
+	cookie.Secure = true
+	cookie.HttpOnly = true
+	cookie.SameSite = http.SameSiteNoneMode
+	c.SetCookie(cookie) // httpレスポンスに含める
+}
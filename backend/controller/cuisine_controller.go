@@ -3,21 +3,19 @@ package controller
 // GetAllCuisines: cuisine_usecaseの同メソッドを呼び出している
 // GetCuisineByID:cuisine_usecaseの同メソッドを呼び出している
 // DeleteCuisine:料理を削除している
-// AddCuisine:cuisine_usecaseの同メソッドを呼び出している
+// AddCuisine:cuisine_usecaseの同メソッドを呼び出している（画像はフォームのファイル（icon）か直接アップロードのID（upload_id）で指定する）
+// GetDuplicates:重複している可能性のある料理をまとめて返している（by=image・title_urlで絞り込める）
 // SetCuisine:cuisine_usecaseのgetAllcuisinesメソッドで料理を取得したのち、同メソッドを呼び出している
 // このプログラムが一番外側であり、routerで呼び出される
 
 import (
+	"backend/auth"
 	"backend/model"
 	"backend/usecase"
-	"backend/utils"
 	"errors"
 	"net/http"
-	"path/filepath"
 	"strconv"
 
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
@@ -28,6 +26,7 @@ type ICuisineController interface {
 	// UpdateCuisine(c echo.Context) error
 	DeleteCuisine(c echo.Context) error
 	AddCuisine(c echo.Context) error
+	GetDuplicates(c echo.Context) error
 	// SetCuisine(c echo.Context) error
 }
 
@@ -40,12 +39,12 @@ func NewCuisineController(cu usecase.ICuisineUsecase) ICuisineController {
 }
 
 func (cc *cuisineController) GetAllCuisines(c echo.Context) error {
-	user := c.Get("user").(*jwt.Token)    // コンテキストからjwtをデコードした値を読み込む
-	claims := user.Claims.(jwt.MapClaims) // その中のデコードされたclaimsを取得
-	UserID := claims["user_id"]           // claimsの中のUserIDを取得
-	// log.Print(UserID)
+	userID, err := auth.UserID(c) // 認証ミドルウェアが格納したユーザーIDを取得
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
 
-	cuisineRes, err := cc.cu.GetAllCuisines(uint(UserID.(float64))) // 一度floatにしてからuintに型変換
+	cuisineRes, err := cc.cu.GetAllCuisines(userID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -53,26 +52,43 @@ func (cc *cuisineController) GetAllCuisines(c echo.Context) error {
 }
 
 func (cc *cuisineController) GetCuisineByID(c echo.Context) error {
-	user := c.Get("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	UserID := claims["user_id"]
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
 
 	id := c.Param("cuisineID")
 	cuisineID, err := strconv.ParseUint(id, 10, 32) // Atoiの代わりにParseUintを使用
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, "Invalid cuisine ID")
 	}
-	cuisineRes, err := cc.cu.GetCuisineByID(uint(UserID.(float64)), uint(cuisineID))
+	cuisineRes, err := cc.cu.GetCuisineByID(userID, uint(cuisineID))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
 	return c.JSON(http.StatusOK, cuisineRes)
 }
 
+func (cc *cuisineController) GetDuplicates(c echo.Context) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	groups, err := cc.cu.GetDuplicates(userID, c.QueryParam("by"))
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidDuplicateCriterion) {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, groups)
+}
+
 func (cc *cuisineController) DeleteCuisine(c echo.Context) error {
-	user := c.Get("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	userID := uint(claims["user_id"].(float64)) // userIDに修正
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
 
 	id := c.Param("cuisineID")
 	cuisineID, err := strconv.ParseUint(id, 10, 32)
@@ -97,53 +113,59 @@ func (cc *cuisineController) DeleteCuisine(c echo.Context) error {
 }
 
 func (cc *cuisineController) AddCuisine(c echo.Context) error {
-	user := c.Get("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	UserID := claims["user_id"]
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
 
 	iconFile, err := c.FormFile("icon")
+	uploadID := c.FormValue("upload_id")
 	title := c.FormValue("title")
 	url := c.FormValue("url")
 	comment := c.FormValue("comment") // コメントを取得
 
 	if err != nil {
-		if err != http.ErrMissingFile {
+		if err != http.ErrMissingFile && err != http.ErrNotMultipart { // upload_idを指定する場合はmultipartでなくてもよい
 			return c.JSON(http.StatusBadRequest, err.Error())
 		}
 	}
+	if iconFile != nil && uploadID != "" {
+		return c.JSON(http.StatusBadRequest, "icon and upload_id cannot be specified together")
+	}
 
-	var imageURL string
-	if iconFile != nil {
-		// ファイルを読み込みbase64エンコード
-		src, openErr := iconFile.Open()
-		if openErr != nil {
-			return c.JSON(http.StatusInternalServerError, openErr.Error())
+	var imageKey *string
+	var image model.CuisineImage
+	if iconFile != nil || uploadID != "" {
+		// 画像を確認・変換してサイズごとに保存する（URLは期限切れになるため、キーを保存する）
+		var uploadErr error
+		if uploadID != "" {
+			image, uploadErr = cc.cu.UploadImageFromUpload(userID, uploadID)
+		} else {
+			image, uploadErr = cc.cu.UploadImage(userID, iconFile)
 		}
-		defer src.Close()
-
-		UserIDStr := strconv.FormatUint(uint64(UserID.(float64)), 10)
-
-		// Cloud Storage にアップロード
-		bucket := "cookmeet"
-		objectName := "images/" + UserIDStr + "/" + uuid.New().String() + filepath.Ext(iconFile.Filename)
-
-		imageURL, err = utils.UploadToCloudStorage(bucket, objectName, src)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, err.Error())
+		if uploadErr != nil {
+			if status, ok := uploadErrorStatus(uploadErr); ok {
+				return c.JSON(status, uploadErr.Error())
+			}
+			return c.JSON(http.StatusInternalServerError, uploadErr.Error())
 		}
+		imageKey = &image.Key
 	}
 
 	cuisine := model.Cuisine{}
-	cuisine.UserID = uint(UserID.(float64))
+	cuisine.UserID = userID
 	cuisine.Title = title
 	cuisine.URL = url
 	cuisine.Comment = comment // コメントをセット
-	// 画像がアップロードされた場合のみURLをセット
-	if imageURL != "" {
-		cuisine.IconURL = &imageURL // Cloud StorageのURLをセット
+	cuisine.BlurHash = image.BlurHash
+	cuisine.DominantColor = image.DominantColor
+	cuisine.ImageHash = image.PerceptualHash
+	// 画像がアップロードされた場合のみキーをセット
+	if imageKey != nil {
+		cuisine.IconURL = imageKey
 	}
 
-	cuisineRes, err := cc.cu.AddCuisine(cuisine, &imageURL, url, title)
+	cuisineRes, err := cc.cu.AddCuisine(cuisine, imageKey, url, title)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -179,7 +201,7 @@ func (cc *cuisineController) AddCuisine(c echo.Context) error {
 // 		return c.JSON(http.StatusBadRequest, bindErr.Error())
 // 	}
 
-// 	cuisineRes, err := cc.cu.GetCuisineByID(uint(UserID.(float64)), uint(cuisineID))
+// 	cuisineRes, err := cc.cu.GetCuisineByID(userID, uint(cuisineID))
 // 	if err != nil {
 // 		return c.JSON(http.StatusInternalServerError, err.Error())
 // 	}
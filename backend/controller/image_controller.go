@@ -0,0 +1,89 @@
+package controller
+
+// リサイズした画像の配信（GET /img/<キー>?w=&h=&fit=&fmt=&e=&s=）
+// URLは料理のレスポンス（images.presets）で返す署名済みのもののみ受け付けるため、ログインは不要
+// キーはアップロードごとに異なり内容が変わらないため、URLの期限（e）までキャッシュさせる
+
+import (
+	"backend/imageproc"
+	"backend/usecase"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type IImageController interface {
+	GetImage(c echo.Context) error
+}
+
+type imageController struct {
+	iu usecase.IImageResizeUsecase
+}
+
+func NewImageController(iu usecase.IImageResizeUsecase) IImageController {
+	return &imageController{iu}
+}
+
+func (ic *imageController) GetImage(c echo.Context) error {
+	key, err := url.PathUnescape(c.Param("*"))
+	if err != nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	t := imageproc.Transform{Fit: c.QueryParam("fit"), Format: c.QueryParam("fmt")}
+	if t.Width, err = queryInt(c, "w"); err != nil {
+		return c.JSON(http.StatusBadRequest, "invalid w")
+	}
+	if t.Height, err = queryInt(c, "h"); err != nil {
+		return c.JSON(http.StatusBadRequest, "invalid h")
+	}
+	expires, err := strconv.ParseInt(c.QueryParam("e"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "invalid e")
+	}
+
+	img, err := ic.iu.Render(key, t, expires, c.QueryParam("s"), c.Request().Header.Get("If-None-Match"))
+	h := c.Response().Header()
+	switch {
+	case errors.Is(err, usecase.ErrImageNotModified):
+		h.Set(echo.HeaderCacheControl, imageCacheControl(img))
+		h.Set("ETag", img.ETag)
+		return c.NoContent(http.StatusNotModified)
+	case errors.Is(err, usecase.ErrInvalidImageSignature), errors.Is(err, usecase.ErrImageURLExpired):
+		return c.JSON(http.StatusForbidden, err.Error())
+	case errors.Is(err, usecase.ErrImagePresetNotAllowed):
+		return c.JSON(http.StatusBadRequest, err.Error())
+	case errors.Is(err, usecase.ErrImageNotFound):
+		return c.NoContent(http.StatusNotFound)
+	case errors.Is(err, usecase.ErrInvalidImage), errors.Is(err, usecase.ErrImageTooLarge):
+		return c.JSON(http.StatusUnprocessableEntity, err.Error())
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	h.Set(echo.HeaderCacheControl, imageCacheControl(img))
+	h.Set("ETag", img.ETag)
+	h.Set("X-Content-Type-Options", "nosniff")
+	return c.Blob(http.StatusOK, img.ContentType, img.Data)
+}
+
+// imageCacheControl はURLの期限までキャッシュさせるCache-Controlを返す
+func imageCacheControl(img usecase.DerivedImage) string {
+	return fmt.Sprintf("public, max-age=%d", int64(img.MaxAge.Seconds()))
+}
+
+// queryInt は整数のクエリパラメーターを返す（未指定は0）
+func queryInt(c echo.Context, name string) (int, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid " + name)
+	}
+	return n, nil
+}
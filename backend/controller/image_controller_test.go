@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/imageproc"
+	"backend/usecase"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockImageResizeUsecase struct {
+	mock.Mock
+}
+
+func (m *mockImageResizeUsecase) PresetURLs(key string) map[string]string {
+	args := m.Called(key)
+	return args.Get(0).(map[string]string)
+}
+
+func (m *mockImageResizeUsecase) Render(key string, t imageproc.Transform, expires int64, signature string, ifNoneMatch string) (usecase.DerivedImage, error) {
+	args := m.Called(key, t, expires, signature, ifNoneMatch)
+	return args.Get(0).(usecase.DerivedImage), args.Error(1)
+}
+
+func TestGetImage(t *testing.T) {
+	get := func(m *mockImageResizeUsecase, target string, ifNoneMatch string) *httptest.ResponseRecorder {
+		e := echo.New()
+		e.GET("/img/*", NewImageController(m).GetImage)
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	og := imageproc.Transform{Width: 1200, Height: 630, Fit: imageproc.FitCover}
+
+	t.Run("リサイズした画像をURLの期限までキャッシュできるヘッダーとともに返す", func(t *testing.T) {
+		m := new(mockImageResizeUsecase)
+		m.On("Render", "images/1/abc/full.jpg", og, int64(1700000000), "sig", "").
+			Return(usecase.DerivedImage{ContentType: "image/jpeg", ETag: `"abc"`, Data: []byte("jpeg"), MaxAge: 90 * time.Minute}, nil)
+		rec := get(m, "/img/images/1/abc/full.jpg?w=1200&h=630&fit=cover&e=1700000000&s=sig", "")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "jpeg", rec.Body.String())
+		assert.Equal(t, "image/jpeg", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, `"abc"`, rec.Header().Get("ETag"))
+		assert.Equal(t, "public, max-age=5400", rec.Header().Get(echo.HeaderCacheControl))
+	})
+
+	t.Run("ETagが一致すれば304", func(t *testing.T) {
+		m := new(mockImageResizeUsecase)
+		m.On("Render", "images/1/abc/full.jpg", og, int64(1700000000), "sig", `"abc"`).
+			Return(usecase.DerivedImage{ETag: `"abc"`, MaxAge: time.Minute}, usecase.ErrImageNotModified)
+		rec := get(m, "/img/images/1/abc/full.jpg?w=1200&h=630&fit=cover&e=1700000000&s=sig", `"abc"`)
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Equal(t, `"abc"`, rec.Header().Get("ETag"))
+		assert.Equal(t, "public, max-age=60", rec.Header().Get(echo.HeaderCacheControl))
+	})
+
+	testCases := []struct {
+		name         string
+		err          error
+		expectStatus int
+	}{
+		{"署名が一致しない", usecase.ErrInvalidImageSignature, http.StatusForbidden},
+		{"期限切れ", usecase.ErrImageURLExpired, http.StatusForbidden},
+		{"許可していない大きさ", usecase.ErrImagePresetNotAllowed, http.StatusBadRequest},
+		{"元の画像がない", usecase.ErrImageNotFound, http.StatusNotFound},
+		{"画像として読めない", usecase.ErrInvalidImage, http.StatusUnprocessableEntity},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockImageResizeUsecase)
+			m.On("Render", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(usecase.DerivedImage{}, tc.err)
+			rec := get(m, "/img/images/1/abc/full.jpg?w=1200&h=630&fit=cover&e=1700000000&s=sig", "")
+			assert.Equal(t, tc.expectStatus, rec.Code)
+		})
+	}
+
+	t.Run("大きさ・期限が数値でなければ400", func(t *testing.T) {
+		rec := get(new(mockImageResizeUsecase), "/img/images/1/abc/full.jpg?w=large&e=1700000000&s=sig", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		rec = get(new(mockImageResizeUsecase), "/img/images/1/abc/full.jpg?w=1200&s=sig", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, "期限のないURL")
+	})
+}
@@ -0,0 +1,174 @@
+package controller
+
+// OIDCプロバイダーによるログインと、外部アカウントの連携・解除
+// Login:stateをcookieに保存してプロバイダーの認可画面へリダイレクトする
+// Callback:プロバイダーから戻ったユーザーをログインさせ、フロントエンドへリダイレクトする
+// Connect:ログイン中のユーザーへの連携を開始し、認可画面のURLを返す
+// ListIdentities / Disconnect:連携済みのプロバイダーの一覧と解除
+
+import (
+	"backend/auth"
+	"backend/usecase"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const oidcStateCookie = "oidc_state"
+
+type IOIDCController interface {
+	Providers(c echo.Context) error
+	Login(c echo.Context) error
+	Callback(c echo.Context) error
+	Connect(c echo.Context) error
+	ListIdentities(c echo.Context) error
+	Disconnect(c echo.Context) error
+}
+
+type oidcController struct {
+	ou           usecase.IOIDCUsecase
+	feURL        string // 認可後にリダイレクトするフロントエンドのURL
+	cookieDomain string
+}
+
+func NewOIDCController(ou usecase.IOIDCUsecase, feURL string, cookieDomain string) IOIDCController {
+	return &oidcController{ou, feURL, cookieDomain}
+}
+
+// setOIDCStateCookie はコールバックまでの間stateを保持するcookieを設定する（空文字の場合は削除）
+func setOIDCStateCookie(c echo.Context, stateToken string, domain string) {
+	cookie := new(http.Cookie)
+	cookie.Name = oidcStateCookie
+	cookie.Value = stateToken
+	if stateToken == "" {
+		cookie.MaxAge = -1
+	} else {
+		cookie.Expires = time.Now().Add(10 * time.Minute)
+	}
+	cookie.Path = "/auth"
+	cookie.Domain = domain
+	cookie.Secure = true
+	cookie.HttpOnly = true
+	cookie.SameSite = http.SameSiteNoneMode
+	c.SetCookie(cookie)
+}
+
+// oidcErrorCode はフロントエンドへ渡すエラーコードを返す
+func oidcErrorCode(err error) string {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidOIDCState):
+		return "invalid_state"
+	case errors.Is(err, usecase.ErrOIDCAuthorizationError):
+		return "authorization_failed"
+	case errors.Is(err, usecase.ErrOIDCEmailNotVerified):
+		return "email_not_verified"
+	case errors.Is(err, usecase.ErrOIDCAccountExists):
+		return "account_exists"
+	case errors.Is(err, usecase.ErrIdentityLinkedToOther):
+		return "identity_in_use"
+	case errors.Is(err, usecase.ErrProviderAlreadyLinked):
+		return "provider_already_linked"
+	case errors.Is(err, usecase.ErrUnknownOIDCProvider):
+		return "unknown_provider"
+	case errors.Is(err, usecase.ErrAccountDisabled):
+		return "account_disabled"
+	default:
+		return "server_error"
+	}
+}
+
+func (oc *oidcController) Providers(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string][]string{"providers": oc.ou.Providers()})
+}
+
+func (oc *oidcController) Login(c echo.Context) error {
+	authURL, stateToken, err := oc.ou.Begin(c.Request().Context(), c.Param("provider"), 0)
+	if err != nil {
+		if errors.Is(err, usecase.ErrUnknownOIDCProvider) {
+			return c.JSON(http.StatusNotFound, err.Error())
+		}
+		return c.JSON(http.StatusBadGateway, err.Error())
+	}
+	setOIDCStateCookie(c, stateToken, oc.cookieDomain)
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+func (oc *oidcController) Callback(c echo.Context) error {
+	feURL := oc.feURL
+	stateToken := ""
+	if cookie, err := c.Cookie(oidcStateCookie); err == nil {
+		stateToken = cookie.Value
+	}
+	setOIDCStateCookie(c, "", oc.cookieDomain) // stateは1回限り
+
+	// ユーザーが認可を拒否した場合など
+	if c.QueryParam("error") != "" {
+		return c.Redirect(http.StatusFound, feURL+"/login?error=access_denied")
+	}
+
+	result, err := oc.ou.Callback(c.Request().Context(), c.Param("provider"), c.QueryParam("code"), c.QueryParam("state"), stateToken, clientInfo(c))
+	if result.Link {
+		if err != nil {
+			return c.Redirect(http.StatusFound, feURL+"/settings?error="+oidcErrorCode(err))
+		}
+		return c.Redirect(http.StatusFound, feURL+"/settings?linked="+url.QueryEscape(c.Param("provider")))
+	}
+	if err != nil {
+		return c.Redirect(http.StatusFound, feURL+"/login?error="+oidcErrorCode(err))
+	}
+	if result.MFARequired {
+		// MFAトークンはサーバーのログに残らないようフラグメントで渡す
+		return c.Redirect(http.StatusFound, feURL+"/login/2fa#mfa_token="+url.QueryEscape(result.MFAToken))
+	}
+	setTokenCookie(c, result.Token, oc.cookieDomain)
+	return c.Redirect(http.StatusFound, feURL+"/")
+}
+
+func (oc *oidcController) Connect(c echo.Context) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	authURL, stateToken, err := oc.ou.Begin(c.Request().Context(), c.Param("provider"), userID)
+	if err != nil {
+		if errors.Is(err, usecase.ErrUnknownOIDCProvider) {
+			return c.JSON(http.StatusNotFound, err.Error())
+		}
+		return c.JSON(http.StatusBadGateway, err.Error())
+	}
+	setOIDCStateCookie(c, stateToken, oc.cookieDomain)
+	return c.JSON(http.StatusOK, map[string]string{"auth_url": authURL})
+}
+
+func (oc *oidcController) ListIdentities(c echo.Context) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	identities, err := oc.ou.ListIdentities(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, identities)
+}
+
+func (oc *oidcController) Disconnect(c echo.Context) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	if err := oc.ou.Disconnect(userID, c.Param("provider")); err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrIdentityNotFound):
+			return c.JSON(http.StatusNotFound, err.Error())
+		case errors.Is(err, usecase.ErrLastLoginMethod):
+			return c.JSON(http.StatusConflict, err.Error())
+		default:
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"backend/model"
+	"backend/usecase"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockOIDCUsecase struct {
+	mock.Mock
+}
+
+func (m *mockOIDCUsecase) Providers() []string {
+	args := m.Called()
+	return args.Get(0).([]string)
+}
+
+func (m *mockOIDCUsecase) Begin(ctx context.Context, provider string, linkUserID uint) (string, string, error) {
+	args := m.Called(provider, linkUserID)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *mockOIDCUsecase) Callback(ctx context.Context, provider string, code string, state string, stateToken string, client model.ClientInfo) (model.OIDCCallbackResult, error) {
+	args := m.Called(provider, code, state, stateToken)
+	return args.Get(0).(model.OIDCCallbackResult), args.Error(1)
+}
+
+func (m *mockOIDCUsecase) ListIdentities(userID uint) ([]model.UserIdentityResponse, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.UserIdentityResponse), args.Error(1)
+}
+
+func (m *mockOIDCUsecase) Disconnect(userID uint, provider string) error {
+	args := m.Called(userID, provider)
+	return args.Error(0)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	e := echo.New()
+	mockUsecase := new(mockOIDCUsecase)
+	controller := NewOIDCController(mockUsecase, "https://fe.example.com", "localhost")
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/fake/login", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("provider")
+	c.SetParamValues("fake")
+
+	mockUsecase.On("Begin", "fake", uint(0)).Return("https://issuer.example.com/authorize?state=s", "state-token", nil)
+
+	err := controller.Login(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://issuer.example.com/authorize?state=s", rec.Header().Get("Location"))
+	assert.Contains(t, rec.Header().Get("Set-Cookie"), "oidc_state=state-token")
+}
+
+func TestOIDCCallback(t *testing.T) {
+	e := echo.New()
+
+	testCases := []struct {
+		name           string
+		result         model.OIDCCallbackResult
+		mockError      error
+		expectLocation string
+		expectToken    bool
+	}{
+		{
+			name:           "ログイン成功",
+			result:         model.OIDCCallbackResult{LoginResult: model.LoginResult{Token: "session"}},
+			expectLocation: "https://fe.example.com/",
+			expectToken:    true,
+		},
+		{
+			name:           "二要素認証が必要",
+			result:         model.OIDCCallbackResult{LoginResult: model.LoginResult{MFARequired: true, MFAToken: "mfa"}},
+			expectLocation: "https://fe.example.com/login/2fa#mfa_token=mfa",
+		},
+		{
+			name:           "既存のメールアドレス",
+			mockError:      usecase.ErrOIDCAccountExists,
+			expectLocation: "https://fe.example.com/login?error=account_exists",
+		},
+		{
+			name:           "連携成功",
+			result:         model.OIDCCallbackResult{Link: true},
+			expectLocation: "https://fe.example.com/settings?linked=fake",
+		},
+		{
+			name:           "別のユーザーに連携済み",
+			result:         model.OIDCCallbackResult{Link: true},
+			mockError:      usecase.ErrIdentityLinkedToOther,
+			expectLocation: "https://fe.example.com/settings?error=identity_in_use",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUsecase := new(mockOIDCUsecase)
+			controller := NewOIDCController(mockUsecase, "https://fe.example.com", "localhost")
+
+			req := httptest.NewRequest(http.MethodGet, "/auth/fake/callback?code=c&state=s", nil)
+			req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: "state-token"})
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("provider")
+			c.SetParamValues("fake")
+
+			mockUsecase.On("Callback", "fake", "c", "s", "state-token").Return(tc.result, tc.mockError)
+
+			err := controller.Callback(c)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusFound, rec.Code)
+			assert.Equal(t, tc.expectLocation, rec.Header().Get("Location"))
+			assert.Equal(t, tc.expectToken, strings.Contains(strings.Join(rec.Header().Values("Set-Cookie"), ";"), "token=session"))
+		})
+	}
+
+	t.Run("認可の拒否", func(t *testing.T) {
+		mockUsecase := new(mockOIDCUsecase)
+		controller := NewOIDCController(mockUsecase, "https://fe.example.com", "localhost")
+
+		req := httptest.NewRequest(http.MethodGet, "/auth/fake/callback?error=access_denied&state=s", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("provider")
+		c.SetParamValues("fake")
+
+		err := controller.Callback(c)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://fe.example.com/login?error=access_denied", rec.Header().Get("Location"))
+		mockUsecase.AssertNotCalled(t, "Callback", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestOIDCDisconnect(t *testing.T) {
+	e := echo.New()
+
+	testCases := []struct {
+		name         string
+		mockError    error
+		expectStatus int
+	}{
+		{name: "解除", mockError: nil, expectStatus: http.StatusNoContent},
+		{name: "未連携", mockError: usecase.ErrIdentityNotFound, expectStatus: http.StatusNotFound},
+		{name: "最後のログイン手段", mockError: usecase.ErrLastLoginMethod, expectStatus: http.StatusConflict},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUsecase := new(mockOIDCUsecase)
+			controller := NewOIDCController(mockUsecase, "https://fe.example.com", "localhost")
+
+			req := httptest.NewRequest(http.MethodDelete, "/me/identities/fake", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("provider")
+			c.SetParamValues("fake")
+			setAuthUser(c, 1)
+
+			mockUsecase.On("Disconnect", uint(1), "fake").Return(tc.mockError)
+
+			err := controller.Disconnect(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectStatus, rec.Code)
+		})
+	}
+}
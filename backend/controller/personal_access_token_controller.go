@@ -0,0 +1,80 @@
+package controller
+
+// パーソナルアクセストークンの作成・一覧・失効
+// トークンの管理はログインセッション（cookie）でのみ行える（ルーターでBearerトークンを受け付けない）
+
+import (
+	"backend/auth"
+	"backend/model"
+	"backend/usecase"
+	"errors"
+	"net/http"
+	"strconv"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type IPersonalAccessTokenController interface {
+	CreateToken(c echo.Context) error
+	ListTokens(c echo.Context) error
+	RevokeToken(c echo.Context) error
+}
+
+type personalAccessTokenController struct {
+	pu usecase.IPersonalAccessTokenUsecase
+}
+
+func NewPersonalAccessTokenController(pu usecase.IPersonalAccessTokenUsecase) IPersonalAccessTokenController {
+	return &personalAccessTokenController{pu}
+}
+
+func (pc *personalAccessTokenController) CreateToken(c echo.Context) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	req := model.PersonalAccessTokenRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	created, err := pc.pu.Create(userID, req, clientInfo(c))
+	if err != nil {
+		var verrs validation.Errors
+		if errors.As(err, &verrs) {
+			return validationError(c, verrs)
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, created)
+}
+
+func (pc *personalAccessTokenController) ListTokens(c echo.Context) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	tokens, err := pc.pu.List(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func (pc *personalAccessTokenController) RevokeToken(c echo.Context) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	tokenID, err := strconv.ParseUint(c.Param("tokenID"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid token ID")
+	}
+	if err := pc.pu.Revoke(userID, uint(tokenID), clientInfo(c)); err != nil {
+		if errors.Is(err, usecase.ErrTokenNotFound) {
+			return c.JSON(http.StatusNotFound, err.Error())
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/auth"
+	"backend/model"
+	"backend/usecase"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockPersonalAccessTokenUsecase struct {
+	mock.Mock
+}
+
+func (m *mockPersonalAccessTokenUsecase) Create(userID uint, req model.PersonalAccessTokenRequest, client model.ClientInfo) (model.PersonalAccessTokenCreated, error) {
+	args := m.Called(userID, req)
+	return args.Get(0).(model.PersonalAccessTokenCreated), args.Error(1)
+}
+
+func (m *mockPersonalAccessTokenUsecase) List(userID uint) ([]model.PersonalAccessTokenResponse, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.PersonalAccessTokenResponse), args.Error(1)
+}
+
+func (m *mockPersonalAccessTokenUsecase) Revoke(userID uint, tokenID uint, client model.ClientInfo) error {
+	args := m.Called(userID, tokenID)
+	return args.Error(0)
+}
+
+func (m *mockPersonalAccessTokenUsecase) VerifyToken(token string) (*auth.Claims, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*auth.Claims), args.Error(1)
+}
+
+func TestCreateToken(t *testing.T) {
+	e := echo.New()
+	req := model.PersonalAccessTokenRequest{Name: "shortcut", Scopes: []string{auth.ScopeWriteCuisines}}
+
+	testCases := []struct {
+		name         string
+		mockError    error
+		expectStatus int
+	}{
+		{name: "作成", mockError: nil, expectStatus: http.StatusCreated},
+		{name: "バリデーションエラー", mockError: validation.Errors{"scopes": validation.NewError("", "unknown scope")}, expectStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUsecase := new(mockPersonalAccessTokenUsecase)
+			controller := NewPersonalAccessTokenController(mockUsecase)
+
+			httpReq := httptest.NewRequest(http.MethodPost, "/me/tokens", bytes.NewBufferString(`{"name":"shortcut","scopes":["write:cuisines"]}`))
+			httpReq.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httpReq, rec)
+			setAuthUser(c, 1)
+
+			mockUsecase.On("Create", uint(1), req).Return(model.PersonalAccessTokenCreated{Token: "cmpat_x"}, tc.mockError)
+
+			err := controller.CreateToken(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectStatus, rec.Code)
+			if tc.mockError == nil {
+				assert.Contains(t, rec.Body.String(), `"token":"cmpat_x"`)
+			}
+		})
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	e := echo.New()
+
+	testCases := []struct {
+		name         string
+		tokenID      string
+		mockError    error
+		expectStatus int
+	}{
+		{name: "失効", tokenID: "3", expectStatus: http.StatusNoContent},
+		{name: "存在しない", tokenID: "3", mockError: usecase.ErrTokenNotFound, expectStatus: http.StatusNotFound},
+		{name: "不正なID", tokenID: "abc", expectStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUsecase := new(mockPersonalAccessTokenUsecase)
+			controller := NewPersonalAccessTokenController(mockUsecase)
+
+			req := httptest.NewRequest(http.MethodDelete, "/me/tokens/"+tc.tokenID, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("tokenID")
+			c.SetParamValues(tc.tokenID)
+			setAuthUser(c, 1)
+
+			mockUsecase.On("Revoke", uint(1), uint(3)).Return(tc.mockError)
+
+			err := controller.RevokeToken(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectStatus, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package controller
+
+// ログイン中のユーザー本人のセキュリティイベント（ログイン履歴・パスワード変更など）の一覧
+
+import (
+	"backend/auth"
+	"backend/usecase"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ISecurityEventController interface {
+	ListSecurityEvents(c echo.Context) error
+}
+
+type securityEventController struct {
+	su usecase.ISecurityEventUsecase
+}
+
+func NewSecurityEventController(su usecase.ISecurityEventUsecase) ISecurityEventController {
+	return &securityEventController{su}
+}
+
+func (sc *securityEventController) ListSecurityEvents(c echo.Context) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	events, err := sc.su.List(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/model"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockSecurityEventUsecase struct {
+	mock.Mock
+}
+
+func (m *mockSecurityEventUsecase) List(userID uint) ([]model.SecurityEventResponse, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.SecurityEventResponse), args.Error(1)
+}
+
+func TestListSecurityEvents(t *testing.T) {
+	e := echo.New()
+
+	testCases := []struct {
+		name         string
+		userID       float64
+		mockResponse []model.SecurityEventResponse
+		mockError    error
+		expectStatus int
+	}{
+		{
+			name:         "success",
+			userID:       1,
+			mockResponse: []model.SecurityEventResponse{{ID: 1, Action: "auth.login", Metadata: []byte(`{"method":"password"}`)}},
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "error",
+			userID:       1,
+			mockResponse: []model.SecurityEventResponse{},
+			mockError:    errors.New("db error"),
+			expectStatus: http.StatusInternalServerError,
+		},
+		{
+			name:         "未認証",
+			expectStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUsecase := new(mockSecurityEventUsecase)
+			controller := NewSecurityEventController(mockUsecase)
+
+			req := httptest.NewRequest(http.MethodGet, "/me/security-events", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			if tc.userID != 0 {
+				setAuthUser(c, tc.userID)
+				mockUsecase.On("List", uint(tc.userID)).Return(tc.mockResponse, tc.mockError)
+			}
+
+			err := controller.ListSecurityEvents(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectStatus, rec.Code)
+			if tc.expectStatus == http.StatusOK {
+				assert.Contains(t, rec.Body.String(), `"metadata":{"method":"password"}`)
+			}
+		})
+	}
+}
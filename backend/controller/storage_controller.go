@@ -0,0 +1,88 @@
+package controller
+
+// ローカルの保存先（STORAGE_BACKEND=local）のオブジェクトを署名付きURLで配信・受信する
+// 署名を確認するため、ログインは不要
+// アップロード（PUT）の大きさ・種類はアップロードの完了時（upload_controller）に確認するため、ここでは上限のみ確認する
+// Cloud StorageやS3では署名付きURLが保存先を直接指すため、404を返す
+
+import (
+	"backend/storage"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxLocalPutSize はローカルの保存先へ直接アップロードできる大きさの上限
+const maxLocalPutSize = 64 << 20
+
+type IStorageController interface {
+	GetObject(c echo.Context) error
+	PutObject(c echo.Context) error
+}
+
+type storageController struct {
+	st storage.ObjectStore
+}
+
+func NewStorageController(st storage.ObjectStore) IStorageController {
+	return &storageController{st}
+}
+
+func (sc *storageController) GetObject(c echo.Context) error {
+	verifier, ok := sc.st.(storage.SignedURLVerifier)
+	if !ok {
+		return c.NoContent(http.StatusNotFound)
+	}
+	key, err := url.PathUnescape(c.Param("*"))
+	if err != nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	if err := verifier.VerifySignedURL(key, c.QueryParam("expires"), c.QueryParam("signature")); err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	r, info, err := sc.st.Get(c.Request().Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			return c.NoContent(http.StatusNotFound)
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	defer r.Close()
+
+	h := c.Response().Header()
+	h.Set("Cache-Control", "private, max-age=86400")
+	h.Set("X-Content-Type-Options", "nosniff")
+	return c.Stream(http.StatusOK, info.ContentType, r)
+}
+
+func (sc *storageController) PutObject(c echo.Context) error {
+	verifier, ok := sc.st.(storage.SignedURLVerifier)
+	if !ok {
+		return c.NoContent(http.StatusNotFound)
+	}
+	key, err := url.PathUnescape(c.Param("*"))
+	if err != nil {
+		return c.NoContent(http.StatusNotFound)
+	}
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+	if err := verifier.VerifySignedPutURL(key, contentType, c.QueryParam("expires"), c.QueryParam("signature")); err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	body := http.MaxBytesReader(c.Response(), c.Request().Body, maxLocalPutSize)
+	if err := sc.st.Put(c.Request().Context(), key, contentType, body); err != nil {
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxErr):
+			return c.JSON(http.StatusRequestEntityTooLarge, "object is too large")
+		case errors.Is(err, storage.ErrInvalidKey):
+			return c.NoContent(http.StatusNotFound)
+		default:
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+	}
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/storage"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetObject(t *testing.T) {
+	st, err := storage.NewLocalStore(storage.LocalConfig{Dir: t.TempDir(), PublicURL: "http://api.example.com", SigningKey: "secret"})
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, st.Put(ctx, "images/1/a.png", "image/png", strings.NewReader("png")))
+
+	e := echo.New()
+	e.GET(storage.LocalRoutePrefix+"*", NewStorageController(st).GetObject)
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+	signed := func(key string, ttl time.Duration) string {
+		s, err := st.SignURL(ctx, key, ttl)
+		require.NoError(t, err)
+		u, err := url.Parse(s)
+		require.NoError(t, err)
+		return u.RequestURI()
+	}
+
+	t.Run("署名付きURLで取得できる", func(t *testing.T) {
+		rec := get(signed("images/1/a.png", time.Minute))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "png", rec.Body.String())
+		assert.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+	})
+
+	t.Run("署名がない・期限切れの場合は403", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, get(storage.LocalRoutePrefix+"images/1/a.png").Code)
+		assert.Equal(t, http.StatusForbidden, get(signed("images/1/a.png", -time.Minute)).Code)
+	})
+
+	t.Run("存在しない場合は404", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get(signed("images/1/b.png", time.Minute)).Code)
+	})
+
+	t.Run("アプリが配信しない保存先では404", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/storage/images/1/a.png", nil), rec)
+		c.SetParamNames("*")
+		c.SetParamValues("images/1/a.png")
+		assert.NoError(t, NewStorageController(storage.NewMemoryStore()).GetObject(c))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestPutObject(t *testing.T) {
+	st, err := storage.NewLocalStore(storage.LocalConfig{Dir: t.TempDir(), PublicURL: "http://api.example.com", SigningKey: "secret"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	e := echo.New()
+	e.PUT(storage.LocalRoutePrefix+"*", NewStorageController(st).PutObject)
+	put := func(target string, contentType string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	signed := func(key string, contentType string) string {
+		s, err := st.SignPutURL(ctx, key, contentType, time.Minute)
+		require.NoError(t, err)
+		u, err := url.Parse(s)
+		require.NoError(t, err)
+		return u.RequestURI()
+	}
+
+	t.Run("署名付きURLでアップロードできる", func(t *testing.T) {
+		rec := put(signed("uploads/1/a.png", "image/png"), "image/png", "png")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		info, err := st.Stat(ctx, "uploads/1/a.png")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), info.Size)
+	})
+
+	t.Run("Content-Typeが異なる場合は403", func(t *testing.T) {
+		rec := put(signed("uploads/1/b.png", "image/png"), "text/html", "<html>")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		_, err := st.Stat(ctx, "uploads/1/b.png")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("参照用の署名付きURLではアップロードできない", func(t *testing.T) {
+		s, err := st.SignURL(ctx, "uploads/1/a.png", time.Minute)
+		require.NoError(t, err)
+		u, _ := url.Parse(s)
+		assert.Equal(t, http.StatusForbidden, put(u.RequestURI(), "image/png", "overwrite").Code)
+	})
+}
@@ -0,0 +1,35 @@
+package controller
+
+// ログイン中のユーザー本人の保存先の使用量（料理画像・アイコン）と上限
+
+import (
+	"backend/auth"
+	"backend/usecase"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type IStorageUsageController interface {
+	GetUsage(c echo.Context) error
+}
+
+type storageUsageController struct {
+	su usecase.IStorageUsageUsecase
+}
+
+func NewStorageUsageController(su usecase.IStorageUsageUsecase) IStorageUsageController {
+	return &storageUsageController{su}
+}
+
+func (sc *storageUsageController) GetUsage(c echo.Context) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	usage, err := sc.su.GetUsage(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, usage)
+}
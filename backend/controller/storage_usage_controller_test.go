@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/model"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockStorageUsageUsecase struct {
+	mock.Mock
+}
+
+func (m *mockStorageUsageUsecase) GetUsage(userID uint) (model.StorageUsageResponse, error) {
+	args := m.Called(userID)
+	return args.Get(0).(model.StorageUsageResponse), args.Error(1)
+}
+
+func (m *mockStorageUsageUsecase) Reserve(userID uint, kind string, bytes int64) error {
+	return m.Called(userID, kind, bytes).Error(0)
+}
+
+func (m *mockStorageUsageUsecase) Release(userID uint, kind string, bytes int64) {
+	m.Called(userID, kind, bytes)
+}
+
+func TestGetStorageUsage(t *testing.T) {
+	e := echo.New()
+	remaining := int64(900)
+
+	testCases := []struct {
+		name         string
+		userID       float64
+		mockResponse model.StorageUsageResponse
+		mockError    error
+		expectStatus int
+	}{
+		{
+			name:         "success",
+			userID:       1,
+			mockResponse: model.StorageUsageResponse{UsedBytes: 100, CuisineImageBytes: 80, UserIconBytes: 20, QuotaBytes: 1000, RemainingBytes: &remaining},
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "error",
+			userID:       1,
+			mockError:    errors.New("db error"),
+			expectStatus: http.StatusInternalServerError,
+		},
+		{
+			name:         "未認証",
+			expectStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUsecase := new(mockStorageUsageUsecase)
+			controller := NewStorageUsageController(mockUsecase)
+
+			req := httptest.NewRequest(http.MethodGet, "/me/usage", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			if tc.userID != 0 {
+				setAuthUser(c, tc.userID)
+				mockUsecase.On("GetUsage", uint(tc.userID)).Return(tc.mockResponse, tc.mockError)
+			}
+
+			err := controller.GetUsage(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectStatus, rec.Code)
+			if tc.expectStatus == http.StatusOK {
+				assert.JSONEq(t, `{"used_bytes":100,"cuisine_image_bytes":80,"user_icon_bytes":20,"quota_bytes":1000,"remaining_bytes":900}`, rec.Body.String())
+			}
+		})
+	}
+}
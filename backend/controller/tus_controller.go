@@ -0,0 +1,191 @@
+package controller
+
+// tusプロトコル（1.0.0）による再開可能なアップロード
+// POST /files: アップロードを作成する（Upload-Length、Upload-Metadataのpurpose・filename）
+// HEAD /files/:uploadID: 受け取ったバイト数（Upload-Offset）を返す
+// PATCH /files/:uploadID: Upload-Offsetの位置から続きを受け取る（Upload-Checksumを指定すると確認する）
+// DELETE /files/:uploadID: アップロードを中止する
+// 完了したIDは直接アップロード（upload_controller.go）と同じく、upload_idとして料理の追加・アイコンの変更で使える
+
+import (
+	"backend/auth"
+	"backend/model"
+	"backend/usecase"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	TusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,checksum,termination"
+
+	// StatusChecksumMismatch はチェックサムが一致しない場合のステータスコード（tusの仕様で定義）
+	StatusChecksumMismatch = 460
+	tusContentType         = "application/offset+octet-stream"
+)
+
+// CORSで許可するリクエストのヘッダー・公開するレスポンスのヘッダー
+var (
+	TusRequestHeaders  = []string{"Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata", "Upload-Checksum"}
+	TusResponseHeaders = []string{"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size", "Tus-Checksum-Algorithm", "Upload-Offset", "Upload-Length", "Upload-Expires"}
+)
+
+type ITusController interface {
+	Options(c echo.Context) error
+	Create(c echo.Context) error
+	Head(c echo.Context) error
+	Patch(c echo.Context) error
+	Terminate(c echo.Context) error
+}
+
+type tusController struct {
+	tu usecase.ITusUsecase
+}
+
+func NewTusController(tu usecase.ITusUsecase) ITusController {
+	return &tusController{tu}
+}
+
+// Options は対応するバージョン・拡張を返す（Tus-Resumableは不要）
+func (tc *tusController) Options(c echo.Context) error {
+	h := c.Response().Header()
+	h.Set("Tus-Resumable", TusVersion)
+	h.Set("Tus-Version", TusVersion)
+	h.Set("Tus-Extension", tusExtensions)
+	h.Set("Tus-Max-Size", strconv.FormatInt(tc.tu.MaxSize(), 10))
+	h.Set("Tus-Checksum-Algorithm", strings.Join(usecase.TusChecksumAlgorithms(), ","))
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (tc *tusController) Create(c echo.Context) error {
+	userID, ok, err := tc.begin(c)
+	if !ok {
+		return err
+	}
+	length, err := strconv.ParseInt(c.Request().Header.Get("Upload-Length"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "invalid Upload-Length")
+	}
+	metadata, err := parseUploadMetadata(c.Request().Header.Get("Upload-Metadata"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	upload, err := tc.tu.Create(userID, model.TusCreateRequest{Purpose: metadata["purpose"], Filename: metadata["filename"], Length: length})
+	if err != nil {
+		return tusError(c, err)
+	}
+	h := c.Response().Header()
+	h.Set(echo.HeaderLocation, strings.TrimSuffix(c.Request().URL.Path, "/")+"/"+upload.ID)
+	h.Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	h.Set("Upload-Offset", "0")
+	return c.NoContent(http.StatusCreated)
+}
+
+func (tc *tusController) Head(c echo.Context) error {
+	userID, ok, err := tc.begin(c)
+	if !ok {
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	upload, err := tc.tu.Get(userID, c.Param("uploadID"))
+	if err != nil {
+		return tusError(c, err)
+	}
+	setUploadHeaders(c, upload)
+	return c.NoContent(http.StatusOK)
+}
+
+func (tc *tusController) Patch(c echo.Context) error {
+	userID, ok, err := tc.begin(c)
+	if !ok {
+		return err
+	}
+	if c.Request().Header.Get(echo.HeaderContentType) != tusContentType {
+		return c.JSON(http.StatusUnsupportedMediaType, "Content-Type must be "+tusContentType)
+	}
+	offset, err := strconv.ParseInt(c.Request().Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return c.JSON(http.StatusBadRequest, "invalid Upload-Offset")
+	}
+	upload, err := tc.tu.WriteChunk(userID, c.Param("uploadID"), offset, c.Request().Header.Get("Upload-Checksum"), c.Request().Body)
+	if err != nil {
+		return tusError(c, err)
+	}
+	setUploadHeaders(c, upload)
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (tc *tusController) Terminate(c echo.Context) error {
+	userID, ok, err := tc.begin(c)
+	if !ok {
+		return err
+	}
+	if err := tc.tu.Terminate(userID, c.Param("uploadID")); err != nil {
+		return tusError(c, err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// begin はTus-Resumableを確認し、ログイン中のユーザーのIDを返す（okがfalseの場合はレスポンスを返し終えている）
+func (tc *tusController) begin(c echo.Context) (uint, bool, error) {
+	c.Response().Header().Set("Tus-Resumable", TusVersion)
+	if c.Request().Header.Get("Tus-Resumable") != TusVersion {
+		c.Response().Header().Set("Tus-Version", TusVersion)
+		return 0, false, c.JSON(http.StatusPreconditionFailed, "unsupported Tus-Resumable")
+	}
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return 0, false, c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	return userID, true, nil
+}
+
+func setUploadHeaders(c echo.Context, upload model.TusUpload) {
+	h := c.Response().Header()
+	h.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	h.Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if upload.CompletedAt == nil {
+		h.Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// parseUploadMetadata はUpload-Metadata（「キー base64の値」をカンマで区切ったもの）を読む
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.New("invalid Upload-Metadata")
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// tusError はtusアップロード・画像のエラーをステータスコードに変換して返す
+func tusError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, usecase.ErrTusOffsetMismatch):
+		return c.JSON(http.StatusConflict, err.Error())
+	case errors.Is(err, usecase.ErrTusChecksumMismatch):
+		return c.JSON(StatusChecksumMismatch, err.Error())
+	case errors.Is(err, usecase.ErrTusUnsupportedChecksum):
+		return c.JSON(http.StatusBadRequest, err.Error())
+	case errors.Is(err, usecase.ErrTusExceedsLength):
+		return c.JSON(http.StatusRequestEntityTooLarge, err.Error())
+	}
+	if status, ok := uploadErrorStatus(err); ok {
+		return c.JSON(status, err.Error())
+	}
+	return c.JSON(http.StatusInternalServerError, err.Error())
+}
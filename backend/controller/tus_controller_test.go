@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/model"
+	"backend/usecase"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockTusUsecase struct {
+	mock.Mock
+}
+
+func (m *mockTusUsecase) MaxSize() int64 {
+	return 10 << 20
+}
+
+func (m *mockTusUsecase) Create(userID uint, req model.TusCreateRequest) (model.TusUpload, error) {
+	args := m.Called(userID, req)
+	return args.Get(0).(model.TusUpload), args.Error(1)
+}
+
+func (m *mockTusUsecase) Get(userID uint, uploadID string) (model.TusUpload, error) {
+	args := m.Called(userID, uploadID)
+	return args.Get(0).(model.TusUpload), args.Error(1)
+}
+
+func (m *mockTusUsecase) WriteChunk(userID uint, uploadID string, offset int64, checksum string, r io.Reader) (model.TusUpload, error) {
+	data, _ := io.ReadAll(r)
+	args := m.Called(userID, uploadID, offset, checksum, string(data))
+	return args.Get(0).(model.TusUpload), args.Error(1)
+}
+
+func (m *mockTusUsecase) Terminate(userID uint, uploadID string) error {
+	args := m.Called(userID, uploadID)
+	return args.Error(0)
+}
+
+func newTusContext(method string, target string, body string, headers map[string]string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Tus-Resumable", TusVersion)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("uploadID")
+	c.SetParamValues("tus-1")
+	setAuthUser(c, 1)
+	return c, rec
+}
+
+func TestTusOptions(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodOptions, "/files", nil), rec)
+	assert.NoError(t, NewTusController(new(mockTusUsecase)).Options(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, TusVersion, rec.Header().Get("Tus-Version"))
+	assert.Equal(t, "creation,expiration,checksum,termination", rec.Header().Get("Tus-Extension"))
+	assert.Equal(t, "10485760", rec.Header().Get("Tus-Max-Size"))
+	assert.Equal(t, "md5,sha1,sha256", rec.Header().Get("Tus-Checksum-Algorithm"))
+}
+
+func TestTusCreate(t *testing.T) {
+	t.Run("作成してLocationを返す", func(t *testing.T) {
+		m := new(mockTusUsecase)
+		expires := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		m.On("Create", uint(1), model.TusCreateRequest{Purpose: "cuisine_image", Filename: "a.jpg", Length: 1024}).
+			Return(model.TusUpload{ID: "tus-1", Length: 1024, ExpiresAt: expires}, nil)
+		c, rec := newTusContext(http.MethodPost, "/files", "", map[string]string{
+			"Upload-Length":   "1024",
+			"Upload-Metadata": "purpose Y3Vpc2luZV9pbWFnZQ==,filename YS5qcGc=",
+		})
+
+		assert.NoError(t, NewTusController(m).Create(c))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "/files/tus-1", rec.Header().Get(echo.HeaderLocation))
+		assert.Equal(t, "Fri, 02 Jan 2026 03:04:05 GMT", rec.Header().Get("Upload-Expires"))
+		assert.Equal(t, TusVersion, rec.Header().Get("Tus-Resumable"))
+	})
+
+	testCases := []struct {
+		name         string
+		headers      map[string]string
+		expectStatus int
+	}{
+		{"Tus-Resumableが異なる", map[string]string{"Tus-Resumable": "0.2.2", "Upload-Length": "1"}, http.StatusPreconditionFailed},
+		{"Upload-Lengthがない", map[string]string{}, http.StatusBadRequest},
+		{"Upload-Metadataが不正", map[string]string{"Upload-Length": "1", "Upload-Metadata": "purpose !!!"}, http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, rec := newTusContext(http.MethodPost, "/files", "", tc.headers)
+			assert.NoError(t, NewTusController(new(mockTusUsecase)).Create(c))
+			assert.Equal(t, tc.expectStatus, rec.Code)
+		})
+	}
+
+	t.Run("大きすぎる場合は413", func(t *testing.T) {
+		m := new(mockTusUsecase)
+		m.On("Create", uint(1), mock.Anything).Return(model.TusUpload{}, usecase.ErrImageTooLarge)
+		c, rec := newTusContext(http.MethodPost, "/files", "", map[string]string{"Upload-Length": "99999999999"})
+		assert.NoError(t, NewTusController(m).Create(c))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}
+
+func TestTusHead(t *testing.T) {
+	m := new(mockTusUsecase)
+	m.On("Get", uint(1), "tus-1").Return(model.TusUpload{ID: "tus-1", Offset: 512, Length: 1024, ExpiresAt: time.Now().Add(time.Hour)}, nil)
+	c, rec := newTusContext(http.MethodHead, "/files/tus-1", "", nil)
+
+	assert.NoError(t, NewTusController(m).Head(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "512", rec.Header().Get("Upload-Offset"))
+	assert.Equal(t, "1024", rec.Header().Get("Upload-Length"))
+	assert.Equal(t, "no-store", rec.Header().Get(echo.HeaderCacheControl))
+}
+
+func TestTusPatch(t *testing.T) {
+	headers := map[string]string{echo.HeaderContentType: "application/offset+octet-stream", "Upload-Offset": "512"}
+
+	t.Run("受け取ってオフセットを返す", func(t *testing.T) {
+		m := new(mockTusUsecase)
+		m.On("WriteChunk", uint(1), "tus-1", int64(512), "", "chunk").Return(model.TusUpload{ID: "tus-1", Offset: 517, Length: 1024}, nil)
+		c, rec := newTusContext(http.MethodPatch, "/files/tus-1", "chunk", headers)
+
+		assert.NoError(t, NewTusController(m).Patch(c))
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "517", rec.Header().Get("Upload-Offset"))
+	})
+
+	t.Run("Content-Typeが異なる場合は415", func(t *testing.T) {
+		c, rec := newTusContext(http.MethodPatch, "/files/tus-1", "chunk", map[string]string{"Upload-Offset": "0"})
+		assert.NoError(t, NewTusController(new(mockTusUsecase)).Patch(c))
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	})
+
+	testCases := []struct {
+		name         string
+		err          error
+		expectStatus int
+	}{
+		{"オフセットが一致しない", usecase.ErrTusOffsetMismatch, http.StatusConflict},
+		{"チェックサムが一致しない", usecase.ErrTusChecksumMismatch, StatusChecksumMismatch},
+		{"対応していないチェックサム", usecase.ErrTusUnsupportedChecksum, http.StatusBadRequest},
+		{"期限切れ", usecase.ErrUploadExpired, http.StatusGone},
+		{"存在しない", usecase.ErrUploadNotFound, http.StatusNotFound},
+		{"画像以外", usecase.ErrInvalidImage, http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockTusUsecase)
+			m.On("WriteChunk", uint(1), "tus-1", int64(512), mock.Anything, mock.Anything).Return(model.TusUpload{}, tc.err)
+			c, rec := newTusContext(http.MethodPatch, "/files/tus-1", "chunk", headers)
+			assert.NoError(t, NewTusController(m).Patch(c))
+			assert.Equal(t, tc.expectStatus, rec.Code)
+		})
+	}
+}
+
+func TestTusTerminate(t *testing.T) {
+	m := new(mockTusUsecase)
+	m.On("Terminate", uint(1), "tus-1").Return(nil)
+	c, rec := newTusContext(http.MethodDelete, "/files/tus-1", "", nil)
+
+	assert.NoError(t, NewTusController(m).Terminate(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	m.AssertExpectations(t)
+}
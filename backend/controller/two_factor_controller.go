@@ -0,0 +1,100 @@
+package controller
+
+// 二要素認証の登録・有効化と、ログイン時のコード検証
+// Enroll:認証アプリ登録用のURIとQRコードを返す
+// Confirm:最初のコードで二要素認証を有効化し、リカバリーコードを返す
+// VerifyLogin:ログイン時に返したMFAトークンとコードを検証し、セッションのcookieを設定する
+
+import (
+	"backend/auth"
+	"backend/usecase"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ITwoFactorController interface {
+	Enroll(c echo.Context) error
+	Confirm(c echo.Context) error
+	VerifyLogin(c echo.Context) error
+}
+
+type twoFactorController struct {
+	tu           usecase.ITwoFactorUsecase
+	cookieDomain string
+}
+
+func NewTwoFactorController(tu usecase.ITwoFactorUsecase, cookieDomain string) ITwoFactorController {
+	return &twoFactorController{tu, cookieDomain}
+}
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code" form:"code"`
+}
+
+type twoFactorLoginRequest struct {
+	MFAToken string `json:"mfa_token" form:"mfa_token"`
+	Code     string `json:"code" form:"code"`
+}
+
+func (tc *twoFactorController) Enroll(c echo.Context) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	enrollment, err := tc.tu.Enroll(userID)
+	if err != nil {
+		if errors.Is(err, usecase.ErrTwoFactorAlreadyEnabled) {
+			return c.JSON(http.StatusConflict, err.Error())
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, enrollment)
+}
+
+func (tc *twoFactorController) Confirm(c echo.Context) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	req := twoFactorCodeRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	confirmation, err := tc.tu.Confirm(userID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrTwoFactorAlreadyEnabled):
+			return c.JSON(http.StatusConflict, err.Error())
+		case errors.Is(err, usecase.ErrTwoFactorNotEnrolled), errors.Is(err, usecase.ErrInvalidTwoFactorCode):
+			return c.JSON(http.StatusBadRequest, err.Error())
+		default:
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+	}
+	return c.JSON(http.StatusOK, confirmation)
+}
+
+func (tc *twoFactorController) VerifyLogin(c echo.Context) error {
+	req := twoFactorLoginRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	token, err := tc.tu.VerifyLogin(req.MFAToken, req.Code, clientInfo(c))
+	if err != nil {
+		var lockoutErr *usecase.LockoutError
+		switch {
+		case errors.As(err, &lockoutErr):
+			return tooManyAttempts(c, lockoutErr)
+		case errors.Is(err, usecase.ErrInvalidMFAToken), errors.Is(err, usecase.ErrInvalidTwoFactorCode):
+			return c.JSON(http.StatusUnauthorized, err.Error())
+		case errors.Is(err, usecase.ErrAccountDisabled):
+			return c.JSON(http.StatusForbidden, err.Error())
+		default:
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+	}
+	setTokenCookie(c, token, tc.cookieDomain)
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/model"
+	"backend/usecase"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockTwoFactorUsecase struct {
+	mock.Mock
+}
+
+func (m *mockTwoFactorUsecase) Enroll(userID uint) (model.TwoFactorEnrollment, error) {
+	args := m.Called(userID)
+	return args.Get(0).(model.TwoFactorEnrollment), args.Error(1)
+}
+
+func (m *mockTwoFactorUsecase) Confirm(userID uint, code string) (model.TwoFactorConfirmation, error) {
+	args := m.Called(userID, code)
+	return args.Get(0).(model.TwoFactorConfirmation), args.Error(1)
+}
+
+func (m *mockTwoFactorUsecase) VerifyLogin(mfaToken string, code string, client model.ClientInfo) (string, error) {
+	args := m.Called(mfaToken, code, client)
+	return args.String(0), args.Error(1)
+}
+
+func TestTwoFactorEnroll(t *testing.T) {
+	e := echo.New()
+
+	testCases := []struct {
+		name         string
+		mockError    error
+		expectStatus int
+	}{
+		{name: "登録開始", mockError: nil, expectStatus: http.StatusOK},
+		{name: "既に有効", mockError: usecase.ErrTwoFactorAlreadyEnabled, expectStatus: http.StatusConflict},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUsecase := new(mockTwoFactorUsecase)
+			controller := NewTwoFactorController(mockUsecase, "localhost")
+
+			req := httptest.NewRequest(http.MethodPost, "/me/2fa/enroll", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			setAuthUser(c, 1)
+
+			mockUsecase.On("Enroll", uint(1)).Return(model.TwoFactorEnrollment{
+				Secret: "SECRET",
+				URI:    "otpauth://totp/CookMeet:test@example.com?secret=SECRET",
+				QRCode: []byte("png"),
+			}, tc.mockError)
+
+			err := controller.Enroll(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectStatus, rec.Code)
+
+			if tc.expectStatus == http.StatusOK {
+				var response map[string]string
+				if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+					t.Fatalf("failed to unmarshal response: %v", err)
+				}
+				assert.Equal(t, "SECRET", response["secret"])
+				assert.Equal(t, "cG5n", response["qr_code_png"]) // base64("png")
+			}
+			mockUsecase.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTwoFactorConfirm(t *testing.T) {
+	e := echo.New()
+
+	testCases := []struct {
+		name         string
+		mockError    error
+		expectStatus int
+	}{
+		{name: "有効化", mockError: nil, expectStatus: http.StatusOK},
+		{name: "コードが違う", mockError: usecase.ErrInvalidTwoFactorCode, expectStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUsecase := new(mockTwoFactorUsecase)
+			controller := NewTwoFactorController(mockUsecase, "localhost")
+
+			req := httptest.NewRequest(http.MethodPost, "/me/2fa/confirm", bytes.NewBufferString(`{"code":"123456"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			setAuthUser(c, 1)
+
+			mockUsecase.On("Confirm", uint(1), "123456").Return(model.TwoFactorConfirmation{
+				RecoveryCodes: []string{"abcde-fghij"},
+			}, tc.mockError)
+
+			err := controller.Confirm(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectStatus, rec.Code)
+			mockUsecase.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTwoFactorVerifyLogin(t *testing.T) {
+	e := echo.New()
+
+	testCases := []struct {
+		name         string
+		mockToken    string
+		mockError    error
+		expectStatus int
+	}{
+		{name: "コードが正しい", mockToken: "valid.jwt.token", expectStatus: http.StatusOK},
+		{name: "コードが違う", mockError: usecase.ErrInvalidTwoFactorCode, expectStatus: http.StatusUnauthorized},
+		{name: "MFAトークンが無効", mockError: usecase.ErrInvalidMFAToken, expectStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUsecase := new(mockTwoFactorUsecase)
+			controller := NewTwoFactorController(mockUsecase, "localhost")
+
+			body := `{"mfa_token":"mfa.jwt.token","code":"123456"}`
+			req := httptest.NewRequest(http.MethodPost, "/login/2fa", bytes.NewBufferString(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			mockUsecase.On("VerifyLogin", "mfa.jwt.token", "123456", mock.AnythingOfType("model.ClientInfo")).
+				Return(tc.mockToken, tc.mockError)
+
+			err := controller.VerifyLogin(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectStatus, rec.Code)
+
+			if tc.expectStatus == http.StatusOK {
+				cookies := rec.Result().Cookies()
+				assert.Equal(t, 1, len(cookies))
+				assert.Equal(t, "token", cookies[0].Name)
+				assert.Equal(t, tc.mockToken, cookies[0].Value)
+			}
+			mockUsecase.AssertExpectations(t)
+		})
+	}
+}
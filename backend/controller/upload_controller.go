@@ -0,0 +1,82 @@
+package controller
+
+// 保存先への直接アップロード
+// CreateUpload: アップロード用の署名付きURLとIDを返す（クライアントはこのURLへPUTする）
+// CompleteUpload: アップロードしたファイルの大きさと種類を確認する
+// 完了したIDは料理の追加（POST /cuisines）・アイコンの変更（PUT /update）でupload_idとして指定する
+
+import (
+	"backend/auth"
+	"backend/model"
+	"backend/usecase"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type IUploadController interface {
+	CreateUpload(c echo.Context) error
+	CompleteUpload(c echo.Context) error
+}
+
+type uploadController struct {
+	uu usecase.IUploadUsecase
+}
+
+func NewUploadController(uu usecase.IUploadUsecase) IUploadController {
+	return &uploadController{uu}
+}
+
+func (uc *uploadController) CreateUpload(c echo.Context) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	req := model.UploadRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	res, err := uc.uu.CreateUpload(userID, req)
+	if err != nil {
+		if status, ok := uploadErrorStatus(err); ok {
+			return c.JSON(status, err.Error())
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, res)
+}
+
+func (uc *uploadController) CompleteUpload(c echo.Context) error {
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	res, err := uc.uu.CompleteUpload(userID, c.Param("uploadID"))
+	if err != nil {
+		if status, ok := uploadErrorStatus(err); ok {
+			return c.JSON(status, err.Error())
+		}
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// uploadErrorStatus は直接アップロード・画像のエラーのステータスコードを返す
+func uploadErrorStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, usecase.ErrUploadNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, usecase.ErrUploadExpired):
+		return http.StatusGone, true
+	case errors.Is(err, usecase.ErrUploadNotUploaded), errors.Is(err, usecase.ErrUploadNotCompleted), errors.Is(err, usecase.ErrUploadAlreadyUsed):
+		return http.StatusConflict, true
+	case errors.Is(err, usecase.ErrUploadMismatch), errors.Is(err, usecase.ErrInvalidUploadTarget), errors.Is(err, usecase.ErrInvalidImage):
+		return http.StatusBadRequest, true
+	case errors.Is(err, usecase.ErrImageTooLarge):
+		return http.StatusRequestEntityTooLarge, true
+	case errors.Is(err, usecase.ErrStorageQuotaExceeded): // 1つのファイルの大きさの上限（413）と区別する
+		return http.StatusForbidden, true
+	}
+	return 0, false
+}
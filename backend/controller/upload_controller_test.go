@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/model"
+	"backend/usecase"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockUploadUsecase struct {
+	mock.Mock
+}
+
+func (m *mockUploadUsecase) CreateUpload(userID uint, req model.UploadRequest) (model.UploadResponse, error) {
+	args := m.Called(userID, req)
+	return args.Get(0).(model.UploadResponse), args.Error(1)
+}
+
+func (m *mockUploadUsecase) CompleteUpload(userID uint, uploadID string) (model.UploadResponse, error) {
+	args := m.Called(userID, uploadID)
+	return args.Get(0).(model.UploadResponse), args.Error(1)
+}
+
+func TestCreateUpload(t *testing.T) {
+	newContext := func(body string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodPost, "/uploads", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		setAuthUser(c, 1)
+		return c, rec
+	}
+
+	t.Run("署名付きURLを返す", func(t *testing.T) {
+		m := new(mockUploadUsecase)
+		m.On("CreateUpload", uint(1), model.UploadRequest{Purpose: "cuisine_image", ContentType: "image/jpeg", Size: 1024}).
+			Return(model.UploadResponse{ID: "upload-1", Status: model.UploadStatusPending, UploadURL: "https://storage.example.com/uploads/1/upload-1.jpg", Method: "PUT", ExpiresAt: time.Now()}, nil)
+		c, rec := newContext(`{"purpose":"cuisine_image","content_type":"image/jpeg","size":1024}`)
+
+		assert.NoError(t, NewUploadController(m).CreateUpload(c))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		res := model.UploadResponse{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "upload-1", res.ID)
+		assert.Equal(t, "PUT", res.Method)
+	})
+
+	testCases := []struct {
+		name         string
+		err          error
+		expectStatus int
+	}{
+		{"用途が不正", usecase.ErrInvalidUploadTarget, http.StatusBadRequest},
+		{"画像以外", usecase.ErrInvalidImage, http.StatusBadRequest},
+		{"大きすぎる", usecase.ErrImageTooLarge, http.StatusRequestEntityTooLarge},
+		{"使用量の上限", fmt.Errorf("%w: 100 of 100 bytes used", usecase.ErrStorageQuotaExceeded), http.StatusForbidden},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockUploadUsecase)
+			m.On("CreateUpload", uint(1), mock.Anything).Return(model.UploadResponse{}, tc.err)
+			c, rec := newContext(`{"purpose":"cuisine_image","content_type":"image/jpeg","size":1024}`)
+
+			assert.NoError(t, NewUploadController(m).CreateUpload(c))
+			assert.Equal(t, tc.expectStatus, rec.Code)
+		})
+	}
+}
+
+func TestCompleteUpload(t *testing.T) {
+	newContext := func() (echo.Context, *httptest.ResponseRecorder) {
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/uploads/upload-1/complete", nil), rec)
+		c.SetParamNames("uploadID")
+		c.SetParamValues("upload-1")
+		setAuthUser(c, 1)
+		return c, rec
+	}
+
+	t.Run("完了する", func(t *testing.T) {
+		m := new(mockUploadUsecase)
+		m.On("CompleteUpload", uint(1), "upload-1").Return(model.UploadResponse{ID: "upload-1", Status: model.UploadStatusCompleted}, nil)
+		c, rec := newContext()
+
+		assert.NoError(t, NewUploadController(m).CompleteUpload(c))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"status":"completed"`)
+	})
+
+	testCases := []struct {
+		name         string
+		err          error
+		expectStatus int
+	}{
+		{"存在しない", usecase.ErrUploadNotFound, http.StatusNotFound},
+		{"期限切れ", usecase.ErrUploadExpired, http.StatusGone},
+		{"アップロードされていない", usecase.ErrUploadNotUploaded, http.StatusConflict},
+		{"大きさ・種類が異なる", usecase.ErrUploadMismatch, http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockUploadUsecase)
+			m.On("CompleteUpload", uint(1), "upload-1").Return(model.UploadResponse{}, tc.err)
+			c, rec := newContext()
+
+			assert.NoError(t, NewUploadController(m).CompleteUpload(c))
+			assert.Equal(t, tc.expectStatus, rec.Code)
+		})
+	}
+}
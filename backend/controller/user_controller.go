@@ -1,14 +1,15 @@
 package controller
 
 import (
+	"backend/auth"
 	"backend/model"
 	"backend/usecase"
 	"errors"
+	"math"
 	"net/http"
-	"os"
-	"time"
+	"strconv"
 
-	"github.com/golang-jwt/jwt/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/labstack/echo/v4"
 )
 
@@ -17,15 +18,19 @@ type IUserController interface {
 	Login(c echo.Context) error
 	Logout(c echo.Context) error
 	Update(c echo.Context) error
+	ChangePassword(c echo.Context) error
+	ConfirmEmailChange(c echo.Context) error
+	UndoEmailChange(c echo.Context) error
 	CsrfToken(c echo.Context) error
 }
 
 type UserController struct {
-	uu usecase.IUserUsecase
+	uu           usecase.IUserUsecase
+	cookieDomain string
 }
 
-func NewUserController(uu usecase.IUserUsecase) IUserController {
-	return &UserController{uu}
+func NewUserController(uu usecase.IUserUsecase, cookieDomain string) IUserController {
+	return &UserController{uu, cookieDomain}
 }
 
 func (uc *UserController) SignUp(c echo.Context) error {
@@ -40,6 +45,11 @@ func (uc *UserController) SignUp(c echo.Context) error {
 		if errors.Is(err, usecase.ErrUserAlreadyExists) {
 			return c.JSON(http.StatusConflict, err.Error())
 		}
+		// パスワードがポリシーを満たさない場合などは、項目ごとのエラーコードを返す
+		var verrs validation.Errors
+		if errors.As(err, &verrs) {
+			return validationError(c, verrs)
+		}
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
 	return c.JSON(http.StatusCreated, userRes) // Createdのステータス、新規作成したユーザーを返す
@@ -50,43 +60,45 @@ func (uc *UserController) Login(c echo.Context) error {
 	if err := c.Bind(&user); err != nil {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
-	tokenString, err := uc.uu.Login(user)
+	result, err := uc.uu.Login(user, clientInfo(c))
 	if err != nil {
+		var lockoutErr *usecase.LockoutError
 		switch {
-		case errors.Is(err, usecase.ErrUserNotFound):
-			return c.JSON(http.StatusNotFound, "ユーザーが見つかりません")
-		case errors.Is(err, usecase.ErrInvalidPassword):
-			return c.JSON(http.StatusUnauthorized, "パスワードが間違っています")
+		case errors.As(err, &lockoutErr):
+			return tooManyAttempts(c, lockoutErr)
+		case errors.Is(err, usecase.ErrInvalidCredentials):
+			// アカウントの有無が分からないよう、ユーザー不在とパスワード不一致は同じ応答にする
+			return c.JSON(http.StatusUnauthorized, "メールアドレスまたはパスワードが間違っています")
 		case errors.Is(err, usecase.ErrInvalidPasswordLength):
 			return c.JSON(http.StatusBadRequest, "パスワードは6文字以上である必要があります")
+		case errors.Is(err, usecase.ErrAccountDisabled):
+			return c.JSON(http.StatusForbidden, err.Error())
 		default:
 			return c.JSON(http.StatusInternalServerError, err.Error())
 		}
 	}
-	cookie := new(http.Cookie)
-	cookie.Name = "token"
-	cookie.Value = tokenString
-	cookie.Expires = time.Now().Add(24 * time.Hour)
-	cookie.Path = "/"
-	cookie.Domain = os.Getenv("API_DOMAIN")
-	cookie.Secure = true
-	cookie.HttpOnly = true
-	cookie.SameSite = http.SameSiteNoneMode
-	c.SetCookie(cookie) // httpレスポンスに含める
+	// 二要素認証が有効な場合はcookieを設定せず、MFAトークンを返す
+	if result.MFARequired {
+		return c.JSON(http.StatusOK, result)
+	}
+	setTokenCookie(c, result.Token, uc.cookieDomain)
 	return c.NoContent(http.StatusOK)
 }
 
+// tooManyAttempts はロック中であることをRetry-Afterヘッダー付きで返す
+func tooManyAttempts(c echo.Context, lockoutErr *usecase.LockoutError) error {
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockoutErr.RetryAfter.Seconds()))))
+	return c.JSON(http.StatusTooManyRequests, "ログインの試行回数が多すぎます。しばらくしてから再度お試しください")
+}
+
 func (uc *UserController) Logout(c echo.Context) error {
-	cookie := new(http.Cookie)
-	cookie.Name = "token"
-	cookie.Value = ""
-	cookie.Expires = time.Now()
-	cookie.Path = "/"
-	cookie.Domain = os.Getenv("API_DOMAIN")
-	cookie.Secure = true
-	cookie.HttpOnly = true
-	cookie.SameSite = http.SameSiteNoneMode
-	c.SetCookie(cookie) // httpレスポンスに含める
+	setTokenCookie(c, "", uc.cookieDomain)
+	// ログイン中であればサーバー側のセッションも失効させる
+	if claims, err := auth.ClaimsFrom(c); err == nil && claims.SessionID != "" {
+		if err := uc.uu.Logout(claims.UserID, claims.SessionID, clientInfo(c)); err != nil {
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+	}
 	return c.NoContent(http.StatusOK)
 }
 
@@ -97,36 +109,106 @@ func (uc *UserController) Update(c echo.Context) error {
 	}
 	// log.Print(user)
 
-	authUser := c.Get("user").(*jwt.Token)
-	claims := authUser.Claims.(jwt.MapClaims)
-	UserID := claims["user_id"]
-
-	// log.Print(authUser)
-	// log.Print(UserID)
-
-	userID := uint(UserID.(float64))
+	userID, err := auth.UserID(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
 	user.ID = userID
 	newEmail := c.FormValue("email")
 	newName := c.FormValue("name")
-	newPassword := c.FormValue("password")
+	// パスワードは現在のパスワードの確認が必要なため、POST /me/passwordでのみ変更できる
+	if c.FormValue("password") != "" {
+		return c.JSON(http.StatusBadRequest, "パスワードは /me/password で変更してください")
+	}
 	iconFile, err := c.FormFile("icon")
+	iconUploadID := c.FormValue("upload_id") // 直接アップロードしたアイコン
 
-	// log.Print(UserID, newEmail, newName, newPassword, iconFile)
+	// log.Print(UserID, newEmail, newName, iconFile)
 
 	if err != nil {
-		if err != http.ErrMissingFile {
+		if err != http.ErrMissingFile && err != http.ErrNotMultipart {
 			return c.JSON(http.StatusBadRequest, err.Error())
 		}
 	}
+	if iconFile != nil && iconUploadID != "" {
+		return c.JSON(http.StatusBadRequest, "icon and upload_id cannot be specified together")
+	}
 
-	userRes, err := uc.uu.Update(user, newEmail, newName, newPassword, iconFile)
+	userRes, err := uc.uu.Update(user, newEmail, newName, iconFile, iconUploadID, clientInfo(c))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, err.Error())
+		status, isUploadErr := uploadErrorStatus(err)
+		switch {
+		case errors.Is(err, usecase.ErrEmailAlreadyInUse):
+			return c.JSON(http.StatusConflict, err.Error())
+		case errors.Is(err, usecase.ErrIconTooLarge):
+			return c.JSON(http.StatusRequestEntityTooLarge, err.Error())
+		case isUploadErr:
+			return c.JSON(status, err.Error())
+		default:
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
 	}
 
 	return c.JSON(http.StatusOK, userRes)
 }
 
+func (uc *UserController) ChangePassword(c echo.Context) error {
+	claims, err := auth.ClaimsFrom(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, err.Error())
+	}
+	req := model.PasswordChangeRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if err := uc.uu.ChangePassword(claims.UserID, claims.SessionID, req, clientInfo(c)); err != nil {
+		var lockoutErr *usecase.LockoutError
+		var verrs validation.Errors
+		switch {
+		case errors.As(err, &lockoutErr):
+			return tooManyAttempts(c, lockoutErr)
+		case errors.As(err, &verrs):
+			return validationError(c, verrs)
+		case errors.Is(err, usecase.ErrIncorrectPassword):
+			return c.JSON(http.StatusForbidden, err.Error())
+		default:
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ConfirmEmailChange は新しいメールアドレスに送った確認リンクのトークンで変更を反映する
+func (uc *UserController) ConfirmEmailChange(c echo.Context) error {
+	req := model.EmailChangeTokenRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return emailChangeResult(c, uc.uu.ConfirmEmailChange(req.Token, clientInfo(c)))
+}
+
+// UndoEmailChange は変更前のメールアドレスに送った取り消しリンクのトークンで元に戻す
+func (uc *UserController) UndoEmailChange(c echo.Context) error {
+	req := model.EmailChangeTokenRequest{}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return emailChangeResult(c, uc.uu.UndoEmailChange(req.Token, clientInfo(c)))
+}
+
+func emailChangeResult(c echo.Context, err error) error {
+	switch {
+	case err == nil:
+		return c.NoContent(http.StatusNoContent)
+	case errors.Is(err, usecase.ErrInvalidEmailChangeLink):
+		return c.JSON(http.StatusBadRequest, err.Error())
+	case errors.Is(err, usecase.ErrEmailAlreadyInUse):
+		return c.JSON(http.StatusConflict, err.Error())
+	default:
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+}
+
 func (uc *UserController) CsrfToken(c echo.Context) error {
 	token := c.Get("csrf").(string)
 	return c.JSON(http.StatusOK, echo.Map{ // クライアントにcsrfトークンをレスポンス
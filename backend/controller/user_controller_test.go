@@ -3,17 +3,19 @@ package controller
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"backend/model"
 	"backend/usecase"
 
-	"github.com/golang-jwt/jwt/v5"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -28,16 +30,36 @@ func (m *mockUserUsecase) SignUp(user model.User) (model.UserResponse, error) {
 	return args.Get(0).(model.UserResponse), args.Error(1)
 }
 
-func (m *mockUserUsecase) Login(user model.User) (string, error) {
-	args := m.Called(user)
-	return args.String(0), args.Error(1)
+func (m *mockUserUsecase) Login(user model.User, client model.ClientInfo) (model.LoginResult, error) {
+	args := m.Called(user, client)
+	return args.Get(0).(model.LoginResult), args.Error(1)
+}
+
+func (m *mockUserUsecase) Logout(userID uint, sessionID string, client model.ClientInfo) error {
+	args := m.Called(userID, sessionID)
+	return args.Error(0)
 }
 
-func (m *mockUserUsecase) Update(user model.User, newEmail string, newName string, newPassword string, iconFile *multipart.FileHeader) (model.UserResponse, error) {
-	args := m.Called(user, newEmail, newName, newPassword, iconFile)
+func (m *mockUserUsecase) Update(user model.User, newEmail string, newName string, iconFile *multipart.FileHeader, iconUploadID string, client model.ClientInfo) (model.UserResponse, error) {
+	args := m.Called(user, newEmail, newName, iconFile, iconUploadID)
 	return args.Get(0).(model.UserResponse), args.Error(1)
 }
 
+func (m *mockUserUsecase) ChangePassword(userID uint, sessionID string, req model.PasswordChangeRequest, client model.ClientInfo) error {
+	args := m.Called(userID, sessionID, req)
+	return args.Error(0)
+}
+
+func (m *mockUserUsecase) ConfirmEmailChange(token string, client model.ClientInfo) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *mockUserUsecase) UndoEmailChange(token string, client model.ClientInfo) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
 func TestSignUp(t *testing.T) {
 	// Echoのインスタンスを作成
 	e := echo.New()
@@ -73,7 +95,7 @@ func TestSignUp(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// モックを設定
 			mockUsecase := new(mockUserUsecase)
-			controller := NewUserController(mockUsecase)
+			controller := NewUserController(mockUsecase, "localhost")
 
 			// リクエストを作成
 			req := httptest.NewRequest(http.MethodPost, "/signup", bytes.NewBufferString(tc.inputJSON))
@@ -114,14 +136,39 @@ func TestSignUp(t *testing.T) {
 	}
 }
 
+func TestSignUpValidationError(t *testing.T) {
+	e := echo.New()
+	mockUsecase := new(mockUserUsecase)
+	controller := NewUserController(mockUsecase, "localhost")
+
+	input := `{"name":"Test User","email":"test@example.com","password":"short"}`
+	var user model.User
+	if err := json.Unmarshal([]byte(input), &user); err != nil {
+		t.Fatal(err)
+	}
+	mockUsecase.On("SignUp", user).Return(model.UserResponse{}, validation.Errors{
+		"password": validation.NewError("password_too_short", "must be at least {{.min}} characters").
+			SetParams(map[string]interface{}{"min": 8}),
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(input))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	assert.NoError(t, controller.SignUp(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	// フロントエンドで翻訳できるよう、コードとパラメーターを返す
+	assert.JSONEq(t, `{"password":{"code":"password_too_short","message":"must be at least 8 characters","params":{"min":8}}}`, rec.Body.String())
+}
+
 func TestLogin(t *testing.T) {
 	e := echo.New()
-	os.Setenv("API_DOMAIN", "localhost")
 
 	testCases := []struct {
 		name         string
 		inputJSON    string
 		mockToken    string
+		mockMFA      bool
 		mockError    error
 		expectStatus int
 	}{
@@ -132,26 +179,40 @@ func TestLogin(t *testing.T) {
 			mockError:    nil,
 			expectStatus: http.StatusOK,
 		},
+		{
+			name:         "二要素認証が必要",
+			inputJSON:    `{"name":"Test User","email":"test@example.com","password":"password123"}`,
+			mockMFA:      true,
+			mockError:    nil,
+			expectStatus: http.StatusOK,
+		},
 		{
 			name:         "無効な認証情報",
 			inputJSON:    `{"name":"Test User","email":"test@example.com","password":"wrongpassword"}`,
 			mockToken:    "",
-			mockError:    usecase.ErrUserNotFound,
-			expectStatus: http.StatusNotFound,
+			mockError:    fmt.Errorf("%w: %w", usecase.ErrInvalidCredentials, usecase.ErrUserNotFound),
+			expectStatus: http.StatusUnauthorized, // ユーザーの有無が分からないよう401に統一
 		},
 		{
 			name:         "パスワードが間違っている",
 			inputJSON:    `{"name":"Test User","email":"test@example.com","password":"wrongpassword"}`,
 			mockToken:    "",
-			mockError:    usecase.ErrInvalidPassword, // usecase で定義しているパスワード不一致エラー
+			mockError:    fmt.Errorf("%w: %w", usecase.ErrInvalidCredentials, usecase.ErrInvalidPassword),
 			expectStatus: http.StatusUnauthorized,
 		},
+		{
+			name:         "ロック中",
+			inputJSON:    `{"name":"Test User","email":"test@example.com","password":"password123"}`,
+			mockToken:    "",
+			mockError:    &usecase.LockoutError{RetryAfter: 90 * time.Second},
+			expectStatus: http.StatusTooManyRequests,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockUsecase := new(mockUserUsecase)
-			controller := NewUserController(mockUsecase)
+			controller := NewUserController(mockUsecase, "localhost")
 
 			req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBufferString(tc.inputJSON))
 			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
@@ -169,12 +230,28 @@ func TestLogin(t *testing.T) {
 			// }
 
 			// モックの期待値を設定
-			mockUsecase.On("Login", user).Return(tc.mockToken, tc.mockError)
+			result := model.LoginResult{Token: tc.mockToken}
+			if tc.mockMFA {
+				result = model.LoginResult{MFARequired: true, MFAToken: "mfa.jwt.token"}
+			}
+			mockUsecase.On("Login", user, mock.AnythingOfType("model.ClientInfo")).Return(result, tc.mockError)
 
 			err := controller.Login(c)
 
+			// 二要素認証が必要な場合はcookieを設定せずMFAトークンを返す
+			if tc.mockMFA {
+				assert.NoError(t, err)
+				assert.Empty(t, rec.Result().Cookies())
+				var response model.LoginResult
+				if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				assert.True(t, response.MFARequired)
+				assert.Equal(t, "mfa.jwt.token", response.MFAToken)
+			}
+
 			// ログイン成功時のみトークンを確認
-			if tc.expectStatus == http.StatusOK {
+			if tc.expectStatus == http.StatusOK && !tc.mockMFA {
 				assert.NoError(t, err)
 				cookies := rec.Result().Cookies()
 				assert.Equal(t, 1, len(cookies))
@@ -182,6 +259,10 @@ func TestLogin(t *testing.T) {
 				assert.Equal(t, tc.mockToken, cookies[0].Value)
 			}
 
+			if tc.expectStatus == http.StatusTooManyRequests {
+				assert.Equal(t, "90", rec.Header().Get("Retry-After"))
+			}
+
 			assert.Equal(t, tc.expectStatus, rec.Code)
 			mockUsecase.AssertExpectations(t)
 		})
@@ -190,11 +271,10 @@ func TestLogin(t *testing.T) {
 
 func TestLogout(t *testing.T) {
 	e := echo.New()
-	os.Setenv("API_DOMAIN", "localhost")
 
 	t.Run("ログアウト処理", func(t *testing.T) {
 		mockUsecase := new(mockUserUsecase)
-		controller := NewUserController(mockUsecase)
+		controller := NewUserController(mockUsecase, "localhost")
 
 		req := httptest.NewRequest(http.MethodPost, "/logout", nil)
 		rec := httptest.NewRecorder()
@@ -209,17 +289,31 @@ func TestLogout(t *testing.T) {
 		assert.Equal(t, "token", cookies[0].Name)
 		assert.Equal(t, "", cookies[0].Value)
 		assert.True(t, cookies[0].Expires.Before(time.Now())) // 現在時刻を指定
+		mockUsecase.AssertNotCalled(t, "Logout", mock.Anything, mock.Anything)
+	})
+
+	t.Run("ログイン中のセッションを失効させる", func(t *testing.T) {
+		mockUsecase := new(mockUserUsecase)
+		controller := NewUserController(mockUsecase, "localhost")
+
+		req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		setAuthUser(c, 1)
+
+		mockUsecase.On("Logout", uint(1), "test-session").Return(nil).Once()
+
+		err := controller.Logout(c)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "", rec.Result().Cookies()[0].Value)
+		mockUsecase.AssertExpectations(t)
 	})
 }
 
 func TestUpdate(t *testing.T) {
 	e := echo.New()
 
-	// テスト用のJWTトークンを作成
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
-	claims["user_id"] = float64(1)
-
 	testCases := []struct {
 		name         string
 		setupRequest func() (*http.Request, *httptest.ResponseRecorder)
@@ -257,8 +351,8 @@ func TestUpdate(t *testing.T) {
 					}),
 					"new@example.com",
 					"Updated Name",
-					"",
 					mock.Anything,
+					"",
 				).Return(model.UserResponse{
 					ID:    1,
 					Name:  "Updated Name",
@@ -267,16 +361,56 @@ func TestUpdate(t *testing.T) {
 			},
 			expectStatus: http.StatusOK,
 		},
+		{
+			name: "パスワードは変更できない",
+			setupRequest: func() (*http.Request, *httptest.ResponseRecorder) {
+				body := new(bytes.Buffer)
+				writer := multipart.NewWriter(body)
+				if err := writer.WriteField("password", "newpassword"); err != nil {
+					t.Fatalf("failed to write password field: %v", err)
+				}
+				if err := writer.Close(); err != nil {
+					t.Fatalf("failed to close writer: %v", err)
+				}
+
+				req := httptest.NewRequest(http.MethodPut, "/users/update", body)
+				req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+				return req, httptest.NewRecorder()
+			},
+			mockSetup:    func(m *mockUserUsecase) {},
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name: "使用中のメールアドレス",
+			setupRequest: func() (*http.Request, *httptest.ResponseRecorder) {
+				body := new(bytes.Buffer)
+				writer := multipart.NewWriter(body)
+				if err := writer.WriteField("email", "taken@example.com"); err != nil {
+					t.Fatalf("failed to write email field: %v", err)
+				}
+				if err := writer.Close(); err != nil {
+					t.Fatalf("failed to close writer: %v", err)
+				}
+				req := httptest.NewRequest(http.MethodPut, "/users/update", body)
+				req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+				return req, httptest.NewRecorder()
+			},
+			mockSetup: func(m *mockUserUsecase) {
+				m.On("Update", mock.Anything, "taken@example.com", "", mock.Anything, "").
+					Return(model.UserResponse{}, usecase.ErrEmailAlreadyInUse)
+			},
+			expectStatus: http.StatusConflict,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockUsecase := new(mockUserUsecase)
-			controller := NewUserController(mockUsecase)
+			controller := NewUserController(mockUsecase, "localhost")
 
 			req, rec := tc.setupRequest()
 			c := e.NewContext(req, rec)
-			c.Set("user", token)
+			setAuthUser(c, 1)
 
 			tc.mockSetup(mockUsecase)
 
@@ -297,12 +431,90 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestChangePassword(t *testing.T) {
+	e := echo.New()
+	body := `{"current_password":"current-pass","new_password":"new-password"}`
+	req := model.PasswordChangeRequest{CurrentPassword: "current-pass", NewPassword: "new-password"}
+
+	testCases := []struct {
+		name         string
+		mockError    error
+		expectStatus int
+	}{
+		{name: "success", expectStatus: http.StatusNoContent},
+		{name: "現在のパスワードが違う", mockError: usecase.ErrIncorrectPassword, expectStatus: http.StatusForbidden},
+		{name: "条件を満たさない", mockError: validation.Errors{"new_password": errors.New("limited min 6 max 30 char")}, expectStatus: http.StatusBadRequest},
+		{name: "ロック中", mockError: &usecase.LockoutError{RetryAfter: time.Minute}, expectStatus: http.StatusTooManyRequests},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUsecase := new(mockUserUsecase)
+			controller := NewUserController(mockUsecase, "localhost")
+
+			httpReq := httptest.NewRequest(http.MethodPost, "/me/password", strings.NewReader(body))
+			httpReq.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httpReq, rec)
+			setAuthUser(c, 1)
+
+			mockUsecase.On("ChangePassword", uint(1), "test-session", req).Return(tc.mockError).Once()
+
+			err := controller.ChangePassword(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectStatus, rec.Code)
+			mockUsecase.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEmailChangeLinks(t *testing.T) {
+	e := echo.New()
+
+	testCases := []struct {
+		name         string
+		method       string
+		mockError    error
+		expectStatus int
+	}{
+		{name: "確認", method: "ConfirmEmailChange", expectStatus: http.StatusNoContent},
+		{name: "取り消し", method: "UndoEmailChange", expectStatus: http.StatusNoContent},
+		{name: "無効なリンク", method: "ConfirmEmailChange", mockError: usecase.ErrInvalidEmailChangeLink, expectStatus: http.StatusBadRequest},
+		{name: "使用中のメールアドレス", method: "ConfirmEmailChange", mockError: usecase.ErrEmailAlreadyInUse, expectStatus: http.StatusConflict},
+		{name: "取り消し先が使用中", method: "UndoEmailChange", mockError: usecase.ErrEmailAlreadyInUse, expectStatus: http.StatusConflict},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUsecase := new(mockUserUsecase)
+			controller := NewUserController(mockUsecase, "localhost")
+
+			req := httptest.NewRequest(http.MethodPost, "/email/confirm", strings.NewReader(`{"token":"link-token"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			mockUsecase.On(tc.method, "link-token").Return(tc.mockError).Once()
+
+			var err error
+			if tc.method == "ConfirmEmailChange" {
+				err = controller.ConfirmEmailChange(c)
+			} else {
+				err = controller.UndoEmailChange(c)
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectStatus, rec.Code)
+			mockUsecase.AssertExpectations(t)
+		})
+	}
+}
+
 func TestCsrfToken(t *testing.T) {
 	e := echo.New()
 
 	t.Run("CSRFトークン取得", func(t *testing.T) {
 		mockUsecase := new(mockUserUsecase)
-		controller := NewUserController(mockUsecase)
+		controller := NewUserController(mockUsecase, "localhost")
 
 		req := httptest.NewRequest(http.MethodGet, "/csrf", nil)
 		rec := httptest.NewRecorder()
@@ -0,0 +1,37 @@
+package controller
+
+// 入力エラーのレスポンス
+// フロントエンドで翻訳できるよう、項目ごとにコード・メッセージ・パラメーターを返す
+// 例: {"password": {"code": "password_too_short", "message": "must be at least 8 characters", "params": {"min": 8}}}
+
+import (
+	"net/http"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/labstack/echo/v4"
+)
+
+type fieldError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+func validationError(c echo.Context, verrs validation.Errors) error {
+	return c.JSON(http.StatusBadRequest, fieldErrors(verrs))
+}
+
+func fieldErrors(verrs validation.Errors) map[string]interface{} {
+	res := make(map[string]interface{}, len(verrs))
+	for field, err := range verrs {
+		switch e := err.(type) {
+		case validation.Errors: // 配列の要素ごとのエラー
+			res[field] = fieldErrors(e)
+		case validation.Error:
+			res[field] = fieldError{Code: e.Code(), Message: e.Error(), Params: e.Params()}
+		default:
+			res[field] = fieldError{Code: "invalid", Message: err.Error()}
+		}
+	}
+	return res
+}
@@ -1,79 +1,99 @@
 package db
 
 // dbへの接続
+// 接続はすべてNewDBで作成する（設定はconfigパッケージで読み込む）
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"strings"
 	"time"
 
-	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func NewDB() *gorm.DB {
-	// if os.Getenv("GO_ENV") == "dev" {
-	// 	err := godotenv.Load()
-	// 	if err != nil {
-	// 		log.Fatalln(err)
-	// 	}
-	// }
-	// if os.Getenv("GO_ENV") == "dev" {
-	// 	err := godotenv.Load(fmt.Sprintf(".env.%s", os.Getenv("GO_ENV")))
-	// 	if err != nil {
-	// 		log.Fatalln(err)
-	// 	}
-	// }
+// Config はPostgresへの接続と接続プールの設定
+type Config struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	SSLMode  string // disable（既定）/ require / verify-full など
+	TimeZone string
 
-	// ローカルの場合は以下のコメントアウトを外す
-	// err := godotenv.Load(fmt.Sprintf("C:/Users/hatot/.vscode/go_backend_hackathon/backend/.env.dev"))
+	MaxOpenConns    int           // 同時に開くことができる接続の最大数
+	MaxIdleConns    int           // アイドル状態で保持する接続の最大数
+	ConnMaxLifetime time.Duration // 接続の最大寿命
+}
 
-	if _, err := os.Stat(".env.dev"); err == nil {
-		if err := godotenv.Load(".env.dev"); err != nil {
-			log.Fatalln(err)
-		}
-	} else {
-		log.Println(".env.dev not found, skip loading local env file")
+// DefaultConfig は接続先以外の既定値
+func DefaultConfig() Config {
+	return Config{
+		Port:            "5432",
+		SSLMode:         "disable",
+		TimeZone:        "Asia/Tokyo",
+		MaxOpenConns:    10,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: time.Hour,
 	}
+}
 
-	url := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", os.Getenv("POSTGRES_USER"),
-		os.Getenv("POSTGRES_PW"), os.Getenv("POSTGRES_HOST"),
-		os.Getenv("POSTGRES_PORT"), os.Getenv("POSTGRES_DB"))
-
-	// GORMの設定を追加
-	gormConfig := &gorm.Config{
-		PrepareStmt: false, // プリペアドステートメントを無効化
+// DSN はキーワード形式の接続文字列を返す
+// HostにはCloud SQLのUnixソケット（/cloudsql/<インスタンス>）も指定できる
+// パスワードなどに空白や記号を含んでもよいよう、値はすべて引用符で囲む
+func (c Config) DSN() string {
+	params := []struct{ key, value string }{
+		{"host", c.Host},
+		{"port", c.Port},
+		{"user", c.User},
+		{"password", c.Password},
+		{"dbname", c.Name},
+		{"sslmode", c.SSLMode},
+		{"TimeZone", c.TimeZone},
 	}
+	parts := make([]string, 0, len(params))
+	for _, p := range params {
+		parts = append(parts, p.key+"="+quoteDSNValue(p.value))
+	}
+	return strings.Join(parts, " ")
+}
 
-	db, err := gorm.Open(postgres.Open(url), gormConfig)
+// quoteDSNValue は値を引用符で囲み、\と'をエスケープする
+func quoteDSNValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+// NewDB は接続して接続プールを設定する
+// Cloud SQLのプロキシなどでプリペアドステートメントを使えないため、シンプルプロトコルで問い合わせる
+func NewDB(cfg Config) (*gorm.DB, error) {
+	db, err := gorm.Open(
+		postgres.New(postgres.Config{
+			DSN:                  cfg.DSN(),
+			PreferSimpleProtocol: true,
+		}),
+		&gorm.Config{PrepareStmt: false},
+	)
 	if err != nil {
-		log.Fatalln(err)
+		return nil, err
 	}
 
-	// 接続プールの設定
 	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatalln("failed to get database connection:", err)
+		return nil, fmt.Errorf("failed to get database connection: %w", err)
 	}
-
-	// 接続プールの最大数を設定
-	sqlDB.SetMaxOpenConns(10)           // 同時に開くことができる接続の最大数
-	sqlDB.SetMaxIdleConns(5)            // アイドル状態で保持する接続の最大数
-	sqlDB.SetConnMaxLifetime(time.Hour) // 接続の最大寿命
-
-	fmt.Println("Connected")
-	return db
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	return db, nil
 }
 
-func CloseDB(db *gorm.DB) {
+func CloseDB(db *gorm.DB) error {
 	sqlDB, err := db.DB()
-	if err != nil { // エラーチェックを追加
-		log.Printf("Error getting database instance: %v", err)
-		return
-	}
-	if err := sqlDB.Close(); err != nil {
-		log.Fatalln(err)
+	if err != nil {
+		return err
 	}
+	return sqlDB.Close()
 }
@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/joho/godotenv"
 	"gorm.io/gorm"
 )
@@ -31,6 +32,44 @@ func init() {
 		panic("Error loading .env.test file")
 	}
 }
+
+// newTestDB は.env.testの接続先に接続する
+func newTestDB() *gorm.DB {
+	cfg := DefaultConfig()
+	cfg.Host = os.Getenv("POSTGRES_HOST")
+	cfg.Port = os.Getenv("POSTGRES_PORT")
+	cfg.User = os.Getenv("POSTGRES_USER")
+	cfg.Password = os.Getenv("POSTGRES_PW")
+	cfg.Name = os.Getenv("POSTGRES_DB")
+	db, err := NewDB(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+func TestConfigDSN(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Host = "db"
+	cfg.User = "hato"
+	cfg.Password = `p@ss wo'rd\`
+	cfg.Name = "hato"
+	want := `host='db' port='5432' user='hato' password='p@ss wo\'rd\\' dbname='hato' sslmode='disable' TimeZone='Asia/Tokyo'`
+	if got := cfg.DSN(); got != want {
+		t.Errorf("DSN() = %s, want %s", got, want)
+	}
+
+	// Cloud SQLのUnixソケット
+	cfg.Host = "/cloudsql/project:region:instance"
+	pgxCfg, err := pgx.ParseConfig(cfg.DSN())
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	if pgxCfg.Host != cfg.Host || pgxCfg.Password != cfg.Password || pgxCfg.RuntimeParams["TimeZone"] != "Asia/Tokyo" {
+		t.Errorf("ParseConfig() = host %s, password %s, params %v", pgxCfg.Host, pgxCfg.Password, pgxCfg.RuntimeParams)
+	}
+}
+
 func TestNewDB(t *testing.T) {
 	tests := []struct {
 		name string
@@ -42,7 +81,7 @@ func TestNewDB(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := NewDB()
+			got := newTestDB()
 
 			// nilでないことを確認
 			if got == nil {
@@ -92,7 +131,7 @@ func TestCloseDB(t *testing.T) {
 		{
 			name: "Close valid database connection",
 			args: args{
-				db: NewDB(), // 新しいDB接続を作成
+				db: newTestDB(), // 新しいDB接続を作成
 			},
 			wantPanic: false,
 		},
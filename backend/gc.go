@@ -0,0 +1,84 @@
+package main
+
+// 保存先の使われていないオブジェクトの削除
+// サブコマンド（スケジューラーから実行する場合）: backend gc-storage [-dry-run] [-grace 24h]
+// サーバーと同時に定期実行する場合はSTORAGE_GC_INTERVAL（例: 24h）を設定する
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"backend/usecase"
+)
+
+// runStorageGCCommand はgc-storageサブコマンドを実行する
+func runStorageGCCommand(args []string, gc usecase.IStorageGCUsecase, out io.Writer) error {
+	fs := flag.NewFlagSet("gc-storage", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "削除せずに対象を表示する")
+	grace := fs.Duration("grace", usecase.DefaultStorageGCGracePeriod, "更新からこの期間が経っていないオブジェクトは削除しない")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := gc.Run(context.Background(), usecase.StorageGCOptions{DryRun: *dryRun, GracePeriod: *grace})
+	if err != nil {
+		return err
+	}
+	printStorageGCReport(out, report)
+	if len(report.Failed) > 0 {
+		return fmt.Errorf("failed to delete %d objects", len(report.Failed))
+	}
+	return nil
+}
+
+func printStorageGCReport(out io.Writer, report usecase.StorageGCReport) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tSIZE\tUPDATED")
+	for _, o := range report.Orphans {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", o.Key, o.Size, o.UpdatedAt.Format(time.RFC3339))
+	}
+	tw.Flush()
+	mode := "deleted"
+	if report.DryRun {
+		mode = "dry-run"
+	}
+	fmt.Fprintf(out, "%s: scanned=%d referenced=%d recent=%d orphans=%d (%d bytes) deleted=%d failed=%d released=%d bytes\n",
+		mode, report.Scanned, report.Referenced, report.Recent, len(report.Orphans), report.OrphanBytes, report.Deleted, len(report.Failed), report.ReleasedBytes)
+	for _, key := range report.Failed {
+		fmt.Fprintf(out, "failed: %s\n", key)
+	}
+}
+
+// startStorageGC はintervalが設定されていれば（STORAGE_GC_INTERVAL）、定期的に削除を実行する
+// ctxを取り消すと実行中の削除を打ち切って終了する（終了はwgで待つ）
+func startStorageGC(ctx context.Context, gc usecase.IStorageGCUsecase, interval time.Duration, wg *sync.WaitGroup) {
+	if interval <= 0 {
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report, err := gc.Run(ctx, usecase.StorageGCOptions{GracePeriod: usecase.DefaultStorageGCGracePeriod})
+				if err != nil {
+					log.Printf("storage gc failed: %v", err)
+					continue
+				}
+				log.Printf("storage gc: scanned=%d orphans=%d deleted=%d failed=%d released=%d bytes",
+					report.Scanned, len(report.Orphans), report.Deleted, len(report.Failed), report.ReleasedBytes)
+			}
+		}
+	}()
+}
@@ -0,0 +1,225 @@
+package imageproc
+
+// アップロードされた画像の変換
+// 内容（マジックバイト）で種類を判定し、バイト数・ピクセル数の上限を確認したうえでデコードする
+// EXIFの向きに合わせて回転してから、サイズごと（サムネイル・中・元のサイズ）に再エンコードする
+// 再エンコードするため、EXIF（位置情報を含む）などのメタデータは出力に残らない
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // image.Decodeで使う
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("image must be a JPEG, PNG, GIF or WebP")
+	ErrTooLarge          = errors.New("image is too large")
+	ErrTooManyPixels     = errors.New("image has too many pixels")
+)
+
+// 画像の種類（Sniffの結果）
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+	FormatWebP = "webp"
+)
+
+// Sniff は先頭のマジックバイトから画像の種類を判定する
+func Sniff(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return FormatJPEG, nil
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG, nil
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return FormatGIF, nil
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return FormatWebP, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// Limits は受け付ける画像の上限
+type Limits struct {
+	MaxBytes  int64 // ファイルの大きさ
+	MaxPixels int   // 幅×高さ（デコード前に確認し、展開すると巨大になる画像を拒否する）
+}
+
+// DefaultLimits はスマートフォンで撮影した写真を受け付けられる既定値
+func DefaultLimits() Limits {
+	return Limits{
+		MaxBytes:  10 << 20, // 10MiB
+		MaxPixels: 50_000_000,
+	}
+}
+
+// Variant は出力するサイズ（長辺の最大ピクセル数。元の画像より大きくはしない）
+type Variant struct {
+	Name    string
+	MaxSize int
+}
+
+// 出力するサイズの名前
+const (
+	VariantThumbnail = "thumbnail"
+	VariantMedium    = "medium"
+	VariantFull      = "full"
+)
+
+// DefaultVariants は料理画像のサイズ（大きい順に並べる）
+var DefaultVariants = []Variant{
+	{VariantFull, 2048},
+	{VariantMedium, 800},
+	{VariantThumbnail, 200},
+}
+
+// Output は変換した画像
+type Output struct {
+	Variant     string
+	ContentType string // image/jpeg（透過がある場合はimage/png）
+	Ext         string // .jpg / .png（すべてのサイズで同じ）
+	Data        []byte
+	Width       int
+	Height      int
+}
+
+const jpegQuality = 85
+
+// Processor は画像を確認・変換する
+type Processor struct {
+	Limits   Limits
+	Variants []Variant
+}
+
+func NewProcessor(limits Limits, variants ...Variant) *Processor {
+	if len(variants) == 0 {
+		variants = DefaultVariants
+	}
+	return &Processor{limits, variants}
+}
+
+// Process は画像を読み込み、Variantsの順に変換した画像を返す
+// GIFアニメーションは最初のフレームのみを使う
+func (p *Processor) Process(r io.Reader) ([]Output, error) {
+	src, orientation, err := p.decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make([]Output, 0, len(p.Variants))
+	var prev *image.RGBA
+	opaque := true // すべてのサイズを同じ形式で出力するため、最初のサイズで決める
+	for _, v := range p.Variants {
+		var img *image.RGBA
+		if prev == nil {
+			// 縮小してから回転する（回転する画素を減らすため）
+			w, h := src.Bounds().Dx(), src.Bounds().Dy()
+			if swapsAxes(orientation) {
+				h, w = fit(h, w, v.MaxSize)
+			} else {
+				w, h = fit(w, h, v.MaxSize)
+			}
+			img = orient(scale(src, w, h), orientation)
+			opaque = img.Opaque()
+		} else {
+			// 1つ前（大きい方）のサイズから縮小する
+			w, h := fit(prev.Bounds().Dx(), prev.Bounds().Dy(), v.MaxSize)
+			img = scale(prev, w, h)
+		}
+		out, err := encode(img, opaque)
+		if err != nil {
+			return nil, err
+		}
+		out.Variant = v.Name
+		outputs = append(outputs, out)
+		prev = img
+	}
+	return outputs, nil
+}
+
+// decode は上限を確認して画像をデコードし、EXIFの向きとともに返す
+func (p *Processor) decode(r io.Reader) (image.Image, int, error) {
+	data, err := io.ReadAll(io.LimitReader(r, p.Limits.MaxBytes+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if int64(len(data)) > p.Limits.MaxBytes {
+		return nil, 0, ErrTooLarge
+	}
+	format, err := Sniff(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > p.Limits.MaxPixels {
+		return nil, 0, ErrTooManyPixels
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+
+	orientation := 1
+	if format == FormatJPEG {
+		orientation = jpegOrientation(data)
+	}
+	return src, orientation, nil
+}
+
+// fit は縦横比を保ったまま長辺をmaxSize以下にした大きさを返す
+func fit(w, h, maxSize int) (int, int) {
+	if maxSize <= 0 || (w <= maxSize && h <= maxSize) {
+		return w, h
+	}
+	if w >= h {
+		return maxSize, max(1, h*maxSize/w)
+	}
+	return max(1, w*maxSize/h), maxSize
+}
+
+func scale(src image.Image, w, h int) *image.RGBA {
+	return scaleRect(src, src.Bounds(), w, h)
+}
+
+// scaleRect はsrcのrの範囲をw×hに拡大・縮小する
+func scaleRect(src image.Image, r image.Rectangle, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if r.Dx() == w && r.Dy() == h {
+		draw.Draw(dst, dst.Bounds(), src, r.Min, draw.Src)
+		return dst
+	}
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, r, xdraw.Src, nil)
+	return dst
+}
+
+func encode(img *image.RGBA, opaque bool) (Output, error) {
+	buf := new(bytes.Buffer)
+	out := Output{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	if opaque {
+		if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return Output{}, err
+		}
+		out.ContentType, out.Ext = "image/jpeg", ".jpg"
+	} else {
+		if err := png.Encode(buf, img); err != nil {
+			return Output{}, err
+		}
+		out.ContentType, out.Ext = "image/png", ".png"
+	}
+	out.Data = buf.Bytes()
+	return out, nil
+}
@@ -0,0 +1,118 @@
+package imageproc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestImage は左半分が赤・右半分が青の画像を作成する
+func newTestImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{255, 0, 0, 255}
+			if x >= w/2 {
+				c = color.NRGBA{0, 0, 255, 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	require.NoError(t, jpeg.Encode(buf, img, nil))
+	return buf.Bytes()
+}
+
+func TestSniff(t *testing.T) {
+	testCases := []struct {
+		data   string
+		format string
+	}{
+		{"\xff\xd8\xff\xe0rest", FormatJPEG},
+		{"\x89PNG\r\n\x1a\nrest", FormatPNG},
+		{"GIF89a", FormatGIF},
+		{"GIF87a", FormatGIF},
+		{"RIFF\x00\x00\x00\x00WEBPVP8 ", FormatWebP},
+		{"RIFF\x00\x00\x00\x00WAVEfmt ", ""},
+		{"<html></html>", ""},
+		{"", ""},
+	}
+	for _, tc := range testCases {
+		format, err := Sniff([]byte(tc.data))
+		assert.Equal(t, tc.format, format, tc.data)
+		if tc.format == "" {
+			assert.ErrorIs(t, err, ErrUnsupportedFormat)
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	p := NewProcessor(DefaultLimits())
+
+	t.Run("サイズごとに縮小する", func(t *testing.T) {
+		outputs, err := p.Process(bytes.NewReader(encodeJPEG(t, newTestImage(3000, 1500))))
+		require.NoError(t, err)
+		require.Len(t, outputs, 3)
+		sizes := map[string][2]int{}
+		for _, o := range outputs {
+			sizes[o.Variant] = [2]int{o.Width, o.Height}
+			assert.Equal(t, "image/jpeg", o.ContentType)
+			assert.Equal(t, ".jpg", o.Ext)
+			cfg, err := jpeg.DecodeConfig(bytes.NewReader(o.Data))
+			assert.NoError(t, err)
+			assert.Equal(t, o.Width, cfg.Width)
+		}
+		assert.Equal(t, [2]int{2048, 1024}, sizes[VariantFull])
+		assert.Equal(t, [2]int{800, 400}, sizes[VariantMedium])
+		assert.Equal(t, [2]int{200, 100}, sizes[VariantThumbnail])
+	})
+
+	t.Run("小さい画像は拡大しない", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		require.NoError(t, gif.Encode(buf, newTestImage(100, 50), nil))
+		outputs, err := p.Process(buf)
+		require.NoError(t, err)
+		for _, o := range outputs {
+			assert.Equal(t, 100, o.Width, o.Variant)
+			assert.Equal(t, 50, o.Height, o.Variant)
+		}
+	})
+
+	t.Run("透過がある画像はPNGで出力する", func(t *testing.T) {
+		img := newTestImage(10, 10)
+		img.SetNRGBA(0, 0, color.NRGBA{0, 0, 0, 0})
+		buf := new(bytes.Buffer)
+		require.NoError(t, png.Encode(buf, img))
+		outputs, err := p.Process(buf)
+		require.NoError(t, err)
+		assert.Equal(t, "image/png", outputs[0].ContentType)
+		assert.Equal(t, ".png", outputs[0].Ext)
+	})
+
+	t.Run("上限を超える画像は受け付けない", func(t *testing.T) {
+		data := encodeJPEG(t, newTestImage(100, 100))
+		_, err := NewProcessor(Limits{MaxBytes: int64(len(data) - 1), MaxPixels: 1 << 20}).Process(bytes.NewReader(data))
+		assert.ErrorIs(t, err, ErrTooLarge)
+		_, err = NewProcessor(Limits{MaxBytes: 1 << 20, MaxPixels: 100*100 - 1}).Process(bytes.NewReader(data))
+		assert.ErrorIs(t, err, ErrTooManyPixels)
+	})
+
+	t.Run("画像以外・壊れた画像は受け付けない", func(t *testing.T) {
+		_, err := p.Process(bytes.NewReader([]byte("<html></html>")))
+		assert.ErrorIs(t, err, ErrUnsupportedFormat)
+		_, err = p.Process(bytes.NewReader([]byte("\x89PNG\r\n\x1a\nbroken")))
+		assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+}
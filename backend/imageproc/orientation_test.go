@@ -0,0 +1,106 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withExif はJPEGにOrientationと位置情報らしき値を含むEXIFを追加する
+func withExif(data []byte, orientation uint16) []byte {
+	tiff := new(bytes.Buffer)
+	tiff.WriteString("MM\x00\x2a")
+	binary.Write(tiff, binary.BigEndian, uint32(8)) // IFD0の位置
+	binary.Write(tiff, binary.BigEndian, uint16(2)) // エントリー数
+	// Orientation（SHORT, 1個）
+	binary.Write(tiff, binary.BigEndian, []uint16{exifOrientationTag, 3})
+	binary.Write(tiff, binary.BigEndian, uint32(1))
+	binary.Write(tiff, binary.BigEndian, []uint16{orientation, 0})
+	// GPS IFDへのポインター（テストでは値だけ確認する）
+	binary.Write(tiff, binary.BigEndian, []uint16{0x8825, 4})
+	binary.Write(tiff, binary.BigEndian, []uint32{1, 38})
+	binary.Write(tiff, binary.BigEndian, uint32(0))
+	tiff.WriteString("GPS35.6812N139.7671E")
+
+	seg := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	app1 := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(seg)+2))
+	out := append([]byte{}, data[:2]...) // SOI
+	out = append(out, app1...)
+	out = append(out, seg...)
+	return append(out, data[2:]...)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	data := encodeJPEG(t, newTestImage(8, 8))
+	assert.Equal(t, 1, jpegOrientation(data), "EXIFなし")
+	for o := uint16(1); o <= 8; o++ {
+		assert.Equal(t, int(o), jpegOrientation(withExif(data, o)))
+	}
+	assert.Equal(t, 1, jpegOrientation(withExif(data, 9)), "範囲外")
+	assert.Equal(t, 1, jpegOrientation([]byte("\xff\xd8\xff\xe1\xff\xff")), "壊れたセグメント")
+}
+
+func TestProcessOrientation(t *testing.T) {
+	// 横長の画像（左が赤）を時計回りに90度回転すると、縦長で上が赤になる
+	data := withExif(encodeJPEG(t, newTestImage(400, 200)), 6)
+	outputs, err := NewProcessor(DefaultLimits()).Process(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	for _, o := range outputs {
+		assert.Equal(t, o.Width*2, o.Height, "縦長になる: %s", o.Variant)
+		assert.False(t, bytes.Contains(o.Data, []byte("Exif")), "EXIFを残さない")
+		assert.False(t, bytes.Contains(o.Data, []byte("GPS35")), "位置情報を残さない")
+	}
+	assert.Equal(t, 200, outputs[0].Width)
+	img, err := jpeg.Decode(bytes.NewReader(outputs[0].Data))
+	require.NoError(t, err)
+	assertRed(t, img, 100, 50)
+	assertBlue(t, img, 100, 350)
+}
+
+func TestOrient(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	copy(src.Pix, []byte{1, 1, 1, 255, 2, 2, 2, 255}) // [1 2]
+	testCases := []struct {
+		orientation int
+		w, h        int
+		pix         []byte // 各画素のR
+	}{
+		{1, 2, 1, []byte{1, 2}},
+		{2, 2, 1, []byte{2, 1}},
+		{3, 2, 1, []byte{2, 1}},
+		{4, 2, 1, []byte{1, 2}},
+		{5, 1, 2, []byte{1, 2}},
+		{6, 1, 2, []byte{1, 2}},
+		{7, 1, 2, []byte{2, 1}},
+		{8, 1, 2, []byte{2, 1}},
+	}
+	for _, tc := range testCases {
+		dst := orient(src, tc.orientation)
+		assert.Equal(t, tc.w, dst.Bounds().Dx(), tc.orientation)
+		assert.Equal(t, tc.h, dst.Bounds().Dy(), tc.orientation)
+		r := []byte{}
+		for i := 0; i < len(dst.Pix); i += 4 {
+			r = append(r, dst.Pix[i])
+		}
+		assert.Equal(t, tc.pix, r, tc.orientation)
+	}
+}
+
+func assertRed(t *testing.T, img image.Image, x, y int) {
+	t.Helper()
+	r, _, b, _ := img.At(x, y).RGBA()
+	assert.Greater(t, r, b, "(%d, %d) は赤", x, y)
+}
+
+func assertBlue(t *testing.T, img image.Image, x, y int) {
+	t.Helper()
+	r, _, b, _ := img.At(x, y).RGBA()
+	assert.Greater(t, b, r, "(%d, %d) は青", x, y)
+}
@@ -0,0 +1,66 @@
+package imageproc
+
+// 重複した写真を見つけるための知覚ハッシュ（dHash）
+// 横9×縦8のグレースケールに縮小し、横に隣り合う画素の明るさを比べた64ビット
+// 縮小・再圧縮・わずかな色の変化ではほとんど変わらず、ハミング距離が小さいほど似ている
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+	"strconv"
+)
+
+const (
+	perceptualHashWidth  = 9
+	perceptualHashHeight = 8
+)
+
+// PerceptualHash は画像を読み込み、EXIFの向きに合わせて回転してから知覚ハッシュを求める
+func (p *Processor) PerceptualHash(r io.Reader) (uint64, error) {
+	src, orientation, err := p.decode(r)
+	if err != nil {
+		return 0, err
+	}
+	w, h := fit(src.Bounds().Dx(), src.Bounds().Dy(), placeholderMaxSize) // 回転してから9×8に縮小するため、先に小さくしておく
+	return NewPerceptualHash(orient(scale(src, w, h), orientation)), nil
+}
+
+// NewPerceptualHash は画像の知覚ハッシュを求める
+func NewPerceptualHash(img image.Image) uint64 {
+	small := scale(img, perceptualHashWidth, perceptualHashHeight)
+	var hash uint64
+	for y := 0; y < perceptualHashHeight; y++ {
+		for x := 0; x < perceptualHashWidth-1; x++ {
+			hash <<= 1
+			if luminance(small.At(x, y)) > luminance(small.At(x+1, y)) {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HammingDistance は2つの知覚ハッシュで異なるビットの数（0〜64）を返す
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// FormatPerceptualHash は保存用の16桁の16進数にする
+func FormatPerceptualHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+func ParsePerceptualHash(s string) (uint64, error) {
+	if len(s) != 16 {
+		return 0, fmt.Errorf("invalid perceptual hash: %q", s)
+	}
+	return strconv.ParseUint(s, 16, 64)
+}
+
+func luminance(c color.Color) uint32 {
+	r, g, b, _ := c.RGBA()
+	return (299*r + 587*g + 114*b) / 1000
+}
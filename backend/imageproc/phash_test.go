@@ -0,0 +1,59 @@
+package imageproc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newGradientImage は左右・上下で明るさの変わる画像を返す（mirrorで左右を反転する）
+func newGradientImage(w, h int, mirror bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			fx := x
+			if mirror {
+				fx = w - 1 - x
+			}
+			v := uint8((fx*255/w + (y*3*255/h)%255) / 2)
+			img.SetNRGBA(x, y, color.NRGBA{v, v / 2, 255 - v, 255})
+		}
+	}
+	return img
+}
+
+func TestPerceptualHash(t *testing.T) {
+	p := NewProcessor(DefaultLimits())
+	original, err := p.PerceptualHash(bytes.NewReader(encodeJPEG(t, newGradientImage(400, 300, false))))
+	require.NoError(t, err)
+
+	// 縮小・再圧縮した画像はほとんど同じ
+	resized, err := p.PerceptualHash(bytes.NewReader(encodeJPEG(t, newGradientImage(120, 90, false))))
+	require.NoError(t, err)
+	assert.LessOrEqual(t, HammingDistance(original, resized), 4)
+
+	// 左右を反転した画像は異なる
+	mirrored := NewPerceptualHash(newGradientImage(400, 300, true))
+	assert.Greater(t, HammingDistance(original, mirrored), 20)
+
+	_, err = p.PerceptualHash(bytes.NewReader([]byte("<html></html>")))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestFormatPerceptualHash(t *testing.T) {
+	s := FormatPerceptualHash(0x00ff00000000abcd)
+	assert.Equal(t, "00ff00000000abcd", s)
+	hash, err := ParsePerceptualHash(s)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0x00ff00000000abcd), hash)
+
+	_, err = ParsePerceptualHash("abc")
+	assert.Error(t, err)
+	_, err = ParsePerceptualHash("zzzzzzzzzzzzzzzz")
+	assert.Error(t, err)
+	assert.Equal(t, 64, HammingDistance(0, ^uint64(0)))
+}
@@ -0,0 +1,164 @@
+package imageproc
+
+// 画像の読み込み中に表示する代わりの情報（BlurHashと代表色）
+// BlurHash: https://github.com/woltapp/blurhash のアルゴリズム（横4×縦3の成分）
+// 代表色: 縮小した画像の色を各チャンネル16段階にまとめ、最も多いまとまりの平均の色
+// いずれも長辺32pxに縮小してから求める（結果はほとんど変わらず、計算量が小さい）
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strings"
+)
+
+const (
+	blurHashComponentsX = 4
+	blurHashComponentsY = 3
+	placeholderMaxSize  = 32
+)
+
+// Placeholder は画像の代わりに表示する情報
+type Placeholder struct {
+	BlurHash      string
+	DominantColor string // #rrggbb
+}
+
+// Placeholder は画像を読み込み、EXIFの向きに合わせて回転してから代わりの情報を求める
+func (p *Processor) Placeholder(r io.Reader) (Placeholder, error) {
+	src, orientation, err := p.decode(r)
+	if err != nil {
+		return Placeholder{}, err
+	}
+	w, h := fit(src.Bounds().Dx(), src.Bounds().Dy(), placeholderMaxSize)
+	return NewPlaceholder(orient(scale(src, w, h), orientation)), nil
+}
+
+// NewPlaceholder は画像から代わりの情報を求める（大きな画像は縮小してから渡す）
+func NewPlaceholder(img image.Image) Placeholder {
+	return Placeholder{BlurHash: blurHash(img), DominantColor: dominantColor(img)}
+}
+
+func blurHash(img image.Image) string {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// 画素をリニアRGBに変換しておく
+	linear := make([][3]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			linear[y*w+x] = [3]float64{srgbToLinear(r >> 8), srgbToLinear(g >> 8), srgbToLinear(bl >> 8)}
+		}
+	}
+
+	factors := make([][3]float64, 0, blurHashComponentsX*blurHashComponentsY)
+	for j := 0; j < blurHashComponentsY; j++ {
+		for i := 0; i < blurHashComponentsX; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			var f [3]float64
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					basis := normalisation * math.Cos(math.Pi*float64(i*x)/float64(w)) * math.Cos(math.Pi*float64(j*y)/float64(h))
+					for c := 0; c < 3; c++ {
+						f[c] += basis * linear[y*w+x][c]
+					}
+				}
+			}
+			for c := 0; c < 3; c++ {
+				f[c] /= float64(w * h)
+			}
+			factors = append(factors, f)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(encode83((blurHashComponentsX-1)+(blurHashComponentsY-1)*9, 1))
+	dc, ac := factors[0], factors[1:]
+	var actualMax float64
+	for _, f := range ac {
+		for c := 0; c < 3; c++ {
+			actualMax = math.Max(actualMax, math.Abs(f[c]))
+		}
+	}
+	quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+	maxValue := float64(quantisedMax+1) / 166
+	sb.WriteString(encode83(quantisedMax, 1))
+	sb.WriteString(encode83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+	for _, f := range ac {
+		quant := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+		}
+		sb.WriteString(encode83(quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2))
+	}
+	return sb.String()
+}
+
+func dominantColor(img image.Image) string {
+	type bucket struct {
+		count   int
+		r, g, b uint64
+	}
+	buckets := map[uint32]*bucket{}
+	var best *bucket
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue // 透明な部分は数えない
+			}
+			r, g, b = r>>8, g>>8, b>>8
+			id := r>>4<<8 | g>>4<<4 | b>>4
+			bk, ok := buckets[id]
+			if !ok {
+				bk = &bucket{}
+				buckets[id] = bk
+			}
+			bk.count++
+			bk.r, bk.g, bk.b = bk.r+uint64(r), bk.g+uint64(g), bk.b+uint64(b)
+			if best == nil || bk.count > best.count {
+				best = bk
+			}
+		}
+	}
+	if best == nil {
+		return "#000000"
+	}
+	n := uint64(best.count)
+	return fmt.Sprintf("#%02x%02x%02x", best.r/n, best.g/n, best.b/n)
+}
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+func encode83(value int, length int) string {
+	out := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		out[i] = base83Chars[value%83]
+		value /= 83
+	}
+	return string(out)
+}
+
+func srgbToLinear(v uint32) float64 {
+	f := float64(v) / 255
+	if f <= 0.04045 {
+		return f / 12.92
+	}
+	return math.Pow((f+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v float64, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
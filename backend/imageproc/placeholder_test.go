@@ -0,0 +1,55 @@
+package imageproc
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPlaceholder(t *testing.T) {
+	t.Run("単色の画像", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{255, 0, 0, 255}), image.Point{}, draw.Src)
+		p := NewPlaceholder(img)
+		// 成分数（4×3）・ACの最大値・DC（#ff0000）・AC（11個×2文字）
+		assert.Len(t, p.BlurHash, 28)
+		assert.Equal(t, "L", p.BlurHash[:1])
+		assert.Equal(t, "TI:j", p.BlurHash[2:6])
+		assert.Equal(t, "#ff0000", p.DominantColor)
+	})
+
+	t.Run("最も多い色を代表色にする", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+		for y := 0; y < 10; y++ {
+			for x := 0; x < 10; x++ {
+				c := color.NRGBA{0, 0, 255, 255}
+				if x < 3 {
+					c = color.NRGBA{255, 0, 0, 255}
+				}
+				if y == 0 {
+					c = color.NRGBA{0, 255, 0, 0} // 透明な部分は数えない
+				}
+				img.SetNRGBA(x, y, c)
+			}
+		}
+		p := NewPlaceholder(img)
+		assert.Equal(t, "#0000ff", p.DominantColor)
+		assert.Len(t, p.BlurHash, 28)
+	})
+}
+
+func TestProcessorPlaceholder(t *testing.T) {
+	p := NewProcessor(DefaultLimits())
+	res, err := p.Placeholder(bytes.NewReader(encodeJPEG(t, newTestImage(400, 200))))
+	require.NoError(t, err)
+	assert.Len(t, res.BlurHash, 28)
+	assert.Regexp(t, `^#[0-9a-f]{6}$`, res.DominantColor)
+
+	_, err = p.Placeholder(bytes.NewReader([]byte("<html></html>")))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
@@ -0,0 +1,105 @@
+package imageproc
+
+// 指定した大きさ・切り抜き方・形式への変換（画像の配信時のリサイズ）
+// contain: 縦横比を保って枠内に収める（幅・高さの一方を0にするともう一方のみで制限する）
+// cover: 枠と同じ縦横比で中央を切り抜いてから縮小する
+// いずれも元の画像より大きくはしない
+
+import (
+	"errors"
+	"image"
+	"io"
+)
+
+var ErrInvalidTransform = errors.New("invalid image transform")
+
+// Transform.Fit
+const (
+	FitContain = "contain"
+	FitCover   = "cover"
+)
+
+// Transform は変換の指定
+type Transform struct {
+	Width  int    // 0は制限しない（coverでは必須）
+	Height int    // 0は制限しない（coverでは必須）
+	Fit    string // contain（既定）/ cover
+	Format string // jpeg / png（空の場合は透過があればpng、なければjpeg）
+}
+
+// Resize は画像を読み込み、tに従って変換する
+func (p *Processor) Resize(r io.Reader, t Transform) (Output, error) {
+	if t.Width < 0 || t.Height < 0 {
+		return Output{}, ErrInvalidTransform
+	}
+	opaque, err := transformOpaque(t.Format)
+	if err != nil {
+		return Output{}, err
+	}
+	src, orientation, err := p.decode(r)
+	if err != nil {
+		return Output{}, err
+	}
+
+	// 回転する前の画像で大きさを求める
+	tw, th := t.Width, t.Height
+	if swapsAxes(orientation) {
+		tw, th = th, tw
+	}
+	b := src.Bounds()
+	crop := b
+	var w, h int
+	switch t.Fit {
+	case FitContain, "":
+		w, h = fitBox(b.Dx(), b.Dy(), tw, th)
+	case FitCover:
+		if tw <= 0 || th <= 0 {
+			return Output{}, ErrInvalidTransform
+		}
+		cw, ch := b.Dx(), max(1, b.Dx()*th/tw)
+		if ch > b.Dy() {
+			cw, ch = max(1, b.Dy()*tw/th), b.Dy()
+		}
+		crop = image.Rect(0, 0, cw, ch).Add(b.Min).Add(image.Pt((b.Dx()-cw)/2, (b.Dy()-ch)/2))
+		w, h = cw, ch
+		if cw > tw {
+			w, h = tw, th
+		}
+	default:
+		return Output{}, ErrInvalidTransform
+	}
+
+	img := orient(scaleRect(src, crop, w, h), orientation)
+	if opaque == nil {
+		o := img.Opaque()
+		opaque = &o
+	}
+	return encode(img, *opaque)
+}
+
+// transformOpaque は形式からJPEGで出力するかを返す（nilは画像によって決める）
+func transformOpaque(format string) (*bool, error) {
+	var opaque bool
+	switch format {
+	case "":
+		return nil, nil
+	case FormatJPEG:
+		opaque = true
+	case FormatPNG:
+		opaque = false
+	default:
+		return nil, ErrInvalidTransform
+	}
+	return &opaque, nil
+}
+
+// fitBox は縦横比を保ったまま幅をmaxW以下・高さをmaxH以下にした大きさを返す（0は制限しない）
+func fitBox(w, h, maxW, maxH int) (int, int) {
+	if maxW > 0 && w > maxW {
+		w, h = maxW, max(1, h*maxW/w)
+	}
+	if maxH > 0 && h > maxH {
+		w, h = max(1, w*maxH/h), maxH
+	}
+	return w, h
+}
@@ -0,0 +1,80 @@
+package imageproc
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResize(t *testing.T) {
+	p := NewProcessor(DefaultLimits())
+	src := encodeJPEG(t, newTestImage(3000, 1500))
+
+	testCases := []struct {
+		name      string
+		transform Transform
+		w, h      int
+	}{
+		{"枠内に収める", Transform{Width: 400, Height: 400, Fit: FitContain}, 400, 200},
+		{"幅のみ指定", Transform{Width: 1200}, 1200, 600},
+		{"高さのみ指定", Transform{Height: 300}, 600, 300},
+		{"中央を切り抜く", Transform{Width: 400, Height: 400, Fit: FitCover}, 400, 400},
+		{"横長に切り抜く", Transform{Width: 1200, Height: 630, Fit: FitCover}, 1200, 630},
+		{"拡大しない", Transform{Width: 4000, Height: 4000, Fit: FitContain}, 3000, 1500},
+		{"切り抜いても拡大しない", Transform{Width: 4000, Height: 4000, Fit: FitCover}, 1500, 1500},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := p.Resize(bytes.NewReader(src), tc.transform)
+			require.NoError(t, err)
+			assert.Equal(t, tc.w, out.Width)
+			assert.Equal(t, tc.h, out.Height)
+			assert.Equal(t, "image/jpeg", out.ContentType)
+		})
+	}
+
+	t.Run("中央を切り抜くと左右の色が残る", func(t *testing.T) {
+		out, err := p.Resize(bytes.NewReader(src), Transform{Width: 100, Height: 100, Fit: FitCover, Format: FormatPNG})
+		require.NoError(t, err)
+		assert.Equal(t, "image/png", out.ContentType)
+		img, err := png.Decode(bytes.NewReader(out.Data))
+		require.NoError(t, err)
+		r, _, _, _ := img.At(5, 50).RGBA()
+		_, _, b, _ := img.At(95, 50).RGBA()
+		assert.Greater(t, r, uint32(0xc000))
+		assert.Greater(t, b, uint32(0xc000))
+	})
+
+	t.Run("EXIFの向きに合わせて回転する", func(t *testing.T) {
+		out, err := p.Resize(bytes.NewReader(withExif(src, 6)), Transform{Width: 300, Fit: FitContain})
+		require.NoError(t, err)
+		assert.Equal(t, 300, out.Width)
+		assert.Equal(t, 600, out.Height)
+	})
+
+	t.Run("形式を指定しなければ透過がある画像はPNGで出力する", func(t *testing.T) {
+		img := newTestImage(10, 10)
+		img.SetNRGBA(0, 0, color.NRGBA{0, 0, 0, 0})
+		buf := new(bytes.Buffer)
+		require.NoError(t, png.Encode(buf, img))
+		out, err := p.Resize(buf, Transform{Width: 5})
+		require.NoError(t, err)
+		assert.Equal(t, "image/png", out.ContentType)
+	})
+
+	t.Run("不正な指定は受け付けない", func(t *testing.T) {
+		for _, tr := range []Transform{
+			{Width: 100, Fit: FitCover},
+			{Width: 100, Fit: "stretch"},
+			{Width: 100, Format: "webp"},
+			{Width: -1},
+		} {
+			_, err := p.Resize(bytes.NewReader(src), tr)
+			assert.ErrorIs(t, err, ErrInvalidTransform, tr)
+		}
+	})
+}
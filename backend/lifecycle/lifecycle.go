@@ -0,0 +1,96 @@
+package lifecycle
+
+// 起動したコンポーネントの停止
+// 起動した順に登録し、停止時は登録と逆の順（HTTPサーバー → バックグラウンドの処理 → ログ → 保存先 → DB）に閉じる
+// 停止の期限を過ぎた場合、残りのコンポーネントは閉じる処理を開始するだけで終了を待たない
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Group は停止時に閉じるコンポーネントの一覧
+type Group struct {
+	mu      sync.Mutex
+	closers []closer
+	once    sync.Once
+	err     error
+}
+
+type closer struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+// Add は停止時に閉じる処理を登録する（後から登録したものほど先に閉じる）
+func (g *Group) Add(name string, close func(ctx context.Context) error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closers = append(g.closers, closer{name, close})
+}
+
+// Shutdown は登録と逆の順にすべて閉じ、失敗したものをまとめて返す（2回目以降は何もしない）
+func (g *Group) Shutdown(ctx context.Context) error {
+	g.once.Do(func() {
+		g.mu.Lock()
+		closers := g.closers
+		g.mu.Unlock()
+
+		started := time.Now()
+		errs := []error{}
+		for i := len(closers) - 1; i >= 0; i-- {
+			c := closers[i]
+			stepStarted := time.Now()
+			if err := runUntil(ctx, c.close); err != nil {
+				slog.Error("failed to stop component", "component", c.name, "error", err, "duration", time.Since(stepStarted))
+				errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+				continue
+			}
+			slog.Info("stopped component", "component", c.name, "duration", time.Since(stepStarted))
+		}
+		g.err = errors.Join(errs...)
+		slog.Info("shutdown complete", "duration", time.Since(started), "failed", len(errs))
+	})
+	return g.err
+}
+
+// runUntil はcloseを実行し、ctxの期限を過ぎた場合は終了を待たずにエラーを返す
+func runUntil(ctx context.Context, close func(ctx context.Context) error) error {
+	done := make(chan error, 1)
+	go func() { done <- close(ctx) }()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WaitGroup はsync.WaitGroupをctxの期限まで待つ停止処理にする
+func WaitGroup(wg *sync.WaitGroup) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Func はctxを受け取らない停止処理（Close() errorなど）を登録できる形にする
+func Func(close func() error) func(ctx context.Context) error {
+	return func(context.Context) error { return close() }
+}
@@ -0,0 +1,91 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdown(t *testing.T) {
+	t.Run("登録と逆の順に閉じる", func(t *testing.T) {
+		var mu sync.Mutex
+		order := []string{}
+		record := func(name string) func(ctx context.Context) error {
+			return func(ctx context.Context) error {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, name)
+				return nil
+			}
+		}
+		g := &Group{}
+		g.Add("database", record("database"))
+		g.Add("storage", record("storage"))
+		g.Add("http", record("http"))
+
+		require.NoError(t, g.Shutdown(context.Background()))
+		assert.Equal(t, []string{"http", "storage", "database"}, order)
+
+		require.NoError(t, g.Shutdown(context.Background()))
+		assert.Len(t, order, 3, "2回目は何もしない")
+	})
+
+	t.Run("失敗しても残りを閉じる", func(t *testing.T) {
+		closed := false
+		g := &Group{}
+		g.Add("database", Func(func() error { closed = true; return nil }))
+		g.Add("storage", Func(func() error { return errors.New("boom") }))
+
+		err := g.Shutdown(context.Background())
+		assert.ErrorContains(t, err, "storage: boom")
+		assert.True(t, closed)
+	})
+
+	t.Run("期限を過ぎたら待たずに残りを開始する", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		started := make(chan struct{})
+		g := &Group{}
+		g.Add("database", Func(func() error { close(started); return nil }))
+		g.Add("workers", Func(func() error { <-release; return nil }))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := g.Shutdown(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("期限を過ぎた後の処理が開始されていない")
+		}
+	})
+
+	t.Run("WaitGroupの終了を待つ", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		done := false
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			done = true
+			wg.Done()
+		}()
+		g := &Group{}
+		g.Add("workers", WaitGroup(&wg))
+		require.NoError(t, g.Shutdown(context.Background()))
+		assert.True(t, done)
+	})
+	t.Run("WaitGroupは期限を過ぎたら待たない", func(t *testing.T) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		defer wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, WaitGroup(&wg)(ctx), context.DeadlineExceeded)
+	})
+}
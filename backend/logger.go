@@ -0,0 +1,35 @@
+package main
+
+// 構造化ログの設定
+// LOG_FORMAT=json（既定）はCloud Loggingが読めるよう、レベルをseverity、本文をmessageとして出力する
+// log.Printfなど従来のログも同じ出力先・形式になる
+
+import (
+	"io"
+	"log/slog"
+)
+
+// newLogger はformat（json / text）に応じたロガーを返す
+func newLogger(format string, w io.Writer) *slog.Logger {
+	if format == "text" {
+		return slog.New(slog.NewTextHandler(w, nil))
+	}
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) > 0 {
+				return a
+			}
+			switch a.Key {
+			case slog.LevelKey:
+				a.Key = "severity"
+				// Cloud LoggingはWARNではなくWARNINGを使う
+				if level, ok := a.Value.Any().(slog.Level); ok && level == slog.LevelWarn {
+					a.Value = slog.StringValue("WARNING")
+				}
+			case slog.MessageKey:
+				a.Key = "message"
+			}
+			return a
+		},
+	}))
+}
@@ -0,0 +1,118 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildMessage(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	data, err := buildMessage("CookMeet <noreply@example.com>", Message{
+		To:      "user@example.com",
+		Subject: "パスワードが変更されました",
+		Body:    "こんにちは\nパスワードが変更されました。",
+	}, now)
+	assert.NoError(t, err)
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, "user@example.com", parsed.Header.Get("To"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	assert.NoError(t, err)
+	assert.Equal(t, "パスワードが変更されました", subject)
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	assert.NoError(t, err)
+	assert.Equal(t, "こんにちは\r\nパスワードが変更されました。", string(body))
+}
+
+func TestBuildMessageHeaderInjection(t *testing.T) {
+	for _, msg := range []Message{
+		{To: "user@example.com\r\nBcc: victim@example.com", Subject: "s"},
+		{To: "user@example.com", Subject: "s\nBcc: victim@example.com"},
+		{To: "not an address", Subject: "s"},
+	} {
+		_, err := buildMessage("noreply@example.com", msg, time.Now())
+		assert.ErrorIs(t, err, ErrInvalidHeader)
+	}
+}
+
+// fakeSMTPServer は受信したメールを1通だけ返す最小限のSMTPサーバー
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					received <- data.String()
+					reply("250 OK")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				inData = true
+				reply("354 go ahead")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	m := NewSMTPMailer(SMTPConfig{Host: host, Port: port, From: "CookMeet <noreply@example.com>"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := m.Send(ctx, Message{To: "user@example.com", Subject: "test", Body: "hello"})
+	assert.NoError(t, err)
+
+	select {
+	case data := <-received:
+		assert.Contains(t, data, "To: user@example.com")
+		assert.Contains(t, data, "hello")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail received")
+	}
+}
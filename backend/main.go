@@ -1,97 +1,224 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"database/sql"
+	"errors"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"backend/auth"
+	"backend/config"
 	"backend/controller"
-	"backend/model"
+	"backend/db"
+	"backend/imageproc"
+	"backend/lifecycle"
+	"backend/mail"
 	"backend/repository"
 	"backend/router"
+	"backend/storage"
 	"backend/usecase"
 	"backend/validator"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func NewDB() (*gorm.DB, error) {
-	// 環境変数から接続情報を取得
-	host := os.Getenv("POSTGRES_HOST")
-	port := os.Getenv("POSTGRES_PORT")
-	user := os.Getenv("POSTGRES_USER")
-	password := os.Getenv("POSTGRES_PW")
-	dbname := os.Getenv("POSTGRES_DB")
-
-	// DSN を組み立て
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable "+
-			"TimeZone=Asia/Tokyo prefer_simple_protocol=true",
-		host, user, password, dbname, port,
-	)
-
-	db, err := gorm.Open(
-		postgres.New(postgres.Config{
-			DSN:                  dsn,
-			PreferSimpleProtocol: true,
-		}),
-		&gorm.Config{PrepareStmt: false},
-	)
-	if err != nil {
-		return nil, err
+// newLoginAttemptRepository はLOGIN_ATTEMPT_STOREに応じてログイン失敗回数の保存先を選ぶ
+// 複数インスタンスで共有できるよう、既定はPostgres
+func newLoginAttemptRepository(db *gorm.DB, store string) repository.ILoginAttemptRepository {
+	if store == "memory" {
+		return repository.NewMemoryLoginAttemptRepository()
 	}
-	return db, nil
+	return repository.NewLoginAttemptRepository(db)
 }
 
 func main() {
-	// アプリケーション設定を環境変数から取得
-	port := os.Getenv("PORT")
-	goEnv := os.Getenv("GO_ENV")
-	feURL := os.Getenv("FE_URL")
-	if port == "" {
-		port = "8081"
+	// migrate createはデータベースに接続しないため、設定を読み込む前に実行する
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		connect := func() (*sql.DB, error) {
+			cfg, err := config.Load()
+			if err != nil {
+				return nil, err
+			}
+			gdb, err := db.NewDB(cfg.DB)
+			if err != nil {
+				return nil, err
+			}
+			return gdb.DB()
+		}
+		if err := runMigrateCommand(os.Args[2:], connect, os.Stdout); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
 	}
 
-	log.Printf("env=%s, fe_url=%s, listening on :%s", goEnv, feURL, port)
-
-	db, err := NewDB()
+	// 設定を環境変数・.envファイル・YAMLファイルから読み込む（誤りはすべてまとめて表示する）
+	cfg, err := config.Load()
 	if err != nil {
 		log.Fatal(err)
 	}
-	success := false
-	defer func() {
-		if success {
-			log.Println("Successfully Migrated")
-		} else {
-			log.Println("Migration failed")
-		}
-	}()
+	slog.SetDefault(newLogger(cfg.LogFormat, os.Stderr))
+	slog.Info("starting", "env", cfg.Env, "fe_url", cfg.FrontendURL, "port", cfg.Port)
+
+	// 起動したコンポーネントは停止時に逆の順で閉じる
+	// 失敗した場合もfailで閉じてから終了する（os.Exitはdeferを実行しないため）
+	components := &lifecycle.Group{}
+	fail := func(msg string, err error) {
+		slog.Error(msg, "error", err)
+		shutdown(components, cfg.ShutdownTimeout)
+		os.Exit(1)
+	}
 
-	// マイグレーション
-	if err := db.AutoMigrate(&model.User{}, &model.Cuisine{}); err != nil {
-		log.Printf("Failed to migrate database: %v", err)
-		return
+	gdb, err := db.NewDB(cfg.DB)
+	if err != nil {
+		fail("failed to connect to database", err)
 	}
+	components.Add("database", lifecycle.Func(func() error { return db.CloseDB(gdb) }))
 
-	success = true
+	// スキーマが古い場合は起動しない（backend migrate upで適用する）
+	sqlDB, err := gdb.DB()
+	if err != nil {
+		fail("failed to get database connection", err)
+	}
+	if err := prepareSchema(context.Background(), sqlDB, cfg.MigrateOnStart); err != nil {
+		fail("failed to prepare database schema", err)
+	}
 
 	// 以下、従来どおりの初期化
-	userValidator := validator.NewUserValidator()
+	userValidator := validator.NewUserValidator(validator.NewPasswordPolicyFromConfig(cfg.PasswordPolicy))
 	cuisineValidator := validator.NewCuisineValidator()
+	tokenValidator := validator.NewPersonalAccessTokenValidator()
+
+	userRepo := repository.NewUserRepository(gdb)
+	cuisineRepo := repository.NewCuisineRepository(gdb)
+	loginAttemptRepo := newLoginAttemptRepository(gdb, cfg.LoginAttemptStore)
+	recoveryCodeRepo := repository.NewRecoveryCodeRepository(gdb)
+	userIdentityRepo := repository.NewUserIdentityRepository(gdb)
+	tokenRepo := repository.NewPersonalAccessTokenRepository(gdb)
+	sessionRepo := repository.NewSessionRepository(gdb)
+	auditRepo := repository.NewAuditEventRepository(gdb)
+	adminRepo := repository.NewAdminRepository(gdb)
+	emailChangeRepo := repository.NewEmailChangeRepository(gdb)
+	uploadRepo := repository.NewUploadRepository(gdb)
+	tusUploadRepo := repository.NewTusUploadRepository(gdb)
+	storageUsageRepo := repository.NewStorageUsageRepository(gdb)
+	storageUsageUC := usecase.NewStorageUsageUsecase(storageUsageRepo, cfg.StorageQuotaBytes)
+
+	objectStore, err := storage.New(context.Background(), cfg.Storage)
+	if err != nil {
+		fail("failed to initialize storage", err)
+	}
+	components.Add("storage", lifecycle.Func(objectStore.Close))
 
-	userRepo := repository.NewUserRepository(db)
-	cuisineRepo := repository.NewCuisineRepository(db)
-
-	userUC := usecase.NewUserUsecase(userRepo, userValidator)
-	cuisineUC := usecase.NewCuisineUsecase(cuisineRepo, cuisineValidator)
+	imageProcessor := imageproc.NewProcessor(cfg.ImageLimits)
+	storageGC := usecase.NewStorageGCUsecase(objectStore, repository.NewStorageReferenceRepository(gdb), storageUsageUC, cfg.Storage.Bucket)
+	if len(os.Args) > 1 && os.Args[1] == "gc-storage" {
+		if err := runStorageGCCommand(os.Args[2:], storageGC, os.Stdout); err != nil {
+			fail("gc-storage failed", err)
+		}
+		shutdown(components, cfg.ShutdownTimeout)
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "backfill-placeholders" {
+		backfill := usecase.NewPlaceholderBackfillUsecase(repository.NewCuisinePlaceholderRepository(gdb), objectStore, imageProcessor)
+		if err := runPlaceholderBackfillCommand(os.Args[2:], backfill, os.Stdout); err != nil {
+			fail("backfill-placeholders failed", err)
+		}
+		shutdown(components, cfg.ShutdownTimeout)
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "recompute-storage-usage" {
+		recompute := usecase.NewStorageUsageRecomputeUsecase(storageUsageRepo, objectStore)
+		if err := runStorageUsageRecomputeCommand(os.Args[2:], recompute, os.Stdout); err != nil {
+			fail("recompute-storage-usage failed", err)
+		}
+		shutdown(components, cfg.ShutdownTimeout)
+		return
+	}
 
-	userCtrl := controller.NewUserController(userUC)
+	auditLogger := usecase.NewAuditLogger(auditRepo, usecase.DefaultAuditBufferSize)
+	components.Add("audit logger", lifecycle.Func(func() error {
+		auditLogger.Close()
+		return nil
+	}))
+	loginGuard := usecase.NewLoginGuard(loginAttemptRepo, auditLogger, usecase.DefaultLockoutPolicy())
+	sessionManager := usecase.NewSessionManager(userRepo, sessionRepo, cfg.Secret)
+
+	// バックグラウンドの処理は停止時にctxを取り消し、終了を待つ
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	startStorageGC(workerCtx, storageGC, cfg.StorageGCInterval, &workers)
+	components.Add("background workers", func(ctx context.Context) error {
+		stopWorkers()
+		return lifecycle.WaitGroup(&workers)(ctx)
+	})
+
+	userUC := usecase.NewUserUsecase(userRepo, emailChangeRepo, userValidator, loginGuard, sessionManager, auditLogger, auth.NewArgon2Hasher(cfg.Argon2), mail.NewMailer(cfg.SMTP), objectStore, uploadRepo, storageUsageUC, cfg.Secret, cfg.FrontendURL)
+	imageResizeUC := usecase.NewImageResizeUsecase(objectStore, imageProcessor, cfg.ImageURL)
+	cuisineUC := usecase.NewCuisineUsecase(cuisineRepo, cuisineValidator, objectStore, imageProcessor, uploadRepo, imageResizeUC, storageUsageUC)
+	uploadUC := usecase.NewUploadUsecase(uploadRepo, objectStore, imageProcessor.Limits.MaxBytes, storageUsageUC)
+	tusUC := usecase.NewTusUsecase(tusUploadRepo, objectStore, imageProcessor.Limits.MaxBytes, storageUsageUC)
+	twoFactorUC := usecase.NewTwoFactorUsecase(userRepo, recoveryCodeRepo, loginGuard, sessionManager, auditLogger, cfg.Secret)
+	oidcUC := usecase.NewOIDCUsecase(userRepo, userIdentityRepo, sessionManager, auditLogger, auth.NewOIDCRegistry(cfg.OIDC), cfg.Secret)
+	tokenUC := usecase.NewPersonalAccessTokenUsecase(tokenRepo, tokenValidator, auditLogger)
+	adminUC := usecase.NewAdminUsecase(userRepo, adminRepo, sessionRepo, auditRepo, storageUsageUC)
+	securityEventUC := usecase.NewSecurityEventUsecase(auditRepo)
+
+	userCtrl := controller.NewUserController(userUC, cfg.APIDomain)
 	cuisineCtrl := controller.NewCuisineController(cuisineUC)
-
-	e := router.NewRouter(userCtrl, cuisineCtrl)
-
-	if err := e.Start(":" + port); err != nil {
-		log.Panicf("error: %s", err)
+	twoFactorCtrl := controller.NewTwoFactorController(twoFactorUC, cfg.APIDomain)
+	oidcCtrl := controller.NewOIDCController(oidcUC, cfg.FrontendURL, cfg.APIDomain)
+	tokenCtrl := controller.NewPersonalAccessTokenController(tokenUC)
+	adminCtrl := controller.NewAdminController(adminUC)
+	securityEventCtrl := controller.NewSecurityEventController(securityEventUC)
+	storageCtrl := controller.NewStorageController(objectStore)
+	uploadCtrl := controller.NewUploadController(uploadUC)
+	tusCtrl := controller.NewTusController(tusUC)
+	imageCtrl := controller.NewImageController(imageResizeUC)
+	storageUsageCtrl := controller.NewStorageUsageController(storageUsageUC)
+
+	authenticator := auth.NewAuthenticator(cfg.Secret, sessionManager, tokenUC)
+	e := router.NewRouter(userCtrl, cuisineCtrl, twoFactorCtrl, oidcCtrl, tokenCtrl, adminCtrl, securityEventCtrl, storageCtrl, uploadCtrl, tusCtrl, imageCtrl, storageUsageCtrl, authenticator, router.Config{FrontendURL: cfg.FrontendURL, CookieDomain: cfg.APIDomain})
+	e.HideBanner = true
+	e.HidePort = true
+	// 新しい接続の受け付けをやめ、処理中のリクエストの完了を待つ
+	components.Add("http server", e.Shutdown)
+
+	// SIGTERM（Cloud Runの停止）またはSIGINTを受けたら停止する
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serverErr := make(chan error, 1)
+	go func() { serverErr <- e.Start(":" + cfg.Port) }()
+	slog.Info("server started", "addr", ":"+cfg.Port)
+
+	code := 0
+	select {
+	case <-ctx.Done():
+		slog.Info("received shutdown signal")
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server stopped unexpectedly", "error", err)
+			code = 1
+		}
+	}
+	if err := shutdown(components, cfg.ShutdownTimeout); err != nil {
+		code = 1
 	}
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// shutdown は登録したコンポーネントをtimeout（SHUTDOWN_TIMEOUT）までに閉じる
+func shutdown(components *lifecycle.Group, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	slog.Info("shutting down", "timeout", timeout)
+	return components.Shutdown(ctx)
 }
@@ -0,0 +1,110 @@
+package main
+
+// データベースのマイグレーション
+// サブコマンド: backend migrate up | down N | status | create NAME
+// サーバーは未適用のマイグレーションがあると起動しない（MIGRATE_ON_START=trueの場合は起動時に適用する）
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"backend/migration"
+)
+
+const migrateUsage = "usage: migrate up | down N | status | create NAME"
+
+// runMigrateCommand はmigrateサブコマンドを実行する（createはデータベースに接続しない）
+func runMigrateCommand(args []string, connect func() (*sql.DB, error), out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	if args[0] == "create" {
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		paths, err := migration.Create(migration.Dir, args[1])
+		for _, p := range paths {
+			fmt.Fprintf(out, "created %s\n", p)
+		}
+		return err
+	}
+
+	migrations, err := migration.Embedded()
+	if err != nil {
+		return err
+	}
+	sqlDB, err := connect()
+	if err != nil {
+		return err
+	}
+	m := migration.NewMigrator(sqlDB, migrations)
+	ctx := context.Background()
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		applied, err := m.Up(ctx)
+		printMigrations(out, "applied", applied)
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(out, "already up to date")
+		}
+		return err
+	case args[0] == "down" && len(args) == 2:
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("N must be a positive number: %q", args[1])
+		}
+		reverted, err := m.Down(ctx, n)
+		printMigrations(out, "reverted", reverted)
+		return err
+	case args[0] == "status" && len(args) == 1:
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printMigrationStatus(out, statuses)
+		return nil
+	}
+	return errors.New(migrateUsage)
+}
+
+func printMigrations(out io.Writer, verb string, migrations []migration.Migration) {
+	for _, mig := range migrations {
+		fmt.Fprintf(out, "%s %04d_%s\n", verb, mig.Version, mig.Name)
+	}
+}
+
+func printMigrationStatus(out io.Writer, statuses []migration.Status) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+	w.Flush()
+}
+
+// prepareSchema はサーバーの起動前にスキーマが最新であることを確認する
+// migrateOnStart（MIGRATE_ON_START）の場合は未適用のマイグレーションを適用する（ローカルの開発向け）
+func prepareSchema(ctx context.Context, sqlDB *sql.DB, migrateOnStart bool) error {
+	migrations, err := migration.Embedded()
+	if err != nil {
+		return err
+	}
+	m := migration.NewMigrator(sqlDB, migrations)
+	if migrateOnStart {
+		applied, err := m.Up(ctx)
+		printMigrations(os.Stdout, "applied", applied)
+		return err
+	}
+	return m.CheckCurrent(ctx)
+}
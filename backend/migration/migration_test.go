@@ -0,0 +1,85 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func file(body string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(body)}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("バージョンの昇順に並べる", func(t *testing.T) {
+		migrations, err := Load(fstest.MapFS{
+			"0010_add_index.up.sql":        file("CREATE INDEX a;"),
+			"0010_add_index.down.sql":      file("DROP INDEX a;"),
+			"0002_create_users.up.sql":     file("CREATE TABLE users ();"),
+			"0002_create_users.down.sql":   file("DROP TABLE users;"),
+			"0003_add_users_name.up.sql":   file("ALTER TABLE users ADD name text;"),
+			"0003_add_users_name.down.sql": file("ALTER TABLE users DROP name;"),
+		})
+		require.NoError(t, err)
+		require.Len(t, migrations, 3)
+		assert.Equal(t, Migration{Version: 2, Name: "create_users", Up: "CREATE TABLE users ();", Down: "DROP TABLE users;"}, migrations[0])
+		assert.Equal(t, int64(3), migrations[1].Version)
+		assert.Equal(t, int64(10), migrations[2].Version, "数値として比べる")
+	})
+
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{"downがない", fstest.MapFS{"0001_a.up.sql": file("SELECT 1;")}, "must have both up and down"},
+		{"名前の形式が異なる", fstest.MapFS{"0001-a.up.sql": file("SELECT 1;")}, "invalid migration file name"},
+		{"拡張子が異なる", fstest.MapFS{"0001_a.up.txt": file("SELECT 1;")}, "invalid migration file name"},
+		{"バージョンが0", fstest.MapFS{"0000_a.up.sql": file("SELECT 1;"), "0000_a.down.sql": file("SELECT 1;")}, "invalid migration version"},
+		{"バージョンが重複", fstest.MapFS{
+			"0001_a.up.sql": file("SELECT 1;"), "0001_a.down.sql": file("SELECT 1;"),
+			"0001_b.up.sql": file("SELECT 1;"), "0001_b.down.sql": file("SELECT 1;"),
+		}, "duplicate migration version 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.files)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	migrations, err := Embedded()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	assert.Equal(t, Migration{Version: 1, Name: "initial_schema"}, Migration{Version: migrations[0].Version, Name: migrations[0].Name})
+	assert.Contains(t, migrations[0].Up, `CREATE TABLE IF NOT EXISTS "users"`)
+	assert.NotContains(t, migrations[0].Up, "totp_secret", "baselineより後に追加した列は0002で追加する")
+	for i := 1; i < len(migrations); i++ {
+		assert.Equal(t, migrations[i-1].Version+1, migrations[i].Version, "バージョンは連番にする")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	paths, err := Create(dir, "create_users")
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "0001_create_users.up.sql"), filepath.Join(dir, "0001_create_users.down.sql")}, paths)
+
+	paths, err = Create(dir, "add_users_name")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0002_add_users_name.up.sql"), paths[0])
+
+	migrations, err := Load(os.DirFS(dir))
+	require.NoError(t, err)
+	assert.Len(t, migrations, 2, "作成したファイルはそのまま読み込める")
+
+	_, err = Create(dir, "Add Column")
+	assert.ErrorContains(t, err, "migration name must match")
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "cuisines";
+DROP TABLE IF EXISTS "users";
@@ -0,0 +1,26 @@
+-- 最初のリリース（baseline）のAutoMigrateで作成していたスキーマ
+-- 本番のデータベースには既に存在するため、IF NOT EXISTSで作成する
+-- 以降に追加した列・テーブルは0002・0003で追加する（ここには追加しない）
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" bigserial,
+    "name" text,
+    "email" text,
+    "password" text,
+    "icon_url" text,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_users_email" UNIQUE ("email")
+);
+
+CREATE TABLE IF NOT EXISTS "cuisines" (
+    "id" bigserial,
+    "title" text NOT NULL,
+    "icon_url" text,
+    "url" text,
+    "comment" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "user_id" bigint NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_cuisines_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
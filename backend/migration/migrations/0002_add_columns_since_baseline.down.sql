@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS "idx_cuisines_image_hash";
+ALTER TABLE "cuisines" DROP COLUMN IF EXISTS "image_hash";
+ALTER TABLE "cuisines" DROP COLUMN IF EXISTS "dominant_color";
+ALTER TABLE "cuisines" DROP COLUMN IF EXISTS "blur_hash";
+
+ALTER TABLE "users" DROP COLUMN IF EXISTS "created_at";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "disabled_at";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "role";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "totp_last_step";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "totp_enabled";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "totp_secret";
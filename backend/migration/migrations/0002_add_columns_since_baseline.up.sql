@@ -0,0 +1,14 @@
+-- baselineのテーブルにAutoMigrateで追加していた列
+-- 0001はbaselineのテーブルが既にあると何もしないため、列はここで追加する（AutoMigrateで追加済みの列はそのまま）
+
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_secret" text;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_enabled" boolean NOT NULL DEFAULT false;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_last_step" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "role" text NOT NULL DEFAULT 'user';
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "disabled_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "created_at" timestamptz;
+
+ALTER TABLE "cuisines" ADD COLUMN IF NOT EXISTS "blur_hash" text;
+ALTER TABLE "cuisines" ADD COLUMN IF NOT EXISTS "dominant_color" text;
+ALTER TABLE "cuisines" ADD COLUMN IF NOT EXISTS "image_hash" text;
+CREATE INDEX IF NOT EXISTS "idx_cuisines_image_hash" ON "cuisines" ("image_hash");
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS "storage_usages";
+DROP TABLE IF EXISTS "tus_uploads";
+DROP TABLE IF EXISTS "uploads";
+DROP TABLE IF EXISTS "email_changes";
+DROP TABLE IF EXISTS "audit_events";
+DROP TABLE IF EXISTS "sessions";
+DROP TABLE IF EXISTS "personal_access_tokens";
+DROP TABLE IF EXISTS "user_identities";
+DROP TABLE IF EXISTS "recovery_codes";
+DROP TABLE IF EXISTS "login_attempts";
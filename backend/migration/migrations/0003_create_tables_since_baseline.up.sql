@@ -0,0 +1,141 @@
+-- AutoMigrateで追加していたテーブル
+-- AutoMigrateで作成済みのデータベースにも適用できるよう、IF NOT EXISTSで作成する
+
+CREATE TABLE IF NOT EXISTS "login_attempts" (
+    "key" text,
+    "failures" bigint NOT NULL DEFAULT 0,
+    "last_failed_at" timestamptz,
+    "locked_until" timestamptz,
+    PRIMARY KEY ("key")
+);
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "code_hash" text NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_recovery_codes_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "user_identities" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "provider" text NOT NULL,
+    "subject" text NOT NULL,
+    "email" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_user_identities_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_identity_subject" ON "user_identities" ("provider", "subject");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_user_identity_user_provider" ON "user_identities" ("user_id", "provider");
+
+CREATE TABLE IF NOT EXISTS "personal_access_tokens" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "name" text NOT NULL,
+    "token_hash" text NOT NULL,
+    "prefix" text NOT NULL,
+    "scopes" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "last_used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_personal_access_tokens_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_personal_access_tokens_token_hash" ON "personal_access_tokens" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_personal_access_tokens_user_id" ON "personal_access_tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "sessions" (
+    "id" text,
+    "user_id" bigint NOT NULL,
+    "ip" text,
+    "user_agent" text,
+    "created_at" timestamptz,
+    "last_seen_at" timestamptz NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "revoked_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_sessions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_sessions_last_seen_at" ON "sessions" ("last_seen_at");
+CREATE INDEX IF NOT EXISTS "idx_sessions_user_id" ON "sessions" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "audit_events" (
+    "id" bigserial,
+    "actor_id" bigint,
+    "action" text NOT NULL,
+    "target_user_id" bigint,
+    "ip" text,
+    "user_agent" text,
+    "metadata" jsonb NOT NULL DEFAULT '{}',
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_audit_events_actor_id" ON "audit_events" ("actor_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_created_at" ON "audit_events" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_target_user_id" ON "audit_events" ("target_user_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_action" ON "audit_events" ("action");
+
+CREATE TABLE IF NOT EXISTS "email_changes" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "old_email" text NOT NULL,
+    "new_email" text NOT NULL,
+    "nonce" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "confirmed_at" timestamptz,
+    "undone_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_email_changes_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_email_changes_nonce" ON "email_changes" ("nonce");
+CREATE INDEX IF NOT EXISTS "idx_email_changes_user_id" ON "email_changes" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "uploads" (
+    "id" text,
+    "user_id" bigint NOT NULL,
+    "purpose" text NOT NULL,
+    "key" text NOT NULL,
+    "content_type" text NOT NULL,
+    "size" bigint NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "completed_at" timestamptz,
+    "consumed_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_uploads_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_uploads_expires_at" ON "uploads" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_uploads_user_id" ON "uploads" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "tus_uploads" (
+    "id" text,
+    "user_id" bigint NOT NULL,
+    "purpose" text NOT NULL,
+    "filename" text,
+    "length" bigint NOT NULL,
+    "offset" bigint NOT NULL,
+    "chunk_keys" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "completed_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_tus_uploads_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS "idx_tus_uploads_expires_at" ON "tus_uploads" ("expires_at");
+CREATE INDEX IF NOT EXISTS "idx_tus_uploads_user_id" ON "tus_uploads" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "storage_usages" (
+    "user_id" bigint,
+    "cuisine_image_bytes" bigint NOT NULL DEFAULT 0,
+    "user_icon_bytes" bigint NOT NULL DEFAULT 0,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("user_id"),
+    CONSTRAINT "fk_storage_usages_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
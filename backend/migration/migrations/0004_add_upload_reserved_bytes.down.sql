@@ -0,0 +1,2 @@
+ALTER TABLE "tus_uploads" DROP COLUMN IF EXISTS "reserved_bytes";
+ALTER TABLE "uploads" DROP COLUMN IF EXISTS "reserved_bytes";
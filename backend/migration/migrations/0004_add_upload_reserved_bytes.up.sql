@@ -0,0 +1,2 @@
+ALTER TABLE "uploads" ADD COLUMN IF NOT EXISTS "reserved_bytes" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "tus_uploads" ADD COLUMN IF NOT EXISTS "reserved_bytes" bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- 書き換える前のURLは残していない（署名付きURLはすでに失効している）ため戻さない
+SELECT 1;
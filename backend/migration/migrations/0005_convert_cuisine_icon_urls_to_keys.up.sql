@@ -0,0 +1,8 @@
+-- 料理画像のURLをオブジェクトのキー（images/<ユーザーID>/<ファイル名>）に書き換える
+-- 以前はアップロード時に発行した署名付きURL（7日で失効）を保存していた。保存先の形式（パス形式・仮想ホスト形式・ローカル）によらずパスの末尾から取り出す
+-- 料理の作成者のキーを指していないURLはそのまま残す（画像なしとして扱う）
+
+UPDATE "cuisines"
+SET "icon_url" = substring("icon_url" from '/(images/[0-9]+/[^/?#]+)(?:[?#].*)?$')
+WHERE "icon_url" LIKE '%://%'
+  AND "icon_url" ~ ('/images/' || "user_id" || '/[^/?#]+([?#].*)?$');
@@ -0,0 +1,2 @@
+-- 平文のパスワードは残していないため戻さない（pgcryptoは他で使われている場合があるため削除しない）
+SELECT 1;
@@ -0,0 +1,10 @@
+-- 以前のユーザー情報の更新で平文のまま保存されたパスワードをbcryptでハッシュ化する
+-- bcryptのハッシュは次のログイン時にargon2idで再ハッシュされる
+-- ハッシュの形式（argon2id・bcrypt）でない値を平文とみなす（パスワードのないOIDCのみのユーザーは対象にしない）
+
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+UPDATE "users"
+SET "password" = crypt("password", gen_salt('bf', 10))
+WHERE "password" <> ''
+  AND "password" !~ '^\$(argon2id|2[aby])\$';
@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// AdminUserResponse は管理画面のユーザー一覧の1件
+type AdminUserResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	DisabledAt *time.Time `json:"disabled_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// AdminUserList はユーザー一覧のページ
+type AdminUserList struct {
+	Users   []AdminUserResponse `json:"users"`
+	Total   int64               `json:"total"`
+	Page    int                 `json:"page"`
+	PerPage int                 `json:"per_page"`
+}
+
+// AdminUserDetail はユーザーの詳細と利用状況
+type AdminUserDetail struct {
+	AdminUserResponse
+	CuisineCount int64                `json:"cuisine_count"`
+	Storage      StorageUsageResponse `json:"storage"` // 保存先の使用量（料理画像・アイコン・合計のバイト数）
+}
+
+// DailyCount は日ごとの件数
+type DailyCount struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Count int64  `json:"count"`
+}
+
+// AdminMetrics は全体の利用状況
+type AdminMetrics struct {
+	TotalUsers int64        `json:"total_users"`
+	DAU        int64        `json:"dau"` // 直近24時間のアクティブユーザー数
+	MAU        int64        `json:"mau"` // 直近30日のアクティブユーザー数
+	SignUps    []DailyCount `json:"sign_ups"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEvent は監査ログの1件（追記のみで、更新・削除はしない）
+type AuditEvent struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	ActorID      *uint     `json:"actor_id" gorm:"index"` // 操作したユーザー（未ログインの操作ではnil）
+	Action       string    `json:"action" gorm:"not null;index"`
+	TargetUserID *uint     `json:"target_user_id" gorm:"index"` // 操作対象のユーザー
+	IP           string    `json:"ip"`
+	UserAgent    string    `json:"user_agent"`
+	Metadata     string    `json:"metadata" gorm:"type:jsonb;not null;default:'{}'"`
+	CreatedAt    time.Time `json:"created_at" gorm:"index"`
+}
+
+// SecurityEventResponse は本人に表示するセキュリティイベント
+type SecurityEventResponse struct {
+	ID        uint            `json:"id"`
+	Action    string          `json:"action"`
+	IP        string          `json:"ip"`
+	UserAgent string          `json:"user_agent"`
+	Metadata  json.RawMessage `json:"metadata"`
+	CreatedAt time.Time       `json:"created_at"`
+}
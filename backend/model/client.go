@@ -0,0 +1,7 @@
+package model
+
+// ClientInfo はリクエスト元の情報（セキュリティ関連の記録に使用）
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
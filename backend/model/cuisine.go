@@ -3,24 +3,69 @@ package model
 import "time"
 
 type Cuisine struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`  // 主キーになる
-	Title     string    `json:"title" gorm:"not null"` // 空の値を許可しない
-	IconURL   *string   `json:"icon_url"`
-	URL       string    `json:"url"`
-	Comment   string    `json:"comment"` // コメント追加
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	UserID    uint      `json:"user_id" gorm:"not null"`
-	User      User      `json:"user" gorm:"foreignKey:UserID; constraint:OnDelete:CASCADE"` // userを削除したときにuserに紐づいている料理も消去される
+	ID            uint      `json:"id" gorm:"primaryKey"`  // 主キーになる
+	Title         string    `json:"title" gorm:"not null"` // 空の値を許可しない
+	IconURL       *string   `json:"icon_url"`              // 画像（元のサイズ）のオブジェクトのキー（URLはレスポンスを作成する時に署名する）
+	BlurHash      string    `json:"blur_hash"`             // 画像の読み込み中に表示するBlurHash（画像がない・求めていない場合は空）
+	DominantColor string    `json:"dominant_color"`        // 画像の代表色（#rrggbb）
+	ImageHash     string    `json:"-" gorm:"index"`        // 重複を見つけるための画像の知覚ハッシュ（16桁の16進数）
+	URL           string    `json:"url"`
+	Comment       string    `json:"comment"` // コメント追加
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	UserID        uint      `json:"user_id" gorm:"not null"`
+	User          User      `json:"user" gorm:"foreignKey:UserID; constraint:OnDelete:CASCADE"` // userを削除したときにuserに紐づいている料理も消去される
 }
 
 type CuisineResponse struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`  // 主キーになる
-	Title     string    `json:"title" gorm:"not null"` // 空の値を許可しない
-	IconURL   *string   `json:"icon_url"`
-	URL       string    `json:"url"`
-	Comment   string    `json:"comment"` // コメント追加
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	UserID    uint      `json:"user_id"`
+	ID            uint              `json:"id" gorm:"primaryKey"`  // 主キーになる
+	Title         string            `json:"title" gorm:"not null"` // 空の値を許可しない
+	IconURL       *string           `json:"icon_url"`              // 期限付きの署名付きURL（images.fullと同じ）
+	Images        *CuisineImageURLs `json:"images,omitempty"`
+	BlurHash      string            `json:"blur_hash,omitempty"`      // 署名付きURLの画像を読み込むまでに表示する
+	DominantColor string            `json:"dominant_color,omitempty"` // #rrggbb
+	URL           string            `json:"url"`
+	Comment       string            `json:"comment"` // コメント追加
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	UserID        uint              `json:"user_id"`
+	// PossibleDuplicates は追加した料理の画像とほぼ同じ画像の料理（追加時のみ返す）
+	PossibleDuplicates []CuisineDuplicateMatch `json:"possible_duplicates,omitempty"`
+}
+
+// CuisineImage は保存した料理画像
+type CuisineImage struct {
+	Key            string // 元のサイズのキー
+	BlurHash       string
+	DominantColor  string
+	PerceptualHash string
+}
+
+// 重複している可能性のある料理のまとめ方
+const (
+	CuisineDuplicateByImage    = "image"     // 画像がほぼ同じ
+	CuisineDuplicateByTitleURL = "title_url" // 正規化したタイトルとURLが同じ
+)
+
+// CuisineDuplicateMatch は画像がほぼ同じ料理
+type CuisineDuplicateMatch struct {
+	ID       uint   `json:"id"`
+	Title    string `json:"title"`
+	Distance int    `json:"distance"` // 知覚ハッシュのハミング距離（0は同じ）
+}
+
+// CuisineDuplicateGroup は重複している可能性のある料理のまとまり
+type CuisineDuplicateGroup struct {
+	Reason   string            `json:"reason"` // image / title_url
+	Cuisines []CuisineResponse `json:"cuisines"`
+}
+
+// CuisineImageURLs はサイズごとの画像の署名付きURL
+// 以前にアップロードした画像は変換していないため、すべて同じURLになる
+type CuisineImageURLs struct {
+	Thumbnail string `json:"thumbnail"` // 長辺200px
+	Medium    string `json:"medium"`    // 長辺800px
+	Full      string `json:"full"`      // 長辺2048px
+	// Presets はプリセット（list・detail・og）ごとのリサイズした画像のURL（署名済み、期限なし）
+	Presets map[string]string `json:"presets,omitempty"`
 }
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// EmailChange はメールアドレスの変更申請
+// 新しいアドレスで確認されるまでは反映せず、反映後も一定期間は元に戻せる
+type EmailChange struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	User        User       `json:"-" gorm:"foreignKey:UserID; constraint:OnDelete:CASCADE"`
+	OldEmail    string     `json:"old_email" gorm:"not null"`
+	NewEmail    string     `json:"new_email" gorm:"not null"`
+	Nonce       string     `json:"-" gorm:"not null;uniqueIndex"` // 確認・取り消しのリンクに署名して含める値
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null"`    // 確認の期限
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	UndoneAt    *time.Time `json:"undone_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// EmailChangeTokenRequest は確認・取り消しのリンクに含まれるトークン
+type EmailChangeTokenRequest struct {
+	Token string `json:"token" form:"token"`
+}
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// LoginAttempt はアカウントまたはIPごとのログイン失敗回数とロック状態
+type LoginAttempt struct {
+	Key          string     `json:"key" gorm:"primaryKey"` // "account:<email>" または "ip:<ip>"
+	Failures     int        `json:"failures" gorm:"not null;default:0"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until"`
+}
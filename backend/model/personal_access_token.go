@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// PersonalAccessToken はスクリプトや外部連携からAPIを利用するためのトークン（ハッシュのみ保存する）
+type PersonalAccessToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	User       User       `json:"-" gorm:"foreignKey:UserID; constraint:OnDelete:CASCADE"`
+	Name       string     `json:"name" gorm:"not null"`
+	TokenHash  string     `json:"-" gorm:"not null;uniqueIndex"`
+	Prefix     string     `json:"prefix" gorm:"not null"` // 一覧でトークンを見分けるための先頭部分
+	Scopes     string     `json:"scopes" gorm:"not null"` // スペース区切り
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// PersonalAccessTokenRequest はトークン作成時のリクエスト
+type PersonalAccessTokenRequest struct {
+	Name          string   `json:"name" form:"name"`
+	Scopes        []string `json:"scopes" form:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days" form:"expires_in_days"` // 省略時は30日
+}
+
+type PersonalAccessTokenResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// PersonalAccessTokenCreated は作成時のレスポンス（トークンはこの時だけ返す）
+type PersonalAccessTokenCreated struct {
+	PersonalAccessTokenResponse
+	Token string `json:"token"`
+}
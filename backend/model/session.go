@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Session はログインセッション（jwtのsidに対応する）
+// 強制ログアウトやアクティブユーザー数の集計のためにサーバー側でも保持する
+type Session struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	User       User       `json:"-" gorm:"foreignKey:UserID; constraint:OnDelete:CASCADE"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at" gorm:"not null;index"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// StorageUsage はユーザーごとの保存先の使用量（バイト数）
+// 料理画像（すべてのサイズ）とアイコンの保存・削除で更新し、ずれた場合はrecompute-storage-usageで計算し直す
+type StorageUsage struct {
+	UserID            uint      `json:"-" gorm:"primaryKey"`
+	User              User      `json:"-" gorm:"foreignKey:UserID; constraint:OnDelete:CASCADE"`
+	CuisineImageBytes int64     `json:"cuisine_image_bytes" gorm:"not null;default:0"`
+	UserIconBytes     int64     `json:"user_icon_bytes" gorm:"not null;default:0"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// TotalBytes は使用量の合計を返す
+func (u StorageUsage) TotalBytes() int64 {
+	return u.CuisineImageBytes + u.UserIconBytes
+}
+
+// StoredObjectOwner はデータベースから参照されているオブジェクトと所有者（使用量の計算し直しで使う）
+type StoredObjectOwner struct {
+	UserID uint
+	Key    string
+}
+
+// UploadReservation はアップロードの作成時に使用量に加えた大きさ
+type UploadReservation struct {
+	UserID  uint
+	Purpose string // model.UploadPurpose*（使用量の種類）
+	Bytes   int64
+}
+
+type StorageUsageResponse struct {
+	UsedBytes         int64  `json:"used_bytes"`
+	CuisineImageBytes int64  `json:"cuisine_image_bytes"`
+	UserIconBytes     int64  `json:"user_icon_bytes"`
+	QuotaBytes        int64  `json:"quota_bytes"`               // 0は無制限
+	RemainingBytes    *int64 `json:"remaining_bytes,omitempty"` // 無制限の場合は返さない
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// TusUpload はtusプロトコルによる再開可能なアップロードの進捗
+// 受け取った部分（PATCH）ごとに保存先へ保存し、そのキーを順に記録する
+// すべて受け取ると1つのファイルにまとめ、同じIDのUpload（完了済み）を作成する
+type TusUpload struct {
+	ID            string     `json:"id" gorm:"primaryKey"` // uuid（完了後はupload_idとして指定する）
+	UserID        uint       `json:"user_id" gorm:"not null;index"`
+	User          User       `json:"-" gorm:"foreignKey:UserID; constraint:OnDelete:CASCADE"`
+	Purpose       string     `json:"purpose" gorm:"not null"`
+	Filename      string     `json:"filename"`                    // Upload-Metadataのfilename（記録のみ）
+	Length        int64      `json:"length" gorm:"not null"`      // Upload-Length
+	ReservedBytes int64      `json:"-" gorm:"not null;default:0"` // 作成時に使用量に加えた大きさ（完了するとUploadに引き継いで0にする）
+	Offset        int64      `json:"offset" gorm:"not null"`      // 受け取ったバイト数
+	ChunkKeys     string     `json:"-" gorm:"not null"`           // 受け取った部分のキー（改行区切り、先頭から順に）
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"not null;index"`
+	CompletedAt   *time.Time `json:"completed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TusCreateRequest はtusのアップロードの作成時のリクエスト（ヘッダーから作成する）
+type TusCreateRequest struct {
+	Purpose  string // Upload-Metadataのpurpose（cuisine_image / user_icon）
+	Filename string
+	Length   int64
+}
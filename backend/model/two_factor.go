@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// RecoveryCode は認証アプリを使えないときのための使い捨てコード（ハッシュのみ保存する）
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	User      User       `json:"-" gorm:"foreignKey:UserID; constraint:OnDelete:CASCADE"`
+	CodeHash  string     `json:"-" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TwoFactorEnrollment は二要素認証の登録開始時に返す情報
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+	QRCode []byte `json:"qr_code_png"` // base64でエンコードされる
+}
+
+// TwoFactorConfirmation は二要素認証の有効化時に返す情報
+type TwoFactorConfirmation struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
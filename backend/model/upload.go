@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+// アップロードの用途
+const (
+	UploadPurposeCuisineImage = "cuisine_image"
+	UploadPurposeUserIcon     = "user_icon"
+)
+
+// アップロードの状態（レスポンスのみ。保存はCompletedAt・ConsumedAtで行う）
+const (
+	UploadStatusPending   = "pending"   // アップロード待ち
+	UploadStatusCompleted = "completed" // 確認済み（料理の追加・アイコンの変更で使える）
+	UploadStatusConsumed  = "consumed"  // 使用済み
+	UploadStatusExpired   = "expired"
+)
+
+// Upload はクライアントが保存先へ直接アップロードするためのセッション
+// 署名付きURLでアップロードした後に完了を通知し、大きさと種類を確認してから使えるようにする
+type Upload struct {
+	ID            string     `json:"id" gorm:"primaryKey"` // uuid（料理の追加・アイコンの変更でupload_idとして指定する）
+	UserID        uint       `json:"user_id" gorm:"not null;index"`
+	User          User       `json:"-" gorm:"foreignKey:UserID; constraint:OnDelete:CASCADE"`
+	Purpose       string     `json:"purpose" gorm:"not null"`
+	Key           string     `json:"-" gorm:"not null"` // uploads/<ユーザーID>/<ID>.<拡張子>
+	ContentType   string     `json:"content_type" gorm:"not null"`
+	Size          int64      `json:"size" gorm:"not null"`             // 作成時に申告された大きさ
+	ReservedBytes int64      `json:"-" gorm:"not null;default:0"`      // 作成時に使用量に加えた大きさ（使用・期限切れで使用量から減らすと0にする）
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"not null;index"` // 完了・使用の期限
+	CompletedAt   *time.Time `json:"completed_at"`
+	ConsumedAt    *time.Time `json:"consumed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// UploadRequest はアップロードの作成時のリクエスト
+type UploadRequest struct {
+	Purpose     string `json:"purpose" form:"purpose"`           // cuisine_image / user_icon
+	ContentType string `json:"content_type" form:"content_type"` // image/jpeg / image/png / image/gif / image/webp
+	Size        int64  `json:"size" form:"size"`                 // バイト数
+}
+
+type UploadResponse struct {
+	ID        string            `json:"id"`
+	Purpose   string            `json:"purpose"`
+	Status    string            `json:"status"`
+	UploadURL string            `json:"upload_url,omitempty"` // 作成時のみ返す
+	Method    string            `json:"method,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"` // アップロード時に付けるヘッダー
+	Size      int64             `json:"size"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
@@ -1,16 +1,39 @@
 package model
 
+import "time"
+
 type User struct {
-	ID       uint    `json:"id" gorm:"primaryKey"` // 主キーになる
-	Name     string  `json:"name"`
-	Email    string  `json:"email" gorm:"unique"` // 重複を許さない
-	Password string  `json:"password"`
-	IconURL  *string `json:"icon_url"`
+	ID           uint       `json:"id" gorm:"primaryKey"` // 主キーになる
+	Name         string     `json:"name"`
+	Email        string     `json:"email" gorm:"unique"` // 重複を許さない
+	Password     string     `json:"password"`
+	IconURL      *string    `json:"icon_url"`
+	TOTPSecret   *string    `json:"-"`                                // 二要素認証の共有鍵（確認前も保持する）
+	TOTPEnabled  bool       `json:"-" gorm:"not null;default:false"`  // 二要素認証が有効か
+	TOTPLastStep int64      `json:"-" gorm:"not null;default:0"`      // 最後に使用したコードのステップ（再利用防止）
+	Role         string     `json:"-" gorm:"not null;default:'user'"` // user または admin
+	DisabledAt   *time.Time `json:"-"`                                // 管理者により無効化された日時
+	CreatedAt    time.Time  `json:"-"`
 }
 
 type UserResponse struct {
-	ID      uint    `json:"id" gorm:"primaryKey"`
-	Name    string  `json:"name"`
-	Email   string  `json:"email" gorm:"unique"`
-	IconURL *string `json:"icon_url"`
+	ID           uint    `json:"id" gorm:"primaryKey"`
+	Name         string  `json:"name"`
+	Email        string  `json:"email" gorm:"unique"`
+	PendingEmail string  `json:"pending_email,omitempty"` // 確認待ちの新しいメールアドレス
+	IconURL      *string `json:"icon_url"`
+}
+
+// LoginResult はログインの結果
+// 二要素認証が有効な場合はセッションの代わりにMFAトークンを返す
+type LoginResult struct {
+	Token       string `json:"-"`
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
+
+// PasswordChangeRequest はパスワード変更のリクエスト
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
 }
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// UserIdentity は外部のOIDCプロバイダーのアカウントとユーザーの紐付け
+// プロバイダー内ではsubjectで一意になる。1ユーザーにつき1プロバイダー1アカウントまで
+type UserIdentity struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_user_identity_user_provider"`
+	User      User      `json:"-" gorm:"foreignKey:UserID; constraint:OnDelete:CASCADE"`
+	Provider  string    `json:"provider" gorm:"not null;uniqueIndex:idx_user_identity_subject;uniqueIndex:idx_user_identity_user_provider"`
+	Subject   string    `json:"-" gorm:"not null;uniqueIndex:idx_user_identity_subject"`
+	Email     string    `json:"email"` // 連携時点のプロバイダー側のメールアドレス（表示用）
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserIdentityResponse struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OIDCCallbackResult はOIDCのコールバックの結果
+// Linkはログイン中のユーザーへの連携として開始されたフローかどうか
+type OIDCCallbackResult struct {
+	LoginResult
+	Link bool
+}
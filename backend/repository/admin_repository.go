@@ -0,0 +1,80 @@
+package repository
+
+// 管理者向けのユーザー検索・無効化と、利用状況の集計
+
+import (
+	"backend/model"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IAdminRepository interface {
+	SearchUsers(query string, offset int, limit int) ([]model.User, int64, error) // 名前・メールアドレスの部分一致
+	SetUserDisabled(userID uint, disabledAt *time.Time) error
+	CountCuisines(userID uint) (int64, error)
+	CountUsers() (int64, error)
+	CountSignUpsByDay(since time.Time) ([]model.DailyCount, error)
+}
+
+type adminRepository struct {
+	db *gorm.DB
+}
+
+func NewAdminRepository(db *gorm.DB) IAdminRepository {
+	return &adminRepository{db}
+}
+
+func (ar *adminRepository) SearchUsers(query string, offset int, limit int) ([]model.User, int64, error) {
+	tx := ar.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.User{})
+	if query != "" {
+		// LIKEの特殊文字はエスケープして、文字どおりに検索する
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(query)) + "%"
+		tx = tx.Where("LOWER(name) LIKE ? OR LOWER(email) LIKE ?", pattern, pattern)
+	}
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	users := []model.User{}
+	if err := tx.Order("id").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (ar *adminRepository) SetUserDisabled(userID uint, disabledAt *time.Time) error {
+	result := ar.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.User{}).
+		Where("id = ?", userID).Update("disabled_at", disabledAt)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (ar *adminRepository) CountCuisines(userID uint) (int64, error) {
+	var count int64
+	err := ar.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.Cuisine{}).
+		Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func (ar *adminRepository) CountUsers() (int64, error) {
+	var count int64
+	err := ar.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.User{}).Count(&count).Error
+	return count, err
+}
+
+func (ar *adminRepository) CountSignUpsByDay(since time.Time) ([]model.DailyCount, error) {
+	counts := []model.DailyCount{}
+	err := ar.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.User{}).
+		Select("TO_CHAR(created_at, 'YYYY-MM-DD') AS date, COUNT(*) AS count").
+		Where("created_at >= ?", since).
+		Group("date").Order("date").
+		Scan(&counts).Error
+	return counts, err
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestAdminRepository(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	repo := NewAdminRepository(db)
+	user := CreateTestUser(db)
+	assert.NoError(t, db.Create(&model.User{Name: "Alice", Email: "alice@example.com"}).Error)
+	assert.NoError(t, db.Create(&model.User{Name: "100% Bob", Email: "bob@example.com"}).Error)
+
+	icon := "cuisine_icons/a.jpg"
+	assert.NoError(t, db.Create(&model.Cuisine{Title: "curry", UserID: user.ID, IconURL: &icon}).Error)
+	assert.NoError(t, db.Create(&model.Cuisine{Title: "rice", UserID: user.ID}).Error)
+
+	t.Run("検索", func(t *testing.T) {
+		users, total, err := repo.SearchUsers("ALICE", 0, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, "alice@example.com", users[0].Email)
+
+		// %は文字どおりに扱う
+		_, total, err = repo.SearchUsers("%", 0, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+	})
+
+	t.Run("ページング", func(t *testing.T) {
+		users, total, err := repo.SearchUsers("", 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Len(t, users, 1)
+	})
+
+	t.Run("料理の数", func(t *testing.T) {
+		count, err := repo.CountCuisines(user.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("無効化", func(t *testing.T) {
+		now := time.Now()
+		assert.NoError(t, repo.SetUserDisabled(user.ID, &now))
+		assert.ErrorIs(t, repo.SetUserDisabled(9999, &now), gorm.ErrRecordNotFound)
+	})
+
+	t.Run("登録数", func(t *testing.T) {
+		counts, err := repo.CountSignUpsByDay(time.Now().Add(-24 * time.Hour))
+		assert.NoError(t, err)
+		var sum int64
+		for _, c := range counts {
+			sum += c.Count
+		}
+		assert.Equal(t, int64(3), sum)
+	})
+}
@@ -0,0 +1,37 @@
+package repository
+
+// 監査ログの追記と、ユーザーごとのセキュリティイベントの取得
+
+import (
+	"backend/model"
+
+	"gorm.io/gorm"
+)
+
+type IAuditEventRepository interface {
+	CreateAuditEvent(event *model.AuditEvent) error
+	GetEventsByTargetUser(userID uint, actions []string, limit int) ([]model.AuditEvent, error) // 新しい順
+}
+
+type auditEventRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditEventRepository(db *gorm.DB) IAuditEventRepository {
+	return &auditEventRepository{db}
+}
+
+func (ar *auditEventRepository) CreateAuditEvent(event *model.AuditEvent) error {
+	if event.Metadata == "" {
+		event.Metadata = "{}"
+	}
+	return ar.db.Session(&gorm.Session{PrepareStmt: false}).Create(event).Error
+}
+
+func (ar *auditEventRepository) GetEventsByTargetUser(userID uint, actions []string, limit int) ([]model.AuditEvent, error) {
+	events := []model.AuditEvent{}
+	err := ar.db.Session(&gorm.Session{PrepareStmt: false}).
+		Where("target_user_id = ? AND action IN ?", userID, actions).
+		Order("created_at DESC, id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"testing"
+
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditEvents(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	repo := NewAuditEventRepository(db)
+	user := CreateTestUser(db)
+	other := model.User{Name: "other", Email: "other@example.com"}
+	assert.NoError(t, db.Create(&other).Error)
+
+	events := []model.AuditEvent{
+		{ActorID: &user.ID, TargetUserID: &user.ID, Action: "auth.login"},
+		{TargetUserID: &user.ID, Action: "auth.login_failed", Metadata: `{"reason":"invalid_password"}`},
+		{ActorID: &other.ID, TargetUserID: &user.ID, Action: "admin.users.view"},
+		{ActorID: &other.ID, TargetUserID: &other.ID, Action: "auth.login"},
+	}
+	for i := range events {
+		assert.NoError(t, repo.CreateAuditEvent(&events[i]))
+	}
+	assert.Equal(t, "{}", events[0].Metadata)
+
+	got, err := repo.GetEventsByTargetUser(user.ID, []string{"auth.login", "auth.login_failed"}, 10)
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+	assert.Equal(t, "auth.login_failed", got[0].Action, "新しい順に返す")
+	assert.JSONEq(t, `{"reason":"invalid_password"}`, got[0].Metadata)
+
+	got, err = repo.GetEventsByTargetUser(user.ID, []string{"auth.login", "auth.login_failed"}, 1)
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+}
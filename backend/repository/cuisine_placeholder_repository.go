@@ -0,0 +1,44 @@
+package repository
+
+// 料理画像のBlurHash・代表色・知覚ハッシュの補完（以前に保存した画像。backfill-placeholdersで使う）
+
+import (
+	"backend/model"
+
+	"gorm.io/gorm"
+)
+
+type ICuisinePlaceholderRepository interface {
+	// CuisinesWithoutPlaceholder は画像があり、BlurHashか知覚ハッシュを求めていない料理をIDの昇順で返す（afterIDより後をlimit件）
+	CuisinesWithoutPlaceholder(afterID uint, limit int) ([]model.Cuisine, error)
+	SetCuisinePlaceholder(cuisineID uint, blurHash string, dominantColor string, imageHash string) error
+}
+
+type cuisinePlaceholderRepository struct {
+	db *gorm.DB
+}
+
+func NewCuisinePlaceholderRepository(db *gorm.DB) ICuisinePlaceholderRepository {
+	return &cuisinePlaceholderRepository{db}
+}
+
+func (pr *cuisinePlaceholderRepository) CuisinesWithoutPlaceholder(afterID uint, limit int) ([]model.Cuisine, error) {
+	cuisines := []model.Cuisine{}
+	err := pr.db.Session(&gorm.Session{PrepareStmt: false}).
+		Where("id > ? AND icon_url IS NOT NULL AND icon_url <> '' AND (blur_hash IS NULL OR blur_hash = '' OR image_hash IS NULL OR image_hash = '')", afterID).
+		Order("id").Limit(limit).Find(&cuisines).Error
+	return cuisines, err
+}
+
+// SetCuisinePlaceholder は更新日時を変えずにBlurHash・代表色・知覚ハッシュを保存する
+func (pr *cuisinePlaceholderRepository) SetCuisinePlaceholder(cuisineID uint, blurHash string, dominantColor string, imageHash string) error {
+	result := pr.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.Cuisine{}).Where("id = ?", cuisineID).
+		UpdateColumns(map[string]interface{}{"blur_hash": blurHash, "dominant_color": dominantColor, "image_hash": imageHash})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestCuisinePlaceholderRepository(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	repo := NewCuisinePlaceholderRepository(db)
+	user := CreateTestUser(db)
+	image1, image2, image3 := "images/1/a/full.jpg", "images/1/b/full.jpg", "images/1/c/full.jpg"
+	a := model.Cuisine{Title: "a", UserID: user.ID, IconURL: &image1}
+	b := model.Cuisine{Title: "b", UserID: user.ID, IconURL: &image2, BlurHash: "LEHV6nWB2yk8pyo0adR*.7kCMdnj", DominantColor: "#c08040", ImageHash: "f0e0c0c0e0f0f8fc"}
+	c := model.Cuisine{Title: "c", UserID: user.ID}
+	d := model.Cuisine{Title: "d", UserID: user.ID, IconURL: &image3, BlurHash: "LEHV6nWB2yk8pyo0adR*.7kCMdnj", DominantColor: "#c08040"} // 知覚ハッシュのみ求めていない
+	for _, cuisine := range []*model.Cuisine{&a, &b, &c, &d} {
+		require.NoError(t, db.Create(cuisine).Error)
+	}
+
+	cuisines, err := repo.CuisinesWithoutPlaceholder(0, 10)
+	require.NoError(t, err)
+	require.Len(t, cuisines, 2)
+	assert.Equal(t, []uint{a.ID, d.ID}, []uint{cuisines[0].ID, cuisines[1].ID})
+
+	cuisines, err = repo.CuisinesWithoutPlaceholder(a.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, cuisines, 1)
+	assert.Equal(t, d.ID, cuisines[0].ID)
+
+	require.NoError(t, repo.SetCuisinePlaceholder(a.ID, "L00000fQfQfQfQfQfQfQfQfQfQfQ", "#000000", "0000000000000000"))
+	saved := model.Cuisine{}
+	require.NoError(t, db.First(&saved, a.ID).Error)
+	assert.Equal(t, "L00000fQfQfQfQfQfQfQfQfQfQfQ", saved.BlurHash)
+	assert.Equal(t, "#000000", saved.DominantColor)
+	assert.Equal(t, "0000000000000000", saved.ImageHash)
+	assert.WithinDuration(t, a.UpdatedAt, saved.UpdatedAt, time.Millisecond) // 更新日時は変えない
+
+	assert.ErrorIs(t, repo.SetCuisinePlaceholder(9999, "x", "#000000", ""), gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,90 @@
+package repository
+
+// メールアドレスの変更申請の保存と、確認・取り消し時のユーザーのメールアドレスの更新
+
+import (
+	"backend/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IEmailChangeRepository interface {
+	CreateEmailChange(change *model.EmailChange) error // 確認前の古い申請は削除する
+	GetEmailChangeByNonce(change *model.EmailChange, nonce string) error
+	ConfirmEmailChange(change *model.EmailChange, confirmedAt time.Time) error // 新しいアドレスを反映する
+	UndoEmailChange(change *model.EmailChange, undoneAt time.Time) error       // 元のアドレスに戻す
+}
+
+type emailChangeRepository struct {
+	db *gorm.DB
+}
+
+func NewEmailChangeRepository(db *gorm.DB) IEmailChangeRepository {
+	return &emailChangeRepository{db}
+}
+
+func (er *emailChangeRepository) CreateEmailChange(change *model.EmailChange) error {
+	return er.db.Session(&gorm.Session{PrepareStmt: false}).Transaction(func(tx *gorm.DB) error {
+		// 最後に送ったリンクだけを有効にする
+		if err := tx.Where("user_id = ? AND confirmed_at IS NULL", change.UserID).Delete(&model.EmailChange{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(change).Error
+	})
+}
+
+func (er *emailChangeRepository) GetEmailChangeByNonce(change *model.EmailChange, nonce string) error {
+	return er.db.Session(&gorm.Session{PrepareStmt: false}).Where("nonce = ?", nonce).First(change).Error
+}
+
+func (er *emailChangeRepository) ConfirmEmailChange(change *model.EmailChange, confirmedAt time.Time) error {
+	return er.db.Session(&gorm.Session{PrepareStmt: false}).Transaction(func(tx *gorm.DB) error {
+		// 同じリンクが並行して使われても一方しか成功しないよう条件付きで更新する
+		result := tx.Model(&model.EmailChange{}).
+			Where("id = ? AND confirmed_at IS NULL AND undone_at IS NULL AND expires_at > ?", change.ID, confirmedAt).
+			Update("confirmed_at", confirmedAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		// 申請後にメールアドレスが変わっていれば反映しない
+		result = tx.Model(&model.User{}).Where("id = ? AND email = ?", change.UserID, change.OldEmail).
+			Update("email", change.NewEmail)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		change.ConfirmedAt = &confirmedAt
+		return nil
+	})
+}
+
+func (er *emailChangeRepository) UndoEmailChange(change *model.EmailChange, undoneAt time.Time) error {
+	return er.db.Session(&gorm.Session{PrepareStmt: false}).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.EmailChange{}).
+			Where("id = ? AND confirmed_at IS NOT NULL AND undone_at IS NULL", change.ID).
+			Update("undone_at", undoneAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		// 反映後にさらに変更されていれば、その変更は取り消さない
+		result = tx.Model(&model.User{}).Where("id = ? AND email = ?", change.UserID, change.NewEmail).
+			Update("email", change.OldEmail)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		change.UndoneAt = &undoneAt
+		return nil
+	})
+}
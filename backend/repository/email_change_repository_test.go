@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestEmailChanges(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	repo := NewEmailChangeRepository(db)
+	user := CreateTestUser(db)
+	now := time.Now()
+
+	first := &model.EmailChange{UserID: user.ID, OldEmail: user.Email, NewEmail: "first@example.com", Nonce: "nonce-1", ExpiresAt: now.Add(time.Hour)}
+	assert.NoError(t, repo.CreateEmailChange(first))
+	change := &model.EmailChange{UserID: user.ID, OldEmail: user.Email, NewEmail: "new@example.com", Nonce: "nonce-2", ExpiresAt: now.Add(time.Hour)}
+	assert.NoError(t, repo.CreateEmailChange(change))
+	assert.ErrorIs(t, repo.GetEmailChangeByNonce(&model.EmailChange{}, "nonce-1"), gorm.ErrRecordNotFound, "古い申請は削除される")
+
+	found := model.EmailChange{}
+	assert.NoError(t, repo.GetEmailChangeByNonce(&found, "nonce-2"))
+	assert.Equal(t, "new@example.com", found.NewEmail)
+
+	// 確認前は取り消せない
+	assert.ErrorIs(t, repo.UndoEmailChange(&found, now), gorm.ErrRecordNotFound)
+
+	assert.NoError(t, repo.ConfirmEmailChange(&found, now))
+	assert.ErrorIs(t, repo.ConfirmEmailChange(&found, now), gorm.ErrRecordNotFound, "同じリンクは一度しか使えない")
+	stored := model.User{}
+	assert.NoError(t, db.First(&stored, user.ID).Error)
+	assert.Equal(t, "new@example.com", stored.Email)
+
+	assert.NoError(t, repo.UndoEmailChange(&found, now))
+	assert.ErrorIs(t, repo.UndoEmailChange(&found, now), gorm.ErrRecordNotFound)
+	assert.NoError(t, db.First(&stored, user.ID).Error)
+	assert.Equal(t, user.Email, stored.Email)
+
+	// 期限切れの申請は反映しない
+	expired := &model.EmailChange{UserID: user.ID, OldEmail: user.Email, NewEmail: "late@example.com", Nonce: "nonce-3", ExpiresAt: now.Add(-time.Minute)}
+	assert.NoError(t, repo.CreateEmailChange(expired))
+	assert.ErrorIs(t, repo.ConfirmEmailChange(expired, now), gorm.ErrRecordNotFound)
+}
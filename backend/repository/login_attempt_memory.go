@@ -0,0 +1,56 @@
+package repository
+
+// ログイン失敗回数のインメモリ実装（単一インスタンス構成やテストで使用）
+
+import (
+	"backend/model"
+	"sync"
+	"time"
+)
+
+type memoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]model.LoginAttempt
+}
+
+func NewMemoryLoginAttemptRepository() ILoginAttemptRepository {
+	return &memoryLoginAttemptRepository{attempts: map[string]model.LoginAttempt{}}
+}
+
+func (mr *memoryLoginAttemptRepository) GetLoginAttempt(attempt *model.LoginAttempt, key string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	*attempt = mr.attempts[key]
+	return nil
+}
+
+func (mr *memoryLoginAttemptRepository) RecordFailure(attempt *model.LoginAttempt, key string, now time.Time, window time.Duration) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	a, ok := mr.attempts[key]
+	if !ok || a.LastFailedAt.Before(now.Add(-window)) {
+		a = model.LoginAttempt{Key: key, LockedUntil: a.LockedUntil}
+	}
+	a.Failures++
+	a.LastFailedAt = now
+	mr.attempts[key] = a
+	*attempt = a
+	return nil
+}
+
+func (mr *memoryLoginAttemptRepository) LockLoginAttempt(key string, until time.Time) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	a := mr.attempts[key]
+	a.Key = key
+	a.LockedUntil = &until
+	mr.attempts[key] = a
+	return nil
+}
+
+func (mr *memoryLoginAttemptRepository) ResetLoginAttempt(key string) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	delete(mr.attempts, key)
+	return nil
+}
@@ -0,0 +1,58 @@
+package repository
+
+// ログイン失敗回数の記録とロック状態の管理（Postgres実装）
+// RecordFailure:失敗回数を加算する（前回の失敗からwindow以上経過していれば1から数え直す）
+// LockLoginAttempt:指定時刻までロックする
+// ResetLoginAttempt:ログイン成功時に記録を消去する
+
+import (
+	"backend/model"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ILoginAttemptRepository interface {
+	GetLoginAttempt(attempt *model.LoginAttempt, key string) error // 記録がない場合はattemptを空のまま返す
+	RecordFailure(attempt *model.LoginAttempt, key string, now time.Time, window time.Duration) error
+	LockLoginAttempt(key string, until time.Time) error
+	ResetLoginAttempt(key string) error
+}
+
+type loginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) ILoginAttemptRepository {
+	return &loginAttemptRepository{db}
+}
+
+func (lr *loginAttemptRepository) GetLoginAttempt(attempt *model.LoginAttempt, key string) error {
+	err := lr.db.Where("key = ?", key).First(attempt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		*attempt = model.LoginAttempt{}
+		return nil
+	}
+	return err
+}
+
+func (lr *loginAttemptRepository) RecordFailure(attempt *model.LoginAttempt, key string, now time.Time, window time.Duration) error {
+	// 複数インスタンスから同時に呼ばれても数え漏れがないよう、1回のupsertで加算する
+	return lr.db.Raw(`
+		INSERT INTO login_attempts (key, failures, last_failed_at) VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failed_at < ? THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failed_at = EXCLUDED.last_failed_at
+		RETURNING key, failures, last_failed_at, locked_until`,
+		key, now, now.Add(-window),
+	).Scan(attempt).Error
+}
+
+func (lr *loginAttemptRepository) LockLoginAttempt(key string, until time.Time) error {
+	return lr.db.Model(&model.LoginAttempt{}).Where("key = ?", key).Update("locked_until", until).Error
+}
+
+func (lr *loginAttemptRepository) ResetLoginAttempt(key string) error {
+	return lr.db.Where("key = ?", key).Delete(&model.LoginAttempt{}).Error
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Postgres実装とインメモリ実装で同じ振る舞いになることを確認する
+func testLoginAttemptRepository(t *testing.T, repo ILoginAttemptRepository) {
+	now := time.Now().UTC().Truncate(time.Second)
+	window := time.Hour
+
+	var attempt model.LoginAttempt
+	assert.NoError(t, repo.GetLoginAttempt(&attempt, "account:test@example.com"))
+	assert.Equal(t, 0, attempt.Failures)
+
+	assert.NoError(t, repo.RecordFailure(&attempt, "account:test@example.com", now, window))
+	assert.Equal(t, 1, attempt.Failures)
+	assert.NoError(t, repo.RecordFailure(&attempt, "account:test@example.com", now.Add(time.Minute), window))
+	assert.Equal(t, 2, attempt.Failures)
+
+	// windowより前の失敗は数え直す
+	assert.NoError(t, repo.RecordFailure(&attempt, "account:test@example.com", now.Add(2*window), window))
+	assert.Equal(t, 1, attempt.Failures)
+
+	until := now.Add(3 * window)
+	assert.NoError(t, repo.LockLoginAttempt("account:test@example.com", until))
+	assert.NoError(t, repo.GetLoginAttempt(&attempt, "account:test@example.com"))
+	if assert.NotNil(t, attempt.LockedUntil) {
+		assert.True(t, until.Equal(*attempt.LockedUntil))
+	}
+
+	assert.NoError(t, repo.ResetLoginAttempt("account:test@example.com"))
+	assert.NoError(t, repo.GetLoginAttempt(&attempt, "account:test@example.com"))
+	assert.Equal(t, 0, attempt.Failures)
+	assert.Nil(t, attempt.LockedUntil)
+}
+
+func TestLoginAttemptRepository(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	testLoginAttemptRepository(t, NewLoginAttemptRepository(db))
+}
+
+func TestMemoryLoginAttemptRepository(t *testing.T) {
+	testLoginAttemptRepository(t, NewMemoryLoginAttemptRepository())
+}
@@ -0,0 +1,60 @@
+package repository
+
+// パーソナルアクセストークンの保存・検索・削除
+
+import (
+	"backend/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IPersonalAccessTokenRepository interface {
+	CreateToken(token *model.PersonalAccessToken) error
+	GetTokensByUserID(userID uint) ([]model.PersonalAccessToken, error)
+	GetTokenByHash(token *model.PersonalAccessToken, tokenHash string) error
+	DeleteToken(userID uint, tokenID uint) error // 他のユーザーのトークンは削除できない
+	TouchToken(tokenID uint, usedAt time.Time) error
+}
+
+type personalAccessTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewPersonalAccessTokenRepository(db *gorm.DB) IPersonalAccessTokenRepository {
+	return &personalAccessTokenRepository{db}
+}
+
+func (pr *personalAccessTokenRepository) CreateToken(token *model.PersonalAccessToken) error {
+	return pr.db.Session(&gorm.Session{PrepareStmt: false}).Create(token).Error
+}
+
+func (pr *personalAccessTokenRepository) GetTokensByUserID(userID uint) ([]model.PersonalAccessToken, error) {
+	tokens := []model.PersonalAccessToken{}
+	if err := pr.db.Session(&gorm.Session{PrepareStmt: false}).
+		Where("user_id = ?", userID).Order("created_at").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (pr *personalAccessTokenRepository) GetTokenByHash(token *model.PersonalAccessToken, tokenHash string) error {
+	return pr.db.Session(&gorm.Session{PrepareStmt: false}).Where("token_hash = ?", tokenHash).First(token).Error
+}
+
+func (pr *personalAccessTokenRepository) DeleteToken(userID uint, tokenID uint) error {
+	result := pr.db.Session(&gorm.Session{PrepareStmt: false}).
+		Where("id = ? AND user_id = ?", tokenID, userID).Delete(&model.PersonalAccessToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (pr *personalAccessTokenRepository) TouchToken(tokenID uint, usedAt time.Time) error {
+	return pr.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.PersonalAccessToken{}).
+		Where("id = ?", tokenID).Update("last_used_at", usedAt).Error
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestPersonalAccessTokens(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	repo := NewPersonalAccessTokenRepository(db)
+	user := CreateTestUser(db)
+	other := &model.User{Name: "Other", Email: "other@example.com"}
+	assert.NoError(t, db.Create(other).Error)
+
+	token := &model.PersonalAccessToken{
+		UserID:    user.ID,
+		Name:      "script",
+		TokenHash: "hash1",
+		Prefix:    "cmpat_abc",
+		Scopes:    "read:cuisines",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	assert.NoError(t, repo.CreateToken(token))
+
+	found := model.PersonalAccessToken{}
+	assert.NoError(t, repo.GetTokenByHash(&found, "hash1"))
+	assert.Equal(t, token.ID, found.ID)
+
+	assert.NoError(t, repo.TouchToken(token.ID, time.Now()))
+	tokens, err := repo.GetTokensByUserID(user.ID)
+	assert.NoError(t, err)
+	assert.Len(t, tokens, 1)
+	assert.NotNil(t, tokens[0].LastUsedAt)
+
+	// 他のユーザーのトークンは削除できない
+	assert.ErrorIs(t, repo.DeleteToken(other.ID, token.ID), gorm.ErrRecordNotFound)
+	assert.NoError(t, repo.DeleteToken(user.ID, token.ID))
+	assert.ErrorIs(t, repo.GetTokenByHash(&found, "hash1"), gorm.ErrRecordNotFound)
+}
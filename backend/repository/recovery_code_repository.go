@@ -0,0 +1,45 @@
+package repository
+
+// 二要素認証のリカバリーコードの保存と使用
+
+import (
+	"backend/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IRecoveryCodeRepository interface {
+	ReplaceRecoveryCodes(userID uint, codes []model.RecoveryCode) error // 既存のコードを破棄して新しいコードを保存
+	UseRecoveryCode(userID uint, codeHash string) (bool, error)         // 未使用のコードであれば使用済みにしてtrueを返す
+}
+
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) IRecoveryCodeRepository {
+	return &recoveryCodeRepository{db}
+}
+
+func (rr *recoveryCodeRepository) ReplaceRecoveryCodes(userID uint, codes []model.RecoveryCode) error {
+	return rr.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (rr *recoveryCodeRepository) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := rr.db.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
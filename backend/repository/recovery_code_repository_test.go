@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"testing"
+
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoveryCodes(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	repo := NewRecoveryCodeRepository(db)
+	user := CreateTestUser(db)
+
+	codes := []model.RecoveryCode{
+		{UserID: user.ID, CodeHash: "hash1"},
+		{UserID: user.ID, CodeHash: "hash2"},
+	}
+	assert.NoError(t, repo.ReplaceRecoveryCodes(user.ID, codes))
+
+	// 1回目は成功し、2回目は使用済みとして失敗する
+	ok, err := repo.UseRecoveryCode(user.ID, "hash1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = repo.UseRecoveryCode(user.ID, "hash1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// 再発行すると古いコードは使えなくなる
+	assert.NoError(t, repo.ReplaceRecoveryCodes(user.ID, []model.RecoveryCode{{UserID: user.ID, CodeHash: "hash3"}}))
+	ok, err = repo.UseRecoveryCode(user.ID, "hash2")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
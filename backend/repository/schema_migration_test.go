@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"backend/auth"
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// baselineUser・baselineCuisine は最初のリリースのモデル（本番のテーブルはこのモデルのAutoMigrateで作成した）
+type baselineUser struct {
+	ID       uint `gorm:"primaryKey"`
+	Name     string
+	Email    string `gorm:"unique"`
+	Password string
+	IconURL  *string
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineCuisine struct {
+	ID        uint   `gorm:"primaryKey"`
+	Title     string `gorm:"not null"`
+	IconURL   *string
+	URL       string
+	Comment   string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uint         `gorm:"not null"`
+	User      baselineUser `gorm:"foreignKey:UserID; constraint:OnDelete:CASCADE"`
+}
+
+func (baselineCuisine) TableName() string { return "cuisines" }
+
+func TestMigrationsOnBaselineSchema(t *testing.T) {
+	db := connectTestDB()
+	defer CleanupTestDB(db)
+
+	// 本番と同じくbaselineのAutoMigrateで作成したテーブルにデータがある状態から適用する
+	require.NoError(t, db.AutoMigrate(&baselineUser{}, &baselineCuisine{}))
+	user := baselineUser{Name: "Existing User", Email: "existing@example.com", Password: "hash"}
+	require.NoError(t, db.Create(&user).Error)
+	require.NoError(t, db.Create(&baselineCuisine{Title: "existing", UserID: user.ID}).Error)
+	// 以前は料理画像の署名付きURLを保存していた
+	ptr := func(s string) *string { return &s }
+	iconURLs := []string{
+		fmt.Sprintf("https://storage.googleapis.com/cookmeet/images/%d/a.jpg?X-Goog-Signature=abc", user.ID),
+		fmt.Sprintf("https://cookmeet.s3.ap-northeast-1.amazonaws.com/images/%d/b.png?X-Amz-Signature=abc", user.ID),
+		fmt.Sprintf("http://localhost:8081/storage/images/%d/c.jpg?expires=1&signature=abc", user.ID),
+		fmt.Sprintf("https://storage.googleapis.com/cookmeet/images/%d/d.jpg", user.ID+1), // 作成者のキーではない
+		"https://example.com/e.jpg",
+		fmt.Sprintf("images/%d/f.jpg", user.ID),
+	}
+	legacy := make([]baselineCuisine, len(iconURLs))
+	for i, u := range iconURLs {
+		legacy[i] = baselineCuisine{Title: "legacy", UserID: user.ID, IconURL: ptr(u)}
+		require.NoError(t, db.Create(&legacy[i]).Error)
+	}
+
+	// 平文のまま保存されたパスワードのみハッシュ化する
+	plain := baselineUser{Name: "Plain", Email: "plain@example.com", Password: "password123"}
+	hashed := baselineUser{Name: "Hashed", Email: "hashed@example.com", Password: "$argon2id$v=19$m=1024,t=1,p=1$c2FsdA$aGFzaA"}
+	oidcOnly := baselineUser{Name: "OIDC", Email: "oidc@example.com"}
+	for _, u := range []*baselineUser{&plain, &hashed, &oidcOnly} {
+		require.NoError(t, db.Create(u).Error)
+	}
+
+	applied, err := testMigrator(db).Up(context.Background())
+	require.NoError(t, err)
+	statuses, err := testMigrator(db).Status(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, applied, len(statuses), "すべてのマイグレーションを適用する")
+
+	for _, column := range []string{"totp_secret", "totp_enabled", "totp_last_step", "role", "disabled_at", "created_at"} {
+		assert.True(t, db.Migrator().HasColumn(&model.User{}, column), column)
+	}
+	for _, column := range []string{"blur_hash", "dominant_color", "image_hash"} {
+		assert.True(t, db.Migrator().HasColumn(&model.Cuisine{}, column), column)
+	}
+	assert.True(t, db.Migrator().HasIndex(&model.Cuisine{}, "idx_cuisines_image_hash"))
+	assert.True(t, db.Migrator().HasTable(&model.StorageUsage{}))
+	assert.True(t, db.Migrator().HasColumn(&model.Upload{}, "reserved_bytes"))
+	assert.True(t, db.Migrator().HasColumn(&model.TusUpload{}, "reserved_bytes"))
+
+	// 料理画像のURLは作成者のキーを指すものだけをキーに書き換える
+	expectedIcons := []string{
+		fmt.Sprintf("images/%d/a.jpg", user.ID),
+		fmt.Sprintf("images/%d/b.png", user.ID),
+		fmt.Sprintf("images/%d/c.jpg", user.ID),
+		iconURLs[3],
+		iconURLs[4],
+		iconURLs[5],
+	}
+	for i, c := range legacy {
+		var got model.Cuisine
+		require.NoError(t, db.First(&got, c.ID).Error)
+		assert.Equal(t, expectedIcons[i], *got.IconURL, iconURLs[i])
+	}
+
+	// 平文のパスワードはbcryptのハッシュに置き換わり、元のパスワードでログインできる
+	hasher := auth.NewArgon2Hasher(auth.DefaultArgon2Params())
+	var plainGot model.User
+	require.NoError(t, NewUserRepository(db).GetUserByEmail(&plainGot, plain.Email))
+	assert.NotEqual(t, plain.Password, plainGot.Password)
+	ok, rehash, err := hasher.Verify(plainGot.Password, plain.Password)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash, "次のログインでargon2idに再ハッシュする")
+	for _, u := range []baselineUser{hashed, oidcOnly} {
+		var got model.User
+		require.NoError(t, NewUserRepository(db).GetUserByEmail(&got, u.Email))
+		assert.Equal(t, u.Password, got.Password, u.Email)
+	}
+
+	// 既存の行は追加した列の既定値で読み込める
+	got := model.User{}
+	require.NoError(t, NewUserRepository(db).GetUserByEmail(&got, "existing@example.com"))
+	assert.Equal(t, "user", got.Role)
+	assert.False(t, got.TOTPEnabled)
+}
@@ -0,0 +1,59 @@
+package repository
+
+// ログインセッションの作成・検索・失効と、アクティブユーザー数の集計
+
+import (
+	"backend/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ISessionRepository interface {
+	CreateSession(session *model.Session) error
+	GetSession(session *model.Session, sessionID string) error
+	TouchSession(sessionID string, seenAt time.Time) error
+	RevokeSession(sessionID string) error
+	RevokeUserSessions(userID uint, exceptSessionID string) error // 指定したセッション以外を失効させる（空文字ならすべて）
+	CountActiveUsers(since time.Time) (int64, error)              // since以降にアクセスがあったユーザー数
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) ISessionRepository {
+	return &sessionRepository{db}
+}
+
+func (sr *sessionRepository) CreateSession(session *model.Session) error {
+	return sr.db.Session(&gorm.Session{PrepareStmt: false}).Create(session).Error
+}
+
+func (sr *sessionRepository) GetSession(session *model.Session, sessionID string) error {
+	return sr.db.Session(&gorm.Session{PrepareStmt: false}).Where("id = ?", sessionID).First(session).Error
+}
+
+func (sr *sessionRepository) TouchSession(sessionID string, seenAt time.Time) error {
+	return sr.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.Session{}).
+		Where("id = ?", sessionID).Update("last_seen_at", seenAt).Error
+}
+
+func (sr *sessionRepository) RevokeSession(sessionID string) error {
+	return sr.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (sr *sessionRepository) RevokeUserSessions(userID uint, exceptSessionID string) error {
+	return sr.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, exceptSessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (sr *sessionRepository) CountActiveUsers(since time.Time) (int64, error) {
+	var count int64
+	err := sr.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.Session{}).
+		Where("last_seen_at >= ?", since).Distinct("user_id").Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessions(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	repo := NewSessionRepository(db)
+	user := CreateTestUser(db)
+	now := time.Now()
+
+	for _, id := range []string{"s1", "s2", "s3"} {
+		assert.NoError(t, repo.CreateSession(&model.Session{ID: id, UserID: user.ID, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}))
+	}
+
+	count, err := repo.CountActiveUsers(now.Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count, "同じユーザーのセッションは1人として数える")
+
+	// s1以外を失効させる
+	assert.NoError(t, repo.RevokeUserSessions(user.ID, "s1"))
+	session := model.Session{}
+	assert.NoError(t, repo.GetSession(&session, "s1"))
+	assert.Nil(t, session.RevokedAt)
+	assert.NoError(t, repo.GetSession(&session, "s2"))
+	assert.NotNil(t, session.RevokedAt)
+
+	// 空文字ならすべて失効させる
+	assert.NoError(t, repo.RevokeUserSessions(user.ID, ""))
+	session = model.Session{}
+	assert.NoError(t, repo.GetSession(&session, "s1"))
+	assert.NotNil(t, session.RevokedAt)
+
+	// 1つのセッションのみ失効させる（ログアウト）
+	assert.NoError(t, repo.CreateSession(&model.Session{ID: "s4", UserID: user.ID, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}))
+	assert.NoError(t, repo.CreateSession(&model.Session{ID: "s5", UserID: user.ID, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}))
+	assert.NoError(t, repo.RevokeSession("s4"))
+	session = model.Session{}
+	assert.NoError(t, repo.GetSession(&session, "s4"))
+	assert.NotNil(t, session.RevokedAt)
+	session = model.Session{}
+	assert.NoError(t, repo.GetSession(&session, "s5"))
+	assert.Nil(t, session.RevokedAt)
+
+	assert.NoError(t, repo.TouchSession("s1", now.Add(-48*time.Hour)))
+	assert.NoError(t, repo.TouchSession("s2", now.Add(-48*time.Hour)))
+	assert.NoError(t, repo.TouchSession("s3", now.Add(-48*time.Hour)))
+	assert.NoError(t, repo.TouchSession("s4", now.Add(-48*time.Hour)))
+	assert.NoError(t, repo.TouchSession("s5", now.Add(-48*time.Hour)))
+	count, err = repo.CountActiveUsers(now.Add(-24 * time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
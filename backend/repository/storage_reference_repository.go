@@ -0,0 +1,82 @@
+package repository
+
+// データベースから参照されている保存先のオブジェクトのキー（使われていないオブジェクトの削除で使う）
+// 期限切れのアップロードの作成時に使用量に加えた大きさも、ここで取り出して使用量から減らす
+
+import (
+	"backend/model"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IStorageReferenceRepository interface {
+	CuisineImageKeys() ([]string, error) // 料理画像（元のサイズ）のキー
+	UserIconKeys() ([]string, error)
+	PendingUploadKeys(now time.Time) ([]string, error)   // 期限内で使用していない直接アップロードのキー
+	PendingTusChunkKeys(now time.Time) ([]string, error) // 期限内で完了していないtusのアップロードの部分のキー
+	// TakeExpiredReservations は期限切れで使用・完了していないアップロードの、作成時に使用量に加えた大きさを返して0にする
+	// 同時に実行しても同じ分は一度しか返さない（使用量から減らすのは呼び出し側）
+	TakeExpiredReservations(now time.Time) ([]model.UploadReservation, error)
+}
+
+// takeReservationsQuery は期限切れのアップロードのReservedBytesを0にし、0にする前の値を返す
+const takeReservationsQuery = `UPDATE %[1]s SET reserved_bytes = 0
+FROM (SELECT id, reserved_bytes FROM %[1]s WHERE %[2]s AND expires_at <= ? AND reserved_bytes > 0 FOR UPDATE) AS taken
+WHERE %[1]s.id = taken.id
+RETURNING %[1]s.user_id, %[1]s.purpose, taken.reserved_bytes AS bytes`
+
+type storageReferenceRepository struct {
+	db *gorm.DB
+}
+
+func NewStorageReferenceRepository(db *gorm.DB) IStorageReferenceRepository {
+	return &storageReferenceRepository{db}
+}
+
+func (sr *storageReferenceRepository) CuisineImageKeys() ([]string, error) {
+	keys := []string{}
+	err := sr.db.Model(&model.Cuisine{}).Where("icon_url IS NOT NULL AND icon_url <> ''").Pluck("icon_url", &keys).Error
+	return keys, err
+}
+
+func (sr *storageReferenceRepository) UserIconKeys() ([]string, error) {
+	keys := []string{}
+	err := sr.db.Model(&model.User{}).Where("icon_url IS NOT NULL AND icon_url <> ''").Pluck("icon_url", &keys).Error
+	return keys, err
+}
+
+func (sr *storageReferenceRepository) PendingUploadKeys(now time.Time) ([]string, error) {
+	keys := []string{}
+	err := sr.db.Model(&model.Upload{}).Where("consumed_at IS NULL AND expires_at > ?", now).Pluck("key", &keys).Error
+	return keys, err
+}
+
+func (sr *storageReferenceRepository) PendingTusChunkKeys(now time.Time) ([]string, error) {
+	chunkKeys := []string{}
+	if err := sr.db.Model(&model.TusUpload{}).Where("completed_at IS NULL AND expires_at > ?", now).Pluck("chunk_keys", &chunkKeys).Error; err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for _, k := range chunkKeys {
+		keys = append(keys, strings.Fields(k)...)
+	}
+	return keys, nil
+}
+
+func (sr *storageReferenceRepository) TakeExpiredReservations(now time.Time) ([]model.UploadReservation, error) {
+	reservations := []model.UploadReservation{}
+	for _, t := range []struct{ table, cond string }{
+		{"uploads", "consumed_at IS NULL"},
+		{"tus_uploads", "completed_at IS NULL"}, // 完了したものはUploadに引き継いでいる
+	} {
+		taken := []model.UploadReservation{}
+		if err := sr.db.Raw(fmt.Sprintf(takeReservationsQuery, t.table, t.cond), now).Scan(&taken).Error; err != nil {
+			return reservations, err
+		}
+		reservations = append(reservations, taken...)
+	}
+	return reservations, nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageReferenceKeys(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	repo := NewStorageReferenceRepository(db)
+	user := CreateTestUser(db)
+	icon := "user_icons/1/a.png"
+	assert.NoError(t, db.Model(user).Update("icon_url", icon).Error)
+	image, empty := "images/1/abc/full.jpg", ""
+	assert.NoError(t, db.Create(&model.Cuisine{Title: "a", UserID: user.ID, IconURL: &image}).Error)
+	assert.NoError(t, db.Create(&model.Cuisine{Title: "b", UserID: user.ID, IconURL: &empty}).Error)
+	assert.NoError(t, db.Create(&model.Cuisine{Title: "c", UserID: user.ID}).Error)
+
+	keys, err := repo.CuisineImageKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{image}, keys)
+
+	keys, err = repo.UserIconKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{icon}, keys)
+
+	now := time.Now()
+	for id, exp := range map[string]time.Time{"pending": now.Add(time.Hour), "expired": now.Add(-time.Hour)} {
+		assert.NoError(t, db.Create(&model.Upload{ID: id, UserID: user.ID, Purpose: model.UploadPurposeCuisineImage,
+			Key: "uploads/1/" + id + ".png", ContentType: "image/png", Size: 1, ExpiresAt: exp}).Error)
+	}
+	assert.NoError(t, db.Create(&model.Upload{ID: "consumed", UserID: user.ID, Purpose: model.UploadPurposeCuisineImage,
+		Key: "uploads/1/consumed.png", ContentType: "image/png", Size: 1, ExpiresAt: now.Add(time.Hour), ConsumedAt: &now}).Error)
+	keys, err = repo.PendingUploadKeys(now)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"uploads/1/pending.png"}, keys)
+
+	assert.NoError(t, db.Create(&model.TusUpload{ID: "tus-pending", UserID: user.ID, Purpose: model.UploadPurposeCuisineImage,
+		Length: 10, Offset: 6, ChunkKeys: "tus/1/tus-pending/a\ntus/1/tus-pending/b\n", ExpiresAt: now.Add(time.Hour)}).Error)
+	assert.NoError(t, db.Create(&model.TusUpload{ID: "tus-expired", UserID: user.ID, Purpose: model.UploadPurposeCuisineImage,
+		Length: 10, Offset: 3, ChunkKeys: "tus/1/tus-expired/a\n", ExpiresAt: now.Add(-time.Hour)}).Error)
+	keys, err = repo.PendingTusChunkKeys(now)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tus/1/tus-pending/a", "tus/1/tus-pending/b"}, keys)
+}
+
+func TestTakeExpiredReservations(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	repo := NewStorageReferenceRepository(db)
+	user := CreateTestUser(db)
+	now := time.Now()
+	for id, exp := range map[string]time.Time{"pending": now.Add(time.Hour), "expired": now.Add(-time.Hour)} {
+		assert.NoError(t, db.Create(&model.Upload{ID: id, UserID: user.ID, Purpose: model.UploadPurposeCuisineImage,
+			Key: "uploads/1/" + id + ".png", ContentType: "image/png", Size: 5, ReservedBytes: 5, ExpiresAt: exp}).Error)
+	}
+	assert.NoError(t, db.Create(&model.Upload{ID: "consumed", UserID: user.ID, Purpose: model.UploadPurposeCuisineImage,
+		Key: "uploads/1/consumed.png", ContentType: "image/png", Size: 5, ReservedBytes: 5, ExpiresAt: now.Add(-time.Hour), ConsumedAt: &now}).Error)
+	assert.NoError(t, db.Create(&model.TusUpload{ID: "tus-expired", UserID: user.ID, Purpose: model.UploadPurposeUserIcon,
+		Length: 3, ReservedBytes: 3, ExpiresAt: now.Add(-time.Hour)}).Error)
+	assert.NoError(t, db.Create(&model.TusUpload{ID: "tus-completed", UserID: user.ID, Purpose: model.UploadPurposeUserIcon,
+		Length: 3, ReservedBytes: 3, ExpiresAt: now.Add(-time.Hour), CompletedAt: &now}).Error)
+
+	reservations, err := repo.TakeExpiredReservations(now)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.UploadReservation{
+		{UserID: user.ID, Purpose: model.UploadPurposeCuisineImage, Bytes: 5},
+		{UserID: user.ID, Purpose: model.UploadPurposeUserIcon, Bytes: 3},
+	}, reservations)
+
+	// 一度返した分は返さない
+	reservations, err = repo.TakeExpiredReservations(now)
+	assert.NoError(t, err)
+	assert.Empty(t, reservations)
+}
@@ -0,0 +1,113 @@
+package repository
+
+// ユーザーごとの保存先の使用量の取得・更新
+// 使用量への加算は上限と合わせて一つのSQLで行い、同時にアップロードされても上限を超えない
+
+import (
+	"backend/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrQuotaExceeded は加えると上限を超えるため使用量を変えなかったことを表す
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// storageUsageColumns は用途（model.UploadPurpose*）ごとの使用量の列
+var storageUsageColumns = map[string]string{
+	model.UploadPurposeCuisineImage: "cuisine_image_bytes",
+	model.UploadPurposeUserIcon:     "user_icon_bytes",
+}
+
+type IStorageUsageRepository interface {
+	GetStorageUsage(usage *model.StorageUsage, userID uint) error // 記録がない場合はgorm.ErrRecordNotFound
+	ListStorageUsages() ([]model.StorageUsage, error)
+	// AddStorageUsage は用途kindの使用量にdeltaを加える（0未満にはしない）
+	// limitが0より大きく、加えた後の合計がlimitを超える場合は加えずにErrQuotaExceededを返す
+	AddStorageUsage(userID uint, kind string, delta int64, limit int64) error
+	SetStorageUsage(usage *model.StorageUsage) error        // 計算し直した使用量で置き換える
+	CuisineImageOwners() ([]model.StoredObjectOwner, error) // 料理画像（元のサイズ）のキーと所有者
+	UserIconOwners() ([]model.StoredObjectOwner, error)
+	UploadReservations() ([]model.UploadReservation, error) // 作成時に使用量に加え、まだ減らしていないアップロード
+}
+
+type storageUsageRepository struct {
+	db *gorm.DB
+}
+
+func NewStorageUsageRepository(db *gorm.DB) IStorageUsageRepository {
+	return &storageUsageRepository{db}
+}
+
+func (sr *storageUsageRepository) GetStorageUsage(usage *model.StorageUsage, userID uint) error {
+	return sr.db.Session(&gorm.Session{PrepareStmt: false}).Where("user_id = ?", userID).First(usage).Error
+}
+
+func (sr *storageUsageRepository) ListStorageUsages() ([]model.StorageUsage, error) {
+	usages := []model.StorageUsage{}
+	err := sr.db.Session(&gorm.Session{PrepareStmt: false}).Order("user_id").Find(&usages).Error
+	return usages, err
+}
+
+func (sr *storageUsageRepository) AddStorageUsage(userID uint, kind string, delta int64, limit int64) error {
+	column, ok := storageUsageColumns[kind]
+	if !ok {
+		return fmt.Errorf("unknown storage usage kind: %s", kind)
+	}
+	initial := delta
+	if initial < 0 {
+		initial = 0
+	}
+	checkLimit := limit > 0 && delta > 0
+	if checkLimit && initial > limit {
+		return ErrQuotaExceeded
+	}
+
+	query := fmt.Sprintf(`INSERT INTO storage_usages (user_id, %[1]s, updated_at) VALUES (?, ?, ?)
+ON CONFLICT (user_id) DO UPDATE SET %[1]s = GREATEST(storage_usages.%[1]s + ?, 0), updated_at = EXCLUDED.updated_at`, column)
+	args := []interface{}{userID, initial, time.Now(), delta}
+	if checkLimit {
+		query += " WHERE storage_usages.cuisine_image_bytes + storage_usages.user_icon_bytes + ? <= ?"
+		args = append(args, delta, limit)
+	}
+	result := sr.db.Session(&gorm.Session{PrepareStmt: false}).Exec(query, args...)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+func (sr *storageUsageRepository) SetStorageUsage(usage *model.StorageUsage) error {
+	return sr.db.Session(&gorm.Session{PrepareStmt: false}).Omit(clause.Associations).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"cuisine_image_bytes", "user_icon_bytes", "updated_at"}),
+		}).Create(usage).Error
+}
+
+func (sr *storageUsageRepository) CuisineImageOwners() ([]model.StoredObjectOwner, error) {
+	owners := []model.StoredObjectOwner{}
+	err := sr.db.Model(&model.Cuisine{}).Select(`user_id, icon_url AS "key"`).
+		Where("icon_url IS NOT NULL AND icon_url <> ''").Scan(&owners).Error
+	return owners, err
+}
+
+func (sr *storageUsageRepository) UserIconOwners() ([]model.StoredObjectOwner, error) {
+	owners := []model.StoredObjectOwner{}
+	err := sr.db.Model(&model.User{}).Select(`id AS user_id, icon_url AS "key"`).
+		Where("icon_url IS NOT NULL AND icon_url <> ''").Scan(&owners).Error
+	return owners, err
+}
+
+func (sr *storageUsageRepository) UploadReservations() ([]model.UploadReservation, error) {
+	reservations := []model.UploadReservation{}
+	err := sr.db.Raw(`SELECT user_id, purpose, reserved_bytes AS bytes FROM uploads WHERE reserved_bytes > 0
+UNION ALL SELECT user_id, purpose, reserved_bytes AS bytes FROM tus_uploads WHERE reserved_bytes > 0`).Scan(&reservations).Error
+	return reservations, err
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestStorageUsage(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	repo := NewStorageUsageRepository(db)
+	user := CreateTestUser(db)
+
+	usage := model.StorageUsage{}
+	assert.ErrorIs(t, repo.GetStorageUsage(&usage, user.ID), gorm.ErrRecordNotFound, "記録がない場合")
+
+	// 上限を超えない場合のみ加える
+	assert.NoError(t, repo.AddStorageUsage(user.ID, model.UploadPurposeCuisineImage, 60, 100))
+	assert.NoError(t, repo.AddStorageUsage(user.ID, model.UploadPurposeUserIcon, 30, 100))
+	assert.ErrorIs(t, repo.AddStorageUsage(user.ID, model.UploadPurposeCuisineImage, 20, 100), ErrQuotaExceeded)
+	assert.ErrorIs(t, repo.AddStorageUsage(user.ID+1, model.UploadPurposeCuisineImage, 200, 100), ErrQuotaExceeded, "記録がなくても上限を超える場合")
+	assert.NoError(t, repo.AddStorageUsage(user.ID, model.UploadPurposeCuisineImage, 20, 0), "上限なし")
+	assert.NoError(t, repo.GetStorageUsage(&usage, user.ID))
+	assert.Equal(t, int64(80), usage.CuisineImageBytes)
+	assert.Equal(t, int64(30), usage.UserIconBytes)
+
+	// 減らす場合は上限を確認せず、0未満にはしない
+	assert.NoError(t, repo.AddStorageUsage(user.ID, model.UploadPurposeUserIcon, -50, 100))
+	assert.NoError(t, repo.GetStorageUsage(&usage, user.ID))
+	assert.Equal(t, int64(0), usage.UserIconBytes)
+	assert.Error(t, repo.AddStorageUsage(user.ID, "unknown", 1, 0))
+
+	assert.NoError(t, repo.SetStorageUsage(&model.StorageUsage{UserID: user.ID, CuisineImageBytes: 5, UserIconBytes: 7}))
+	usages, err := repo.ListStorageUsages()
+	assert.NoError(t, err)
+	assert.Len(t, usages, 1)
+	assert.Equal(t, int64(12), usages[0].TotalBytes())
+}
+
+func TestStoredObjectOwners(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	repo := NewStorageUsageRepository(db)
+	user := CreateTestUser(db)
+	icon := "user_icons/1/a.png"
+	assert.NoError(t, db.Model(user).Update("icon_url", icon).Error)
+	image, empty := "images/1/abc/full.jpg", ""
+	assert.NoError(t, db.Create(&model.Cuisine{Title: "a", UserID: user.ID, IconURL: &image}).Error)
+	assert.NoError(t, db.Create(&model.Cuisine{Title: "b", UserID: user.ID, IconURL: &empty}).Error)
+
+	owners, err := repo.CuisineImageOwners()
+	assert.NoError(t, err)
+	assert.Equal(t, []model.StoredObjectOwner{{UserID: user.ID, Key: image}}, owners)
+
+	owners, err = repo.UserIconOwners()
+	assert.NoError(t, err)
+	assert.Equal(t, []model.StoredObjectOwner{{UserID: user.ID, Key: icon}}, owners)
+	now := time.Now()
+	assert.NoError(t, db.Create(&model.Upload{ID: "reserved", UserID: user.ID, Purpose: model.UploadPurposeCuisineImage,
+		Key: "uploads/1/reserved.png", ContentType: "image/png", Size: 5, ReservedBytes: 5, ExpiresAt: now}).Error)
+	assert.NoError(t, db.Create(&model.Upload{ID: "consumed", UserID: user.ID, Purpose: model.UploadPurposeCuisineImage,
+		Key: "uploads/1/consumed.png", ContentType: "image/png", Size: 5, ExpiresAt: now, ConsumedAt: &now}).Error)
+	assert.NoError(t, db.Create(&model.TusUpload{ID: "tus", UserID: user.ID, Purpose: model.UploadPurposeUserIcon,
+		Length: 3, ReservedBytes: 3, ExpiresAt: now}).Error)
+	reservations, err := repo.UploadReservations()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []model.UploadReservation{
+		{UserID: user.ID, Purpose: model.UploadPurposeCuisineImage, Bytes: 5},
+		{UserID: user.ID, Purpose: model.UploadPurposeUserIcon, Bytes: 3},
+	}, reservations)
+}
@@ -1,14 +1,16 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 
+	"backend/db"
+	"backend/migration"
 	"backend/model"
 
 	"github.com/joho/godotenv"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
@@ -30,42 +32,64 @@ func init() {
 
 // SetupTestDB initializes and returns a test database connection
 func SetupTestDB() *gorm.DB {
-	// テスト用のDB接続情報
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", os.Getenv("POSTGRES_USER"),
-		os.Getenv("POSTGRES_PW"), os.Getenv("POSTGRES_HOST"),
-		os.Getenv("POSTGRES_PORT"), os.Getenv("POSTGRES_DB"))
+	gdb := connectTestDB()
 
-	// テスト用のログ設定
-	config := &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+	// テスト用のテーブルを本番と同じマイグレーションで作成
+	if _, err := testMigrator(gdb).Up(context.Background()); err != nil {
+		panic(fmt.Sprintf("failed to migrate database: %v", err))
 	}
+	log.Println("Successfully migrated database schema") // ログ追加
+
+	return gdb
+}
 
-	db, err := gorm.Open(postgres.Open(dsn), config)
+// connectTestDB はテーブルを作成せずにテスト用のデータベースに接続する
+func connectTestDB() *gorm.DB {
+	// テスト用のDB接続情報
+	cfg := db.DefaultConfig()
+	cfg.Host = os.Getenv("POSTGRES_HOST")
+	cfg.Port = os.Getenv("POSTGRES_PORT")
+	cfg.User = os.Getenv("POSTGRES_USER")
+	cfg.Password = os.Getenv("POSTGRES_PW")
+	cfg.Name = os.Getenv("POSTGRES_DB")
+
+	gdb, err := db.NewDB(cfg)
 	if err != nil {
 		panic(fmt.Sprintf("failed to connect database: %v", err))
 	}
+	// テスト用のログ設定
+	gdb = gdb.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Info)})
 	log.Println("Successfully connected to test database") // ログ追加
-
-	// テスト用のテーブルを作成
-	err = db.AutoMigrate(&model.User{}, &model.Cuisine{})
-	if err != nil {
-		panic(fmt.Sprintf("failed to migrate database: %v", err))
-	}
-	log.Println("Successfully migrated database schema") // ログ追加
-
-	return db
+	return gdb
 }
 
 // CleanupTestDB cleans up the test database
 func CleanupTestDB(db *gorm.DB) {
 	// テスト用のテーブルをクリーンアップ
-	err := db.Migrator().DropTable(&model.User{}, &model.Cuisine{})
+	m := testMigrator(db)
+	statuses, err := m.Status(context.Background())
+	if err == nil {
+		_, err = m.Down(context.Background(), len(statuses))
+	}
 	if err != nil {
 		log.Printf("Warning: failed to cleanup test database: %v", err)
 	}
 	log.Println("Successfully cleaned up test database") // ログ追加
 }
 
+// testMigrator は埋め込んだマイグレーションを実行する
+func testMigrator(db *gorm.DB) *migration.Migrator {
+	sqlDB, err := db.DB()
+	if err != nil {
+		panic(fmt.Sprintf("failed to get database connection: %v", err))
+	}
+	migrations, err := migration.Embedded()
+	if err != nil {
+		panic(fmt.Sprintf("failed to load migrations: %v", err))
+	}
+	return migration.NewMigrator(sqlDB, migrations)
+}
+
 // CreateTestUser creates a test user for testing purposes
 func CreateTestUser(db *gorm.DB) *model.User {
 	user := &model.User{
@@ -0,0 +1,74 @@
+package repository
+
+// tusプロトコルによるアップロードの進捗の保存・更新
+// 部分の追加は現在のオフセットが一致する場合のみ行い、同じオフセットへの同時の書き込みは一方のみ成功する
+
+import (
+	"backend/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ITusUploadRepository interface {
+	CreateTusUpload(upload *model.TusUpload) error
+	GetTusUploadByID(upload *model.TusUpload, userID uint, uploadID string) error // 他のユーザーのアップロードは取得できない
+	AppendTusChunk(uploadID string, offset int64, newOffset int64, chunkKey string) error
+	// CompleteTusUpload は完了を記録し、まとめたファイルのUploadを作成する
+	// 作成時に使用量に加えた大きさはuploadに引き継ぐため、tusのアップロードの記録は0にする
+	CompleteTusUpload(uploadID string, completedAt time.Time, upload *model.Upload) error
+	DeleteTusUpload(uploadID string) error
+}
+
+type tusUploadRepository struct {
+	db *gorm.DB
+}
+
+func NewTusUploadRepository(db *gorm.DB) ITusUploadRepository {
+	return &tusUploadRepository{db}
+}
+
+func (tr *tusUploadRepository) CreateTusUpload(upload *model.TusUpload) error {
+	return tr.db.Session(&gorm.Session{PrepareStmt: false}).Create(upload).Error
+}
+
+func (tr *tusUploadRepository) GetTusUploadByID(upload *model.TusUpload, userID uint, uploadID string) error {
+	return tr.db.Session(&gorm.Session{PrepareStmt: false}).
+		Where("id = ? AND user_id = ?", uploadID, userID).First(upload).Error
+}
+
+// AppendTusChunk はオフセットがoffsetの場合のみ部分を追加する（一致しない場合はgorm.ErrRecordNotFound）
+func (tr *tusUploadRepository) AppendTusChunk(uploadID string, offset int64, newOffset int64, chunkKey string) error {
+	result := tr.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.TusUpload{}).
+		Where("id = ? AND \"offset\" = ? AND completed_at IS NULL", uploadID, offset).
+		Updates(map[string]interface{}{
+			"offset":     newOffset,
+			"chunk_keys": gorm.Expr("chunk_keys || ?", chunkKey+"\n"),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (tr *tusUploadRepository) CompleteTusUpload(uploadID string, completedAt time.Time, upload *model.Upload) error {
+	return tr.db.Session(&gorm.Session{PrepareStmt: false}).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.TusUpload{}).
+			Where("id = ? AND completed_at IS NULL", uploadID).
+			Updates(map[string]interface{}{"completed_at": completedAt, "reserved_bytes": 0})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Create(upload).Error
+	})
+}
+
+func (tr *tusUploadRepository) DeleteTusUpload(uploadID string) error {
+	return tr.db.Session(&gorm.Session{PrepareStmt: false}).Where("id = ?", uploadID).Delete(&model.TusUpload{}).Error
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestTusUploads(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	repo := NewTusUploadRepository(db)
+	user := CreateTestUser(db)
+	other := &model.User{Name: "Other", Email: "other@example.com"}
+	assert.NoError(t, db.Create(other).Error)
+
+	tus := &model.TusUpload{
+		ID:            "4c1d8f0a-2b3e-4f5a-8c7d-9e0f1a2b3c4d",
+		UserID:        user.ID,
+		Purpose:       model.UploadPurposeCuisineImage,
+		Length:        10,
+		ReservedBytes: 10,
+		ExpiresAt:     time.Now().Add(time.Hour),
+	}
+	assert.NoError(t, repo.CreateTusUpload(tus))
+
+	found := model.TusUpload{}
+	assert.ErrorIs(t, repo.GetTusUploadByID(&found, other.ID, tus.ID), gorm.ErrRecordNotFound, "他のユーザーのアップロード")
+
+	// オフセットが一致する場合のみ追加する
+	assert.NoError(t, repo.AppendTusChunk(tus.ID, 0, 6, "tus/1/a/0"))
+	assert.ErrorIs(t, repo.AppendTusChunk(tus.ID, 0, 4, "tus/1/a/0-dup"), gorm.ErrRecordNotFound)
+	assert.NoError(t, repo.AppendTusChunk(tus.ID, 6, 10, "tus/1/a/6"))
+	assert.NoError(t, repo.GetTusUploadByID(&found, user.ID, tus.ID))
+	assert.Equal(t, int64(10), found.Offset)
+	assert.Equal(t, "tus/1/a/0\ntus/1/a/6\n", found.ChunkKeys)
+
+	// 完了すると同じIDのUploadを作成する
+	now := time.Now()
+	upload := &model.Upload{ID: tus.ID, UserID: user.ID, Purpose: tus.Purpose, Key: "uploads/1/a.png",
+		ContentType: "image/png", Size: 10, ReservedBytes: 10, ExpiresAt: now.Add(time.Hour), CompletedAt: &now}
+	assert.NoError(t, repo.CompleteTusUpload(tus.ID, now, upload))
+	assert.ErrorIs(t, repo.CompleteTusUpload(tus.ID, now, upload), gorm.ErrRecordNotFound, "完了は一度だけ")
+	assert.ErrorIs(t, repo.AppendTusChunk(tus.ID, 10, 11, "tus/1/a/10"), gorm.ErrRecordNotFound, "完了後は追加できない")
+	assert.NoError(t, repo.GetTusUploadByID(&found, user.ID, tus.ID))
+	assert.Zero(t, found.ReservedBytes, "使用量に加えた分はUploadに引き継ぐ")
+	completed := model.Upload{}
+	assert.NoError(t, NewUploadRepository(db).GetUploadByID(&completed, user.ID, tus.ID))
+	assert.Equal(t, int64(10), completed.ReservedBytes)
+
+	assert.NoError(t, repo.DeleteTusUpload(tus.ID))
+	assert.ErrorIs(t, repo.GetTusUploadByID(&found, user.ID, tus.ID), gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,60 @@
+package repository
+
+// 直接アップロードのセッションの保存・検索・状態の更新
+// 完了・使用済みへの更新は条件付きで行い、同時に同じアップロードを使われても一度しか成功しない
+
+import (
+	"backend/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IUploadRepository interface {
+	CreateUpload(upload *model.Upload) error
+	GetUploadByID(upload *model.Upload, userID uint, uploadID string) error // 他のユーザーのアップロードは取得できない
+	CompleteUpload(uploadID string, completedAt time.Time) error            // 完了していない場合のみ
+	// ConsumeUpload は完了済み・期限内で使用していない場合のみ使用済みにする
+	// 作成時に使用量に加えた大きさ（ReservedBytes）は0にする（使用量から減らすのは呼び出し側）
+	ConsumeUpload(uploadID string, consumedAt time.Time) error
+}
+
+type uploadRepository struct {
+	db *gorm.DB
+}
+
+func NewUploadRepository(db *gorm.DB) IUploadRepository {
+	return &uploadRepository{db}
+}
+
+func (ur *uploadRepository) CreateUpload(upload *model.Upload) error {
+	return ur.db.Session(&gorm.Session{PrepareStmt: false}).Create(upload).Error
+}
+
+func (ur *uploadRepository) GetUploadByID(upload *model.Upload, userID uint, uploadID string) error {
+	return ur.db.Session(&gorm.Session{PrepareStmt: false}).
+		Where("id = ? AND user_id = ?", uploadID, userID).First(upload).Error
+}
+
+func (ur *uploadRepository) CompleteUpload(uploadID string, completedAt time.Time) error {
+	return ur.updateIf(uploadID, map[string]interface{}{"completed_at": completedAt}, "completed_at IS NULL")
+}
+
+func (ur *uploadRepository) ConsumeUpload(uploadID string, consumedAt time.Time) error {
+	// 期限切れのものは使用量から減らす対象（TakeExpiredReservations）になるため使用できない
+	return ur.updateIf(uploadID, map[string]interface{}{"consumed_at": consumedAt, "reserved_bytes": 0},
+		"completed_at IS NOT NULL AND consumed_at IS NULL AND expires_at > ?", consumedAt)
+}
+
+// updateIf は条件を満たす場合のみ更新する（満たさない場合はgorm.ErrRecordNotFound）
+func (ur *uploadRepository) updateIf(uploadID string, values map[string]interface{}, cond string, args ...interface{}) error {
+	result := ur.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.Upload{}).
+		Where("id = ?", uploadID).Where(cond, args...).Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
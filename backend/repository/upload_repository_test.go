@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestUploads(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	repo := NewUploadRepository(db)
+	user := CreateTestUser(db)
+	other := &model.User{Name: "Other", Email: "other@example.com"}
+	assert.NoError(t, db.Create(other).Error)
+
+	upload := &model.Upload{
+		ID:            "0b6c1f0e-7f3a-4d7e-9a51-5c3e1d2f4a6b",
+		UserID:        user.ID,
+		Purpose:       model.UploadPurposeCuisineImage,
+		Key:           "uploads/1/0b6c1f0e-7f3a-4d7e-9a51-5c3e1d2f4a6b.jpg",
+		ContentType:   "image/jpeg",
+		Size:          1024,
+		ReservedBytes: 1024,
+		ExpiresAt:     time.Now().Add(time.Hour),
+	}
+	assert.NoError(t, repo.CreateUpload(upload))
+
+	found := model.Upload{}
+	assert.NoError(t, repo.GetUploadByID(&found, user.ID, upload.ID))
+	assert.Equal(t, upload.Key, found.Key)
+	assert.ErrorIs(t, repo.GetUploadByID(&found, other.ID, upload.ID), gorm.ErrRecordNotFound, "他のユーザーのアップロード")
+
+	// 完了する前は使用できない
+	assert.ErrorIs(t, repo.ConsumeUpload(upload.ID, time.Now()), gorm.ErrRecordNotFound)
+	assert.NoError(t, repo.CompleteUpload(upload.ID, time.Now()))
+	assert.ErrorIs(t, repo.CompleteUpload(upload.ID, time.Now()), gorm.ErrRecordNotFound, "完了は一度だけ")
+
+	// 期限切れの場合は使用できない
+	assert.ErrorIs(t, repo.ConsumeUpload(upload.ID, upload.ExpiresAt), gorm.ErrRecordNotFound)
+
+	// 使用は一度だけ
+	assert.NoError(t, repo.ConsumeUpload(upload.ID, time.Now()))
+	assert.ErrorIs(t, repo.ConsumeUpload(upload.ID, time.Now()), gorm.ErrRecordNotFound)
+
+	found = model.Upload{}
+	assert.NoError(t, repo.GetUploadByID(&found, user.ID, upload.ID))
+	assert.NotNil(t, found.CompletedAt)
+	assert.NotNil(t, found.ConsumedAt)
+	assert.Zero(t, found.ReservedBytes, "使用すると使用量に加えた分は0にする")
+}
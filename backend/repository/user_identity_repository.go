@@ -0,0 +1,66 @@
+package repository
+
+// 外部のOIDCアカウントとユーザーの紐付けの検索・作成・削除
+
+import (
+	"backend/model"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type IUserIdentityRepository interface {
+	GetIdentity(identity *model.UserIdentity, provider string, subject string) error
+	GetIdentitiesByUserID(userID uint) ([]model.UserIdentity, error)
+	CreateIdentity(identity *model.UserIdentity) error
+	CreateUserWithIdentity(user *model.User, identity *model.UserIdentity) error // ユーザーと紐付けを同時に作成
+	DeleteIdentity(userID uint, provider string) error
+}
+
+type userIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewUserIdentityRepository(db *gorm.DB) IUserIdentityRepository {
+	return &userIdentityRepository{db}
+}
+
+func (ir *userIdentityRepository) GetIdentity(identity *model.UserIdentity, provider string, subject string) error {
+	return ir.db.Session(&gorm.Session{PrepareStmt: false}).
+		Where("provider = ? AND subject = ?", provider, subject).First(identity).Error
+}
+
+func (ir *userIdentityRepository) GetIdentitiesByUserID(userID uint) ([]model.UserIdentity, error) {
+	identities := []model.UserIdentity{}
+	if err := ir.db.Session(&gorm.Session{PrepareStmt: false}).
+		Where("user_id = ?", userID).Order("created_at").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+func (ir *userIdentityRepository) CreateIdentity(identity *model.UserIdentity) error {
+	return ir.db.Session(&gorm.Session{PrepareStmt: false}).Create(identity).Error
+}
+
+func (ir *userIdentityRepository) CreateUserWithIdentity(user *model.User, identity *model.UserIdentity) error {
+	return ir.db.Session(&gorm.Session{PrepareStmt: false}).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+func (ir *userIdentityRepository) DeleteIdentity(userID uint, provider string) error {
+	result := ir.db.Session(&gorm.Session{PrepareStmt: false}).
+		Where("user_id = ? AND provider = ?", userID, provider).Delete(&model.UserIdentity{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
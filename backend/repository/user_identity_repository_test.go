@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"testing"
+
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestUserIdentities(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	repo := NewUserIdentityRepository(db)
+	user := CreateTestUser(db)
+
+	assert.NoError(t, repo.CreateIdentity(&model.UserIdentity{UserID: user.ID, Provider: "google", Subject: "sub-1"}))
+
+	identity := model.UserIdentity{}
+	assert.NoError(t, repo.GetIdentity(&identity, "google", "sub-1"))
+	assert.Equal(t, user.ID, identity.UserID)
+
+	// 同じプロバイダーのアカウントは別のユーザーに紐付けられない
+	other := &model.User{Name: "Other", Email: "other@example.com"}
+	err := repo.CreateUserWithIdentity(other, &model.UserIdentity{Provider: "google", Subject: "sub-1"})
+	assert.Error(t, err)
+	assert.Error(t, db.Where("email = ?", "other@example.com").First(&model.User{}).Error, "ユーザーの作成もロールバックされる")
+
+	// 新しいアカウントであればユーザーと一緒に作成される
+	newUser := &model.User{Name: "New", Email: "new@example.com"}
+	assert.NoError(t, repo.CreateUserWithIdentity(newUser, &model.UserIdentity{Provider: "google", Subject: "sub-2"}))
+	identities, err := repo.GetIdentitiesByUserID(newUser.ID)
+	assert.NoError(t, err)
+	assert.Len(t, identities, 1)
+
+	assert.NoError(t, repo.DeleteIdentity(user.ID, "google"))
+	assert.ErrorIs(t, repo.DeleteIdentity(user.ID, "google"), gorm.ErrRecordNotFound)
+}
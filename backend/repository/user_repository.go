@@ -11,8 +11,12 @@ import (
 
 type IUserRepository interface {
 	GetUserByEmail(user *model.User, email string) error
+	GetUserByID(userID uint) (*model.User, error)
 	CreateUser(user *model.User) error
 	UpdateUser(user *model.User) error
+	UpdatePassword(userID uint, passwordHash string) error
+	UpdateTwoFactor(user *model.User) error                   // 二要素認証の設定を保存
+	UpdateTOTPLastStep(userID uint, step int64) (bool, error) // 使用済みステップを進める（既に使用済みならfalse）
 }
 
 type userRepository struct {
@@ -33,6 +37,16 @@ func (ur *userRepository) GetUserByEmail(user *model.User, email string) error {
 	return nil
 }
 
+func (ur *userRepository) GetUserByID(userID uint) (*model.User, error) {
+	user := model.User{}
+	if err := ur.db.Session(&gorm.Session{
+		PrepareStmt: false,
+	}).First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (ur *userRepository) CreateUser(user *model.User) error {
 	// プリペアドステートメントを無効化したトランザクションを開始
 	tx := ur.db.Session(&gorm.Session{
@@ -86,3 +100,29 @@ func (ur *userRepository) UpdateUser(user *model.User) error {
 
 	return nil
 }
+
+func (ur *userRepository) UpdatePassword(userID uint, passwordHash string) error {
+	return ur.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.User{}).
+		Where("id = ?", userID).Update("password", passwordHash).Error
+}
+
+func (ur *userRepository) UpdateTwoFactor(user *model.User) error {
+	return ur.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.User{}).Where("id = ?", user.ID).
+		Select("totp_secret", "totp_enabled", "totp_last_step").
+		Updates(map[string]interface{}{
+			"totp_secret":    user.TOTPSecret,
+			"totp_enabled":   user.TOTPEnabled,
+			"totp_last_step": user.TOTPLastStep,
+		}).Error
+}
+
+func (ur *userRepository) UpdateTOTPLastStep(userID uint, step int64) (bool, error) {
+	// 同じコードが並行して使われても一方しか成功しないよう条件付きで更新する
+	result := ur.db.Session(&gorm.Session{PrepareStmt: false}).Model(&model.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -174,3 +174,19 @@ func TestUpdateUser(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdatePassword(t *testing.T) {
+	db := SetupTestDB()
+	defer CleanupTestDB(db)
+
+	repo := NewUserRepository(db)
+	user := CreateTestUser(db)
+
+	hash := "$argon2id$v=19$m=65536,t=3,p=4$c2FsdHNhbHRzYWx0c2FsdA$aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g"
+	assert.NoError(t, repo.UpdatePassword(user.ID, hash))
+
+	updated, err := repo.GetUserByID(user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, updated.Password)
+	assert.Equal(t, user.Email, updated.Email, "パスワード以外は変更しない")
+}
@@ -1,32 +1,52 @@
 package router
 
 import (
+	"backend/auth"
 	"backend/controller"
+	"backend/storage"
 	"net/http"
-	"os"
+	"strings"
 
-	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
-func NewRouter(uc controller.IUserController, cc controller.ICuisineController) *echo.Echo {
+// Config はルーティングの設定
+type Config struct {
+	FrontendURL  string // CORSで許可するフロントエンドのオリジン（FE_URL）
+	CookieDomain string // cookieのドメイン（API_DOMAIN）
+}
+
+func NewRouter(uc controller.IUserController, cc controller.ICuisineController, tfc controller.ITwoFactorController, oc controller.IOIDCController, pc controller.IPersonalAccessTokenController, ac controller.IAdminController, sc controller.ISecurityEventController, stc controller.IStorageController, upc controller.IUploadController, tc controller.ITusController, ic controller.IImageController, suc controller.IStorageUsageController, authn *auth.Authenticator, cfg Config) *echo.Echo {
 	e := echo.New()
+	// プロキシ（Cloud Run）経由のリクエストでも接続元IPを正しく取得する
+	e.IPExtractor = echo.ExtractIPFromXFFHeader()
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{ // corsのミドルウェア
-		AllowOrigins: []string{"http://localhost:3000", os.Getenv("FE_URL")}, // デプロイしたときに取得できるドメイン
-		AllowHeaders: []string{
+		// tusの機能の確認（プリフライトでないOPTIONS /files）はハンドラーで応答する
+		Skipper: func(c echo.Context) bool {
+			req := c.Request()
+			return req.Method == http.MethodOptions && req.URL.Path == "/files" && req.Header.Get(echo.HeaderAccessControlRequestMethod) == ""
+		},
+		AllowOrigins: []string{"http://localhost:3000", cfg.FrontendURL}, // デプロイしたときに取得できるドメイン
+		AllowHeaders: append([]string{
 			echo.HeaderOrigin,
 			echo.HeaderContentType,
 			echo.HeaderAccept, // 許可するヘッダーの一覧
 			echo.HeaderAuthorization,
 			echo.HeaderAccessControlAllowHeaders,
-			echo.HeaderXCSRFToken},
-		AllowMethods:     []string{"GET", "PUT", "POST", "DELETE", "OPTIONS"}, // 許可したいメソッド
-		AllowCredentials: true,                                                // クッキーの送受信を可能にする
+			echo.HeaderXCSRFToken}, controller.TusRequestHeaders...),
+		AllowMethods:     []string{"GET", "HEAD", "PUT", "PATCH", "POST", "DELETE", "OPTIONS"}, // 許可したいメソッド
+		ExposeHeaders:    controller.TusResponseHeaders,                                        // tusのクライアントが読むヘッダー
+		AllowCredentials: true,                                                                 // クッキーの送受信を可能にする
 	}))
 	e.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{ // csrfのミドルウェア
+		// パーソナルアクセストークンによるリクエストはcookieを使わないため検証しない
+		// ローカルの保存先への直接アップロードは署名付きURLで認可するため検証しない
+		Skipper: func(c echo.Context) bool {
+			return auth.HasBearerToken(c) || strings.HasPrefix(c.Request().URL.Path, storage.LocalRoutePrefix)
+		},
 		CookiePath:     "/",
-		CookieDomain:   os.Getenv("API_DOMAIN"),
+		CookieDomain:   cfg.CookieDomain,
 		CookieHTTPOnly: true,
 		CookieSameSite: http.SameSiteNoneMode,
 		// CookieSameSite: http.SameSiteDefaultMode, // postmanで確認のため
@@ -39,7 +59,16 @@ func NewRouter(uc controller.IUserController, cc controller.ICuisineController)
 	e.GET("/csrf", uc.CsrfToken)
 	e.POST("/signup", uc.SignUp)
 	e.POST("/login", uc.Login)
-	e.POST("/logout", uc.Logout)
+	e.POST("/login/2fa", tfc.VerifyLogin)           // 二要素認証が有効な場合のコード検証
+	e.POST("/logout", uc.Logout, authn.Optional())  // ログイン中であればセッションを失効させる
+	e.GET("/auth/providers", oc.Providers)          // 設定済みのOIDCプロバイダー
+	e.GET("/auth/:provider/login", oc.Login)        // プロバイダーの認可画面へリダイレクト
+	e.GET("/auth/:provider/callback", oc.Callback)  // 認可後のコールバック
+	e.POST("/email/confirm", uc.ConfirmEmailChange) // メールで送ったリンクのトークンで反映する（ログイン不要）
+	e.POST("/email/undo", uc.UndoEmailChange)
+	e.GET(storage.LocalRoutePrefix+"*", stc.GetObject) // ローカルの保存先の署名付きURL（ログイン不要）
+	e.PUT(storage.LocalRoutePrefix+"*", stc.PutObject) // ローカルの保存先への直接アップロード
+	e.GET("/img/*", ic.GetImage)                       // リサイズした画像（署名済みのURLのみ、ログイン不要）
 	// e.PUT("/update", uc.Update)
 	// e.PUT("/update", uc.Update, echojwt.WithConfig(echojwt.Config{
 	// 	SigningKey:  []byte(os.Getenv("SECRET")),
@@ -47,22 +76,59 @@ func NewRouter(uc controller.IUserController, cc controller.ICuisineController)
 	// }))
 
 	u := e.Group("/update")
-	u.Use(echojwt.WithConfig(echojwt.Config{
-		SigningKey:  []byte(os.Getenv("SECRET")),
-		TokenLookup: "cookie:token",
-	}))
+	u.Use(authn.Session())
 	u.PUT("", uc.Update)
 
+	m := e.Group("/me")
+	m.Use(authn.Session())
+	m.POST("/password", uc.ChangePassword) // 現在のパスワードを確認して変更する
+	m.POST("/2fa/enroll", tfc.Enroll)      // 二要素認証の登録開始
+	m.POST("/2fa/confirm", tfc.Confirm)    // 最初のコードで有効化
+	m.GET("/identities", oc.ListIdentities)
+	m.POST("/identities/:provider", oc.Connect) // 外部アカウントの連携を開始
+	m.DELETE("/identities/:provider", oc.Disconnect)
+	m.GET("/tokens", pc.ListTokens) // パーソナルアクセストークン
+	m.POST("/tokens", pc.CreateToken)
+	m.DELETE("/tokens/:tokenID", pc.RevokeToken)
+	m.GET("/security-events", sc.ListSecurityEvents) // ログイン履歴などのセキュリティイベント
+	m.GET("/usage", suc.GetUsage)                    // 保存先の使用量と上限
+
 	c := e.Group("/cuisines")
-	c.Use(echojwt.WithConfig(echojwt.Config{ // エンドポイントにミドルウェアを追加
-		SigningKey:  []byte(os.Getenv("SECRET")),
-		TokenLookup: "cookie:token",
-	}))
-	c.GET("", cc.GetAllCuisines)            // cuisinesのエンドポイントにリクエストがあった場合
-	c.GET("/:cuisineID", cc.GetCuisineByID) // リクエストパラメーターにcuisineIDが入力された場合
-	c.POST("", cc.AddCuisine)               // cuisineテーブル追加
+	// エンドポイントに認証ミドルウェアを追加（パーソナルアクセストークンも受け付け、スコープを確認する）
+	c.Use(authn.SessionOrToken())
+	read := auth.RequireScope(auth.ScopeReadCuisines)
+	write := auth.RequireScope(auth.ScopeWriteCuisines)
+	c.GET("", cc.GetAllCuisines, read)            // cuisinesのエンドポイントにリクエストがあった場合
+	c.GET("/duplicates", cc.GetDuplicates, read)  // 重複している可能性のある料理
+	c.GET("/:cuisineID", cc.GetCuisineByID, read) // リクエストパラメーターにcuisineIDが入力された場合
+	c.POST("", cc.AddCuisine, write)              // cuisineテーブル追加
 	// c.PUT("/:cuisineID", cc.UpdateCuisine) // titleしか更新されない
-	c.DELETE("/:cuisineID", cc.DeleteCuisine)
+	c.DELETE("/:cuisineID", cc.DeleteCuisine, write)
+
+	up := e.Group("/uploads")
+	// 保存先への直接アップロード（完了したIDを料理の追加・アイコンの変更で指定する）
+	up.Use(authn.SessionOrToken(), write)
+	up.POST("", upc.CreateUpload)                      // 署名付きURLを発行する
+	up.POST("/:uploadID/complete", upc.CompleteUpload) // 大きさと種類を確認する
+
+	f := e.Group("/files")
+	// tusプロトコルによる再開可能なアップロード（完了したIDを料理の追加・アイコンの変更で指定する）
+	e.OPTIONS("/files", tc.Options) // 対応するバージョン・拡張（ログイン不要）
+	f.Use(authn.SessionOrToken(), write)
+	f.POST("", tc.Create)
+	f.HEAD("/:uploadID", tc.Head) // 受け取ったバイト数
+	f.PATCH("/:uploadID", tc.Patch)
+	f.DELETE("/:uploadID", tc.Terminate)
+
+	a := e.Group("/admin")
+	// 管理者APIはログインセッションのみ受け付け、adminロールを要求する
+	a.Use(authn.Session(), auth.RequireRole(auth.RoleAdmin))
+	a.GET("/users", ac.ListUsers) // ?q=検索語&page=&per_page=
+	a.GET("/users/:userID", ac.GetUser)
+	a.POST("/users/:userID/disable", ac.DisableUser)
+	a.POST("/users/:userID/enable", ac.EnableUser)
+	a.POST("/users/:userID/logout", ac.ForceLogout) // すべてのセッションを失効させる
+	a.GET("/metrics", ac.Metrics)
 
 	// c.PUT("/:cuisineID", cc.SetCuisine) // cuisineの更新
 	// c.PUT("/url/:cuisineID", cc.AddURL)
@@ -0,0 +1,79 @@
+package storage
+
+// 再生成できるオブジェクト（配信時にリサイズした画像など）のキャッシュ
+// 保存先のprefix以下に保存し、合計の大きさがmaxBytesを超えたら更新日時の古いものから削除する
+// 合計はインスタンスごとに見積もり、超えた場合は一覧し直して正確な値で削除する（複数のインスタンスで共有できる）
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+)
+
+// cacheEvictRatio は削除後の合計の目安（上限に対する割合。上限付近で削除を繰り返さないため）
+const cacheEvictRatio = 0.9
+
+type Cache struct {
+	st       ObjectStore
+	prefix   string
+	maxBytes int64
+
+	mu    sync.Mutex
+	size  int64 // 見積もった合計
+	known bool  // sizeを一覧から求めたか
+}
+
+// NewCache はstのprefix以下をキャッシュとして使う（prefixは / で終える）
+func NewCache(st ObjectStore, prefix string, maxBytes int64) *Cache {
+	return &Cache{st: st, prefix: prefix, maxBytes: maxBytes}
+}
+
+// Get はキャッシュした内容を返す（ない場合はErrNotFound）
+func (c *Cache) Get(ctx context.Context, name string) (io.ReadCloser, ObjectInfo, error) {
+	return c.st.Get(ctx, c.prefix+name)
+}
+
+// Put は保存し、上限を超えた場合は古いものを削除する
+func (c *Cache) Put(ctx context.Context, name string, contentType string, r io.Reader, size int64) error {
+	if size > c.maxBytes {
+		return nil // 上限より大きいものはキャッシュしない
+	}
+	if err := c.st.Put(ctx, c.prefix+name, contentType, r); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size += size
+	if c.known && c.size <= c.maxBytes {
+		return nil
+	}
+	return c.evict(ctx)
+}
+
+// evict は一覧して合計を求め、上限を超えていれば古いものから削除する
+func (c *Cache) evict(ctx context.Context) error {
+	infos, err := c.st.List(ctx, c.prefix)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, info := range infos {
+		total += info.Size
+	}
+	if total > c.maxBytes {
+		sort.SliceStable(infos, func(i, j int) bool { return infos[i].UpdatedAt.Before(infos[j].UpdatedAt) })
+		target := int64(float64(c.maxBytes) * cacheEvictRatio)
+		for _, info := range infos {
+			if total <= target {
+				break
+			}
+			if err := c.st.Delete(ctx, info.Key); err != nil {
+				return err
+			}
+			total -= info.Size
+		}
+	}
+	c.size, c.known = total, true
+	return nil
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemoryStore()
+	require.NoError(t, st.Put(ctx, "images/1/a.jpg", "image/jpeg", strings.NewReader("original")))
+	cache := NewCache(st, "cache/img/", 10)
+	put := func(name string, data string) {
+		t.Helper()
+		require.NoError(t, cache.Put(ctx, name, "image/jpeg", strings.NewReader(data), int64(len(data))))
+		time.Sleep(time.Millisecond) // 更新日時の順にするため
+	}
+	keys := func() []string {
+		infos, err := st.List(ctx, "")
+		require.NoError(t, err)
+		keys := []string{}
+		for _, info := range infos {
+			keys = append(keys, info.Key)
+		}
+		return keys
+	}
+
+	put("a", "1234")
+	r, info, err := cache.Get(ctx, "a")
+	require.NoError(t, err)
+	r.Close()
+	assert.Equal(t, int64(4), info.Size)
+	_, _, err = cache.Get(ctx, "x")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	put("b", "1234")
+	assert.Equal(t, []string{"cache/img/a", "cache/img/b", "images/1/a.jpg"}, keys())
+
+	// 上限を超えたら古いものから削除する（prefix以外は削除しない）
+	put("c", "1234")
+	assert.Equal(t, []string{"cache/img/b", "cache/img/c", "images/1/a.jpg"}, keys())
+
+	// 上限より大きいものはキャッシュしない
+	put("d", "12345678901")
+	assert.Equal(t, []string{"cache/img/b", "cache/img/c", "images/1/a.jpg"}, keys())
+}
@@ -0,0 +1,128 @@
+package storage
+
+// Cloud Storageの保存先（本番環境）
+// クライアントは起動時に1つだけ作成して使い回す
+// 署名にはクライアントの認証情報を使う（Cloud Runではサービスアカウント）
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+type gcsStore struct {
+	client *gcs.Client
+	bucket *gcs.BucketHandle
+}
+
+func NewGCSStore(ctx context.Context, bucket string) (ObjectStore, error) {
+	client, err := gcs.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+	return &gcsStore{client, client.Bucket(bucket)}, nil
+}
+
+func (s *gcsStore) Put(ctx context.Context, key string, contentType string, r io.Reader) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	w := s.bucket.Object(key).NewWriter(ctx)
+	w.ContentType = contentType
+	w.CacheControl = privateCacheControl
+	if _, err := io.Copy(w, r); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to close writer: %w", err)
+	}
+	return nil
+}
+
+func (s *gcsStore) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	r, err := s.bucket.Object(key).NewReader(ctx)
+	if err != nil {
+		return nil, ObjectInfo{}, gcsError(err)
+	}
+	return r, ObjectInfo{
+		Key:         key,
+		Size:        r.Attrs.Size,
+		ContentType: r.Attrs.ContentType,
+		UpdatedAt:   r.Attrs.LastModified,
+	}, nil
+}
+
+func (s *gcsStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	attrs, err := s.bucket.Object(key).Attrs(ctx)
+	if err != nil {
+		return ObjectInfo{}, gcsError(err)
+	}
+	return gcsInfo(attrs), nil
+}
+
+func (s *gcsStore) Delete(ctx context.Context, key string) error {
+	if err := s.bucket.Object(key).Delete(ctx); err != nil && !errors.Is(err, gcs.ErrObjectNotExist) {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+func (s *gcsStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	infos := []ObjectInfo{}
+	it := s.bucket.Objects(ctx, &gcs.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, gcsInfo(attrs))
+	}
+	return infos, nil
+}
+
+func (s *gcsStore) SignURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	return s.bucket.SignedURL(key, &gcs.SignedURLOptions{
+		Method:  "GET",
+		Expires: time.Now().Add(ttl),
+		Scheme:  gcs.SigningSchemeV4,
+	})
+}
+
+func (s *gcsStore) SignPutURL(ctx context.Context, key string, contentType string, ttl time.Duration) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	return s.bucket.SignedURL(key, &gcs.SignedURLOptions{
+		Method:      "PUT",
+		ContentType: contentType,
+		Expires:     time.Now().Add(ttl),
+		Scheme:      gcs.SigningSchemeV4,
+	})
+}
+
+func (s *gcsStore) Close() error {
+	return s.client.Close()
+}
+
+func gcsInfo(attrs *gcs.ObjectAttrs) ObjectInfo {
+	return ObjectInfo{Key: attrs.Name, Size: attrs.Size, ContentType: attrs.ContentType, UpdatedAt: attrs.Updated}
+}
+
+func gcsError(err error) error {
+	if errors.Is(err, gcs.ErrObjectNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
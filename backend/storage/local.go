@@ -0,0 +1,243 @@
+package storage
+
+// ローカルディスクの保存先（開発環境・単一インスタンス用）
+// 署名付きURLはこのAPIの /storage/<キー>?expires=...&signature=... を指し、HMAC-SHA256で署名する
+// アップロード用（PUT）の署名にはメソッドとContent-Typeを含め、参照用のURLではアップロードできないようにする
+// Content-Typeは保存せず、キーの拡張子から判定する
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalRoutePrefix はローカルの保存先のオブジェクトを配信するパス
+const LocalRoutePrefix = "/storage/"
+
+// 書き込み中のファイルの接頭辞（Listでは返さない）
+const localTempPrefix = ".tmp-"
+
+// LocalConfig はローカルの保存先の設定
+type LocalConfig struct {
+	Dir        string // 保存先のディレクトリ
+	PublicURL  string // 署名付きURLのベース（このAPIのURL）
+	SigningKey string // 署名付きURLの署名に使う鍵
+}
+
+type localStore struct {
+	dir       string
+	publicURL string
+	key       []byte
+}
+
+func NewLocalStore(cfg LocalConfig) (ObjectStore, error) {
+	if cfg.SigningKey == "" {
+		return nil, errors.New("storage signing key is required for local storage")
+	}
+	dir, err := filepath.Abs(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &localStore{dir, strings.TrimRight(cfg.PublicURL, "/"), []byte(cfg.SigningKey)}, nil
+}
+
+func (s *localStore) path(key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// Put は一時ファイルに書き込んでから置き換える（途中で失敗しても壊れたファイルを残さない）
+func (s *localStore) Put(ctx context.Context, key string, contentType string, r io.Reader) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), localTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // 置き換えた後は存在しない
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+func (s *localStore) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, ObjectInfo{}, localError(err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, ObjectInfo{}, err
+	}
+	if fi.IsDir() {
+		f.Close()
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+	return f, localInfo(key, fi), nil
+}
+
+func (s *localStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return ObjectInfo{}, localError(err)
+	}
+	if fi.IsDir() {
+		return ObjectInfo{}, ErrNotFound
+	}
+	return localInfo(key, fi), nil
+}
+
+func (s *localStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *localStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	infos := []ObjectInfo{}
+	err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), localTempPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		infos = append(infos, localInfo(key, fi))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func (s *localStore) SignURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	return s.signedURL(key, expires, s.sign(key+"\n"+expires)), nil
+}
+
+func (s *localStore) SignPutURL(ctx context.Context, key string, contentType string, ttl time.Duration) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	return s.signedURL(key, expires, s.sign(putSigningString(key, contentType, expires))), nil
+}
+
+func (s *localStore) signedURL(key string, expires string, signature string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	q := url.Values{"expires": {expires}, "signature": {signature}}
+	return s.publicURL + LocalRoutePrefix + strings.Join(segments, "/") + "?" + q.Encode()
+}
+
+// VerifySignedURL は署名付きURLのキー・有効期限・署名を確認する
+func (s *localStore) VerifySignedURL(key string, expires string, signature string) error {
+	return s.verify(key+"\n"+expires, expires, signature)
+}
+
+// VerifySignedPutURL はアップロード用の署名付きURLとContent-Typeを確認する
+func (s *localStore) VerifySignedPutURL(key string, contentType string, expires string, signature string) error {
+	return s.verify(putSigningString(key, contentType, expires), expires, signature)
+}
+
+func (s *localStore) verify(signed string, expires string, signature string) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(signed))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func putSigningString(key string, contentType string, expires string) string {
+	return "PUT\n" + key + "\n" + contentType + "\n" + expires
+}
+
+func (s *localStore) sign(signed string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(signed))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *localStore) Close() error {
+	return nil
+}
+
+func localInfo(key string, fi fs.FileInfo) ObjectInfo {
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return ObjectInfo{Key: key, Size: fi.Size(), ContentType: contentType, UpdatedAt: fi.ModTime()}
+}
+
+func localError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
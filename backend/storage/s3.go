@@ -0,0 +1,146 @@
+package storage
+
+// S3互換（AWS S3・MinIO・Cloudflare R2など）の保存先
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// サイズが分からない場合のマルチパートアップロードの1パートの大きさ
+// 指定しないと最大サイズを前提に大きなバッファを確保してしまう
+const s3PartSize = 16 << 20
+
+// S3Config はS3互換の保存先の設定
+type S3Config struct {
+	Endpoint        string // 例: s3.ap-northeast-1.amazonaws.com / localhost:9000
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	UseSSL          bool
+}
+
+type s3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+func NewS3Store(bucket string, cfg S3Config) (ObjectStore, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("S3 endpoint is required")
+	}
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	return &s3Store{client, bucket}, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, contentType string, r io.Reader) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	size := int64(-1)
+	if sr, ok := r.(interface{ Size() int64 }); ok { // bytes.Readerなど
+		size = sr.Size()
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType:  contentType,
+		CacheControl: privateCacheControl,
+		PartSize:     s3PartSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	return nil
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, ObjectInfo{}, s3Error(err)
+	}
+	// GetObjectはリクエストを遅延させるため、Statで存在を確認する
+	stat, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, ObjectInfo{}, s3Error(err)
+	}
+	return obj, s3Info(stat), nil
+}
+
+func (s *s3Store) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	stat, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, s3Error(err)
+	}
+	return s3Info(stat), nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	// S3は存在しないキーの削除も成功する
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+func (s *s3Store) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	infos := []ObjectInfo{}
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		infos = append(infos, s3Info(obj))
+	}
+	return infos, nil
+}
+
+func (s *s3Store) SignURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, ttl, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *s3Store) SignPutURL(ctx context.Context, key string, contentType string, ttl time.Duration) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	// Content-Typeを署名に含め、異なる種類でのアップロードを拒否させる
+	u, err := s.client.PresignHeader(ctx, http.MethodPut, s.bucket, key, ttl, nil, http.Header{"Content-Type": {contentType}})
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *s3Store) Close() error {
+	return nil
+}
+
+func s3Info(obj minio.ObjectInfo) ObjectInfo {
+	return ObjectInfo{Key: obj.Key, Size: obj.Size, ContentType: obj.ContentType, UpdatedAt: obj.LastModified}
+}
+
+func s3Error(err error) error {
+	if code := minio.ToErrorResponse(err).Code; code == "NoSuchKey" || code == "NotFound" {
+		return ErrNotFound
+	}
+	return err
+}
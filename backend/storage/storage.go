@@ -0,0 +1,90 @@
+package storage
+
+// 画像などのオブジェクトの保存先
+// STORAGE_BACKENDでCloud Storage（gcs）・ローカルディスク（local）・S3互換（s3）・メモリ（memory）を切り替え、mainで注入する
+// いずれも非公開で保存し、参照には期限付きの署名付きURLを発行する
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound         = errors.New("object not found")
+	ErrInvalidKey       = errors.New("invalid object key")
+	ErrInvalidSignature = errors.New("invalid or expired signature")
+)
+
+// privateCacheControl は保存するオブジェクトのCache-Control（署名付きURLでのみ参照する）
+const privateCacheControl = "private, max-age=86400"
+
+// ObjectInfo は保存したオブジェクトの情報
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string // Listでは保存先によって空の場合がある
+	UpdatedAt   time.Time
+}
+
+// ObjectStore はオブジェクトの保存先
+// キーは / 区切りの相対パス（例: user_icons/1/<uuid>.png）
+type ObjectStore interface {
+	Put(ctx context.Context, key string, contentType string, r io.Reader) error
+	// Get は内容と情報を返す（読み終わったらCloseする。ctxはCloseするまで有効にしておく）
+	Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Delete は存在しないキーでもエラーにしない
+	Delete(ctx context.Context, key string) error
+	// List はキーがprefixで始まるオブジェクトをキーの昇順で返す
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	SignURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// SignPutURL はクライアントが直接アップロードするための署名付きURL（PUT）を返す
+	// アップロードする際はContent-TypeヘッダーにcontentTypeを指定する必要がある
+	SignPutURL(ctx context.Context, key string, contentType string, ttl time.Duration) (string, error)
+	Close() error
+}
+
+// SignedURLVerifier はアプリ自身が署名付きURLを配信・受信する保存先（local）が実装する
+type SignedURLVerifier interface {
+	VerifySignedURL(key string, expires string, signature string) error
+	VerifySignedPutURL(key string, contentType string, expires string, signature string) error
+}
+
+// Config は保存先の設定
+type Config struct {
+	Backend string // gcs（既定）/ local / s3 / memory
+	Bucket  string // gcs・s3のバケット
+	Local   LocalConfig
+	S3      S3Config
+}
+
+// New は設定に応じた保存先を返す
+func New(ctx context.Context, cfg Config) (ObjectStore, error) {
+	switch cfg.Backend {
+	case "gcs", "":
+		return NewGCSStore(ctx, cfg.Bucket)
+	case "local":
+		return NewLocalStore(cfg.Local)
+	case "s3":
+		return NewS3Store(cfg.Bucket, cfg.S3)
+	case "memory":
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %q", cfg.Backend)
+	}
+}
+
+// validateKey はキーが正規化された相対パスであることを確認する
+// localではファイルパスになるため、保存先の外を指すキーを受け付けない
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.ContainsAny(key, "\\\x00") ||
+		path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testObjectStore はすべての保存先で共通の振る舞いを確認する
+func testObjectStore(t *testing.T, s ObjectStore) {
+	ctx := context.Background()
+	png := []byte("\x89PNG\r\n\x1a\nimage")
+
+	require.NoError(t, s.Put(ctx, "user_icons/1/a.png", "image/png", bytes.NewReader(png)))
+	require.NoError(t, s.Put(ctx, "user_icons/1/b.png", "image/png", strings.NewReader("second")))
+	require.NoError(t, s.Put(ctx, "images/2/c.jpg", "image/jpeg", bytes.NewReader([]byte("jpeg"))))
+
+	r, info, err := s.Get(ctx, "user_icons/1/a.png")
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	r.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, png, data)
+	assert.Equal(t, int64(len(png)), info.Size)
+	assert.Equal(t, "image/png", info.ContentType)
+
+	info, err = s.Stat(ctx, "images/2/c.jpg")
+	assert.NoError(t, err)
+	assert.Equal(t, "images/2/c.jpg", info.Key)
+	assert.Equal(t, int64(4), info.Size)
+	assert.False(t, info.UpdatedAt.IsZero())
+
+	infos, err := s.List(ctx, "user_icons/")
+	assert.NoError(t, err)
+	keys := []string{}
+	for _, info := range infos {
+		keys = append(keys, info.Key)
+	}
+	assert.Equal(t, []string{"user_icons/1/a.png", "user_icons/1/b.png"}, keys)
+
+	signed, err := s.SignURL(ctx, "user_icons/1/a.png", 15*time.Minute)
+	assert.NoError(t, err)
+	assert.Contains(t, signed, "user_icons/1/a.png")
+	signed, err = s.SignPutURL(ctx, "uploads/1/d.png", "image/png", 15*time.Minute)
+	assert.NoError(t, err)
+	assert.Contains(t, signed, "uploads/1/d.png")
+	_, err = s.SignPutURL(ctx, "../d.png", "image/png", time.Minute)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	// 上書き・削除
+	require.NoError(t, s.Put(ctx, "user_icons/1/a.png", "image/png", strings.NewReader("new")))
+	info, err = s.Stat(ctx, "user_icons/1/a.png")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), info.Size)
+	assert.NoError(t, s.Delete(ctx, "user_icons/1/a.png"))
+	assert.NoError(t, s.Delete(ctx, "user_icons/1/a.png"), "存在しないキーの削除はエラーにしない")
+
+	_, err = s.Stat(ctx, "user_icons/1/a.png")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, _, err = s.Get(ctx, "user_icons/1/a.png")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	for _, key := range []string{"", "/etc/passwd", "../secret.png", "a/../../b.png", "a//b.png"} {
+		assert.ErrorIs(t, s.Put(ctx, key, "image/png", strings.NewReader("x")), ErrInvalidKey, key)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testObjectStore(t, NewMemoryStore())
+}
+
+func TestLocalStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewLocalStore(LocalConfig{Dir: dir, PublicURL: "https://api.example.com/", SigningKey: "secret"})
+	require.NoError(t, err)
+	testObjectStore(t, s)
+
+	// 書き込み中の一時ファイルは残さない
+	entries, err := os.ReadDir(filepath.Join(dir, "user_icons", "1"))
+	assert.NoError(t, err)
+	for _, e := range entries {
+		assert.False(t, strings.HasPrefix(e.Name(), localTempPrefix))
+	}
+}
+
+func TestLocalStoreSignedURL(t *testing.T) {
+	s, err := NewLocalStore(LocalConfig{Dir: t.TempDir(), PublicURL: "https://api.example.com/", SigningKey: "secret"})
+	require.NoError(t, err)
+	v := s.(SignedURLVerifier)
+
+	signed, err := s.SignURL(context.Background(), "images/1/料理.jpg", time.Minute)
+	require.NoError(t, err)
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "api.example.com", u.Host)
+	assert.Equal(t, LocalRoutePrefix+"images/1/料理.jpg", u.Path)
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+	assert.NoError(t, v.VerifySignedURL("images/1/料理.jpg", expires, signature))
+
+	assert.ErrorIs(t, v.VerifySignedURL("images/1/other.jpg", expires, signature), ErrInvalidSignature, "別のキー")
+	assert.ErrorIs(t, v.VerifySignedURL("images/1/料理.jpg", expires+"0", signature), ErrInvalidSignature, "期限の改ざん")
+	assert.ErrorIs(t, v.VerifySignedURL("images/1/料理.jpg", expires, ""), ErrInvalidSignature)
+
+	expired, err := s.SignURL(context.Background(), "images/1/a.jpg", -time.Minute)
+	require.NoError(t, err)
+	u, _ = url.Parse(expired)
+	assert.ErrorIs(t, v.VerifySignedURL("images/1/a.jpg", u.Query().Get("expires"), u.Query().Get("signature")), ErrInvalidSignature, "期限切れ")
+
+	other, err := NewLocalStore(LocalConfig{Dir: t.TempDir(), SigningKey: "another"})
+	require.NoError(t, err)
+	assert.ErrorIs(t, other.(SignedURLVerifier).VerifySignedURL("images/1/料理.jpg", expires, signature), ErrInvalidSignature, "別の鍵")
+}
+
+func TestLocalStoreSignedPutURL(t *testing.T) {
+	s, err := NewLocalStore(LocalConfig{Dir: t.TempDir(), PublicURL: "https://api.example.com/", SigningKey: "secret"})
+	require.NoError(t, err)
+	v := s.(SignedURLVerifier)
+
+	signed, err := s.SignPutURL(context.Background(), "uploads/1/a.png", "image/png", time.Minute)
+	require.NoError(t, err)
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	assert.Equal(t, LocalRoutePrefix+"uploads/1/a.png", u.Path)
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+	assert.NoError(t, v.VerifySignedPutURL("uploads/1/a.png", "image/png", expires, signature))
+
+	assert.ErrorIs(t, v.VerifySignedPutURL("uploads/1/a.png", "text/html", expires, signature), ErrInvalidSignature, "別のContent-Type")
+	assert.ErrorIs(t, v.VerifySignedURL("uploads/1/a.png", expires, signature), ErrInvalidSignature, "参照には使えない")
+
+	get, err := s.SignURL(context.Background(), "uploads/1/a.png", time.Minute)
+	require.NoError(t, err)
+	u, _ = url.Parse(get)
+	assert.ErrorIs(t, v.VerifySignedPutURL("uploads/1/a.png", "image/png", u.Query().Get("expires"), u.Query().Get("signature")), ErrInvalidSignature, "参照用のURLではアップロードできない")
+}
+
+// TestS3Store はS3_TEST_ENDPOINTが設定されている場合のみMinIOなどに対して実行する
+// 例: docker run -p 9000:9000 minio/minio server /data
+// S3_TEST_ENDPOINT=localhost:9000 S3_TEST_ACCESS_KEY_ID=minioadmin S3_TEST_SECRET_ACCESS_KEY=minioadmin go test ./storage
+func TestS3Store(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT is not set")
+	}
+	bucket := "cookmeet-test-" + time.Now().Format("20060102150405")
+	s, err := NewS3Store(bucket, S3Config{
+		Endpoint:        endpoint,
+		AccessKeyID:     os.Getenv("S3_TEST_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("S3_TEST_SECRET_ACCESS_KEY"),
+	})
+	require.NoError(t, err)
+	client := s.(*s3Store).client
+	ctx := context.Background()
+	require.NoError(t, client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{}))
+	t.Cleanup(func() {
+		for obj := range client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Recursive: true}) {
+			_ = client.RemoveObject(ctx, bucket, obj.Key, minio.RemoveObjectOptions{})
+		}
+		_ = client.RemoveBucket(ctx, bucket)
+	})
+
+	testObjectStore(t, s)
+
+	t.Run("署名付きURLで直接アップロードできる", func(t *testing.T) {
+		signed, err := s.SignPutURL(ctx, "uploads/1/a.png", "image/png", time.Minute)
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPut, signed, strings.NewReader("png"))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "image/png")
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		info, err := s.Stat(ctx, "uploads/1/a.png")
+		assert.NoError(t, err)
+		assert.Equal(t, "image/png", info.ContentType)
+	})
+}
+
+func TestNew(t *testing.T) {
+	s, err := New(context.Background(), Config{Backend: "memory"})
+	assert.NoError(t, err)
+	assert.NotNil(t, s)
+
+	_, err = New(context.Background(), Config{Backend: "ftp"})
+	assert.Error(t, err)
+	_, err = New(context.Background(), Config{Backend: "local", Local: LocalConfig{Dir: t.TempDir()}})
+	assert.Error(t, err, "署名の鍵が必要")
+}
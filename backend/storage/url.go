@@ -0,0 +1,38 @@
+package storage
+
+// 以前に保存していたURLからオブジェクトのキーを取り出す（保存済みのデータの移行用）
+
+import (
+	"net/url"
+	"strings"
+)
+
+// KeyFromURL はURLからキーを取り出す（取り出せない場合はfalse）
+// 次の形式に対応する（クエリの署名は無視する）
+//   - Cloud Storage・S3のパス形式: https://storage.googleapis.com/<bucket>/<key>
+//   - 仮想ホスト形式: https://<bucket>.storage.googleapis.com/<key>
+//   - local: <STORAGE_PUBLIC_URL>/storage/<key>
+//   - memory: memory:///<key>
+func KeyFromURL(rawURL string, bucket string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" {
+		return "", false
+	}
+	var key string
+	switch {
+	case u.Scheme == "memory":
+		key = strings.TrimPrefix(u.Path, "/")
+	case bucket != "" && strings.HasPrefix(u.Host, bucket+"."):
+		key = strings.TrimPrefix(u.Path, "/")
+	case bucket != "" && strings.HasPrefix(u.Path, "/"+bucket+"/"):
+		key = strings.TrimPrefix(u.Path, "/"+bucket+"/")
+	case strings.HasPrefix(u.Path, LocalRoutePrefix):
+		key = strings.TrimPrefix(u.Path, LocalRoutePrefix)
+	default:
+		return "", false
+	}
+	if validateKey(key) != nil {
+		return "", false
+	}
+	return key, true
+}
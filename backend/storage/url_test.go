@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyFromURL(t *testing.T) {
+	testCases := []struct {
+		url string
+		key string
+	}{
+		{"https://storage.googleapis.com/cookmeet/images/1/a.jpg?X-Goog-Algorithm=GOOG4-RSA-SHA256&X-Goog-Expires=604800", "images/1/a.jpg"},
+		{"https://storage.googleapis.com/cookmeet/images/1/a.jpg", "images/1/a.jpg"},
+		{"https://cookmeet.storage.googleapis.com/images/1/a.jpg?X-Goog-Signature=abc", "images/1/a.jpg"},
+		{"http://localhost:9000/cookmeet/images/1/a.jpg?X-Amz-Signature=abc", "images/1/a.jpg"},
+		{"http://localhost:8081/storage/images/1/%E6%96%99%E7%90%86.jpg?expires=1&signature=abc", "images/1/料理.jpg"},
+		{"memory:///images/1/a.jpg?expires=1", "images/1/a.jpg"},
+		{"https://example.com/images/1/a.jpg", ""},
+		{"https://storage.googleapis.com/other/images/1/a.jpg", ""},
+		{"https://storage.googleapis.com/cookmeet/../secret", ""},
+		{"images/1/a.jpg", ""},
+		{"", ""},
+	}
+	for _, tc := range testCases {
+		key, ok := KeyFromURL(tc.url, "cookmeet")
+		assert.Equal(t, tc.key, key, tc.url)
+		assert.Equal(t, tc.key != "", ok, tc.url)
+	}
+}
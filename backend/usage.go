@@ -0,0 +1,36 @@
+package main
+
+// 保存先の使用量の計算し直し
+// サブコマンド: backend recompute-storage-usage [-dry-run]
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"backend/usecase"
+)
+
+// runStorageUsageRecomputeCommand はrecompute-storage-usageサブコマンドを実行する
+func runStorageUsageRecomputeCommand(args []string, ru usecase.IStorageUsageRecomputeUsecase, out io.Writer) error {
+	fs := flag.NewFlagSet("recompute-storage-usage", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "置き換えずにずれを表示する")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := ru.Run(context.Background(), usecase.StorageUsageRecomputeOptions{DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+	mode := "updated"
+	if report.DryRun {
+		mode = "dry-run"
+	}
+	fmt.Fprintf(out, "%s: users=%d drifted=%d\n", mode, report.Users, len(report.Drifted))
+	for _, d := range report.Drifted {
+		fmt.Fprintf(out, "user %d: recorded=%d actual=%d\n", d.UserID, d.Recorded, d.Actual)
+	}
+	return nil
+}
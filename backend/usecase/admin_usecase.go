@@ -0,0 +1,182 @@
+package usecase
+
+// 管理者向けのユーザー検索・詳細・無効化・強制ログアウトと、利用状況の集計を実装
+// すべての操作は、実行前に監査ログへ記録する（記録できなければ操作を行わない）
+
+import (
+	"backend/auth"
+	"backend/model"
+	"backend/repository"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrCannotModifySelf = errors.New("admins cannot disable or log out themselves")
+
+const (
+	defaultAdminPerPage = 20
+	maxAdminPerPage     = 100
+	signUpMetricsDays   = 30
+)
+
+type IAdminUsecase interface {
+	ListUsers(actorID uint, query string, page int, perPage int, client model.ClientInfo) (model.AdminUserList, error)
+	GetUser(actorID uint, userID uint, client model.ClientInfo) (model.AdminUserDetail, error)
+	SetUserDisabled(actorID uint, userID uint, disabled bool, client model.ClientInfo) error
+	ForceLogout(actorID uint, userID uint, client model.ClientInfo) error
+	Metrics(actorID uint, client model.ClientInfo) (model.AdminMetrics, error)
+}
+
+type adminUsecase struct {
+	ur  repository.IUserRepository
+	adr repository.IAdminRepository
+	sr  repository.ISessionRepository
+	ar  repository.IAuditEventRepository
+	su  IStorageUsageUsecase
+	now func() time.Time
+}
+
+func NewAdminUsecase(ur repository.IUserRepository, adr repository.IAdminRepository, sr repository.ISessionRepository, ar repository.IAuditEventRepository, su IStorageUsageUsecase) IAdminUsecase {
+	return &adminUsecase{ur, adr, sr, ar, su, time.Now}
+}
+
+func toAdminUserResponse(u model.User) model.AdminUserResponse {
+	role := u.Role
+	if role == "" {
+		role = auth.RoleUser
+	}
+	return model.AdminUserResponse{
+		ID:         u.ID,
+		Name:       u.Name,
+		Email:      u.Email,
+		Role:       role,
+		DisabledAt: u.DisabledAt,
+		CreatedAt:  u.CreatedAt,
+	}
+}
+
+func (au *adminUsecase) ListUsers(actorID uint, query string, page int, perPage int, client model.ClientInfo) (model.AdminUserList, error) {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = defaultAdminPerPage
+	}
+	if perPage > maxAdminPerPage {
+		perPage = maxAdminPerPage
+	}
+	if err := recordAudit(au.ar, actorID, AuditAdminListUsers, nil, client, map[string]interface{}{"query": query, "page": page}); err != nil {
+		return model.AdminUserList{}, err
+	}
+
+	users, total, err := au.adr.SearchUsers(query, (page-1)*perPage, perPage)
+	if err != nil {
+		return model.AdminUserList{}, err
+	}
+	res := make([]model.AdminUserResponse, 0, len(users))
+	for _, u := range users {
+		res = append(res, toAdminUserResponse(u))
+	}
+	return model.AdminUserList{Users: res, Total: total, Page: page, PerPage: perPage}, nil
+}
+
+func (au *adminUsecase) GetUser(actorID uint, userID uint, client model.ClientInfo) (model.AdminUserDetail, error) {
+	user, err := au.getUser(userID)
+	if err != nil {
+		return model.AdminUserDetail{}, err
+	}
+	if err := recordAudit(au.ar, actorID, AuditAdminViewUser, &userID, client, nil); err != nil {
+		return model.AdminUserDetail{}, err
+	}
+
+	cuisines, err := au.adr.CountCuisines(userID)
+	if err != nil {
+		return model.AdminUserDetail{}, err
+	}
+	usage, err := au.su.GetUsage(userID)
+	if err != nil {
+		return model.AdminUserDetail{}, err
+	}
+	return model.AdminUserDetail{
+		AdminUserResponse: toAdminUserResponse(*user),
+		CuisineCount:      cuisines,
+		Storage:           usage,
+	}, nil
+}
+
+func (au *adminUsecase) SetUserDisabled(actorID uint, userID uint, disabled bool, client model.ClientInfo) error {
+	if actorID == userID {
+		return ErrCannotModifySelf
+	}
+	if _, err := au.getUser(userID); err != nil {
+		return err
+	}
+	action := AuditAdminEnableUser
+	var disabledAt *time.Time
+	if disabled {
+		action = AuditAdminDisableUser
+		now := au.now()
+		disabledAt = &now
+	}
+	if err := recordAudit(au.ar, actorID, action, &userID, client, nil); err != nil {
+		return err
+	}
+	if err := au.adr.SetUserDisabled(userID, disabledAt); err != nil {
+		return err
+	}
+	if disabled {
+		// 無効化したアカウントのセッションは直ちに失効させる
+		return au.sr.RevokeUserSessions(userID, "")
+	}
+	return nil
+}
+
+func (au *adminUsecase) ForceLogout(actorID uint, userID uint, client model.ClientInfo) error {
+	if actorID == userID {
+		return ErrCannotModifySelf
+	}
+	if _, err := au.getUser(userID); err != nil {
+		return err
+	}
+	if err := recordAudit(au.ar, actorID, AuditAdminForceLogout, &userID, client, nil); err != nil {
+		return err
+	}
+	return au.sr.RevokeUserSessions(userID, "")
+}
+
+func (au *adminUsecase) Metrics(actorID uint, client model.ClientInfo) (model.AdminMetrics, error) {
+	if err := recordAudit(au.ar, actorID, AuditAdminViewMetrics, nil, client, nil); err != nil {
+		return model.AdminMetrics{}, err
+	}
+	now := au.now()
+	total, err := au.adr.CountUsers()
+	if err != nil {
+		return model.AdminMetrics{}, err
+	}
+	dau, err := au.sr.CountActiveUsers(now.Add(-24 * time.Hour))
+	if err != nil {
+		return model.AdminMetrics{}, err
+	}
+	mau, err := au.sr.CountActiveUsers(now.AddDate(0, 0, -30))
+	if err != nil {
+		return model.AdminMetrics{}, err
+	}
+	signUps, err := au.adr.CountSignUpsByDay(now.AddDate(0, 0, -signUpMetricsDays))
+	if err != nil {
+		return model.AdminMetrics{}, err
+	}
+	return model.AdminMetrics{TotalUsers: total, DAU: dau, MAU: mau, SignUps: signUps}, nil
+}
+
+func (au *adminUsecase) getUser(userID uint) (*model.User, error) {
+	user, err := au.ur.GetUserByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
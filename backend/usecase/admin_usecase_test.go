@@ -0,0 +1,221 @@
+package usecase
+
+import (
+	"backend/model"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type MockAdminRepository struct {
+	mock.Mock
+}
+
+func (m *MockAdminRepository) SearchUsers(query string, offset int, limit int) ([]model.User, int64, error) {
+	args := m.Called(query, offset, limit)
+	return args.Get(0).([]model.User), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockAdminRepository) SetUserDisabled(userID uint, disabledAt *time.Time) error {
+	args := m.Called(userID, disabledAt)
+	return args.Error(0)
+}
+
+func (m *MockAdminRepository) CountCuisines(userID uint) (int64, error) {
+	args := m.Called(userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAdminRepository) CountUsers() (int64, error) {
+	args := m.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAdminRepository) CountSignUpsByDay(since time.Time) ([]model.DailyCount, error) {
+	args := m.Called(since)
+	return args.Get(0).([]model.DailyCount), args.Error(1)
+}
+
+type MockAuditEventRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditEventRepository) CreateAuditEvent(event *model.AuditEvent) error {
+	args := m.Called(event)
+	return args.Error(0)
+}
+
+func (m *MockAuditEventRepository) GetEventsByTargetUser(userID uint, actions []string, limit int) ([]model.AuditEvent, error) {
+	args := m.Called(userID, actions, limit)
+	return args.Get(0).([]model.AuditEvent), args.Error(1)
+}
+
+// auditAction は指定したアクションの監査ログに一致する
+func auditAction(action string, target *uint) interface{} {
+	return mock.MatchedBy(func(e *model.AuditEvent) bool {
+		if e.Action != action || e.ActorID == nil || *e.ActorID != 1 || e.IP != testClient.IP {
+			return false
+		}
+		if target == nil {
+			return e.TargetUserID == nil
+		}
+		return e.TargetUserID != nil && *e.TargetUserID == *target
+	})
+}
+
+func TestAdminListUsers(t *testing.T) {
+	ur := new(MockUserRepository)
+	adr := new(MockAdminRepository)
+	ar := new(MockAuditEventRepository)
+	au := NewAdminUsecase(ur, adr, new(MockSessionRepository), ar, newTestStorageUsage())
+
+	ar.On("CreateAuditEvent", mock.MatchedBy(func(e *model.AuditEvent) bool {
+		return e.Action == AuditAdminListUsers && e.Metadata == `{"page":2,"query":"alice"}`
+	})).Return(nil).Once()
+	adr.On("SearchUsers", "alice", 100, maxAdminPerPage).Return([]model.User{{ID: 2, Email: "alice@example.com"}}, int64(101), nil).Once()
+
+	list, err := au.ListUsers(1, "alice", 2, 1000, testClient)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(101), list.Total)
+	assert.Equal(t, maxAdminPerPage, list.PerPage)
+	assert.Equal(t, "user", list.Users[0].Role)
+	ar.AssertExpectations(t)
+	adr.AssertExpectations(t)
+}
+
+func TestAdminGetUser(t *testing.T) {
+	target := uint(2)
+
+	t.Run("success", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		adr := new(MockAdminRepository)
+		ar := new(MockAuditEventRepository)
+		su := NewStorageUsageUsecase(newMemoryStorageUsageRepository(), 1000)
+		au := NewAdminUsecase(ur, adr, new(MockSessionRepository), ar, su)
+		require.NoError(t, su.Reserve(target, model.UploadPurposeCuisineImage, 300))
+		require.NoError(t, su.Reserve(target, model.UploadPurposeUserIcon, 20))
+
+		ur.On("GetUserByID", target).Return(&model.User{ID: 2, Role: "admin"}, nil)
+		ar.On("CreateAuditEvent", auditAction(AuditAdminViewUser, &target)).Return(nil).Once()
+		adr.On("CountCuisines", target).Return(int64(5), nil)
+
+		detail, err := au.GetUser(1, 2, testClient)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), detail.CuisineCount)
+		assert.Equal(t, int64(300), detail.Storage.CuisineImageBytes)
+		assert.Equal(t, int64(20), detail.Storage.UserIconBytes)
+		assert.Equal(t, int64(320), detail.Storage.UsedBytes)
+		assert.Equal(t, int64(1000), detail.Storage.QuotaBytes)
+		assert.Equal(t, "admin", detail.Role)
+		ar.AssertExpectations(t)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		au := NewAdminUsecase(ur, new(MockAdminRepository), new(MockSessionRepository), new(MockAuditEventRepository), newTestStorageUsage())
+		ur.On("GetUserByID", target).Return(nil, gorm.ErrRecordNotFound)
+
+		_, err := au.GetUser(1, 2, testClient)
+		assert.ErrorIs(t, err, ErrUserNotFound)
+	})
+
+	t.Run("監査ログに記録できなければ返さない", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		adr := new(MockAdminRepository)
+		ar := new(MockAuditEventRepository)
+		au := NewAdminUsecase(ur, adr, new(MockSessionRepository), ar, newTestStorageUsage())
+		ur.On("GetUserByID", target).Return(&model.User{ID: 2}, nil)
+		ar.On("CreateAuditEvent", mock.Anything).Return(errors.New("db down"))
+
+		_, err := au.GetUser(1, 2, testClient)
+		assert.Error(t, err)
+		adr.AssertNotCalled(t, "CountCuisines", mock.Anything)
+	})
+}
+
+func TestAdminSetUserDisabled(t *testing.T) {
+	target := uint(2)
+
+	t.Run("disable", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		adr := new(MockAdminRepository)
+		sr := new(MockSessionRepository)
+		ar := new(MockAuditEventRepository)
+		au := NewAdminUsecase(ur, adr, sr, ar, newTestStorageUsage())
+
+		ur.On("GetUserByID", target).Return(&model.User{ID: 2}, nil)
+		ar.On("CreateAuditEvent", auditAction(AuditAdminDisableUser, &target)).Return(nil).Once()
+		adr.On("SetUserDisabled", target, mock.MatchedBy(func(t *time.Time) bool { return t != nil })).Return(nil).Once()
+		sr.On("RevokeUserSessions", target, "").Return(nil).Once()
+
+		assert.NoError(t, au.SetUserDisabled(1, 2, true, testClient))
+		ar.AssertExpectations(t)
+		adr.AssertExpectations(t)
+		sr.AssertExpectations(t)
+	})
+
+	t.Run("enable", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		adr := new(MockAdminRepository)
+		sr := new(MockSessionRepository)
+		ar := new(MockAuditEventRepository)
+		au := NewAdminUsecase(ur, adr, sr, ar, newTestStorageUsage())
+
+		ur.On("GetUserByID", target).Return(&model.User{ID: 2}, nil)
+		ar.On("CreateAuditEvent", auditAction(AuditAdminEnableUser, &target)).Return(nil).Once()
+		adr.On("SetUserDisabled", target, (*time.Time)(nil)).Return(nil).Once()
+
+		assert.NoError(t, au.SetUserDisabled(1, 2, false, testClient))
+		sr.AssertNotCalled(t, "RevokeUserSessions", mock.Anything, mock.Anything)
+	})
+
+	t.Run("self", func(t *testing.T) {
+		au := NewAdminUsecase(new(MockUserRepository), new(MockAdminRepository), new(MockSessionRepository), new(MockAuditEventRepository), newTestStorageUsage())
+		assert.ErrorIs(t, au.SetUserDisabled(1, 1, true, testClient), ErrCannotModifySelf)
+	})
+}
+
+func TestAdminForceLogout(t *testing.T) {
+	target := uint(2)
+	ur := new(MockUserRepository)
+	sr := new(MockSessionRepository)
+	ar := new(MockAuditEventRepository)
+	au := NewAdminUsecase(ur, new(MockAdminRepository), sr, ar, newTestStorageUsage())
+
+	ur.On("GetUserByID", target).Return(&model.User{ID: 2}, nil)
+	ar.On("CreateAuditEvent", auditAction(AuditAdminForceLogout, &target)).Return(nil).Once()
+	sr.On("RevokeUserSessions", target, "").Return(nil).Once()
+
+	assert.NoError(t, au.ForceLogout(1, 2, testClient))
+	ar.AssertExpectations(t)
+	sr.AssertExpectations(t)
+}
+
+func TestAdminMetrics(t *testing.T) {
+	adr := new(MockAdminRepository)
+	sr := new(MockSessionRepository)
+	ar := new(MockAuditEventRepository)
+	au := NewAdminUsecase(new(MockUserRepository), adr, sr, ar, newTestStorageUsage())
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	au.(*adminUsecase).now = func() time.Time { return now }
+
+	ar.On("CreateAuditEvent", auditAction(AuditAdminViewMetrics, nil)).Return(nil).Once()
+	adr.On("CountUsers").Return(int64(100), nil)
+	sr.On("CountActiveUsers", now.Add(-24*time.Hour)).Return(int64(10), nil)
+	sr.On("CountActiveUsers", now.AddDate(0, 0, -30)).Return(int64(40), nil)
+	adr.On("CountSignUpsByDay", now.AddDate(0, 0, -30)).Return([]model.DailyCount{{Date: "2025-06-01", Count: 3}}, nil)
+
+	metrics, err := au.Metrics(1, testClient)
+	assert.NoError(t, err)
+	assert.Equal(t, model.AdminMetrics{
+		TotalUsers: 100,
+		DAU:        10,
+		MAU:        40,
+		SignUps:    []model.DailyCount{{Date: "2025-06-01", Count: 3}},
+	}, metrics)
+}
@@ -0,0 +1,91 @@
+package usecase
+
+// 監査ログに記録する操作の種類と、記録のヘルパー
+
+import (
+	"backend/model"
+	"backend/repository"
+	"encoding/json"
+	"log"
+)
+
+// 監査ログのアクション
+const (
+	AuditAdminListUsers   = "admin.users.list"
+	AuditAdminViewUser    = "admin.users.view"
+	AuditAdminDisableUser = "admin.users.disable"
+	AuditAdminEnableUser  = "admin.users.enable"
+	AuditAdminForceLogout = "admin.users.force_logout"
+	AuditAdminViewMetrics = "admin.metrics.view"
+
+	AuditLogin                = "auth.login"
+	AuditLoginFailed          = "auth.login_failed"
+	AuditLoginLocked          = "auth.login_locked"
+	AuditLogout               = "auth.logout"
+	AuditPasswordChanged      = "account.password_changed"
+	AuditEmailChanged         = "account.email_changed"
+	AuditEmailChangeRequested = "account.email_change_requested"
+	AuditEmailChangeUndone    = "account.email_change_undone"
+	AuditIconChanged          = "account.icon_changed"
+	AuditTokenCreated         = "token.created"
+	AuditTokenRevoked         = "token.revoked"
+)
+
+// securityEventActions は本人に表示するアクション（管理者の操作は含めない）
+var securityEventActions = []string{
+	AuditLogin,
+	AuditLoginFailed,
+	AuditLoginLocked,
+	AuditLogout,
+	AuditPasswordChanged,
+	AuditEmailChangeRequested,
+	AuditEmailChanged,
+	AuditEmailChangeUndone,
+	AuditIconChanged,
+	AuditTokenCreated,
+	AuditTokenRevoked,
+}
+
+// newAuditEvent は監査ログの1件を作成する
+func newAuditEvent(action string, actorID *uint, targetUserID *uint, client model.ClientInfo, metadata map[string]interface{}) (model.AuditEvent, error) {
+	meta := "{}"
+	if len(metadata) > 0 {
+		b, err := json.Marshal(metadata)
+		if err != nil {
+			return model.AuditEvent{}, err
+		}
+		meta = string(b)
+	}
+	return model.AuditEvent{
+		ActorID:      actorID,
+		Action:       action,
+		TargetUserID: targetUserID,
+		IP:           client.IP,
+		UserAgent:    client.UserAgent,
+		Metadata:     meta,
+	}, nil
+}
+
+// recordAudit は監査ログを同期的に記録する（記録できなければ操作を行わない管理者の操作で使用）
+func recordAudit(ar repository.IAuditEventRepository, actorID uint, action string, targetUserID *uint, client model.ClientInfo, metadata map[string]interface{}) error {
+	event, err := newAuditEvent(action, &actorID, targetUserID, client, metadata)
+	if err != nil {
+		return err
+	}
+	return ar.CreateAuditEvent(&event)
+}
+
+// logUserEvent はユーザー本人の操作を非同期に記録する
+func logUserEvent(al IAuditLogger, action string, userID uint, client model.ClientInfo, metadata map[string]interface{}) {
+	logEvent(al, action, &userID, &userID, client, metadata)
+}
+
+// logEvent は監査ログを非同期に記録する（記録の失敗は操作の結果に影響させない）
+func logEvent(al IAuditLogger, action string, actorID *uint, targetUserID *uint, client model.ClientInfo, metadata map[string]interface{}) {
+	event, err := newAuditEvent(action, actorID, targetUserID, client, metadata)
+	if err != nil {
+		log.Printf("failed to build audit event %s: %v", action, err)
+		return
+	}
+	al.Log(event)
+}
@@ -0,0 +1,75 @@
+package usecase
+
+// 監査ログの非同期書き込み
+// リクエストの処理を遅らせないよう、イベントをバッファに積んでバックグラウンドで書き込む
+// バッファが一杯の場合は待たずに破棄し、アプリケーションのログに残す
+
+import (
+	"backend/model"
+	"backend/repository"
+	"log"
+	"sync"
+	"time"
+)
+
+const DefaultAuditBufferSize = 1024
+
+type IAuditLogger interface {
+	Log(event model.AuditEvent) // 書き込みを待たずに戻る
+	Close()                     // バッファに残っているイベントを書き込んでから戻る
+}
+
+type auditLogger struct {
+	ar     repository.IAuditEventRepository
+	events chan model.AuditEvent
+	done   chan struct{}
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewAuditLogger(ar repository.IAuditEventRepository, bufferSize int) IAuditLogger {
+	al := &auditLogger{
+		ar:     ar,
+		events: make(chan model.AuditEvent, bufferSize),
+		done:   make(chan struct{}),
+	}
+	go al.run()
+	return al
+}
+
+func (al *auditLogger) run() {
+	defer close(al.done)
+	for event := range al.events {
+		if err := al.ar.CreateAuditEvent(&event); err != nil {
+			log.Printf("failed to write audit event %s: %v", event.Action, err)
+		}
+	}
+}
+
+func (al *auditLogger) Log(event model.AuditEvent) {
+	// 書き込みが遅れても発生した時刻を記録する
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+	if al.closed {
+		log.Printf("audit logger is closed; dropped %s", event.Action)
+		return
+	}
+	select {
+	case al.events <- event:
+	default:
+		log.Printf("audit log buffer is full; dropped %s", event.Action)
+	}
+}
+
+func (al *auditLogger) Close() {
+	al.mu.Lock()
+	if !al.closed {
+		al.closed = true
+		close(al.events)
+	}
+	al.mu.Unlock()
+	<-al.done
+}
@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"backend/model"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// recordingAuditLogger は記録されたイベントを保持するテスト用のIAuditLogger
+type recordingAuditLogger struct {
+	mu     sync.Mutex
+	events []model.AuditEvent
+}
+
+func newTestAuditLogger() *recordingAuditLogger {
+	return &recordingAuditLogger{}
+}
+
+func (l *recordingAuditLogger) Log(event model.AuditEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *recordingAuditLogger) Close() {}
+
+func (l *recordingAuditLogger) actions() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	actions := []string{}
+	for _, e := range l.events {
+		actions = append(actions, e.Action)
+	}
+	return actions
+}
+
+func TestAuditLogger(t *testing.T) {
+	t.Run("Closeで残りを書き込む", func(t *testing.T) {
+		mockRepo := new(MockAuditEventRepository)
+		mockRepo.On("CreateAuditEvent", mock.AnythingOfType("*model.AuditEvent")).Return(nil).Times(3)
+		al := NewAuditLogger(mockRepo, 10)
+
+		for i := 0; i < 3; i++ {
+			al.Log(model.AuditEvent{Action: AuditLogin})
+		}
+		al.Close()
+		mockRepo.AssertExpectations(t)
+
+		// 発生時刻はLogの呼び出し時に記録する
+		event := mockRepo.Calls[0].Arguments.Get(0).(*model.AuditEvent)
+		assert.WithinDuration(t, time.Now(), event.CreatedAt, time.Minute)
+	})
+
+	t.Run("バッファが一杯なら待たずに破棄する", func(t *testing.T) {
+		mockRepo := new(MockAuditEventRepository)
+		release := make(chan struct{})
+		mockRepo.On("CreateAuditEvent", mock.Anything).Run(func(mock.Arguments) { <-release }).Return(nil)
+		al := NewAuditLogger(mockRepo, 1)
+
+		done := make(chan struct{})
+		go func() {
+			for i := 0; i < 5; i++ {
+				al.Log(model.AuditEvent{Action: AuditLogin})
+			}
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Log blocked while the buffer was full")
+		}
+		close(release)
+		al.Close()
+		assert.LessOrEqual(t, len(mockRepo.Calls), 2)
+	})
+
+	t.Run("Close後のLogは破棄する", func(t *testing.T) {
+		mockRepo := new(MockAuditEventRepository)
+		al := NewAuditLogger(mockRepo, 1)
+		al.Close()
+		al.Log(model.AuditEvent{Action: AuditLogin})
+		al.Close()
+		mockRepo.AssertNotCalled(t, "CreateAuditEvent", mock.Anything)
+	})
+}
@@ -0,0 +1,195 @@
+package usecase
+
+// 重複している可能性のある料理の検出
+// 料理画像は保存時に知覚ハッシュ（imageproc.PerceptualHash）を求めて記録し、ハミング距離がDuplicateImageThreshold以下の画像をほぼ同じとみなす
+// 料理の追加時には同じユーザーの画像がほぼ同じ料理を返す（追加は行う）
+// GET /cuisines/duplicates では画像がほぼ同じ料理と、正規化したタイトルとURLが同じ料理をそれぞれまとめて返す
+
+import (
+	"backend/imageproc"
+	"backend/model"
+	"errors"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var ErrInvalidDuplicateCriterion = errors.New("by must be image or title_url")
+
+// DuplicateImageThreshold はほぼ同じ画像とみなすハミング距離（64ビット中）の上限
+// 縮小・再圧縮・軽い編集では数ビットしか変わらず、別の写真は多くの場合20ビット以上変わる
+const DuplicateImageThreshold = 6
+
+// trackingQueryParams はURLの比較で無視するクエリパラメーター（utm_*も無視する）
+var trackingQueryParams = map[string]bool{"fbclid": true, "gclid": true, "igshid": true}
+
+// findImageDuplicates はユーザーの料理から画像がほぼ同じものを返す（取得に失敗した場合はログに出力して確認しない）
+func (cu *cuisineUsecase) findImageDuplicates(userID uint, imageHash string) []model.CuisineDuplicateMatch {
+	hash, err := imageproc.ParsePerceptualHash(imageHash)
+	if err != nil {
+		return nil // 画像がない・求められなかった場合
+	}
+	cuisines := []model.Cuisine{}
+	if err := cu.cr.GetAllCuisines(&cuisines, userID); err != nil {
+		log.Printf("failed to get cuisines to find duplicates for user %d: %v", userID, err)
+		return nil
+	}
+	matches := []model.CuisineDuplicateMatch{}
+	for _, c := range cuisines {
+		other, err := imageproc.ParsePerceptualHash(c.ImageHash)
+		if err != nil {
+			continue
+		}
+		if d := imageproc.HammingDistance(hash, other); d <= DuplicateImageThreshold {
+			matches = append(matches, model.CuisineDuplicateMatch{ID: c.ID, Title: c.Title, Distance: d})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Distance < matches[j].Distance })
+	return matches
+}
+
+func (cu *cuisineUsecase) GetDuplicates(userID uint, by string) ([]model.CuisineDuplicateGroup, error) {
+	if by != "" && by != model.CuisineDuplicateByImage && by != model.CuisineDuplicateByTitleURL {
+		return nil, ErrInvalidDuplicateCriterion
+	}
+	cuisines := []model.Cuisine{}
+	if err := cu.cr.GetAllCuisines(&cuisines, userID); err != nil {
+		return nil, err
+	}
+	sort.Slice(cuisines, func(i, j int) bool { return cuisines[i].ID < cuisines[j].ID })
+
+	groups := []model.CuisineDuplicateGroup{}
+	if by == "" || by == model.CuisineDuplicateByImage {
+		for _, g := range groupByImage(cuisines) {
+			groups = append(groups, cu.duplicateGroup(model.CuisineDuplicateByImage, g))
+		}
+	}
+	if by == "" || by == model.CuisineDuplicateByTitleURL {
+		for _, g := range groupByTitleURL(cuisines) {
+			groups = append(groups, cu.duplicateGroup(model.CuisineDuplicateByTitleURL, g))
+		}
+	}
+	return groups, nil
+}
+
+func (cu *cuisineUsecase) duplicateGroup(reason string, cuisines []model.Cuisine) model.CuisineDuplicateGroup {
+	group := model.CuisineDuplicateGroup{Reason: reason, Cuisines: []model.CuisineResponse{}}
+	for _, c := range cuisines {
+		res := model.CuisineResponse{
+			ID:        c.ID,
+			Title:     c.Title,
+			URL:       c.URL,
+			Comment:   c.Comment,
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: c.UpdatedAt,
+			UserID:    c.UserID,
+		}
+		setPlaceholder(&res, c)
+		cu.setImageURLs(&res, c.IconURL)
+		group.Cuisines = append(group.Cuisines, res)
+	}
+	return group
+}
+
+// groupByImage は画像がほぼ同じ料理をまとめる（AとB・BとCが近ければA・B・Cを1つにまとめる）
+// cuisinesはIDの昇順で渡し、まとまりは最も小さいIDの順に返す
+func groupByImage(cuisines []model.Cuisine) [][]model.Cuisine {
+	hashed := []model.Cuisine{}
+	hashes := []uint64{}
+	for _, c := range cuisines {
+		if hash, err := imageproc.ParsePerceptualHash(c.ImageHash); err == nil {
+			hashed = append(hashed, c)
+			hashes = append(hashes, hash)
+		}
+	}
+
+	parent := make([]int, len(hashed))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range hashed {
+		for j := i + 1; j < len(hashed); j++ {
+			if imageproc.HammingDistance(hashes[i], hashes[j]) <= DuplicateImageThreshold {
+				if a, b := find(i), find(j); a != b {
+					parent[max(a, b)] = min(a, b) // 小さい方を代表にして順序を保つ
+				}
+			}
+		}
+	}
+
+	members := map[int][]model.Cuisine{}
+	roots := []int{}
+	for i, c := range hashed {
+		root := find(i)
+		if members[root] == nil {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], c)
+	}
+	return duplicateGroups(roots, members)
+}
+
+// groupByTitleURL は正規化したタイトルとURLが同じ料理をまとめる（タイトルが空の料理は対象外）
+func groupByTitleURL(cuisines []model.Cuisine) [][]model.Cuisine {
+	members := map[string][]model.Cuisine{}
+	keys := []string{}
+	for _, c := range cuisines {
+		title := normalizeTitle(c.Title)
+		if title == "" {
+			continue
+		}
+		key := title + "\x00" + normalizeURL(c.URL)
+		if members[key] == nil {
+			keys = append(keys, key)
+		}
+		members[key] = append(members[key], c)
+	}
+	return duplicateGroups(keys, members)
+}
+
+// duplicateGroups は2件以上のまとまりをkeysの順に返す
+func duplicateGroups[K comparable](keys []K, members map[K][]model.Cuisine) [][]model.Cuisine {
+	groups := [][]model.Cuisine{}
+	for _, k := range keys {
+		if len(members[k]) > 1 {
+			groups = append(groups, members[k])
+		}
+	}
+	return groups
+}
+
+// normalizeTitle は全角・半角（NFKC）、大文字・小文字、空白の違いを無視したタイトルを返す
+func normalizeTitle(title string) string {
+	return strings.Join(strings.Fields(strings.ToLower(norm.NFKC.String(title))), " ")
+}
+
+// normalizeURL はスキーム・www・末尾のスラッシュ・フラグメント・トラッキング用のパラメーターの違いを無視したURLを返す
+func normalizeURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return strings.ToLower(raw)
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	query := u.Query()
+	for k := range query {
+		if trackingQueryParams[k] || strings.HasPrefix(k, "utm_") {
+			query.Del(k)
+		}
+	}
+	normalized := host + strings.TrimSuffix(u.EscapedPath(), "/")
+	if len(query) > 0 {
+		normalized += "?" + query.Encode() // キーの順に並べる
+	}
+	return normalized
+}
@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"testing"
+
+	"backend/model"
+	"backend/validator"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddCuisineDuplicateWarning(t *testing.T) {
+	mockRepo := new(MockCuisineRepository)
+	mockRepo.On("GetAllCuisines", mock.Anything, uint(1)).Return([]model.Cuisine{
+		{ID: 1, Title: "カレー", ImageHash: "f0e0c0c0e0f0f8fc"},
+		{ID: 2, Title: "カレー（2回目）", ImageHash: "f0e0c0c0e0f0f8f3"}, // 4ビット違い
+		{ID: 3, Title: "パスタ", ImageHash: "0f1f3f3f1f0f0703"},
+		{ID: 4, Title: "画像なし"},
+	}, nil).Once()
+	mockRepo.On("CreateCuisine", mock.AnythingOfType("*model.Cuisine")).Return(nil).Once()
+	cu := NewCuisineUsecase(mockRepo, validator.NewCuisineValidator(), newTestObjectStore(), newTestImageProcessor(), new(MockUploadRepository), newTestImageResizer(), newTestStorageUsage())
+
+	res, err := cu.AddCuisine(model.Cuisine{Title: "カレー", UserID: 1, ImageHash: "f0e0c0c0e0f0f8fc"}, nil, "", "カレー")
+	require.NoError(t, err)
+	assert.Equal(t, []model.CuisineDuplicateMatch{{ID: 1, Title: "カレー", Distance: 0}, {ID: 2, Title: "カレー（2回目）", Distance: 4}}, res.PossibleDuplicates)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetDuplicates(t *testing.T) {
+	cuisines := []model.Cuisine{
+		{ID: 5, Title: "Curry ", URL: "https://www.example.com/recipes/curry/?utm_source=x", ImageHash: "0f1f3f3f1f0f0703"},
+		{ID: 1, Title: "ｃｕｒｒｙ", URL: "http://example.com/recipes/curry", ImageHash: "f0e0c0c0e0f0f8fc"},
+		{ID: 2, Title: "カレー", ImageHash: "f0e0c0c0e0f0f8f0"},                                   // 1と2ビット違い
+		{ID: 3, Title: "カレー", URL: "https://example.com/other", ImageHash: "f0e0c0c0e0f0f800"}, // 2と4ビット違い（1とは6ビット）
+		{ID: 4, Title: "", ImageHash: "bad"},
+	}
+	setup := func() ICuisineUsecase {
+		mockRepo := new(MockCuisineRepository)
+		mockRepo.On("GetAllCuisines", mock.Anything, uint(1)).Return(cuisines, nil)
+		return NewCuisineUsecase(mockRepo, validator.NewCuisineValidator(), newTestObjectStore(), newTestImageProcessor(), new(MockUploadRepository), newTestImageResizer(), newTestStorageUsage())
+	}
+	ids := func(group model.CuisineDuplicateGroup) []uint {
+		res := []uint{}
+		for _, c := range group.Cuisines {
+			res = append(res, c.ID)
+		}
+		return res
+	}
+
+	t.Run("画像がほぼ同じ料理とタイトル・URLが同じ料理をまとめる", func(t *testing.T) {
+		groups, err := setup().GetDuplicates(1, "")
+		require.NoError(t, err)
+		require.Len(t, groups, 2)
+		assert.Equal(t, model.CuisineDuplicateByImage, groups[0].Reason)
+		assert.Equal(t, []uint{1, 2, 3}, ids(groups[0]))
+		assert.Equal(t, model.CuisineDuplicateByTitleURL, groups[1].Reason)
+		assert.Equal(t, []uint{1, 5}, ids(groups[1]))
+	})
+
+	t.Run("まとめ方を指定する", func(t *testing.T) {
+		groups, err := setup().GetDuplicates(1, model.CuisineDuplicateByTitleURL)
+		require.NoError(t, err)
+		require.Len(t, groups, 1)
+		assert.Equal(t, model.CuisineDuplicateByTitleURL, groups[0].Reason)
+
+		_, err = setup().GetDuplicates(1, "color")
+		assert.ErrorIs(t, err, ErrInvalidDuplicateCriterion)
+	})
+}
+
+func TestNormalizeTitleAndURL(t *testing.T) {
+	assert.Equal(t, "chicken curry", normalizeTitle("  Ｃｈｉｃｋｅｎ　 Curry "))
+	assert.Equal(t, "カレー", normalizeTitle("ｶﾚｰ"))
+
+	for _, tc := range []struct {
+		a, b string
+	}{
+		{"https://www.example.com/a/", "http://example.com/a"},
+		{"https://example.com/a?b=2&a=1#top", "https://EXAMPLE.com/a?a=1&b=2"},
+		{"https://example.com/a?utm_medium=x&fbclid=y", "https://example.com/a"},
+		{" cookpad ", "cookpad"},
+	} {
+		assert.Equal(t, normalizeURL(tc.a), normalizeURL(tc.b), tc.a)
+	}
+	assert.NotEqual(t, normalizeURL("https://example.com/a?id=1"), normalizeURL("https://example.com/a?id=2"))
+}
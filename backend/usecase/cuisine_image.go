@@ -0,0 +1,202 @@
+package usecase
+
+// 料理画像のアップロード・削除と署名付きURLの発行
+// 画像はimageprocで確認・変換し、images/<ユーザーID>/<uuid>/<サイズ>.<拡張子> に保存する
+// Cuisine.IconURLには元のサイズ（full）のキーを記録し、他のサイズのキーはそこから求める
+// 以前にアップロードした画像（images/<ユーザーID>/<uuid>.<拡張子>）は変換していないため、すべてのサイズで同じキーを使う
+// 直接アップロード（upload.go）した画像も同じように変換し、使用済みにしてから元のオブジェクトを削除する
+// 保存時にサムネイルからBlurHashと代表色、重複を見つけるための知覚ハッシュを求め、料理に記録する（以前の画像はbackfill-placeholdersで求める）
+// 保存するすべてのサイズの大きさを保存先の使用量（storage_usage.go）に加え、削除した分は減らす
+
+import (
+	"backend/imageproc"
+	"backend/model"
+	"backend/storage"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidImage  = errors.New("image must be a JPEG, PNG, GIF or WebP image")
+	ErrImageTooLarge = errors.New("image is too large")
+)
+
+const (
+	cuisineImageKeyPrefix = "images/"
+	cuisineImageURLTTL    = time.Hour
+)
+
+// cuisineImageVariants はレスポンスで返すサイズ
+var cuisineImageVariants = []string{imageproc.VariantThumbnail, imageproc.VariantMedium, imageproc.VariantFull}
+
+// UploadImage は画像を変換してサイズごとに保存し、元のサイズのキーを返す
+func (cu *cuisineUsecase) UploadImage(userID uint, iconFile *multipart.FileHeader) (model.CuisineImage, error) {
+	src, err := iconFile.Open()
+	if err != nil {
+		return model.CuisineImage{}, err
+	}
+	defer src.Close()
+	return cu.storeImage(userID, src)
+}
+
+// UploadImageFromUpload は完了済みの直接アップロードを変換してサイズごとに保存し、元のサイズのキーを返す
+// 同じデータを使用量に二重に加えないよう、アップロードを使用済みにして作成時に加えた分を減らしてから保存する
+func (cu *cuisineUsecase) UploadImageFromUpload(userID uint, uploadID string) (model.CuisineImage, error) {
+	upload, data, err := readUpload(cu.ur, cu.st, userID, uploadID, model.UploadPurposeCuisineImage)
+	if err != nil {
+		return model.CuisineImage{}, err
+	}
+	if err := consumeUpload(cu.ur, cu.st, cu.su, upload); err != nil {
+		return model.CuisineImage{}, err
+	}
+	return cu.storeImage(userID, bytes.NewReader(data))
+}
+
+func (cu *cuisineUsecase) storeImage(userID uint, src io.Reader) (model.CuisineImage, error) {
+	outputs, err := cu.ip.Process(src)
+	switch {
+	case errors.Is(err, imageproc.ErrTooLarge), errors.Is(err, imageproc.ErrTooManyPixels):
+		return model.CuisineImage{}, fmt.Errorf("%w: %v", ErrImageTooLarge, err)
+	case errors.Is(err, imageproc.ErrUnsupportedFormat):
+		return model.CuisineImage{}, ErrInvalidImage
+	case err != nil:
+		return model.CuisineImage{}, err
+	}
+
+	var size int64
+	for _, out := range outputs {
+		size += int64(len(out.Data))
+	}
+	if err := cu.su.Reserve(userID, model.UploadPurposeCuisineImage, size); err != nil {
+		return model.CuisineImage{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	dir := fmt.Sprintf("%s%d/%s/", cuisineImageKeyPrefix, userID, uuid.New().String())
+	image := model.CuisineImage{}
+	for _, out := range outputs {
+		key := dir + out.Variant + out.Ext
+		if err := cu.st.Put(ctx, key, out.ContentType, bytes.NewReader(out.Data)); err != nil {
+			cu.removeImage(dir + imageproc.VariantFull + out.Ext) // 途中まで保存したサイズを残さない
+			cu.su.Release(userID, model.UploadPurposeCuisineImage, size)
+			return model.CuisineImage{}, err
+		}
+		switch out.Variant {
+		case imageproc.VariantFull:
+			image.Key = key
+		case imageproc.VariantThumbnail:
+			// 求められなくても画像は保存する（プレースホルダーが表示されない・重複を確認しないのみ）
+			if p, err := cu.ip.Placeholder(bytes.NewReader(out.Data)); err != nil {
+				log.Printf("failed to compute placeholder for %s: %v", key, err)
+			} else {
+				image.BlurHash, image.DominantColor = p.BlurHash, p.DominantColor
+			}
+			if hash, err := cu.ip.PerceptualHash(bytes.NewReader(out.Data)); err != nil {
+				log.Printf("failed to compute perceptual hash for %s: %v", key, err)
+			} else {
+				image.PerceptualHash = imageproc.FormatPerceptualHash(hash)
+			}
+		}
+	}
+	return image, nil
+}
+
+// setPlaceholder はレスポンスにBlurHashと代表色を設定する
+func setPlaceholder(res *model.CuisineResponse, cuisine model.Cuisine) {
+	res.BlurHash = cuisine.BlurHash
+	res.DominantColor = cuisine.DominantColor
+}
+
+// imageKey は保存されているオブジェクトのキーを返す
+// 移行できなかった以前のURLはキーとして扱わない
+func imageKey(iconURL *string) (string, bool) {
+	if iconURL == nil || *iconURL == "" || strings.Contains(*iconURL, "://") {
+		return "", false
+	}
+	return *iconURL, true
+}
+
+// imageVariantKeys は元のサイズのキーからサイズごとのキーを求める
+func imageVariantKeys(fullKey string) map[string]string {
+	dir, base := path.Split(fullKey)
+	ext := path.Ext(base)
+	keys := map[string]string{}
+	for _, v := range cuisineImageVariants {
+		if strings.TrimSuffix(base, ext) == imageproc.VariantFull {
+			keys[v] = dir + v + ext
+		} else {
+			keys[v] = fullKey // 変換していない以前の画像
+		}
+	}
+	return keys
+}
+
+// setImageURLs はレスポンスにサイズごとの署名付きURLを設定する（未設定・発行に失敗した場合は設定しない）
+func (cu *cuisineUsecase) setImageURLs(res *model.CuisineResponse, iconURL *string) {
+	key, ok := imageKey(iconURL)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	urls := map[string]string{}
+	for v, k := range imageVariantKeys(key) {
+		url, err := cu.st.SignURL(ctx, k, cuisineImageURLTTL)
+		if err != nil {
+			log.Printf("failed to sign cuisine image url %s: %v", k, err)
+			return
+		}
+		urls[v] = url
+	}
+	full := urls[imageproc.VariantFull]
+	res.IconURL = &full
+	res.Images = &model.CuisineImageURLs{
+		Thumbnail: urls[imageproc.VariantThumbnail],
+		Medium:    urls[imageproc.VariantMedium],
+		Full:      full,
+		Presets:   cu.rs.PresetURLs(key),
+	}
+}
+
+// deleteImage はすべてのサイズの画像を削除し、使用量から減らす（失敗してもログに出力するのみ）
+func (cu *cuisineUsecase) deleteImage(userID uint, fullKey string) {
+	cu.su.Release(userID, model.UploadPurposeCuisineImage, cu.removeImage(fullKey))
+}
+
+// removeImage はすべてのサイズの画像を削除し、削除した大きさの合計を返す
+func (cu *cuisineUsecase) removeImage(fullKey string) int64 {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	deleted := map[string]bool{}
+	var size int64
+	for _, k := range imageVariantKeys(fullKey) {
+		if deleted[k] {
+			continue
+		}
+		deleted[k] = true
+		info, err := cu.st.Stat(ctx, k)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("failed to stat cuisine image %s: %v", k, err) // 削除は続ける（使用量のずれは計算し直しで直す）
+		}
+		if err := cu.st.Delete(ctx, k); err != nil {
+			log.Printf("failed to delete cuisine image %s: %v", k, err)
+			continue
+		}
+		size += info.Size
+	}
+	return size
+}
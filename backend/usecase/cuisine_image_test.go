@@ -0,0 +1,240 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"backend/imageproc"
+	"backend/model"
+	"backend/storage"
+	"backend/validator"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newTestImageProcessor() *imageproc.Processor {
+	return imageproc.NewProcessor(imageproc.DefaultLimits())
+}
+
+// newTestJPEG は指定した大きさのJPEGを作成する
+func newTestJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 200
+	}
+	img.Set(0, 0, color.Black)
+	buf := new(bytes.Buffer)
+	require.NoError(t, jpeg.Encode(buf, img, nil))
+	return buf.Bytes()
+}
+
+func TestUploadImage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("サイズごとに保存し、レスポンスでそれぞれのURLを返す", func(t *testing.T) {
+		st := newTestObjectStore()
+		cu := NewCuisineUsecase(new(MockCuisineRepository), validator.NewCuisineValidator(), st, newTestImageProcessor(), new(MockUploadRepository), newTestImageResizer(), newTestStorageUsage()).(*cuisineUsecase)
+
+		// 拡張子ではなく内容から種類を判定する
+		image, err := cu.UploadImage(1, newTestFileHeader(t, "curry.png", newTestJPEG(t, 1200, 900)))
+		require.NoError(t, err)
+		key := image.Key
+		assert.Len(t, image.BlurHash, 28)
+		assert.Regexp(t, `^#[0-9a-f]{6}$`, image.DominantColor)
+		assert.Regexp(t, `^[0-9a-f]{16}$`, image.PerceptualHash)
+		assert.Regexp(t, `^images/1/[0-9a-f-]{36}/full\.jpg$`, key)
+
+		keys := storedKeys(t, st)
+		assert.Len(t, keys, 3)
+		for variant, k := range imageVariantKeys(key) {
+			info, err := st.Stat(ctx, k)
+			if assert.NoError(t, err, variant) {
+				assert.Equal(t, "image/jpeg", info.ContentType)
+			}
+		}
+
+		res := model.CuisineResponse{}
+		cu.setImageURLs(&res, &key)
+		if assert.NotNil(t, res.Images) {
+			assert.Contains(t, res.Images.Thumbnail, "/thumbnail.jpg?")
+			assert.Contains(t, res.Images.Medium, "/medium.jpg?")
+			assert.Contains(t, res.Images.Full, "/full.jpg?")
+			assert.Equal(t, res.Images.Full, *res.IconURL)
+			assert.Len(t, res.Images.Presets, len(ImagePresets))
+			assert.Contains(t, res.Images.Presets["og"], "/img/"+key+"?")
+		}
+	})
+
+	t.Run("以前の画像はすべてのサイズで同じURLを返す", func(t *testing.T) {
+		cu := NewCuisineUsecase(new(MockCuisineRepository), validator.NewCuisineValidator(), newTestObjectStore(), newTestImageProcessor(), new(MockUploadRepository), newTestImageResizer(), newTestStorageUsage()).(*cuisineUsecase)
+		key := "images/1/old.jpg"
+		res := model.CuisineResponse{}
+		cu.setImageURLs(&res, &key)
+		if assert.NotNil(t, res.Images) {
+			assert.Equal(t, res.Images.Full, res.Images.Thumbnail)
+			assert.Equal(t, res.Images.Full, res.Images.Medium)
+		}
+	})
+
+	t.Run("画像以外・大きすぎる画像は保存しない", func(t *testing.T) {
+		st := newTestObjectStore()
+		ip := imageproc.NewProcessor(imageproc.Limits{MaxBytes: 1 << 20, MaxPixels: 100 * 100})
+		cu := NewCuisineUsecase(new(MockCuisineRepository), validator.NewCuisineValidator(), st, ip, new(MockUploadRepository), newTestImageResizer(), newTestStorageUsage())
+
+		_, err := cu.UploadImage(1, newTestFileHeader(t, "curry.jpg", []byte("<html></html>")))
+		assert.ErrorIs(t, err, ErrInvalidImage)
+		_, err = cu.UploadImage(1, newTestFileHeader(t, "curry.jpg", newTestJPEG(t, 101, 100)))
+		assert.ErrorIs(t, err, ErrImageTooLarge)
+		assert.Empty(t, storedKeys(t, st))
+	})
+
+	t.Run("料理を削除するとすべてのサイズを削除する", func(t *testing.T) {
+		st := newTestObjectStore()
+		mockRepo := new(MockCuisineRepository)
+		cu := NewCuisineUsecase(mockRepo, new(MockCuisineValidator), st, newTestImageProcessor(), new(MockUploadRepository), newTestImageResizer(), newTestStorageUsage())
+		image, err := cu.UploadImage(1, newTestFileHeader(t, "curry.jpg", newTestJPEG(t, 100, 100)))
+		require.NoError(t, err)
+		key := image.Key
+		mockRepo.On("GetCuisineByID", mock.AnythingOfType("*model.Cuisine"), uint(1), uint(1)).
+			Run(func(args mock.Arguments) {
+				cuisine := args.Get(0).(*model.Cuisine)
+				cuisine.ID = 1
+				cuisine.UserID = 1
+				cuisine.IconURL = &key
+			}).Return(nil)
+		mockRepo.On("DeleteCuisine", uint(1), uint(1)).Return(nil)
+
+		assert.NoError(t, cu.DeleteCuisine(1, 1))
+		assert.Empty(t, storedKeys(t, st))
+	})
+
+	t.Run("保存したすべてのサイズを使用量に加え、削除すると減らす", func(t *testing.T) {
+		st := newTestObjectStore()
+		su := NewStorageUsageUsecase(newMemoryStorageUsageRepository(), 0)
+		mockRepo := new(MockCuisineRepository)
+		cu := NewCuisineUsecase(mockRepo, new(MockCuisineValidator), st, newTestImageProcessor(), new(MockUploadRepository), newTestImageResizer(), su)
+		image, err := cu.UploadImage(1, newTestFileHeader(t, "curry.jpg", newTestJPEG(t, 300, 200)))
+		require.NoError(t, err)
+
+		var size int64
+		for _, k := range imageVariantKeys(image.Key) {
+			info, err := st.Stat(ctx, k)
+			require.NoError(t, err)
+			size += info.Size
+		}
+		usage, err := su.GetUsage(1)
+		require.NoError(t, err)
+		assert.Equal(t, size, usage.CuisineImageBytes)
+
+		mockRepo.On("GetCuisineByID", mock.AnythingOfType("*model.Cuisine"), uint(1), uint(1)).
+			Run(func(args mock.Arguments) {
+				cuisine := args.Get(0).(*model.Cuisine)
+				cuisine.ID = 1
+				cuisine.UserID = 1
+				cuisine.IconURL = &image.Key
+			}).Return(nil)
+		mockRepo.On("DeleteCuisine", uint(1), uint(1)).Return(nil)
+		require.NoError(t, cu.DeleteCuisine(1, 1))
+		usage, err = su.GetUsage(1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), usage.UsedBytes)
+	})
+
+	t.Run("上限を超える場合は保存しない", func(t *testing.T) {
+		st := newTestObjectStore()
+		su := NewStorageUsageUsecase(newMemoryStorageUsageRepository(), 1000)
+		cu := NewCuisineUsecase(new(MockCuisineRepository), validator.NewCuisineValidator(), st, newTestImageProcessor(), new(MockUploadRepository), newTestImageResizer(), su)
+
+		_, err := cu.UploadImage(1, newTestFileHeader(t, "curry.jpg", newTestJPEG(t, 300, 200)))
+		assert.ErrorIs(t, err, ErrStorageQuotaExceeded)
+		assert.Empty(t, storedKeys(t, st))
+		usage, err := su.GetUsage(1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), usage.UsedBytes)
+	})
+
+	t.Run("料理を保存できなかった場合は画像を削除する", func(t *testing.T) {
+		st := newTestObjectStore()
+		mockRepo := new(MockCuisineRepository)
+		cu := NewCuisineUsecase(mockRepo, validator.NewCuisineValidator(), st, newTestImageProcessor(), new(MockUploadRepository), newTestImageResizer(), newTestStorageUsage())
+		image, err := cu.UploadImage(1, newTestFileHeader(t, "curry.jpg", newTestJPEG(t, 100, 100)))
+		require.NoError(t, err)
+		key := image.Key
+		mockRepo.On("CreateCuisine", mock.AnythingOfType("*model.Cuisine")).Return(errors.New("db error"))
+
+		_, err = cu.AddCuisine(model.Cuisine{Title: "カレー", UserID: 1}, &key, "", "カレー")
+		assert.Error(t, err)
+		assert.Empty(t, storedKeys(t, st))
+		_, err = st.Stat(context.Background(), key)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+}
+
+func TestUploadImageFromUpload(t *testing.T) {
+	t.Run("直接アップロードした画像を変換して保存し、元のオブジェクトを削除する", func(t *testing.T) {
+		st := newTestObjectStore()
+		ur, upload := newTestUpload(t, st, model.UploadPurposeCuisineImage, "image/jpeg", newTestJPEG(t, 300, 200), true)
+		ur.On("ConsumeUpload", upload.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		cu := NewCuisineUsecase(new(MockCuisineRepository), validator.NewCuisineValidator(), st, newTestImageProcessor(), ur, newTestImageResizer(), newTestStorageUsage())
+
+		image, err := cu.UploadImageFromUpload(1, upload.ID)
+		require.NoError(t, err)
+		assert.Regexp(t, `^images/1/[0-9a-f-]{36}/full\.jpg$`, image.Key)
+		assert.NotEmpty(t, image.BlurHash)
+		keys := storedKeys(t, st)
+		assert.Len(t, keys, 3)
+		assert.NotContains(t, keys, upload.Key)
+		ur.AssertExpectations(t)
+	})
+
+	t.Run("直接アップロードの分を減らしてから保存する", func(t *testing.T) {
+		st := newTestObjectStore()
+		data := newTestJPEG(t, 300, 200)
+		outputs, err := newTestImageProcessor().Process(bytes.NewReader(data))
+		require.NoError(t, err)
+		var size int64
+		for _, out := range outputs {
+			size += int64(len(out.Data))
+		}
+		// アップロードと変換した画像の合計は上限を超える
+		su := NewStorageUsageUsecase(newMemoryStorageUsageRepository(), size+int64(len(data))-1)
+		ur, upload := newTestReservedUpload(t, st, su, model.UploadPurposeCuisineImage, "image/jpeg", data)
+		ur.On("ConsumeUpload", upload.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		cu := NewCuisineUsecase(new(MockCuisineRepository), validator.NewCuisineValidator(), st, newTestImageProcessor(), ur, newTestImageResizer(), su)
+
+		_, err = cu.UploadImageFromUpload(1, upload.ID)
+		require.NoError(t, err)
+		usage, err := su.GetUsage(1)
+		require.NoError(t, err)
+		assert.Equal(t, size, usage.CuisineImageBytes)
+	})
+
+	t.Run("同時に使われた場合は保存しない", func(t *testing.T) {
+		st := newTestObjectStore()
+		ur, upload := newTestUpload(t, st, model.UploadPurposeCuisineImage, "image/jpeg", newTestJPEG(t, 300, 200), true)
+		ur.On("ConsumeUpload", upload.ID, mock.AnythingOfType("time.Time")).Return(gorm.ErrRecordNotFound).Once()
+		cu := NewCuisineUsecase(new(MockCuisineRepository), validator.NewCuisineValidator(), st, newTestImageProcessor(), ur, newTestImageResizer(), newTestStorageUsage())
+
+		_, err := cu.UploadImageFromUpload(1, upload.ID)
+		assert.ErrorIs(t, err, ErrUploadAlreadyUsed)
+		assert.Equal(t, []string{upload.Key}, storedKeys(t, st))
+	})
+
+	t.Run("アイコン用のアップロードは使えない", func(t *testing.T) {
+		st := newTestObjectStore()
+		ur, upload := newTestUpload(t, st, model.UploadPurposeUserIcon, "image/jpeg", newTestJPEG(t, 300, 200), true)
+		cu := NewCuisineUsecase(new(MockCuisineRepository), validator.NewCuisineValidator(), st, newTestImageProcessor(), ur, newTestImageResizer(), newTestStorageUsage())
+
+		_, err := cu.UploadImageFromUpload(1, upload.ID)
+		assert.ErrorIs(t, err, ErrUploadNotFound)
+	})
+}
@@ -3,15 +3,19 @@ package usecase
 // 全ての料理履歴を取得するGetAllCuisines、指定したIDに一致する料理を取得するGetCuisineByID、
 // 料理を削除するDeleteCuisine、料理を追加するAddCuisine、料理を更新するSetCuisineを実装している
 // それぞれcuisine_repositoryのメソッドを呼び出している
+// 画像はサイズごとに変換して保存し（cuisine_image.go）、元のサイズのキーを記録する
+// レスポンスを作成する時に、サイズごとの期限付きの署名付きURLと、プリセットごとのリサイズした画像のURL（image_resize.go）を発行する
+// 料理の追加時には画像がほぼ同じ料理を返し、重複している可能性のある料理をまとめて取得できる（cuisine_duplicate.go）
 
 import (
+	"backend/imageproc"
 	"backend/model"
 	"backend/repository"
-	"backend/utils"
+	"backend/storage"
 	"backend/validator"
 	"errors"
 	"fmt"
-	"strings"
+	"mime/multipart"
 
 	"gorm.io/gorm"
 )
@@ -23,16 +27,25 @@ type ICuisineUsecase interface {
 	// UpdateCuisine(cuisine model.Cuisine, userID uint, cuisineID uint) (model.CuisineResponse, error)
 	DeleteCuisine(userID uint, cuisineID uint) error
 	AddCuisine(cuisine model.Cuisine, iconFile *string, url string, title string) (model.CuisineResponse, error)
+	UploadImage(userID uint, iconFile *multipart.FileHeader) (model.CuisineImage, error) // 保存した画像のキーとBlurHash・代表色を返す
+	UploadImageFromUpload(userID uint, uploadID string) (model.CuisineImage, error)      // 直接アップロードした画像を変換・保存する
+	// GetDuplicates は重複している可能性のある料理をまとめて返す（byはimage・title_url、空の場合は両方）
+	GetDuplicates(userID uint, by string) ([]model.CuisineDuplicateGroup, error)
 	// SetCuisine(cuisine model.Cuisine, iconFile *multipart.FileHeader, url string, title string, UserID uint, cuisineID uint) (model.CuisineResponse, error)
 }
 
 type cuisineUsecase struct {
 	cr repository.ICuisineRepository
 	cv validator.ICuisineValidator
+	st storage.ObjectStore
+	ip *imageproc.Processor
+	ur repository.IUploadRepository
+	rs IImageResizeUsecase
+	su IStorageUsageUsecase
 }
 
-func NewCuisineUsecase(tr repository.ICuisineRepository, tv validator.ICuisineValidator) ICuisineUsecase { // コンストラクタ
-	return &cuisineUsecase{tr, tv}
+func NewCuisineUsecase(tr repository.ICuisineRepository, tv validator.ICuisineValidator, st storage.ObjectStore, ip *imageproc.Processor, ur repository.IUploadRepository, rs IImageResizeUsecase, su IStorageUsageUsecase) ICuisineUsecase { // コンストラクタ
+	return &cuisineUsecase{tr, tv, st, ip, ur, rs, su}
 }
 
 func (cu *cuisineUsecase) GetAllCuisines(userID uint) ([]model.CuisineResponse, error) {
@@ -45,13 +58,14 @@ func (cu *cuisineUsecase) GetAllCuisines(userID uint) ([]model.CuisineResponse,
 		t := model.CuisineResponse{
 			ID:        v.ID,
 			Title:     v.Title,
-			IconURL:   v.IconURL,
 			URL:       v.URL,
 			Comment:   v.Comment,
 			CreatedAt: v.CreatedAt,
 			UpdatedAt: v.UpdatedAt,
 			UserID:    v.UserID,
 		}
+		setPlaceholder(&t, v)
+		cu.setImageURLs(&t, v.IconURL)
 		resCuisines = append(resCuisines, t)
 	}
 	return resCuisines, nil
@@ -65,13 +79,14 @@ func (cu *cuisineUsecase) GetCuisineByID(userID uint, cuisineID uint) (model.Cui
 	rescuisine := model.CuisineResponse{
 		ID:        cuisine.ID,
 		Title:     cuisine.Title,
-		IconURL:   cuisine.IconURL,
 		URL:       cuisine.URL,
 		Comment:   cuisine.Comment,
 		CreatedAt: cuisine.CreatedAt,
 		UpdatedAt: cuisine.UpdatedAt,
 		UserID:    cuisine.UserID,
 	}
+	setPlaceholder(&rescuisine, cuisine)
+	cu.setImageURLs(&rescuisine, cuisine.IconURL)
 	return rescuisine, nil
 }
 
@@ -136,14 +151,10 @@ func (cu *cuisineUsecase) DeleteCuisine(userID uint, cuisineID uint) error {
 		return ErrUnauthorized
 	}
 
-	// 3. Cloud Storageの写真を削除（IconURLが存在する場合）
-	if cuisine.IconURL != nil && *cuisine.IconURL != "" {
-		// URLからオブジェクト名を抽出
-		objectName := strings.TrimPrefix(*cuisine.IconURL, "https://storage.googleapis.com/cookmeet/")
-		if err := utils.DeleteFromCloudStorage("cookmeet", objectName); err != nil {
-			// 写真の削除に失敗してもデータベースからの削除は続行
-			fmt.Printf("Warning: failed to delete image from Cloud Storage: %v\n", err)
-		}
+	// 3. 保存先の写真を削除（IconURLが存在する場合、すべてのサイズ）
+	// 写真の削除に失敗してもデータベースからの削除は続行
+	if key, ok := imageKey(cuisine.IconURL); ok {
+		cu.deleteImage(userID, key)
 	}
 
 	// 4. データベースから料理を削除
@@ -175,19 +186,25 @@ func (cu *cuisineUsecase) AddCuisine(cuisine model.Cuisine, iconFile *string, ur
 	if err := cu.cv.CuisineValidate(cuisine); err != nil {
 		return model.CuisineResponse{}, err
 	}
+	duplicates := cu.findImageDuplicates(cuisine.UserID, cuisine.ImageHash) // 追加する前に探す（追加は行う）
 	if err := cu.cr.CreateCuisine(&cuisine); err != nil {
+		if key, ok := imageKey(iconFile); ok {
+			cu.deleteImage(cuisine.UserID, key) // 保存できなかった料理の画像は残さない
+		}
 		return model.CuisineResponse{}, err
 	}
 	rescuisine := model.CuisineResponse{
 		ID:        cuisine.ID,
 		Title:     cuisine.Title,
-		IconURL:   cuisine.IconURL,
 		URL:       cuisine.URL,
 		Comment:   cuisine.Comment, // コメントを追加
 		CreatedAt: cuisine.CreatedAt,
 		UpdatedAt: cuisine.UpdatedAt,
 		UserID:    cuisine.UserID,
 	}
+	setPlaceholder(&rescuisine, cuisine)
+	cu.setImageURLs(&rescuisine, cuisine.IconURL)
+	rescuisine.PossibleDuplicates = duplicates
 	// log.Print(rescuisine)
 	return rescuisine, nil
 }
@@ -2,7 +2,10 @@ package usecase
 
 import (
 	"backend/model"
+	"backend/storage"
 	"backend/validator"
+	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -57,7 +60,7 @@ func TestGetAllCuisines(t *testing.T) {
 	// モックの準備
 	mockRepo := new(MockCuisineRepository)
 	validator := validator.NewCuisineValidator()
-	usecase := NewCuisineUsecase(mockRepo, validator)
+	usecase := NewCuisineUsecase(mockRepo, validator, newTestObjectStore(), newTestImageProcessor(), new(MockUploadRepository), newTestImageResizer(), newTestStorageUsage())
 
 	UserID := uint(1)
 	now := time.Now()
@@ -103,7 +106,7 @@ func TestGetCuisineByID(t *testing.T) {
 	// モックの準備
 	mockRepo := new(MockCuisineRepository)
 	validator := validator.NewCuisineValidator()
-	usecase := NewCuisineUsecase(mockRepo, validator)
+	usecase := NewCuisineUsecase(mockRepo, validator, newTestObjectStore(), newTestImageProcessor(), new(MockUploadRepository), newTestImageResizer(), newTestStorageUsage())
 
 	UserID := uint(1)
 	cuisineID := uint(1)
@@ -137,7 +140,7 @@ func TestGetCuisineByID(t *testing.T) {
 func TestDeleteCuisine(t *testing.T) {
 	mockRepo := new(MockCuisineRepository)
 	mockValidator := new(MockCuisineValidator)
-	cu := NewCuisineUsecase(mockRepo, mockValidator)
+	cu := NewCuisineUsecase(mockRepo, mockValidator, newTestObjectStore(), newTestImageProcessor(), new(MockUploadRepository), newTestImageResizer(), newTestStorageUsage())
 
 	tests := []struct {
 		name      string
@@ -217,7 +220,7 @@ func TestAddCuisine(t *testing.T) {
 	// モックの準備
 	mockRepo := new(MockCuisineRepository)
 	validator := validator.NewCuisineValidator()
-	usecase := NewCuisineUsecase(mockRepo, validator)
+	usecase := NewCuisineUsecase(mockRepo, validator, newTestObjectStore(), newTestImageProcessor(), new(MockUploadRepository), newTestImageResizer(), newTestStorageUsage())
 
 	cuisine := model.Cuisine{
 		Title:  "Test Cuisine",
@@ -237,3 +240,47 @@ func TestAddCuisine(t *testing.T) {
 	assert.Equal(t, cuisine.URL, response.URL)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestCuisineImage(t *testing.T) {
+	ctx := context.Background()
+	key := "images/1/a.jpg"
+
+	t.Run("キーから読み込むたびに署名付きURLを発行する", func(t *testing.T) {
+		mockRepo := new(MockCuisineRepository)
+		st := newTestObjectStore()
+		cu := NewCuisineUsecase(mockRepo, validator.NewCuisineValidator(), st, newTestImageProcessor(), new(MockUploadRepository), newTestImageResizer(), newTestStorageUsage())
+		legacy := "https://storage.googleapis.com/cookmeet/images/1/b.jpg?X-Goog-Signature=abc"
+		mockRepo.On("GetAllCuisines", mock.Anything, uint(1)).Return([]model.Cuisine{
+			{ID: 1, Title: "key", UserID: 1, IconURL: &key},
+			{ID: 2, Title: "legacy", UserID: 1, IconURL: &legacy},
+			{ID: 3, Title: "none", UserID: 1},
+		}, nil)
+
+		res, err := cu.GetAllCuisines(1)
+		assert.NoError(t, err)
+		if assert.Len(t, res, 3) && assert.NotNil(t, res[0].IconURL) {
+			assert.True(t, strings.HasPrefix(*res[0].IconURL, "memory:///"+key+"?expires="), *res[0].IconURL)
+			assert.Nil(t, res[1].IconURL, "移行できなかったURLは返さない")
+			assert.Nil(t, res[2].IconURL)
+		}
+	})
+
+	t.Run("削除時に画像も削除する", func(t *testing.T) {
+		mockRepo := new(MockCuisineRepository)
+		st := newTestObjectStore()
+		cu := NewCuisineUsecase(mockRepo, new(MockCuisineValidator), st, newTestImageProcessor(), new(MockUploadRepository), newTestImageResizer(), newTestStorageUsage())
+		assert.NoError(t, st.Put(ctx, key, "image/jpeg", strings.NewReader("jpeg")))
+		mockRepo.On("GetCuisineByID", mock.AnythingOfType("*model.Cuisine"), uint(1), uint(1)).
+			Run(func(args mock.Arguments) {
+				cuisine := args.Get(0).(*model.Cuisine)
+				cuisine.ID = 1
+				cuisine.UserID = 1
+				cuisine.IconURL = &key
+			}).Return(nil)
+		mockRepo.On("DeleteCuisine", uint(1), uint(1)).Return(nil)
+
+		assert.NoError(t, cu.DeleteCuisine(1, 1))
+		_, err := st.Stat(ctx, key)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+}
@@ -0,0 +1,151 @@
+package usecase
+
+// メールアドレスの変更を実装
+// 新しいアドレスは確認待ちとして保存し、そのアドレスに送った署名付きリンクを開くまで反映しない
+// 反映後は変更前のアドレスに通知し、一定期間は取り消しのリンクで元に戻せる（取り消すとすべてのセッションを失効させる）
+
+import (
+	"backend/auth"
+	"backend/model"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEmailAlreadyInUse      = errors.New("email address is already in use")
+	ErrInvalidEmailChangeLink = errors.New("invalid or expired email change link")
+)
+
+const (
+	emailConfirmTTL = 24 * time.Hour
+	emailUndoTTL    = 7 * 24 * time.Hour
+)
+
+// checkEmailAvailable は他のユーザーが使っているメールアドレスでないかを確認する
+func (uu *userUsecase) checkEmailAvailable(email string) error {
+	if err := uu.uv.EmailValidate(email); err != nil {
+		return err
+	}
+	existing := model.User{}
+	err := uu.ur.GetUserByEmail(&existing, email)
+	if err == nil {
+		return ErrEmailAlreadyInUse
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+// requestEmailChange は変更申請を保存し、新しいアドレスに確認メールを送る
+func (uu *userUsecase) requestEmailChange(user *model.User, newEmail string, client model.ClientInfo) error {
+	change := model.EmailChange{
+		UserID:    user.ID,
+		OldEmail:  user.Email,
+		NewEmail:  newEmail,
+		Nonce:     uuid.New().String(),
+		ExpiresAt: time.Now().Add(emailConfirmTTL),
+	}
+	token, err := uu.signEmailChangeToken(user.ID, auth.TokenTypeEmailConfirm, change.Nonce, emailConfirmTTL)
+	if err != nil {
+		return err
+	}
+	if err := uu.er.CreateEmailChange(&change); err != nil {
+		return err
+	}
+	logUserEvent(uu.al, AuditEmailChangeRequested, user.ID, client, map[string]interface{}{"new_email": newEmail})
+	sendNotification(uu.ml, emailChangeConfirmMessage(newEmail, user.Name, uu.emailChangeLink("/email/confirm", token), change.ExpiresAt))
+	return nil
+}
+
+func (uu *userUsecase) ConfirmEmailChange(token string, client model.ClientInfo) error {
+	change, err := uu.emailChangeFromToken(token, auth.TokenTypeEmailConfirm)
+	if err != nil {
+		return err
+	}
+	if err := uu.er.ConfirmEmailChange(change, time.Now()); err != nil {
+		return emailChangeError(err)
+	}
+	logUserEvent(uu.al, AuditEmailChanged, change.UserID, client, map[string]interface{}{"old_email": change.OldEmail, "new_email": change.NewEmail})
+
+	undoToken, err := uu.signEmailChangeToken(change.UserID, auth.TokenTypeEmailUndo, change.Nonce, emailUndoTTL)
+	if err != nil {
+		return err
+	}
+	name := ""
+	if user, err := uu.ur.GetUserByID(change.UserID); err == nil {
+		name = user.Name
+	}
+	sendNotification(uu.ml, emailChangedMessage(change.OldEmail, name, change.NewEmail, uu.emailChangeLink("/email/undo", undoToken), time.Now().Add(emailUndoTTL)))
+	return nil
+}
+
+func (uu *userUsecase) UndoEmailChange(token string, client model.ClientInfo) error {
+	change, err := uu.emailChangeFromToken(token, auth.TokenTypeEmailUndo)
+	if err != nil {
+		return err
+	}
+	if err := uu.er.UndoEmailChange(change, time.Now()); err != nil {
+		return emailChangeError(err)
+	}
+	// 乗っ取りによる変更の可能性があるため、すべての端末をログアウトさせる
+	if err := uu.sm.RevokeAll(change.UserID); err != nil {
+		return err
+	}
+	logUserEvent(uu.al, AuditEmailChangeUndone, change.UserID, client, map[string]interface{}{"old_email": change.OldEmail, "new_email": change.NewEmail})
+	return nil
+}
+
+// emailChangeFromToken はリンクのトークンを検証し、対応する変更申請を返す
+func (uu *userUsecase) emailChangeFromToken(token string, tokenType string) (*model.EmailChange, error) {
+	claims, err := auth.Parse(token, uu.secret, tokenType)
+	if err != nil || claims.ID == "" {
+		return nil, ErrInvalidEmailChangeLink
+	}
+	change := model.EmailChange{}
+	if err := uu.er.GetEmailChangeByNonce(&change, claims.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidEmailChangeLink
+		}
+		return nil, err
+	}
+	if change.UserID != claims.UserID {
+		return nil, ErrInvalidEmailChangeLink
+	}
+	return &change, nil
+}
+
+// emailChangeError は確認・取り消し時のリポジトリのエラーを変換する
+func emailChangeError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// 使用済み・期限切れ、または申請後にメールアドレスが変わっている
+		return ErrInvalidEmailChangeLink
+	case isUniqueViolation(err):
+		return ErrEmailAlreadyInUse
+	default:
+		return err
+	}
+}
+
+func (uu *userUsecase) signEmailChangeToken(userID uint, tokenType string, nonce string, ttl time.Duration) (string, error) {
+	claims := auth.NewClaims(userID, tokenType, ttl)
+	claims.ID = nonce
+	return auth.Sign(claims, uu.secret)
+}
+
+// emailChangeLink はフロントエンドの確認・取り消しページのURLを返す
+func (uu *userUsecase) emailChangeLink(path string, token string) string {
+	return strings.TrimRight(uu.feURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// isUniqueViolation は一意制約違反のエラーかを返す
+func isUniqueViolation(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "duplicate") || strings.Contains(msg, "unique violation")
+}
@@ -0,0 +1,145 @@
+package usecase
+
+import (
+	"backend/auth"
+	"backend/model"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+// MockEmailChangeRepository はEmailChangeRepositoryのモック
+type MockEmailChangeRepository struct {
+	mock.Mock
+}
+
+func (m *MockEmailChangeRepository) CreateEmailChange(change *model.EmailChange) error {
+	args := m.Called(change)
+	return args.Error(0)
+}
+
+func (m *MockEmailChangeRepository) GetEmailChangeByNonce(change *model.EmailChange, nonce string) error {
+	args := m.Called(change, nonce)
+	if found, ok := args.Get(1).(model.EmailChange); ok {
+		*change = found
+	}
+	return args.Error(0)
+}
+
+func (m *MockEmailChangeRepository) ConfirmEmailChange(change *model.EmailChange, confirmedAt time.Time) error {
+	args := m.Called(change, confirmedAt)
+	return args.Error(0)
+}
+
+func (m *MockEmailChangeRepository) UndoEmailChange(change *model.EmailChange, undoneAt time.Time) error {
+	args := m.Called(change, undoneAt)
+	return args.Error(0)
+}
+
+var testEmailChange = model.EmailChange{ID: 1, UserID: 1, OldEmail: "old@example.com", NewEmail: "new@example.com", Nonce: "nonce-1"}
+
+// signTestEmailChangeToken はテスト用の鍵でリンクのトークンを作成する
+func signTestEmailChangeToken(userID uint, tokenType string, nonce string, ttl time.Duration) (string, error) {
+	return (&userUsecase{secret: testSecret}).signEmailChangeToken(userID, tokenType, nonce, ttl)
+}
+
+// tokenFromLink はメール本文のリンクからトークンを取り出す
+func tokenFromLink(t *testing.T, body string, path string) string {
+	t.Helper()
+	for _, line := range strings.Split(body, "\n") {
+		if i := strings.Index(line, path+"?"); i >= 0 {
+			q, err := url.ParseQuery(line[i+len(path)+1:])
+			assert.NoError(t, err)
+			return q.Get("token")
+		}
+	}
+	t.Fatalf("link %s not found in %q", path, body)
+	return ""
+}
+
+func TestConfirmAndUndoEmailChange(t *testing.T) {
+	ur := new(MockUserRepository)
+	er := new(MockEmailChangeRepository)
+	sr := new(MockSessionRepository)
+	al := newTestAuditLogger()
+	ml := newTestMailer()
+	usecase := NewUserUsecase(ur, er, newTestUserValidator(), newTestLoginGuard(), NewSessionManager(ur, sr, testSecret), al, newTestPasswordHasher(), ml, newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+
+	token, err := signTestEmailChangeToken(1, auth.TokenTypeEmailConfirm, "nonce-1", time.Hour)
+	assert.NoError(t, err)
+	er.On("GetEmailChangeByNonce", mock.Anything, "nonce-1").Return(nil, testEmailChange)
+	er.On("ConfirmEmailChange", mock.Anything, mock.Anything).Return(nil).Once()
+	ur.On("GetUserByID", uint(1)).Return(&model.User{ID: 1, Name: "Test", Email: "new@example.com"}, nil)
+
+	assert.NoError(t, usecase.ConfirmEmailChange(token, testClient))
+	assert.Equal(t, []string{AuditEmailChanged}, al.actions())
+	assert.JSONEq(t, `{"old_email":"old@example.com","new_email":"new@example.com"}`, al.events[0].Metadata)
+	if assert.Len(t, ml.messages, 1) {
+		assert.Equal(t, "old@example.com", ml.messages[0].To, "変更前のアドレスに通知する")
+	}
+
+	// 確認のリンクでは取り消せない
+	assert.ErrorIs(t, usecase.UndoEmailChange(token, testClient), ErrInvalidEmailChangeLink)
+
+	assert.Contains(t, ml.messages[0].Body, testFEURL+"/email/undo?token=")
+	undoToken := tokenFromLink(t, ml.messages[0].Body, "/email/undo")
+	er.On("UndoEmailChange", mock.Anything, mock.Anything).Return(nil).Once()
+	sr.On("RevokeUserSessions", uint(1), "").Return(nil).Once()
+
+	assert.NoError(t, usecase.UndoEmailChange(undoToken, testClient))
+	assert.Equal(t, []string{AuditEmailChanged, AuditEmailChangeUndone}, al.actions())
+	er.AssertExpectations(t)
+	sr.AssertExpectations(t)
+}
+
+func TestConfirmEmailChangeErrors(t *testing.T) {
+	token, err := signTestEmailChangeToken(1, auth.TokenTypeEmailConfirm, "nonce-1", time.Hour)
+	assert.NoError(t, err)
+
+	t.Run("不正なトークン", func(t *testing.T) {
+		usecase := NewUserUsecase(new(MockUserRepository), new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		assert.ErrorIs(t, usecase.ConfirmEmailChange("invalid", testClient), ErrInvalidEmailChangeLink)
+
+		expired, err := signTestEmailChangeToken(1, auth.TokenTypeEmailConfirm, "nonce-1", -time.Minute)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, usecase.ConfirmEmailChange(expired, testClient), ErrInvalidEmailChangeLink)
+	})
+
+	t.Run("他のユーザーの申請", func(t *testing.T) {
+		er := new(MockEmailChangeRepository)
+		usecase := NewUserUsecase(new(MockUserRepository), er, newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		other := testEmailChange
+		other.UserID = 2
+		er.On("GetEmailChangeByNonce", mock.Anything, "nonce-1").Return(nil, other).Once()
+
+		assert.ErrorIs(t, usecase.ConfirmEmailChange(token, testClient), ErrInvalidEmailChangeLink)
+		er.AssertNotCalled(t, "ConfirmEmailChange", mock.Anything, mock.Anything)
+	})
+
+	t.Run("使用済みのリンク", func(t *testing.T) {
+		er := new(MockEmailChangeRepository)
+		al := newTestAuditLogger()
+		usecase := NewUserUsecase(new(MockUserRepository), er, newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), al, newTestPasswordHasher(), newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		er.On("GetEmailChangeByNonce", mock.Anything, "nonce-1").Return(nil, testEmailChange).Once()
+		er.On("ConfirmEmailChange", mock.Anything, mock.Anything).Return(gorm.ErrRecordNotFound).Once()
+
+		assert.ErrorIs(t, usecase.ConfirmEmailChange(token, testClient), ErrInvalidEmailChangeLink)
+		assert.Empty(t, al.actions())
+	})
+
+	t.Run("確認までに他のユーザーが使用した", func(t *testing.T) {
+		er := new(MockEmailChangeRepository)
+		usecase := NewUserUsecase(new(MockUserRepository), er, newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		er.On("GetEmailChangeByNonce", mock.Anything, "nonce-1").Return(nil, testEmailChange).Once()
+		er.On("ConfirmEmailChange", mock.Anything, mock.Anything).
+			Return(errors.New(`ERROR: duplicate key value violates unique constraint "uni_users_email"`)).Once()
+
+		assert.ErrorIs(t, usecase.ConfirmEmailChange(token, testClient), ErrEmailAlreadyInUse)
+	})
+}
@@ -0,0 +1,240 @@
+package usecase
+
+// 画像の配信時のリサイズ（GET /img/<キー>?w=&h=&fit=&fmt=&e=&s=）
+// 一覧のサムネイル・詳細・OGカードなど、クライアントごとに必要な大きさを許可したプリセットの中から返す
+// URLはキーと変換の指定・期限（e）に署名し、任意の大きさの生成や他のキーの参照、期限後の利用を防ぐ
+// 期限は接頭辞ごとの期間（imageURLTTLs）で切り上げ、同じ期間内は同じURLを返してキャッシュできるようにする
+// 生成した画像は保存先のキャッシュ（cache/img/、合計の上限あり）に保存し、大きさごとに一度だけ生成する
+// 元の画像が削除された場合は、キャッシュが残っていても404を返す
+
+import (
+	"backend/imageproc"
+	"backend/storage"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrImageNotFound         = errors.New("image not found")
+	ErrInvalidImageSignature = errors.New("invalid image signature")
+	ErrImagePresetNotAllowed = errors.New("image size is not allowed")
+	ErrImageNotModified      = errors.New("image not modified")
+	ErrImageURLExpired       = errors.New("image url has expired")
+)
+
+const (
+	derivedImageKeyPrefix = "cache/img/"
+	// derivedImageVersion は変換の処理を変えた場合に上げる（キャッシュとETagが変わる）
+	derivedImageVersion = "1"
+	imageRoutePrefix    = "/img/"
+)
+
+// ImagePresets は配信できる大きさ（名前はレスポンスのimages.presetsのキー）
+var ImagePresets = map[string]imageproc.Transform{
+	"list":   {Width: 400, Height: 400, Fit: imageproc.FitCover},  // 一覧のサムネイル
+	"detail": {Width: 1200, Fit: imageproc.FitContain},            // 詳細
+	"og":     {Width: 1200, Height: 630, Fit: imageproc.FitCover}, // OGカード
+}
+
+// imageURLTTLs はリサイズして配信できるキーの接頭辞と、URLの期限を揃える期間
+// URLは発行から期間の1〜2倍の間使える（アイコンは保存先の署名付きURLと同じ期間にする）
+var imageURLTTLs = map[string]time.Duration{
+	cuisineImageKeyPrefix: 24 * time.Hour,
+	iconKeyPrefix:         iconURLTTL,
+}
+
+// ImageURLConfig は画像の配信の設定
+type ImageURLConfig struct {
+	BaseURL       string // /img を配信するAPIのURL
+	SigningKey    string
+	CacheMaxBytes int64 // 生成した画像のキャッシュの合計の上限
+}
+
+// DerivedImage はリサイズした画像
+type DerivedImage struct {
+	ContentType string
+	ETag        string
+	Data        []byte
+	MaxAge      time.Duration // キャッシュさせる期間（URLの期限まで）
+}
+
+type IImageResizeUsecase interface {
+	// PresetURLs はプリセットごとの署名したURLを返す（リサイズできないキーはnil）
+	PresetURLs(key string) map[string]string
+	// Render は署名と期限（expires、Unix時間の秒）を確認し、リサイズした画像を返す（キャッシュになければ生成して保存する）
+	// ifNoneMatchがETagと一致する場合は、内容を読まずにETagとErrImageNotModifiedを返す
+	Render(key string, t imageproc.Transform, expires int64, signature string, ifNoneMatch string) (DerivedImage, error)
+}
+
+type imageResizeUsecase struct {
+	st    storage.ObjectStore
+	cache *storage.Cache
+	ip    *imageproc.Processor
+	cfg   ImageURLConfig
+	now   func() time.Time
+}
+
+func NewImageResizeUsecase(st storage.ObjectStore, ip *imageproc.Processor, cfg ImageURLConfig) IImageResizeUsecase {
+	return &imageResizeUsecase{st, storage.NewCache(st, derivedImageKeyPrefix, cfg.CacheMaxBytes), ip, cfg, time.Now}
+}
+
+func (iu *imageResizeUsecase) PresetURLs(key string) map[string]string {
+	ttl, ok := imageURLTTL(key)
+	if !ok {
+		return nil
+	}
+	expires := iu.now().Truncate(ttl).Add(2 * ttl).Unix()
+	urls := map[string]string{}
+	for name, t := range ImagePresets {
+		q := url.Values{}
+		if t.Width > 0 {
+			q.Set("w", strconv.Itoa(t.Width))
+		}
+		if t.Height > 0 {
+			q.Set("h", strconv.Itoa(t.Height))
+		}
+		q.Set("fit", t.Fit)
+		if t.Format != "" {
+			q.Set("fmt", t.Format)
+		}
+		q.Set("e", strconv.FormatInt(expires, 10))
+		q.Set("s", iu.sign(key, t, expires))
+		urls[name] = strings.TrimSuffix(iu.cfg.BaseURL, "/") + imageRoutePrefix + key + "?" + q.Encode()
+	}
+	return urls
+}
+
+func (iu *imageResizeUsecase) Render(key string, t imageproc.Transform, expires int64, signature string, ifNoneMatch string) (DerivedImage, error) {
+	if _, ok := imageURLTTL(key); !ok {
+		return DerivedImage{}, ErrImageNotFound
+	}
+	if !hmac.Equal([]byte(signature), []byte(iu.sign(key, t, expires))) {
+		return DerivedImage{}, ErrInvalidImageSignature
+	}
+	maxAge := time.Unix(expires, 0).Sub(iu.now()).Truncate(time.Second)
+	if maxAge <= 0 {
+		return DerivedImage{}, ErrImageURLExpired
+	}
+	if !allowedTransform(t) {
+		return DerivedImage{}, ErrImagePresetNotAllowed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	if _, err := iu.st.Stat(ctx, key); err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			return DerivedImage{}, ErrImageNotFound
+		}
+		return DerivedImage{}, err
+	}
+	name := derivedImageName(key, t)
+	etag := `"` + name[3:] + `"`
+	if etagMatch(ifNoneMatch, etag) {
+		return DerivedImage{ETag: etag, MaxAge: maxAge}, ErrImageNotModified
+	}
+
+	if r, info, err := iu.cache.Get(ctx, name); err == nil {
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return DerivedImage{}, err
+		}
+		return DerivedImage{ContentType: info.ContentType, ETag: etag, Data: data, MaxAge: maxAge}, nil
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return DerivedImage{}, err
+	}
+
+	out, err := iu.render(ctx, key, t)
+	if err != nil {
+		return DerivedImage{}, err
+	}
+	if err := iu.cache.Put(ctx, name, out.ContentType, bytes.NewReader(out.Data), int64(len(out.Data))); err != nil {
+		log.Printf("failed to cache derived image %s: %v", name, err)
+	}
+	return DerivedImage{ContentType: out.ContentType, ETag: etag, Data: out.Data, MaxAge: maxAge}, nil
+}
+
+func (iu *imageResizeUsecase) render(ctx context.Context, key string, t imageproc.Transform) (imageproc.Output, error) {
+	r, _, err := iu.st.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return imageproc.Output{}, ErrImageNotFound
+		}
+		return imageproc.Output{}, err
+	}
+	defer r.Close()
+	out, err := iu.ip.Resize(r, t)
+	switch {
+	case errors.Is(err, imageproc.ErrTooLarge), errors.Is(err, imageproc.ErrTooManyPixels):
+		return imageproc.Output{}, fmt.Errorf("%w: %v", ErrImageTooLarge, err)
+	case errors.Is(err, imageproc.ErrUnsupportedFormat):
+		return imageproc.Output{}, ErrInvalidImage
+	case errors.Is(err, imageproc.ErrInvalidTransform):
+		return imageproc.Output{}, ErrImagePresetNotAllowed
+	}
+	return out, err
+}
+
+// sign はキーと変換の指定・期限への署名を返す
+func (iu *imageResizeUsecase) sign(key string, t imageproc.Transform, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(iu.cfg.SigningKey))
+	mac.Write([]byte(transformSigningString(key, t) + "\n" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func transformSigningString(key string, t imageproc.Transform) string {
+	return strings.Join([]string{key, strconv.Itoa(t.Width), strconv.Itoa(t.Height), t.Fit, t.Format}, "\n")
+}
+
+// derivedImageName はキャッシュでの名前（<ハッシュの先頭2文字>/<ハッシュ>）を返す
+func derivedImageName(key string, t imageproc.Transform) string {
+	sum := sha256.Sum256([]byte(derivedImageVersion + "\n" + transformSigningString(key, t)))
+	h := hex.EncodeToString(sum[:16])
+	return h[:2] + "/" + h
+}
+
+// imageURLTTL はリサイズして配信できるキーであれば、URLの期限を揃える期間を返す
+func imageURLTTL(key string) (time.Duration, bool) {
+	for prefix, ttl := range imageURLTTLs {
+		if strings.HasPrefix(key, prefix) {
+			return ttl, true
+		}
+	}
+	return 0, false
+}
+
+// allowedTransform はプリセットのいずれかと一致するか（形式は指定しない・jpeg・pngのいずれか）を返す
+func allowedTransform(t imageproc.Transform) bool {
+	if t.Format != "" && t.Format != imageproc.FormatJPEG && t.Format != imageproc.FormatPNG {
+		return false
+	}
+	for _, preset := range ImagePresets {
+		if preset.Width == t.Width && preset.Height == t.Height && preset.Fit == t.Fit {
+			return true
+		}
+	}
+	return false
+}
+
+// etagMatch はIf-None-MatchがETagと一致するかを返す
+func etagMatch(ifNoneMatch string, etag string) bool {
+	for _, v := range strings.Split(ifNoneMatch, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
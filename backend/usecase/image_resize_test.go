@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/imageproc"
+	"backend/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testImageURLConfig = ImageURLConfig{BaseURL: "http://api.example.com", SigningKey: "secret", CacheMaxBytes: 1 << 20}
+
+func newTestImageResizer() IImageResizeUsecase {
+	return NewImageResizeUsecase(newTestObjectStore(), newTestImageProcessor(), testImageURLConfig)
+}
+
+// parseImageURL は/imgのURLからキー・変換の指定・期限・署名を取り出す
+func parseImageURL(t *testing.T, rawURL string) (string, imageproc.Transform, int64, string) {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	q := u.Query()
+	w, _ := strconv.Atoi(q.Get("w"))
+	h, _ := strconv.Atoi(q.Get("h"))
+	e, err := strconv.ParseInt(q.Get("e"), 10, 64)
+	require.NoError(t, err)
+	return strings.TrimPrefix(u.Path, "/img/"), imageproc.Transform{Width: w, Height: h, Fit: q.Get("fit"), Format: q.Get("fmt")}, e, q.Get("s")
+}
+
+func TestImageResize(t *testing.T) {
+	ctx := context.Background()
+	key := "images/1/abc/full.jpg"
+	setup := func(t *testing.T) (storage.ObjectStore, IImageResizeUsecase) {
+		st := newTestObjectStore()
+		require.NoError(t, st.Put(ctx, key, "image/jpeg", bytes.NewReader(newTestJPEG(t, 1600, 1200))))
+		return st, NewImageResizeUsecase(st, newTestImageProcessor(), testImageURLConfig)
+	}
+
+	t.Run("署名したURLでプリセットの大きさに変換し、キャッシュする", func(t *testing.T) {
+		st, iu := setup(t)
+		urls := iu.PresetURLs(key)
+		require.Len(t, urls, len(ImagePresets))
+		assert.True(t, strings.HasPrefix(urls["og"], "http://api.example.com/img/"+key+"?"), urls["og"])
+
+		k, tr, e, sig := parseImageURL(t, urls["og"])
+		img, err := iu.Render(k, tr, e, sig, "")
+		require.NoError(t, err)
+		assert.Equal(t, "image/jpeg", img.ContentType)
+		assert.NotEmpty(t, img.ETag)
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(img.Data))
+		require.NoError(t, err)
+		assert.Equal(t, [2]int{1200, 630}, [2]int{cfg.Width, cfg.Height})
+
+		cached, err := st.List(ctx, derivedImageKeyPrefix)
+		require.NoError(t, err)
+		assert.Len(t, cached, 1)
+
+		// 2回目はキャッシュから返す（元の画像を変えても同じ内容）
+		require.NoError(t, st.Put(ctx, key, "image/jpeg", bytes.NewReader(newTestJPEG(t, 100, 100))))
+		again, err := iu.Render(k, tr, e, sig, "")
+		require.NoError(t, err)
+		assert.Equal(t, img.Data, again.Data)
+		assert.Equal(t, img.ETag, again.ETag)
+	})
+
+	t.Run("ETagが一致すればErrImageNotModified", func(t *testing.T) {
+		_, iu := setup(t)
+		k, tr, e, sig := parseImageURL(t, iu.PresetURLs(key)["list"])
+		img, err := iu.Render(k, tr, e, sig, "")
+		require.NoError(t, err)
+		res, err := iu.Render(k, tr, e, sig, `"other", W/`+img.ETag)
+		assert.ErrorIs(t, err, ErrImageNotModified)
+		assert.Equal(t, img.ETag, res.ETag)
+		assert.Empty(t, res.Data)
+	})
+
+	t.Run("署名が一致しない・許可していない大きさは拒否する", func(t *testing.T) {
+		_, iu := setup(t)
+		k, tr, e, sig := parseImageURL(t, iu.PresetURLs(key)["list"])
+		tr.Width = 4000
+		_, err := iu.Render(k, tr, e, sig, "")
+		assert.ErrorIs(t, err, ErrInvalidImageSignature)
+
+		other := imageproc.Transform{Width: 4000, Height: 4000, Fit: imageproc.FitCover}
+		signed := iu.(*imageResizeUsecase).sign(key, other, e)
+		_, err = iu.Render(key, other, e, signed, "")
+		assert.ErrorIs(t, err, ErrImagePresetNotAllowed)
+	})
+
+	t.Run("URLの期限", func(t *testing.T) {
+		_, iu := setup(t)
+		now := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+		iu.(*imageResizeUsecase).now = func() time.Time { return now }
+		urls := iu.PresetURLs(key)
+		k, tr, e, sig := parseImageURL(t, urls["list"])
+		assert.Equal(t, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC).Unix(), e, "期間で切り上げる")
+
+		// 同じ期間内は同じURLを返す
+		now = now.Add(12 * time.Hour)
+		assert.Equal(t, urls, iu.PresetURLs(key))
+		img, err := iu.Render(k, tr, e, sig, "")
+		require.NoError(t, err)
+		assert.Equal(t, 25*time.Hour+30*time.Minute, img.MaxAge, "期限までキャッシュさせる")
+
+		_, err = iu.Render(k, tr, e+3600, sig, "")
+		assert.ErrorIs(t, err, ErrInvalidImageSignature, "期限を延ばせない")
+
+		now = time.Unix(e, 0)
+		_, err = iu.Render(k, tr, e, sig, "")
+		assert.ErrorIs(t, err, ErrImageURLExpired)
+	})
+
+	t.Run("アイコンのURLは短い期限にする", func(t *testing.T) {
+		st, iu := setup(t)
+		icon := "user_icons/1/a.jpg"
+		require.NoError(t, st.Put(ctx, icon, "image/jpeg", bytes.NewReader(newTestJPEG(t, 400, 400))))
+		now := time.Date(2024, 1, 1, 10, 20, 0, 0, time.UTC)
+		iu.(*imageResizeUsecase).now = func() time.Time { return now }
+		k, tr, e, sig := parseImageURL(t, iu.PresetURLs(icon)["list"])
+		assert.Equal(t, time.Date(2024, 1, 1, 10, 45, 0, 0, time.UTC).Unix(), e)
+		img, err := iu.Render(k, tr, e, sig, "")
+		require.NoError(t, err)
+		assert.Equal(t, 25*time.Minute, img.MaxAge)
+	})
+
+	t.Run("元の画像が削除されていれば404", func(t *testing.T) {
+		st, iu := setup(t)
+		k, tr, e, sig := parseImageURL(t, iu.PresetURLs(key)["detail"])
+		_, err := iu.Render(k, tr, e, sig, "")
+		require.NoError(t, err)
+		require.NoError(t, st.Delete(ctx, key))
+		_, err = iu.Render(k, tr, e, sig, "")
+		assert.ErrorIs(t, err, ErrImageNotFound)
+	})
+
+	t.Run("画像以外のキーは扱わない", func(t *testing.T) {
+		_, iu := setup(t)
+		assert.Nil(t, iu.PresetURLs("uploads/1/a.png"))
+		_, err := iu.Render("uploads/1/a.png", ImagePresets["list"], 0, "", "")
+		assert.ErrorIs(t, err, ErrImageNotFound)
+	})
+}
@@ -0,0 +1,167 @@
+package usecase
+
+// ログインの総当たり攻撃対策
+// アカウント（email）ごと・IPごとに失敗回数を数え、しきい値を超えたら指数的に長くなるロックをかける
+// ロックをかけたことは監査ログに記録する
+
+import (
+	"backend/model"
+	"backend/repository"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+var ErrTooManyAttempts = errors.New("too many login attempts")
+
+// LockoutError はロック中であることと、再試行できるまでの時間を表す
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%s: retry after %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockoutError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// LockoutPolicy はロックの条件
+type LockoutPolicy struct {
+	MaxAccountFailures int           // アカウントごとの許容失敗回数
+	MaxIPFailures      int           // IPごとの許容失敗回数
+	BaseLockout        time.Duration // 最初のロック時間（以降失敗するたびに2倍）
+	MaxLockout         time.Duration // ロック時間の上限
+	FailureWindow      time.Duration // この期間失敗がなければ回数をリセットする
+}
+
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		MaxAccountFailures: 5,
+		MaxIPFailures:      20,
+		BaseLockout:        time.Minute,
+		MaxLockout:         time.Hour,
+		FailureWindow:      24 * time.Hour,
+	}
+}
+
+// lockoutFor は失敗回数に応じたロック時間を返す（しきい値未満なら0）
+func (p LockoutPolicy) lockoutFor(failures, limit int) time.Duration {
+	if failures < limit {
+		return 0
+	}
+	d := p.BaseLockout
+	for i := limit; i < failures && d < p.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > p.MaxLockout {
+		d = p.MaxLockout
+	}
+	return d
+}
+
+type ILoginGuard interface {
+	Check(email string, ip string) error                            // ロック中なら*LockoutErrorを返す
+	Fail(email string, targetUserID *uint, client model.ClientInfo) // targetUserIDはアカウントが特定できた場合のみ
+	Succeed(email string)
+}
+
+type loginGuard struct {
+	lr     repository.ILoginAttemptRepository
+	al     IAuditLogger
+	policy LockoutPolicy
+	now    func() time.Time
+}
+
+func NewLoginGuard(lr repository.ILoginAttemptRepository, al IAuditLogger, policy LockoutPolicy) ILoginGuard {
+	return &loginGuard{lr, al, policy, time.Now}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (lg *loginGuard) keys(email string, ip string) []string {
+	keys := []string{accountKey(email)}
+	if ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+	return keys
+}
+
+func (lg *loginGuard) get(key string) (model.LoginAttempt, error) {
+	attempt := model.LoginAttempt{}
+	err := lg.lr.GetLoginAttempt(&attempt, key)
+	return attempt, err
+}
+
+func (lg *loginGuard) record(key string, now time.Time) (model.LoginAttempt, error) {
+	attempt := model.LoginAttempt{}
+	err := lg.lr.RecordFailure(&attempt, key, now, lg.policy.FailureWindow)
+	return attempt, err
+}
+
+func (lg *loginGuard) Check(email string, ip string) error {
+	now := lg.now()
+	var retryAfter time.Duration
+	for _, key := range lg.keys(email, ip) {
+		attempt, err := lg.get(key)
+		if err != nil {
+			// 記録の取得に失敗してもログイン自体は止めない
+			log.Printf("login guard: failed to get attempt %s: %v", key, err)
+			continue
+		}
+		if attempt.LockedUntil != nil && attempt.LockedUntil.After(now) {
+			if d := attempt.LockedUntil.Sub(now); d > retryAfter {
+				retryAfter = d
+			}
+		}
+	}
+	if retryAfter > 0 {
+		return &LockoutError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+func (lg *loginGuard) Fail(email string, targetUserID *uint, client model.ClientInfo) {
+	now := lg.now()
+	for _, key := range lg.keys(email, client.IP) {
+		scope, limit, target := "account", lg.policy.MaxAccountFailures, targetUserID
+		if strings.HasPrefix(key, "ip:") {
+			// IPのロックは特定のアカウントに対するものではない
+			scope, limit, target = "ip", lg.policy.MaxIPFailures, nil
+		}
+		attempt, err := lg.record(key, now)
+		if err != nil {
+			log.Printf("login guard: failed to record failure %s: %v", key, err)
+			continue
+		}
+		if d := lg.policy.lockoutFor(attempt.Failures, limit); d > 0 {
+			until := now.Add(d)
+			if err := lg.lr.LockLoginAttempt(key, until); err != nil {
+				log.Printf("login guard: failed to lock %s: %v", key, err)
+				continue
+			}
+			logEvent(lg.al, AuditLoginLocked, nil, target, client, map[string]interface{}{
+				"email":        email,
+				"scope":        scope,
+				"failures":     attempt.Failures,
+				"locked_until": until.Format(time.RFC3339),
+			})
+		}
+	}
+}
+
+func (lg *loginGuard) Succeed(email string) {
+	// IPの記録は共有回線を考慮して成功時も残し、アカウントの記録のみ消去する
+	if err := lg.lr.ResetLoginAttempt(accountKey(email)); err != nil {
+		log.Printf("login guard: failed to reset attempt: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"backend/model"
+	"backend/repository"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockoutFor(t *testing.T) {
+	policy := DefaultLockoutPolicy()
+
+	testCases := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{name: "しきい値未満", failures: 4, want: 0},
+		{name: "しきい値ちょうど", failures: 5, want: time.Minute},
+		{name: "1回超過", failures: 6, want: 2 * time.Minute},
+		{name: "3回超過", failures: 8, want: 8 * time.Minute},
+		{name: "上限", failures: 50, want: time.Hour},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, policy.lockoutFor(tc.failures, policy.MaxAccountFailures))
+		})
+	}
+}
+
+func TestLoginGuard(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newGuard := func() *loginGuard {
+		lg := NewLoginGuard(repository.NewMemoryLoginAttemptRepository(), newTestAuditLogger(), DefaultLockoutPolicy()).(*loginGuard)
+		lg.now = func() time.Time { return now }
+		return lg
+	}
+
+	t.Run("アカウントごとのロック", func(t *testing.T) {
+		lg := newGuard()
+		for i := 0; i < 4; i++ {
+			lg.Fail("Test@Example.com", nil, model.ClientInfo{IP: "192.0.2.1"})
+		}
+		assert.NoError(t, lg.Check("test@example.com", "192.0.2.2"))
+
+		lg.Fail("test@example.com", nil, model.ClientInfo{IP: "192.0.2.1"})
+		err := lg.Check("test@example.com", "192.0.2.2") // 別のIPからでもロックされる
+		var lockoutErr *LockoutError
+		assert.True(t, errors.As(err, &lockoutErr))
+		assert.Equal(t, time.Minute, lockoutErr.RetryAfter)
+		assert.ErrorIs(t, err, ErrTooManyAttempts)
+
+		// 他のアカウントには影響しない
+		assert.NoError(t, lg.Check("other@example.com", "192.0.2.2"))
+	})
+
+	t.Run("IPごとのロック", func(t *testing.T) {
+		lg := newGuard()
+		for i := 0; i < 20; i++ {
+			lg.Fail(fmt.Sprintf("user%d@example.com", i), nil, model.ClientInfo{IP: "192.0.2.1"})
+		}
+		assert.ErrorIs(t, lg.Check("new@example.com", "192.0.2.1"), ErrTooManyAttempts)
+		assert.NoError(t, lg.Check("new@example.com", "192.0.2.2"))
+	})
+
+	t.Run("ロック期間の経過", func(t *testing.T) {
+		lg := newGuard()
+		for i := 0; i < 5; i++ {
+			lg.Fail("test@example.com", nil, model.ClientInfo{})
+		}
+		assert.Error(t, lg.Check("test@example.com", ""))
+
+		now = now.Add(time.Minute + time.Second)
+		assert.NoError(t, lg.Check("test@example.com", ""))
+
+		// ロック明けにまた失敗するとロック時間が倍になる
+		lg.Fail("test@example.com", nil, model.ClientInfo{})
+		var lockoutErr *LockoutError
+		assert.True(t, errors.As(lg.Check("test@example.com", ""), &lockoutErr))
+		assert.Equal(t, 2*time.Minute, lockoutErr.RetryAfter)
+	})
+
+	t.Run("ロックを監査ログに記録", func(t *testing.T) {
+		lg := newGuard()
+		al := lg.al.(*recordingAuditLogger)
+		userID := uint(1)
+		for i := 0; i < 4; i++ {
+			lg.Fail("test@example.com", &userID, model.ClientInfo{IP: "192.0.2.1"})
+		}
+		assert.Empty(t, al.actions())
+
+		lg.Fail("test@example.com", &userID, model.ClientInfo{IP: "192.0.2.1"})
+		assert.Equal(t, []string{AuditLoginLocked}, al.actions())
+		event := al.events[0]
+		assert.Equal(t, &userID, event.TargetUserID)
+		assert.Equal(t, "192.0.2.1", event.IP)
+		assert.Contains(t, event.Metadata, `"scope":"account"`)
+		assert.Contains(t, event.Metadata, `"failures":5`)
+	})
+
+	t.Run("IPのロックはアカウントに紐付けない", func(t *testing.T) {
+		lg := newGuard()
+		al := lg.al.(*recordingAuditLogger)
+		userID := uint(1)
+		for i := 0; i < 20; i++ {
+			lg.Fail(fmt.Sprintf("user%d@example.com", i), &userID, model.ClientInfo{IP: "192.0.2.1"})
+		}
+		assert.Equal(t, []string{AuditLoginLocked}, al.actions())
+		assert.Nil(t, al.events[0].TargetUserID)
+		assert.Contains(t, al.events[0].Metadata, `"scope":"ip"`)
+	})
+
+	t.Run("成功でリセット", func(t *testing.T) {
+		lg := newGuard()
+		for i := 0; i < 4; i++ {
+			lg.Fail("test@example.com", nil, model.ClientInfo{})
+		}
+		lg.Succeed("test@example.com")
+		lg.Fail("test@example.com", nil, model.ClientInfo{})
+		assert.NoError(t, lg.Check("test@example.com", ""))
+	})
+}
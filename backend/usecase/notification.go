@@ -0,0 +1,80 @@
+package usecase
+
+// アカウントに関する通知メールの文面と送信
+// 通知の送信に失敗しても元の操作は取り消さない（ログに残す）
+
+import (
+	"backend/mail"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const mailSendTimeout = 10 * time.Second
+
+// sendNotification は通知メールを送信する
+func sendNotification(m mail.Mailer, msg mail.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+	defer cancel()
+	if err := m.Send(ctx, msg); err != nil {
+		log.Printf("failed to send notification %q: %v", msg.Subject, err)
+	}
+}
+
+// passwordChangedMessage はパスワードが変更されたことを知らせるメール
+func passwordChangedMessage(to string, name string, at time.Time, ip string, userAgent string) mail.Message {
+	return mail.Message{
+		To:      to,
+		Subject: "【CookMeet】パスワードが変更されました",
+		Body: fmt.Sprintf(`%sさん
+
+CookMeetアカウントのパスワードが変更されました。
+
+日時: %s
+IPアドレス: %s
+ブラウザ: %s
+
+この操作に心当たりがない場合は、すぐにパスワードを再設定してください。
+他の端末のログインはすべて解除されています。
+`, name, at.Format("2006/01/02 15:04:05 MST"), ip, userAgent),
+	}
+}
+
+// emailChangeConfirmMessage は新しいメールアドレスに送る確認メール
+func emailChangeConfirmMessage(to string, name string, link string, expiresAt time.Time) mail.Message {
+	return mail.Message{
+		To:      to,
+		Subject: "【CookMeet】メールアドレスの確認",
+		Body: fmt.Sprintf(`%sさん
+
+CookMeetアカウントのメールアドレスをこのアドレスに変更する申請がありました。
+以下のリンクを開くと変更が完了します。
+
+%s
+
+リンクの有効期限: %s
+
+この操作に心当たりがない場合は、このメールを無視してください。
+`, name, link, expiresAt.Format("2006/01/02 15:04:05 MST")),
+	}
+}
+
+// emailChangedMessage は変更前のメールアドレスに送る通知（取り消しのリンクを含む）
+func emailChangedMessage(to string, name string, newEmail string, undoLink string, undoUntil time.Time) mail.Message {
+	return mail.Message{
+		To:      to,
+		Subject: "【CookMeet】メールアドレスが変更されました",
+		Body: fmt.Sprintf(`%sさん
+
+CookMeetアカウントのメールアドレスが %s に変更されました。
+
+この操作に心当たりがない場合は、以下のリンクから変更を取り消してください。
+取り消すとすべての端末のログインが解除されます。
+
+%s
+
+取り消しの有効期限: %s
+`, name, newEmail, undoLink, undoUntil.Format("2006/01/02 15:04:05 MST")),
+	}
+}
@@ -0,0 +1,50 @@
+package usecase
+
+import (
+	"backend/mail"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMailer は送信したメールを保持するテスト用のMailer
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []mail.Message
+	err      error
+}
+
+func newTestMailer() *recordingMailer {
+	return &recordingMailer{}
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func TestPasswordChangedMessage(t *testing.T) {
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg := passwordChangedMessage("test@example.com", "テスト", at, "192.0.2.1", "Mozilla/5.0")
+	assert.Equal(t, "test@example.com", msg.To)
+	assert.Contains(t, msg.Body, "テストさん")
+	assert.Contains(t, msg.Body, "2025/01/02 03:04:05 UTC")
+	assert.Contains(t, msg.Body, "192.0.2.1")
+	assert.Contains(t, msg.Body, "Mozilla/5.0")
+}
+
+func TestSendNotification(t *testing.T) {
+	// 送信に失敗してもパニックせず、ログに残すだけ
+	m := &recordingMailer{err: errors.New("smtp down")}
+	sendNotification(m, mail.Message{To: "test@example.com", Subject: "s"})
+	assert.Empty(t, m.messages)
+}
@@ -0,0 +1,222 @@
+package usecase
+
+// OIDCプロバイダー（Googleなど）によるログインと、外部アカウントの連携・解除を実装
+// Begin:state・nonce・PKCEのcode_verifierを生成し、認可エンドポイントのURLと署名済みのstateを返す
+// Callback:stateを検証して認可コードを交換し、紐付いたユーザーでログインする（連携フローであれば紐付けを作成する）
+// Disconnect:紐付けを解除する（ログイン手段がなくなる場合は解除しない）
+
+import (
+	"backend/auth"
+	"backend/model"
+	"backend/repository"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOIDCEmailNotVerified   = errors.New("oidc email is not verified")
+	ErrOIDCAccountExists      = errors.New("an account with this email already exists; log in and connect the provider")
+	ErrIdentityLinkedToOther  = errors.New("this external account is linked to another user")
+	ErrProviderAlreadyLinked  = errors.New("a different account of this provider is already linked")
+	ErrIdentityNotFound       = errors.New("identity not found")
+	ErrLastLoginMethod        = errors.New("cannot disconnect the last login method")
+	ErrUnknownOIDCProvider    = errors.New("unknown oidc provider")
+	ErrInvalidOIDCState       = errors.New("invalid oidc state")
+	ErrOIDCAuthorizationError = errors.New("oidc authorization failed")
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+type IOIDCUsecase interface {
+	Providers() []string
+	Begin(ctx context.Context, provider string, linkUserID uint) (authURL string, stateToken string, err error)
+	Callback(ctx context.Context, provider string, code string, state string, stateToken string, client model.ClientInfo) (model.OIDCCallbackResult, error)
+	ListIdentities(userID uint) ([]model.UserIdentityResponse, error)
+	Disconnect(userID uint, provider string) error
+}
+
+type oidcUsecase struct {
+	ur       repository.IUserRepository
+	ir       repository.IUserIdentityRepository
+	sm       ISessionManager
+	al       IAuditLogger
+	registry *auth.OIDCRegistry
+	secret   string
+}
+
+func NewOIDCUsecase(ur repository.IUserRepository, ir repository.IUserIdentityRepository, sm ISessionManager, al IAuditLogger, registry *auth.OIDCRegistry, secret string) IOIDCUsecase {
+	return &oidcUsecase{ur, ir, sm, al, registry, secret}
+}
+
+func (ou *oidcUsecase) Providers() []string {
+	return ou.registry.Names()
+}
+
+func (ou *oidcUsecase) provider(ctx context.Context, name string) (*auth.OIDCProvider, error) {
+	p, err := ou.registry.Get(ctx, name)
+	if errors.Is(err, auth.ErrUnknownProvider) {
+		return nil, ErrUnknownOIDCProvider
+	}
+	return p, err
+}
+
+func (ou *oidcUsecase) Begin(ctx context.Context, provider string, linkUserID uint) (string, string, error) {
+	p, err := ou.provider(ctx, provider)
+	if err != nil {
+		return "", "", err
+	}
+	st, err := auth.NewOIDCState(provider, linkUserID, oidcStateTTL)
+	if err != nil {
+		return "", "", err
+	}
+	stateToken, err := auth.SignOIDCState(st, ou.secret)
+	if err != nil {
+		return "", "", err
+	}
+	return p.AuthCodeURL(st.State, st.Nonce, st.Verifier), stateToken, nil
+}
+
+func (ou *oidcUsecase) Callback(ctx context.Context, provider string, code string, state string, stateToken string, client model.ClientInfo) (model.OIDCCallbackResult, error) {
+	st, err := auth.ParseOIDCState(stateToken, ou.secret, provider, state)
+	if err != nil {
+		return model.OIDCCallbackResult{}, ErrInvalidOIDCState
+	}
+	result := model.OIDCCallbackResult{Link: st.LinkUserID != 0}
+
+	p, err := ou.provider(ctx, provider)
+	if err != nil {
+		return result, err
+	}
+	ext, err := p.Exchange(ctx, code, st.Verifier, st.Nonce)
+	if err != nil {
+		return result, errors.Join(ErrOIDCAuthorizationError, err)
+	}
+
+	identity := model.UserIdentity{}
+	err = ou.ir.GetIdentity(&identity, provider, ext.Subject)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return result, err
+	}
+	found := err == nil
+
+	if result.Link {
+		return result, ou.link(st.LinkUserID, provider, ext, found, identity)
+	}
+
+	if found {
+		user, err := ou.ur.GetUserByID(identity.UserID)
+		if err != nil {
+			return result, err
+		}
+		result.LoginResult, err = ou.login(user, provider, client)
+		return result, err
+	}
+
+	// 未連携のアカウントでは、確認済みのメールアドレスで新しいユーザーを作成する
+	if ext.Email == "" || !ext.EmailVerified {
+		return result, ErrOIDCEmailNotVerified
+	}
+	// 既存ユーザーへの自動連携は乗っ取りにつながるため行わず、ログイン後の連携を求める
+	if err := ou.ur.GetUserByEmail(&model.User{}, ext.Email); err == nil {
+		return result, ErrOIDCAccountExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return result, err
+	}
+	name := ext.Name
+	if name == "" {
+		name = ext.Email
+	}
+	user := model.User{Name: name, Email: ext.Email}
+	if err := ou.ir.CreateUserWithIdentity(&user, &model.UserIdentity{Provider: provider, Subject: ext.Subject, Email: ext.Email}); err != nil {
+		return result, err
+	}
+	result.LoginResult, err = ou.login(&user, provider, client)
+	return result, err
+}
+
+// link はログイン中のユーザーに外部アカウントを紐付ける
+func (ou *oidcUsecase) link(userID uint, provider string, ext auth.OIDCIdentity, found bool, identity model.UserIdentity) error {
+	if found {
+		if identity.UserID != userID {
+			return ErrIdentityLinkedToOther
+		}
+		return nil // 連携済み
+	}
+	identities, err := ou.ir.GetIdentitiesByUserID(userID)
+	if err != nil {
+		return err
+	}
+	for _, i := range identities {
+		if i.Provider == provider {
+			return ErrProviderAlreadyLinked
+		}
+	}
+	return ou.ir.CreateIdentity(&model.UserIdentity{UserID: userID, Provider: provider, Subject: ext.Subject, Email: ext.Email})
+}
+
+// login はパスワードログインと同様に、二要素認証が有効であればMFAトークンを返す
+func (ou *oidcUsecase) login(user *model.User, provider string, client model.ClientInfo) (model.LoginResult, error) {
+	if user.DisabledAt != nil {
+		logEvent(ou.al, AuditLoginFailed, nil, &user.ID, client, map[string]interface{}{"reason": "account_disabled", "provider": provider})
+		return model.LoginResult{}, ErrAccountDisabled
+	}
+	if user.TOTPEnabled {
+		mfaToken, err := auth.Sign(auth.NewClaims(user.ID, auth.TokenTypeMFAPending, mfaTokenTTL), ou.secret)
+		if err != nil {
+			return model.LoginResult{}, err
+		}
+		return model.LoginResult{MFARequired: true, MFAToken: mfaToken}, nil
+	}
+	token, err := ou.sm.Issue(user, client)
+	if err != nil {
+		return model.LoginResult{}, err
+	}
+	logUserEvent(ou.al, AuditLogin, user.ID, client, map[string]interface{}{"method": "oidc", "provider": provider})
+	return model.LoginResult{Token: token}, nil
+}
+
+func (ou *oidcUsecase) ListIdentities(userID uint) ([]model.UserIdentityResponse, error) {
+	identities, err := ou.ir.GetIdentitiesByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]model.UserIdentityResponse, 0, len(identities))
+	for _, i := range identities {
+		res = append(res, model.UserIdentityResponse{Provider: i.Provider, Email: i.Email, CreatedAt: i.CreatedAt})
+	}
+	return res, nil
+}
+
+func (ou *oidcUsecase) Disconnect(userID uint, provider string) error {
+	user, err := ou.ur.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	identities, err := ou.ir.GetIdentitiesByUserID(userID)
+	if err != nil {
+		return err
+	}
+	linked := false
+	for _, i := range identities {
+		if i.Provider == provider {
+			linked = true
+		}
+	}
+	if !linked {
+		return ErrIdentityNotFound
+	}
+	// パスワードのないユーザーは、最後の連携を解除するとログインできなくなる
+	if user.Password == "" && len(identities) == 1 {
+		return ErrLastLoginMethod
+	}
+	if err := ou.ir.DeleteIdentity(userID, provider); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrIdentityNotFound
+		}
+		return err
+	}
+	return nil
+}
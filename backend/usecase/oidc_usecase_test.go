@@ -0,0 +1,302 @@
+package usecase
+
+import (
+	"backend/auth"
+	"backend/auth/oidctest"
+	"backend/model"
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type MockUserIdentityRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserIdentityRepository) GetIdentity(identity *model.UserIdentity, provider string, subject string) error {
+	args := m.Called(identity, provider, subject)
+	if found, ok := args.Get(1).(model.UserIdentity); ok {
+		*identity = found
+	}
+	return args.Error(0)
+}
+
+func (m *MockUserIdentityRepository) GetIdentitiesByUserID(userID uint) ([]model.UserIdentity, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.UserIdentity), args.Error(1)
+}
+
+func (m *MockUserIdentityRepository) CreateIdentity(identity *model.UserIdentity) error {
+	args := m.Called(identity)
+	return args.Error(0)
+}
+
+func (m *MockUserIdentityRepository) CreateUserWithIdentity(user *model.User, identity *model.UserIdentity) error {
+	args := m.Called(user, identity)
+	user.ID = 10
+	return args.Error(0)
+}
+
+func (m *MockUserIdentityRepository) DeleteIdentity(userID uint, provider string) error {
+	args := m.Called(userID, provider)
+	return args.Error(0)
+}
+
+// newTestOIDC は偽のissuerと、それを"fake"プロバイダーとして設定したレジストリを用意する
+func newTestOIDC(t *testing.T) (*oidctest.Issuer, *auth.OIDCRegistry) {
+	issuer := oidctest.NewIssuer("client-id", "client-secret")
+	t.Cleanup(issuer.Close)
+	registry := auth.NewOIDCRegistry([]auth.OIDCProviderConfig{{
+		Name:         "fake",
+		IssuerURL:    issuer.URL(),
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://localhost/auth/fake/callback",
+	}})
+	return issuer, registry
+}
+
+// authorize は認可URLにアクセスし、リダイレクト先のcodeとstateを返す
+func authorize(t *testing.T, authURL string) (string, string) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestOIDCLoginExistingIdentity(t *testing.T) {
+	issuer, registry := newTestOIDC(t)
+	issuer.SetUser(oidctest.User{Subject: "sub-1", Email: "test@example.com", EmailVerified: true})
+	mockUsers := new(MockUserRepository)
+	mockIdentities := new(MockUserIdentityRepository)
+	ou := NewOIDCUsecase(mockUsers, mockIdentities, newTestSessionManager(), newTestAuditLogger(), registry, testSecret)
+	ctx := context.Background()
+
+	mockIdentities.On("GetIdentity", mock.Anything, "fake", "sub-1").Return(nil, model.UserIdentity{UserID: 1, Provider: "fake", Subject: "sub-1"})
+	mockUsers.On("GetUserByID", uint(1)).Return(&model.User{ID: 1}, nil)
+
+	authURL, stateToken, err := ou.Begin(ctx, "fake", 0)
+	assert.NoError(t, err)
+	code, state := authorize(t, authURL)
+
+	result, err := ou.Callback(ctx, "fake", code, state, stateToken, testClient)
+	assert.NoError(t, err)
+	assert.False(t, result.Link)
+	claims, err := auth.Parse(result.Token, testSecret, auth.TokenTypeAccess)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), claims.UserID)
+
+	t.Run("stateの不一致", func(t *testing.T) {
+		authURL, stateToken, err := ou.Begin(ctx, "fake", 0)
+		assert.NoError(t, err)
+		code, _ := authorize(t, authURL)
+		_, err = ou.Callback(ctx, "fake", code, "forged", stateToken, testClient)
+		assert.ErrorIs(t, err, ErrInvalidOIDCState)
+	})
+
+	t.Run("別プロバイダーのstate", func(t *testing.T) {
+		_, stateToken, err := ou.Begin(ctx, "fake", 0)
+		assert.NoError(t, err)
+		_, err = ou.Callback(ctx, "other", "code", state, stateToken, testClient)
+		assert.ErrorIs(t, err, ErrInvalidOIDCState)
+	})
+
+	t.Run("コードの再利用", func(t *testing.T) {
+		_, err := ou.Callback(ctx, "fake", code, state, stateToken, testClient)
+		assert.ErrorIs(t, err, ErrOIDCAuthorizationError)
+	})
+}
+
+func TestOIDCLoginMFA(t *testing.T) {
+	issuer, registry := newTestOIDC(t)
+	issuer.SetUser(oidctest.User{Subject: "sub-1", Email: "test@example.com", EmailVerified: true})
+	mockUsers := new(MockUserRepository)
+	mockIdentities := new(MockUserIdentityRepository)
+	ou := NewOIDCUsecase(mockUsers, mockIdentities, newTestSessionManager(), newTestAuditLogger(), registry, testSecret)
+	ctx := context.Background()
+
+	mockIdentities.On("GetIdentity", mock.Anything, "fake", "sub-1").Return(nil, model.UserIdentity{UserID: 1})
+	mockUsers.On("GetUserByID", uint(1)).Return(&model.User{ID: 1, TOTPEnabled: true}, nil)
+
+	authURL, stateToken, err := ou.Begin(ctx, "fake", 0)
+	assert.NoError(t, err)
+	code, state := authorize(t, authURL)
+
+	result, err := ou.Callback(ctx, "fake", code, state, stateToken, testClient)
+	assert.NoError(t, err)
+	assert.True(t, result.MFARequired)
+	assert.Empty(t, result.Token)
+	_, err = auth.Parse(result.MFAToken, testSecret, auth.TokenTypeMFAPending)
+	assert.NoError(t, err)
+}
+
+func TestOIDCLoginNewUser(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ユーザーを作成", func(t *testing.T) {
+		issuer, registry := newTestOIDC(t)
+		issuer.SetUser(oidctest.User{Subject: "sub-2", Email: "new@example.com", EmailVerified: true, Name: "New User"})
+		mockUsers := new(MockUserRepository)
+		mockIdentities := new(MockUserIdentityRepository)
+		ou := NewOIDCUsecase(mockUsers, mockIdentities, newTestSessionManager(), newTestAuditLogger(), registry, testSecret)
+
+		mockIdentities.On("GetIdentity", mock.Anything, "fake", "sub-2").Return(gorm.ErrRecordNotFound, nil)
+		mockUsers.On("GetUserByEmail", mock.Anything, "new@example.com").Return(gorm.ErrRecordNotFound)
+		mockIdentities.On("CreateUserWithIdentity", mock.MatchedBy(func(u *model.User) bool {
+			return u.Email == "new@example.com" && u.Name == "New User" && u.Password == ""
+		}), mock.MatchedBy(func(i *model.UserIdentity) bool {
+			return i.Provider == "fake" && i.Subject == "sub-2"
+		})).Return(nil)
+
+		authURL, stateToken, err := ou.Begin(ctx, "fake", 0)
+		assert.NoError(t, err)
+		code, state := authorize(t, authURL)
+		result, err := ou.Callback(ctx, "fake", code, state, stateToken, testClient)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, result.Token)
+		mockIdentities.AssertExpectations(t)
+	})
+
+	t.Run("既存のメールアドレスには自動で連携しない", func(t *testing.T) {
+		issuer, registry := newTestOIDC(t)
+		issuer.SetUser(oidctest.User{Subject: "sub-3", Email: "test@example.com", EmailVerified: true})
+		mockUsers := new(MockUserRepository)
+		mockIdentities := new(MockUserIdentityRepository)
+		ou := NewOIDCUsecase(mockUsers, mockIdentities, newTestSessionManager(), newTestAuditLogger(), registry, testSecret)
+
+		mockIdentities.On("GetIdentity", mock.Anything, "fake", "sub-3").Return(gorm.ErrRecordNotFound, nil)
+		mockUsers.On("GetUserByEmail", mock.Anything, "test@example.com").Return(nil)
+
+		authURL, stateToken, err := ou.Begin(ctx, "fake", 0)
+		assert.NoError(t, err)
+		code, state := authorize(t, authURL)
+		_, err = ou.Callback(ctx, "fake", code, state, stateToken, testClient)
+		assert.ErrorIs(t, err, ErrOIDCAccountExists)
+		mockIdentities.AssertNotCalled(t, "CreateUserWithIdentity", mock.Anything, mock.Anything)
+	})
+
+	t.Run("未確認のメールアドレス", func(t *testing.T) {
+		issuer, registry := newTestOIDC(t)
+		issuer.SetUser(oidctest.User{Subject: "sub-4", Email: "unverified@example.com", EmailVerified: false})
+		mockIdentities := new(MockUserIdentityRepository)
+		ou := NewOIDCUsecase(new(MockUserRepository), mockIdentities, newTestSessionManager(), newTestAuditLogger(), registry, testSecret)
+
+		mockIdentities.On("GetIdentity", mock.Anything, "fake", "sub-4").Return(gorm.ErrRecordNotFound, nil)
+
+		authURL, stateToken, err := ou.Begin(ctx, "fake", 0)
+		assert.NoError(t, err)
+		code, state := authorize(t, authURL)
+		_, err = ou.Callback(ctx, "fake", code, state, stateToken, testClient)
+		assert.ErrorIs(t, err, ErrOIDCEmailNotVerified)
+	})
+}
+
+func TestOIDCLink(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ログイン中のユーザーに連携", func(t *testing.T) {
+		issuer, registry := newTestOIDC(t)
+		issuer.SetUser(oidctest.User{Subject: "sub-5", Email: "other@example.com", EmailVerified: false})
+		mockIdentities := new(MockUserIdentityRepository)
+		ou := NewOIDCUsecase(new(MockUserRepository), mockIdentities, newTestSessionManager(), newTestAuditLogger(), registry, testSecret)
+
+		mockIdentities.On("GetIdentity", mock.Anything, "fake", "sub-5").Return(gorm.ErrRecordNotFound, nil)
+		mockIdentities.On("GetIdentitiesByUserID", uint(1)).Return([]model.UserIdentity{}, nil)
+		mockIdentities.On("CreateIdentity", mock.MatchedBy(func(i *model.UserIdentity) bool {
+			return i.UserID == 1 && i.Provider == "fake" && i.Subject == "sub-5"
+		})).Return(nil)
+
+		authURL, stateToken, err := ou.Begin(ctx, "fake", 1)
+		assert.NoError(t, err)
+		code, state := authorize(t, authURL)
+		result, err := ou.Callback(ctx, "fake", code, state, stateToken, testClient)
+		assert.NoError(t, err)
+		assert.True(t, result.Link)
+		assert.Empty(t, result.Token, "連携ではセッションを発行しない")
+		mockIdentities.AssertExpectations(t)
+	})
+
+	t.Run("別のユーザーに連携済み", func(t *testing.T) {
+		issuer, registry := newTestOIDC(t)
+		issuer.SetUser(oidctest.User{Subject: "sub-6"})
+		mockIdentities := new(MockUserIdentityRepository)
+		ou := NewOIDCUsecase(new(MockUserRepository), mockIdentities, newTestSessionManager(), newTestAuditLogger(), registry, testSecret)
+
+		mockIdentities.On("GetIdentity", mock.Anything, "fake", "sub-6").Return(nil, model.UserIdentity{UserID: 2})
+
+		authURL, stateToken, err := ou.Begin(ctx, "fake", 1)
+		assert.NoError(t, err)
+		code, state := authorize(t, authURL)
+		result, err := ou.Callback(ctx, "fake", code, state, stateToken, testClient)
+		assert.ErrorIs(t, err, ErrIdentityLinkedToOther)
+		assert.True(t, result.Link)
+	})
+}
+
+func TestOIDCDisconnect(t *testing.T) {
+	_, registry := newTestOIDC(t)
+
+	testCases := []struct {
+		name       string
+		user       *model.User
+		identities []model.UserIdentity
+		expectErr  error
+	}{
+		{
+			name:       "パスワードがあれば解除できる",
+			user:       &model.User{ID: 1, Password: "hash"},
+			identities: []model.UserIdentity{{Provider: "fake"}},
+		},
+		{
+			name:       "他の連携があれば解除できる",
+			user:       &model.User{ID: 1},
+			identities: []model.UserIdentity{{Provider: "fake"}, {Provider: "google"}},
+		},
+		{
+			name:       "最後のログイン手段",
+			user:       &model.User{ID: 1},
+			identities: []model.UserIdentity{{Provider: "fake"}},
+			expectErr:  ErrLastLoginMethod,
+		},
+		{
+			name:       "未連携",
+			user:       &model.User{ID: 1, Password: "hash"},
+			identities: []model.UserIdentity{{Provider: "google"}},
+			expectErr:  ErrIdentityNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUsers := new(MockUserRepository)
+			mockIdentities := new(MockUserIdentityRepository)
+			ou := NewOIDCUsecase(mockUsers, mockIdentities, newTestSessionManager(), newTestAuditLogger(), registry, testSecret)
+
+			mockUsers.On("GetUserByID", uint(1)).Return(tc.user, nil)
+			mockIdentities.On("GetIdentitiesByUserID", uint(1)).Return(tc.identities, nil)
+			mockIdentities.On("DeleteIdentity", uint(1), "fake").Return(nil)
+
+			err := ou.Disconnect(1, "fake")
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, err, tc.expectErr)
+				mockIdentities.AssertNotCalled(t, "DeleteIdentity", uint(1), "fake")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package usecase
+
+// パーソナルアクセストークンの作成・一覧・失効と、リクエスト時の検証を実装
+// トークンはランダムな文字列で、SHA-256のハッシュのみを保存する（作成時にだけ本体を返す）
+
+import (
+	"backend/auth"
+	"backend/model"
+	"backend/repository"
+	"backend/validator"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTokenNotFound = errors.New("token not found")
+	ErrInvalidToken  = errors.New("invalid or expired token")
+)
+
+const (
+	patPrefix             = "cmpat_" // シークレットスキャナーで検出しやすいよう固定の接頭辞を付ける
+	patDisplayLength      = 12
+	defaultTokenLifetime  = 30 * 24 * time.Hour
+	tokenTouchGranularity = time.Minute // 最終使用日時の更新はこの間隔より細かく行わない
+)
+
+type IPersonalAccessTokenUsecase interface {
+	Create(userID uint, req model.PersonalAccessTokenRequest, client model.ClientInfo) (model.PersonalAccessTokenCreated, error)
+	List(userID uint) ([]model.PersonalAccessTokenResponse, error)
+	Revoke(userID uint, tokenID uint, client model.ClientInfo) error
+	VerifyToken(token string) (*auth.Claims, error) // auth.TokenVerifierとしてミドルウェアから呼ばれる
+}
+
+type personalAccessTokenUsecase struct {
+	pr  repository.IPersonalAccessTokenRepository
+	pv  validator.IPersonalAccessTokenValidator
+	al  IAuditLogger
+	now func() time.Time
+}
+
+func NewPersonalAccessTokenUsecase(pr repository.IPersonalAccessTokenRepository, pv validator.IPersonalAccessTokenValidator, al IAuditLogger) IPersonalAccessTokenUsecase {
+	return &personalAccessTokenUsecase{pr, pv, al, time.Now}
+}
+
+func (pu *personalAccessTokenUsecase) Create(userID uint, req model.PersonalAccessTokenRequest, client model.ClientInfo) (model.PersonalAccessTokenCreated, error) {
+	if err := pu.pv.PersonalAccessTokenValidate(req); err != nil {
+		return model.PersonalAccessTokenCreated{}, err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return model.PersonalAccessTokenCreated{}, err
+	}
+	plain := patPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	lifetime := defaultTokenLifetime
+	if req.ExpiresInDays > 0 {
+		lifetime = time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	}
+	token := model.PersonalAccessToken{
+		UserID:    userID,
+		Name:      req.Name,
+		TokenHash: hashToken(plain),
+		Prefix:    plain[:patDisplayLength],
+		Scopes:    strings.Join(uniqueScopes(req.Scopes), " "),
+		ExpiresAt: pu.now().Add(lifetime),
+	}
+	if err := pu.pr.CreateToken(&token); err != nil {
+		return model.PersonalAccessTokenCreated{}, err
+	}
+	logUserEvent(pu.al, AuditTokenCreated, userID, client, map[string]interface{}{
+		"token_id": token.ID,
+		"name":     token.Name,
+		"prefix":   token.Prefix,
+		"scopes":   strings.Fields(token.Scopes),
+	})
+	return model.PersonalAccessTokenCreated{PersonalAccessTokenResponse: toTokenResponse(token), Token: plain}, nil
+}
+
+func (pu *personalAccessTokenUsecase) List(userID uint) ([]model.PersonalAccessTokenResponse, error) {
+	tokens, err := pu.pr.GetTokensByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]model.PersonalAccessTokenResponse, 0, len(tokens))
+	for _, t := range tokens {
+		res = append(res, toTokenResponse(t))
+	}
+	return res, nil
+}
+
+func (pu *personalAccessTokenUsecase) Revoke(userID uint, tokenID uint, client model.ClientInfo) error {
+	if err := pu.pr.DeleteToken(userID, tokenID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTokenNotFound
+		}
+		return err
+	}
+	logUserEvent(pu.al, AuditTokenRevoked, userID, client, map[string]interface{}{"token_id": tokenID})
+	return nil
+}
+
+func (pu *personalAccessTokenUsecase) VerifyToken(plain string) (*auth.Claims, error) {
+	if !strings.HasPrefix(plain, patPrefix) {
+		return nil, ErrInvalidToken
+	}
+	token := model.PersonalAccessToken{}
+	if err := pu.pr.GetTokenByHash(&token, hashToken(plain)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	now := pu.now()
+	if !now.Before(token.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= tokenTouchGranularity {
+		_ = pu.pr.TouchToken(token.ID, now) // 記録に失敗してもリクエストは通す
+	}
+	return &auth.Claims{
+		UserID:    token.UserID,
+		Roles:     []string{auth.RoleUser},
+		Scopes:    strings.Fields(token.Scopes),
+		TokenType: auth.TokenTypePAT,
+	}, nil
+}
+
+// hashToken はトークンをハッシュ化する
+// トークンは十分なエントロピーを持つため、高速なハッシュで問題ない
+func hashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+func uniqueScopes(scopes []string) []string {
+	seen := map[string]bool{}
+	res := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		if !seen[s] {
+			seen[s] = true
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func toTokenResponse(t model.PersonalAccessToken) model.PersonalAccessTokenResponse {
+	return model.PersonalAccessTokenResponse{
+		ID:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Scopes:     strings.Fields(t.Scopes),
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"backend/auth"
+	"backend/model"
+	"backend/validator"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type MockPersonalAccessTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockPersonalAccessTokenRepository) CreateToken(token *model.PersonalAccessToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockPersonalAccessTokenRepository) GetTokensByUserID(userID uint) ([]model.PersonalAccessToken, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.PersonalAccessToken), args.Error(1)
+}
+
+func (m *MockPersonalAccessTokenRepository) GetTokenByHash(token *model.PersonalAccessToken, tokenHash string) error {
+	args := m.Called(token, tokenHash)
+	if found, ok := args.Get(1).(model.PersonalAccessToken); ok {
+		*token = found
+	}
+	return args.Error(0)
+}
+
+func (m *MockPersonalAccessTokenRepository) DeleteToken(userID uint, tokenID uint) error {
+	args := m.Called(userID, tokenID)
+	return args.Error(0)
+}
+
+func (m *MockPersonalAccessTokenRepository) TouchToken(tokenID uint, usedAt time.Time) error {
+	args := m.Called(tokenID, usedAt)
+	return args.Error(0)
+}
+
+func TestCreatePersonalAccessToken(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockPersonalAccessTokenRepository)
+		al := newTestAuditLogger()
+		pu := NewPersonalAccessTokenUsecase(mockRepo, validator.NewPersonalAccessTokenValidator(), al)
+
+		var saved *model.PersonalAccessToken
+		mockRepo.On("CreateToken", mock.AnythingOfType("*model.PersonalAccessToken")).Run(func(args mock.Arguments) {
+			saved = args.Get(0).(*model.PersonalAccessToken)
+		}).Return(nil).Once()
+
+		created, err := pu.Create(1, model.PersonalAccessTokenRequest{
+			Name:          "shortcut",
+			Scopes:        []string{auth.ScopeWriteCuisines, auth.ScopeWriteCuisines},
+			ExpiresInDays: 7,
+		}, testClient)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(created.Token, "cmpat_"))
+		assert.Equal(t, []string{auth.ScopeWriteCuisines}, created.Scopes)
+		assert.Equal(t, created.Token[:patDisplayLength], created.Prefix)
+		// 平文は保存しない
+		assert.Equal(t, hashToken(created.Token), saved.TokenHash)
+		assert.NotContains(t, saved.TokenHash, created.Token)
+		assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), saved.ExpiresAt, time.Minute)
+		assert.Equal(t, []string{AuditTokenCreated}, al.actions())
+		assert.NotContains(t, al.events[0].Metadata, created.Token)
+	})
+
+	t.Run("期限を省略", func(t *testing.T) {
+		mockRepo := new(MockPersonalAccessTokenRepository)
+		pu := NewPersonalAccessTokenUsecase(mockRepo, validator.NewPersonalAccessTokenValidator(), newTestAuditLogger())
+		mockRepo.On("CreateToken", mock.Anything).Return(nil).Once()
+
+		created, err := pu.Create(1, model.PersonalAccessTokenRequest{Name: "script", Scopes: []string{auth.ScopeReadCuisines}}, testClient)
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(defaultTokenLifetime), created.ExpiresAt, time.Minute)
+	})
+
+	invalid := []struct {
+		name string
+		req  model.PersonalAccessTokenRequest
+	}{
+		{name: "名前なし", req: model.PersonalAccessTokenRequest{Scopes: []string{auth.ScopeReadCuisines}}},
+		{name: "スコープなし", req: model.PersonalAccessTokenRequest{Name: "script"}},
+		{name: "未知のスコープ", req: model.PersonalAccessTokenRequest{Name: "script", Scopes: []string{"admin"}}},
+		{name: "期限が長すぎる", req: model.PersonalAccessTokenRequest{Name: "script", Scopes: []string{auth.ScopeReadCuisines}, ExpiresInDays: 366}},
+	}
+	for _, tc := range invalid {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockPersonalAccessTokenRepository)
+			pu := NewPersonalAccessTokenUsecase(mockRepo, validator.NewPersonalAccessTokenValidator(), newTestAuditLogger())
+
+			_, err := pu.Create(1, tc.req, testClient)
+			assert.Error(t, err)
+			mockRepo.AssertNotCalled(t, "CreateToken", mock.Anything)
+		})
+	}
+}
+
+func TestVerifyPersonalAccessToken(t *testing.T) {
+	const plain = "cmpat_abcdefghijklmnop"
+
+	t.Run("有効なトークン", func(t *testing.T) {
+		mockRepo := new(MockPersonalAccessTokenRepository)
+		pu := NewPersonalAccessTokenUsecase(mockRepo, validator.NewPersonalAccessTokenValidator(), newTestAuditLogger())
+		mockRepo.On("GetTokenByHash", mock.Anything, hashToken(plain)).Return(nil, model.PersonalAccessToken{
+			ID: 3, UserID: 1, Scopes: "read:cuisines", ExpiresAt: time.Now().Add(time.Hour),
+		})
+		mockRepo.On("TouchToken", uint(3), mock.Anything).Return(nil).Once()
+
+		claims, err := pu.VerifyToken(plain)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), claims.UserID)
+		assert.True(t, claims.HasScope(auth.ScopeReadCuisines))
+		assert.False(t, claims.HasScope(auth.ScopeWriteCuisines))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("最近使用されていれば記録しない", func(t *testing.T) {
+		mockRepo := new(MockPersonalAccessTokenRepository)
+		pu := NewPersonalAccessTokenUsecase(mockRepo, validator.NewPersonalAccessTokenValidator(), newTestAuditLogger())
+		lastUsed := time.Now().Add(-time.Second)
+		mockRepo.On("GetTokenByHash", mock.Anything, hashToken(plain)).Return(nil, model.PersonalAccessToken{
+			ID: 3, UserID: 1, ExpiresAt: time.Now().Add(time.Hour), LastUsedAt: &lastUsed,
+		})
+
+		_, err := pu.VerifyToken(plain)
+		assert.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "TouchToken", mock.Anything, mock.Anything)
+	})
+
+	t.Run("期限切れ", func(t *testing.T) {
+		mockRepo := new(MockPersonalAccessTokenRepository)
+		pu := NewPersonalAccessTokenUsecase(mockRepo, validator.NewPersonalAccessTokenValidator(), newTestAuditLogger())
+		mockRepo.On("GetTokenByHash", mock.Anything, hashToken(plain)).Return(nil, model.PersonalAccessToken{
+			ID: 3, UserID: 1, ExpiresAt: time.Now().Add(-time.Hour),
+		})
+
+		_, err := pu.VerifyToken(plain)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("存在しないトークン", func(t *testing.T) {
+		mockRepo := new(MockPersonalAccessTokenRepository)
+		pu := NewPersonalAccessTokenUsecase(mockRepo, validator.NewPersonalAccessTokenValidator(), newTestAuditLogger())
+		mockRepo.On("GetTokenByHash", mock.Anything, hashToken(plain)).Return(gorm.ErrRecordNotFound, nil)
+
+		_, err := pu.VerifyToken(plain)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("形式が違う", func(t *testing.T) {
+		mockRepo := new(MockPersonalAccessTokenRepository)
+		pu := NewPersonalAccessTokenUsecase(mockRepo, validator.NewPersonalAccessTokenValidator(), newTestAuditLogger())
+
+		_, err := pu.VerifyToken("eyJhbGciOiJIUzI1NiJ9")
+		assert.ErrorIs(t, err, ErrInvalidToken)
+		mockRepo.AssertNotCalled(t, "GetTokenByHash", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRevokePersonalAccessToken(t *testing.T) {
+	mockRepo := new(MockPersonalAccessTokenRepository)
+	al := newTestAuditLogger()
+	pu := NewPersonalAccessTokenUsecase(mockRepo, validator.NewPersonalAccessTokenValidator(), al)
+
+	mockRepo.On("DeleteToken", uint(1), uint(3)).Return(nil).Once()
+	mockRepo.On("DeleteToken", uint(1), uint(4)).Return(gorm.ErrRecordNotFound).Once()
+
+	assert.NoError(t, pu.Revoke(1, 3, testClient))
+	assert.ErrorIs(t, pu.Revoke(1, 4, testClient), ErrTokenNotFound)
+	assert.Equal(t, []string{AuditTokenRevoked}, al.actions(), "失効できなかった場合は記録しない")
+}
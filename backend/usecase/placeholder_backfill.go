@@ -0,0 +1,110 @@
+package usecase
+
+// 以前に保存した料理画像のBlurHash・代表色・知覚ハッシュ（重複の検出に使う）の補完
+// BlurHashか知覚ハッシュを求めていない料理のサムネイル（変換していない以前の画像は元の画像）を読み込んで求め、保存する
+// 以前のURLのままの画像（保存先のキーに移行できなかったもの）は対象にしない
+
+import (
+	"backend/imageproc"
+	"backend/repository"
+	"backend/storage"
+	"bytes"
+	"context"
+	"io"
+	"log"
+)
+
+// DefaultPlaceholderBackfillBatchSize は一度に取得する料理の数の既定値
+const DefaultPlaceholderBackfillBatchSize = 100
+
+type PlaceholderBackfillOptions struct {
+	DryRun    bool // 保存せずに対象を報告する
+	BatchSize int
+}
+
+// PlaceholderBackfillReport は実行結果
+type PlaceholderBackfillReport struct {
+	DryRun  bool   `json:"dry_run"`
+	Scanned int    `json:"scanned"` // BlurHashか知覚ハッシュを求めていない料理の数
+	Updated int    `json:"updated"` // 求めた数（dry-runでは保存しない）
+	Skipped int    `json:"skipped"` // 以前のURLのままの画像
+	Failed  []uint `json:"failed"`  // 画像を読み込めなかった・保存できなかった料理のID
+}
+
+type IPlaceholderBackfillUsecase interface {
+	Run(ctx context.Context, opts PlaceholderBackfillOptions) (PlaceholderBackfillReport, error)
+}
+
+type placeholderBackfillUsecase struct {
+	pr repository.ICuisinePlaceholderRepository
+	st storage.ObjectStore
+	ip *imageproc.Processor
+}
+
+func NewPlaceholderBackfillUsecase(pr repository.ICuisinePlaceholderRepository, st storage.ObjectStore, ip *imageproc.Processor) IPlaceholderBackfillUsecase {
+	return &placeholderBackfillUsecase{pr, st, ip}
+}
+
+func (bu *placeholderBackfillUsecase) Run(ctx context.Context, opts PlaceholderBackfillOptions) (PlaceholderBackfillReport, error) {
+	report := PlaceholderBackfillReport{DryRun: opts.DryRun, Failed: []uint{}}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultPlaceholderBackfillBatchSize
+	}
+
+	var afterID uint
+	for {
+		cuisines, err := bu.pr.CuisinesWithoutPlaceholder(afterID, batchSize)
+		if err != nil {
+			return report, err
+		}
+		if len(cuisines) == 0 {
+			return report, nil
+		}
+		for _, cuisine := range cuisines {
+			afterID = cuisine.ID
+			report.Scanned++
+			key, ok := imageKey(cuisine.IconURL)
+			if !ok {
+				report.Skipped++
+				continue
+			}
+			p, hash, err := bu.placeholder(ctx, imageVariantKeys(key)[imageproc.VariantThumbnail])
+			if err != nil {
+				log.Printf("failed to compute placeholder for cuisine %d: %v", cuisine.ID, err)
+				report.Failed = append(report.Failed, cuisine.ID)
+				continue
+			}
+			if !opts.DryRun {
+				if err := bu.pr.SetCuisinePlaceholder(cuisine.ID, p.BlurHash, p.DominantColor, hash); err != nil {
+					log.Printf("failed to save placeholder for cuisine %d: %v", cuisine.ID, err)
+					report.Failed = append(report.Failed, cuisine.ID)
+					continue
+				}
+			}
+			report.Updated++
+		}
+	}
+}
+
+// placeholder は画像を読み込み、BlurHash・代表色と知覚ハッシュを求める
+func (bu *placeholderBackfillUsecase) placeholder(ctx context.Context, key string) (imageproc.Placeholder, string, error) {
+	r, _, err := bu.st.Get(ctx, key)
+	if err != nil {
+		return imageproc.Placeholder{}, "", err
+	}
+	data, err := io.ReadAll(io.LimitReader(r, bu.ip.Limits.MaxBytes+1))
+	r.Close()
+	if err != nil {
+		return imageproc.Placeholder{}, "", err
+	}
+	p, err := bu.ip.Placeholder(bytes.NewReader(data))
+	if err != nil {
+		return imageproc.Placeholder{}, "", err
+	}
+	hash, err := bu.ip.PerceptualHash(bytes.NewReader(data))
+	if err != nil {
+		return imageproc.Placeholder{}, "", err
+	}
+	return p, imageproc.FormatPerceptualHash(hash), nil
+}
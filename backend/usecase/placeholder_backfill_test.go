@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockCuisinePlaceholderRepository struct {
+	mock.Mock
+}
+
+func (m *MockCuisinePlaceholderRepository) CuisinesWithoutPlaceholder(afterID uint, limit int) ([]model.Cuisine, error) {
+	args := m.Called(afterID, limit)
+	return args.Get(0).([]model.Cuisine), args.Error(1)
+}
+
+func (m *MockCuisinePlaceholderRepository) SetCuisinePlaceholder(cuisineID uint, blurHash string, dominantColor string, imageHash string) error {
+	args := m.Called(cuisineID, blurHash, dominantColor, imageHash)
+	return args.Error(0)
+}
+
+func TestPlaceholderBackfill(t *testing.T) {
+	ctx := context.Background()
+	converted, old, missing, legacy := "images/1/abc/full.jpg", "images/1/old.jpg", "images/1/gone/full.jpg", "https://example.com/legacy.jpg"
+	setup := func(t *testing.T) *MockCuisinePlaceholderRepository {
+		pr := new(MockCuisinePlaceholderRepository)
+		pr.On("CuisinesWithoutPlaceholder", uint(0), 2).Return([]model.Cuisine{{ID: 1, IconURL: &converted}, {ID: 2, IconURL: &old}}, nil).Once()
+		pr.On("CuisinesWithoutPlaceholder", uint(2), 2).Return([]model.Cuisine{{ID: 3, IconURL: &missing}, {ID: 4, IconURL: &legacy}}, nil).Once()
+		pr.On("CuisinesWithoutPlaceholder", uint(4), 2).Return([]model.Cuisine{}, nil).Once()
+		return pr
+	}
+	st := newTestObjectStore()
+	jpeg := newTestJPEG(t, 200, 150)
+	require.NoError(t, st.Put(ctx, "images/1/abc/thumbnail.jpg", "image/jpeg", bytes.NewReader(jpeg))) // 変換した画像はサムネイルから求める
+	require.NoError(t, st.Put(ctx, old, "image/jpeg", bytes.NewReader(jpeg)))                          // 以前の画像は元の画像から求める
+
+	t.Run("BlurHashと代表色を求めて保存する", func(t *testing.T) {
+		pr := setup(t)
+		pr.On("SetCuisinePlaceholder", uint(1), mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil).Once()
+		pr.On("SetCuisinePlaceholder", uint(2), mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil).Once()
+
+		report, err := NewPlaceholderBackfillUsecase(pr, st, newTestImageProcessor()).Run(ctx, PlaceholderBackfillOptions{BatchSize: 2})
+		require.NoError(t, err)
+		assert.Equal(t, 4, report.Scanned)
+		assert.Equal(t, 2, report.Updated)
+		assert.Equal(t, 1, report.Skipped)
+		assert.Equal(t, []uint{3}, report.Failed)
+		pr.AssertExpectations(t)
+		blurHash := pr.Calls[1].Arguments.String(1)
+		assert.Len(t, blurHash, 28)
+		assert.Regexp(t, `^[0-9a-f]{16}$`, pr.Calls[1].Arguments.String(3))
+	})
+
+	t.Run("dry-runでは保存しない", func(t *testing.T) {
+		pr := setup(t)
+		report, err := NewPlaceholderBackfillUsecase(pr, st, newTestImageProcessor()).Run(ctx, PlaceholderBackfillOptions{DryRun: true, BatchSize: 2})
+		require.NoError(t, err)
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 2, report.Updated)
+		pr.AssertNotCalled(t, "SetCuisinePlaceholder", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("料理を取得できなければエラー", func(t *testing.T) {
+		pr := new(MockCuisinePlaceholderRepository)
+		pr.On("CuisinesWithoutPlaceholder", uint(0), DefaultPlaceholderBackfillBatchSize).Return([]model.Cuisine{}, errors.New("db error"))
+		_, err := NewPlaceholderBackfillUsecase(pr, st, newTestImageProcessor()).Run(ctx, PlaceholderBackfillOptions{})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,46 @@
+package usecase
+
+// ユーザー本人のセキュリティイベント（ログイン・パスワード変更など）の一覧を実装
+
+import (
+	"backend/model"
+	"backend/repository"
+	"encoding/json"
+)
+
+const securityEventLimit = 50
+
+type ISecurityEventUsecase interface {
+	List(userID uint) ([]model.SecurityEventResponse, error)
+}
+
+type securityEventUsecase struct {
+	ar repository.IAuditEventRepository
+}
+
+func NewSecurityEventUsecase(ar repository.IAuditEventRepository) ISecurityEventUsecase {
+	return &securityEventUsecase{ar}
+}
+
+func (su *securityEventUsecase) List(userID uint) ([]model.SecurityEventResponse, error) {
+	events, err := su.ar.GetEventsByTargetUser(userID, securityEventActions, securityEventLimit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]model.SecurityEventResponse, 0, len(events))
+	for _, e := range events {
+		metadata := json.RawMessage(e.Metadata)
+		if !json.Valid(metadata) {
+			metadata = json.RawMessage("{}")
+		}
+		res = append(res, model.SecurityEventResponse{
+			ID:        e.ID,
+			Action:    e.Action,
+			IP:        e.IP,
+			UserAgent: e.UserAgent,
+			Metadata:  metadata,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"backend/model"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListSecurityEvents(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockAuditEventRepository)
+		su := NewSecurityEventUsecase(mockRepo)
+		mockRepo.On("GetEventsByTargetUser", uint(1), securityEventActions, securityEventLimit).Return([]model.AuditEvent{
+			{ID: 2, Action: AuditLoginFailed, IP: "192.0.2.1", Metadata: `{"reason":"invalid_password"}`},
+			{ID: 1, Action: AuditLogin, Metadata: ""},
+		}, nil)
+
+		events, err := su.List(1)
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+		assert.Equal(t, json.RawMessage(`{"reason":"invalid_password"}`), events[0].Metadata)
+		assert.Equal(t, json.RawMessage(`{}`), events[1].Metadata)
+	})
+
+	t.Run("管理者の操作は含めない", func(t *testing.T) {
+		assert.NotContains(t, securityEventActions, AuditAdminViewUser)
+		assert.NotContains(t, securityEventActions, AuditAdminDisableUser)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		mockRepo := new(MockAuditEventRepository)
+		su := NewSecurityEventUsecase(mockRepo)
+		mockRepo.On("GetEventsByTargetUser", uint(1), securityEventActions, securityEventLimit).Return([]model.AuditEvent{}, errors.New("db error"))
+
+		_, err := su.List(1)
+		assert.Error(t, err)
+	})
+}
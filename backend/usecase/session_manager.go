@@ -0,0 +1,111 @@
+package usecase
+
+// ログインセッションの発行・検証・失効を実装
+// jwtのsidに対応するセッションをDBにも保存し、強制ログアウトやアカウントの無効化をすぐに反映できるようにする
+
+import (
+	"backend/auth"
+	"backend/model"
+	"backend/repository"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAccountDisabled = errors.New("account is disabled")
+	ErrSessionRevoked  = errors.New("session is revoked or expired")
+)
+
+const (
+	sessionTTL              = 12 * time.Hour
+	sessionTouchGranularity = 5 * time.Minute // 最終アクセス日時の更新はこの間隔より細かく行わない
+)
+
+type ISessionManager interface {
+	Issue(user *model.User, client model.ClientInfo) (string, error) // セッションを作成し、jwtを返す
+	CheckSession(claims *auth.Claims) error                          // auth.SessionCheckerとしてミドルウェアから呼ばれる
+	Revoke(sessionID string) error                                   // ログアウト
+	RevokeOthers(userID uint, sessionID string) error                // 指定したセッション以外を失効させる
+	RevokeAll(userID uint) error
+}
+
+type sessionManager struct {
+	ur     repository.IUserRepository
+	sr     repository.ISessionRepository
+	secret string // jwtの署名に使う鍵
+	now    func() time.Time
+}
+
+func NewSessionManager(ur repository.IUserRepository, sr repository.ISessionRepository, secret string) ISessionManager {
+	return &sessionManager{ur, sr, secret, time.Now}
+}
+
+// rolesFor はユーザーのロールをjwtのロールに変換する（管理者は一般ユーザーの操作もできる）
+func rolesFor(user *model.User) []string {
+	if user.Role == auth.RoleAdmin {
+		return []string{auth.RoleUser, auth.RoleAdmin}
+	}
+	return []string{auth.RoleUser}
+}
+
+func (sm *sessionManager) Issue(user *model.User, client model.ClientInfo) (string, error) {
+	if user.DisabledAt != nil {
+		return "", ErrAccountDisabled
+	}
+	now := sm.now()
+	session := model.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		IP:         client.IP,
+		UserAgent:  client.UserAgent,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(sessionTTL),
+	}
+	if err := sm.sr.CreateSession(&session); err != nil {
+		return "", err
+	}
+	return auth.Sign(auth.NewAccessClaims(user.ID, session.ID, rolesFor(user), sessionTTL), sm.secret)
+}
+
+func (sm *sessionManager) CheckSession(claims *auth.Claims) error {
+	user, err := sm.ur.GetUserByID(claims.UserID)
+	if err != nil {
+		return err
+	}
+	if user.DisabledAt != nil {
+		return ErrAccountDisabled
+	}
+	// パーソナルアクセストークンはセッションを持たず、ロールも一般ユーザーのまま
+	if claims.TokenType != auth.TokenTypeAccess {
+		return nil
+	}
+
+	now := sm.now()
+	session := model.Session{}
+	if err := sm.sr.GetSession(&session, claims.SessionID); err != nil {
+		return ErrSessionRevoked
+	}
+	if session.UserID != claims.UserID || session.RevokedAt != nil || !now.Before(session.ExpiresAt) {
+		return ErrSessionRevoked
+	}
+	if now.Sub(session.LastSeenAt) >= sessionTouchGranularity {
+		_ = sm.sr.TouchSession(session.ID, now) // 記録に失敗してもリクエストは通す
+	}
+	// ロールの変更をすぐに反映する
+	claims.Roles = rolesFor(user)
+	return nil
+}
+
+func (sm *sessionManager) Revoke(sessionID string) error {
+	return sm.sr.RevokeSession(sessionID)
+}
+
+func (sm *sessionManager) RevokeOthers(userID uint, sessionID string) error {
+	return sm.sr.RevokeUserSessions(userID, sessionID)
+}
+
+func (sm *sessionManager) RevokeAll(userID uint) error {
+	return sm.sr.RevokeUserSessions(userID, "")
+}
@@ -0,0 +1,170 @@
+package usecase
+
+import (
+	"backend/auth"
+	"backend/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type MockSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockSessionRepository) CreateSession(session *model.Session) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) GetSession(session *model.Session, sessionID string) error {
+	args := m.Called(session, sessionID)
+	if found, ok := args.Get(1).(model.Session); ok {
+		*session = found
+	}
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) TouchSession(sessionID string, seenAt time.Time) error {
+	args := m.Called(sessionID, seenAt)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) RevokeSession(sessionID string) error {
+	args := m.Called(sessionID)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) RevokeUserSessions(userID uint, exceptSessionID string) error {
+	args := m.Called(userID, exceptSessionID)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) CountActiveUsers(since time.Time) (int64, error) {
+	args := m.Called(since)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// newTestSessionManager はセッションの保存を常に成功させるセッションマネージャーを返す
+// トークンの署名・メールのリンクに使うテスト用の設定
+const (
+	testSecret = "test-secret"
+	testFEURL  = "https://fe.example.com"
+)
+
+func newTestSessionManager() ISessionManager {
+	sr := new(MockSessionRepository)
+	sr.On("CreateSession", mock.Anything).Return(nil)
+	return NewSessionManager(new(MockUserRepository), sr, testSecret)
+}
+
+func TestSessionIssue(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		sr := new(MockSessionRepository)
+		sm := NewSessionManager(new(MockUserRepository), sr, testSecret)
+		var saved *model.Session
+		sr.On("CreateSession", mock.Anything).Run(func(args mock.Arguments) {
+			saved = args.Get(0).(*model.Session)
+		}).Return(nil).Once()
+
+		token, err := sm.Issue(&model.User{ID: 1, Role: auth.RoleAdmin}, testClient)
+		assert.NoError(t, err)
+		claims, err := auth.Parse(token, testSecret, auth.TokenTypeAccess)
+		assert.NoError(t, err)
+		assert.Equal(t, saved.ID, claims.SessionID)
+		assert.Equal(t, testClient.IP, saved.IP)
+		assert.True(t, claims.HasRole(auth.RoleAdmin))
+	})
+
+	t.Run("disabled account", func(t *testing.T) {
+		sr := new(MockSessionRepository)
+		sm := NewSessionManager(new(MockUserRepository), sr, testSecret)
+		now := time.Now()
+
+		_, err := sm.Issue(&model.User{ID: 1, DisabledAt: &now}, testClient)
+		assert.ErrorIs(t, err, ErrAccountDisabled)
+		sr.AssertNotCalled(t, "CreateSession", mock.Anything)
+	})
+}
+
+func TestCheckSession(t *testing.T) {
+	now := time.Now()
+	active := model.Session{ID: "sid", UserID: 1, LastSeenAt: now, ExpiresAt: now.Add(time.Hour)}
+	revoked := active
+	revoked.RevokedAt = &now
+	expired := active
+	expired.ExpiresAt = now.Add(-time.Minute)
+	otherUser := active
+	otherUser.UserID = 2
+
+	testCases := []struct {
+		name      string
+		user      *model.User
+		session   model.Session
+		getErr    error
+		expectErr error
+	}{
+		{name: "有効なセッション", user: &model.User{ID: 1}, session: active},
+		{name: "失効したセッション", user: &model.User{ID: 1}, session: revoked, expectErr: ErrSessionRevoked},
+		{name: "期限切れのセッション", user: &model.User{ID: 1}, session: expired, expectErr: ErrSessionRevoked},
+		{name: "別のユーザーのセッション", user: &model.User{ID: 1}, session: otherUser, expectErr: ErrSessionRevoked},
+		{name: "存在しないセッション", user: &model.User{ID: 1}, getErr: gorm.ErrRecordNotFound, expectErr: ErrSessionRevoked},
+		{name: "無効化されたアカウント", user: &model.User{ID: 1, DisabledAt: &now}, session: active, expectErr: ErrAccountDisabled},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ur := new(MockUserRepository)
+			sr := new(MockSessionRepository)
+			sm := NewSessionManager(ur, sr, testSecret)
+			ur.On("GetUserByID", uint(1)).Return(tc.user, nil)
+			sr.On("GetSession", mock.Anything, "sid").Return(tc.getErr, tc.session)
+
+			err := sm.CheckSession(auth.NewAccessClaims(1, "sid", []string{auth.RoleUser}, time.Hour))
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, err, tc.expectErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("ロールの変更を反映する", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		sr := new(MockSessionRepository)
+		sm := NewSessionManager(ur, sr, testSecret)
+		ur.On("GetUserByID", uint(1)).Return(&model.User{ID: 1, Role: auth.RoleUser}, nil)
+		sr.On("GetSession", mock.Anything, "sid").Return(nil, active)
+
+		claims := auth.NewAccessClaims(1, "sid", []string{auth.RoleUser, auth.RoleAdmin}, time.Hour)
+		assert.NoError(t, sm.CheckSession(claims))
+		assert.False(t, claims.HasRole(auth.RoleAdmin))
+	})
+
+	t.Run("パーソナルアクセストークンは管理者にならない", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		sm := NewSessionManager(ur, new(MockSessionRepository), testSecret)
+		ur.On("GetUserByID", uint(1)).Return(&model.User{ID: 1, Role: auth.RoleAdmin}, nil)
+
+		claims := &auth.Claims{UserID: 1, TokenType: auth.TokenTypePAT, Roles: []string{auth.RoleUser}}
+		assert.NoError(t, sm.CheckSession(claims))
+		assert.False(t, claims.HasRole(auth.RoleAdmin))
+	})
+
+	t.Run("古いアクセス日時を更新する", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		sr := new(MockSessionRepository)
+		sm := NewSessionManager(ur, sr, testSecret)
+		stale := active
+		stale.LastSeenAt = now.Add(-time.Hour)
+		ur.On("GetUserByID", uint(1)).Return(&model.User{ID: 1}, nil)
+		sr.On("GetSession", mock.Anything, "sid").Return(nil, stale)
+		sr.On("TouchSession", "sid", mock.Anything).Return(nil).Once()
+
+		assert.NoError(t, sm.CheckSession(auth.NewAccessClaims(1, "sid", nil, time.Hour)))
+		sr.AssertExpectations(t)
+	})
+}
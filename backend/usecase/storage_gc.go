@@ -0,0 +1,153 @@
+package usecase
+
+// 保存先の使われていないオブジェクトの削除
+// 料理画像（images/）・ユーザーアイコン（user_icons/）・直接アップロード（uploads/）・tusアップロードの部分（tus/）を一覧し、データベースから参照されていないものを削除する
+// 直接アップロードは期限内で使用していないものを参照されているとみなす（期限切れ・完了しなかったものはここで削除される）
+// tusアップロードの部分は期限内で受信中のものを参照されているとみなす
+// アップロードしてから料理を保存するまでの間のオブジェクトを消さないよう、猶予期間より新しいものは対象にしない
+// 参照の一覧は保存先の一覧の後に取得する（一覧中に保存された料理の画像を削除しないため）
+// 期限切れで使用・完了しなかったアップロードの、作成時に使用量に加えた分もここで減らす
+// 料理に署名付きURLのまま残っている画像（キーへの移行前の形式）は、URLから取り出したキーを参照されているとみなす
+
+import (
+	"backend/repository"
+	"backend/storage"
+	"context"
+	"strings"
+	"time"
+)
+
+// DefaultStorageGCGracePeriod は削除の対象にしない期間の既定値
+const DefaultStorageGCGracePeriod = 24 * time.Hour
+
+// storageGCPrefixes は削除の対象にするキーの接頭辞
+var storageGCPrefixes = []string{cuisineImageKeyPrefix, iconKeyPrefix, uploadKeyPrefix, tusKeyPrefix}
+
+type StorageGCOptions struct {
+	DryRun      bool          // 削除せずに対象を報告する
+	GracePeriod time.Duration // 更新からこの期間が経っていないオブジェクトは削除しない
+}
+
+// StorageGCReport は実行結果
+type StorageGCReport struct {
+	DryRun        bool                 `json:"dry_run"`
+	Scanned       int                  `json:"scanned"`    // 一覧したオブジェクトの数
+	Referenced    int                  `json:"referenced"` // 参照されているオブジェクトの数
+	Recent        int                  `json:"recent"`     // 参照されていないが猶予期間内のオブジェクトの数
+	Orphans       []storage.ObjectInfo `json:"orphans"`    // 削除の対象
+	OrphanBytes   int64                `json:"orphan_bytes"`
+	Deleted       int                  `json:"deleted"`
+	Failed        []string             `json:"failed"`         // 削除に失敗したキー
+	ReleasedBytes int64                `json:"released_bytes"` // 期限切れのアップロードについて使用量から減らした大きさ
+}
+
+type IStorageGCUsecase interface {
+	Run(ctx context.Context, opts StorageGCOptions) (StorageGCReport, error)
+}
+
+type storageGCUsecase struct {
+	st     storage.ObjectStore
+	rr     repository.IStorageReferenceRepository
+	su     IStorageUsageUsecase
+	bucket string // 署名付きURLからキーを取り出すためのバケット
+}
+
+func NewStorageGCUsecase(st storage.ObjectStore, rr repository.IStorageReferenceRepository, su IStorageUsageUsecase, bucket string) IStorageGCUsecase {
+	return &storageGCUsecase{st, rr, su, bucket}
+}
+
+func (gu *storageGCUsecase) Run(ctx context.Context, opts StorageGCOptions) (StorageGCReport, error) {
+	report := StorageGCReport{DryRun: opts.DryRun, Orphans: []storage.ObjectInfo{}, Failed: []string{}}
+
+	objects := []storage.ObjectInfo{}
+	for _, prefix := range storageGCPrefixes {
+		infos, err := gu.st.List(ctx, prefix)
+		if err != nil {
+			return report, err
+		}
+		objects = append(objects, infos...)
+	}
+	report.Scanned = len(objects)
+
+	referenced, err := gu.referencedKeys()
+	if err != nil {
+		return report, err
+	}
+
+	threshold := time.Now().Add(-opts.GracePeriod)
+	for _, obj := range objects {
+		switch {
+		case referenced[obj.Key]:
+			report.Referenced++
+		case obj.UpdatedAt.After(threshold):
+			report.Recent++
+		default:
+			report.Orphans = append(report.Orphans, obj)
+			report.OrphanBytes += obj.Size
+		}
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+
+	for _, obj := range report.Orphans {
+		if err := gu.st.Delete(ctx, obj.Key); err != nil {
+			report.Failed = append(report.Failed, obj.Key)
+			continue
+		}
+		report.Deleted++
+	}
+
+	reservations, err := gu.rr.TakeExpiredReservations(time.Now())
+	if err != nil {
+		return report, err
+	}
+	for _, r := range reservations {
+		gu.su.Release(r.UserID, r.Purpose, r.Bytes)
+		report.ReleasedBytes += r.Bytes
+	}
+	return report, nil
+}
+
+// referencedKeys はデータベースから参照されているキーを返す（料理画像はすべてのサイズ）
+func (gu *storageGCUsecase) referencedKeys() (map[string]bool, error) {
+	referenced := map[string]bool{}
+	cuisineKeys, err := gu.rr.CuisineImageKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range cuisineKeys {
+		if strings.Contains(key, "://") {
+			k, ok := storage.KeyFromURL(key, gu.bucket)
+			if !ok {
+				continue
+			}
+			key = k
+		}
+		for _, k := range imageVariantKeys(key) {
+			referenced[k] = true
+		}
+	}
+	iconKeys, err := gu.rr.UserIconKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range iconKeys {
+		referenced[key] = true
+	}
+	uploadKeys, err := gu.rr.PendingUploadKeys(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range uploadKeys {
+		referenced[key] = true
+	}
+	chunkKeys, err := gu.rr.PendingTusChunkKeys(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range chunkKeys {
+		referenced[key] = true
+	}
+	return referenced, nil
+}
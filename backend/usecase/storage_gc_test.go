@@ -0,0 +1,142 @@
+package usecase
+
+import (
+	"backend/model"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockStorageReferenceRepository struct {
+	mock.Mock
+}
+
+func (m *MockStorageReferenceRepository) CuisineImageKeys() ([]string, error) {
+	args := m.Called()
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockStorageReferenceRepository) UserIconKeys() ([]string, error) {
+	args := m.Called()
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockStorageReferenceRepository) PendingUploadKeys(now time.Time) ([]string, error) {
+	args := m.Called(now)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockStorageReferenceRepository) PendingTusChunkKeys(now time.Time) ([]string, error) {
+	args := m.Called(now)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockStorageReferenceRepository) TakeExpiredReservations(now time.Time) ([]model.UploadReservation, error) {
+	args := m.Called(now)
+	return args.Get(0).([]model.UploadReservation), args.Error(1)
+}
+
+func TestStorageGC(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T) (*MockStorageReferenceRepository, IStorageGCUsecase, func() []string, IStorageUsageUsecase) {
+		st := newTestObjectStore()
+		for _, key := range []string{
+			"images/1/abc/full.jpg", "images/1/abc/medium.jpg", "images/1/abc/thumbnail.jpg", // 参照されている料理画像
+			"images/1/old.jpg",                                                               // 参照されている以前の料理画像
+			"images/1/signed.jpg",                                                            // 署名付きURLのまま参照されている料理画像
+			"images/1/def/full.jpg", "images/1/def/medium.jpg", "images/1/def/thumbnail.jpg", // 削除に失敗した料理の画像
+			"user_icons/1/a.png", // 参照されているアイコン
+			"user_icons/1/b.png", // 置き換えたアイコン
+			"uploads/1/p.png",    // 期限内の直接アップロード
+			"uploads/1/e.png",    // 期限切れの直接アップロード
+			"tus/1/t/0-a",        // 受信中のtusアップロードの部分
+			"tus/1/s/0-a",        // 期限切れのtusアップロードの部分
+			"other/1/x.jpg",      // 対象外の接頭辞
+		} {
+			require.NoError(t, st.Put(ctx, key, "image/jpeg", strings.NewReader("data")))
+		}
+		rr := new(MockStorageReferenceRepository)
+		rr.On("CuisineImageKeys").Return([]string{"images/1/abc/full.jpg", "images/1/old.jpg", "https://example.com/legacy.jpg",
+			"https://storage.googleapis.com/cookmeet/images/1/signed.jpg?X-Goog-Signature=abc"}, nil)
+		rr.On("UserIconKeys").Return([]string{"user_icons/1/a.png", "icons/legacy.png"}, nil)
+		rr.On("PendingUploadKeys", mock.AnythingOfType("time.Time")).Return([]string{"uploads/1/p.png"}, nil)
+		rr.On("PendingTusChunkKeys", mock.AnythingOfType("time.Time")).Return([]string{"tus/1/t/0-a"}, nil)
+		// 期限切れの直接アップロードとtusのアップロードで使用量に加えた分
+		rr.On("TakeExpiredReservations", mock.AnythingOfType("time.Time")).Return([]model.UploadReservation{
+			{UserID: 1, Purpose: model.UploadPurposeCuisineImage, Bytes: 4},
+			{UserID: 1, Purpose: model.UploadPurposeUserIcon, Bytes: 3},
+		}, nil)
+		su := newTestStorageUsage()
+		require.NoError(t, su.Reserve(1, model.UploadPurposeCuisineImage, 10))
+		require.NoError(t, su.Reserve(1, model.UploadPurposeUserIcon, 3))
+		return rr, NewStorageGCUsecase(st, rr, su, "cookmeet"), func() []string { return storedKeys(t, st) }, su
+	}
+	orphanKeys := func(report StorageGCReport) []string {
+		keys := []string{}
+		for _, o := range report.Orphans {
+			keys = append(keys, o.Key)
+		}
+		return keys
+	}
+	expected := []string{"images/1/def/full.jpg", "images/1/def/medium.jpg", "images/1/def/thumbnail.jpg", "user_icons/1/b.png", "uploads/1/e.png", "tus/1/s/0-a"}
+
+	t.Run("dry-runでは削除せずに報告する", func(t *testing.T) {
+		rr, gc, keys, _ := setup(t)
+		report, err := gc.Run(ctx, StorageGCOptions{DryRun: true})
+		require.NoError(t, err)
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 14, report.Scanned)
+		assert.Equal(t, 8, report.Referenced)
+		assert.Equal(t, expected, orphanKeys(report))
+		assert.Equal(t, int64(24), report.OrphanBytes)
+		assert.Zero(t, report.Deleted)
+		assert.Zero(t, report.ReleasedBytes)
+		assert.Len(t, keys(), 15)
+		rr.AssertNotCalled(t, "TakeExpiredReservations", mock.Anything)
+	})
+
+	t.Run("参照されていないオブジェクトを削除する", func(t *testing.T) {
+		_, gc, keys, su := setup(t)
+		report, err := gc.Run(ctx, StorageGCOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 6, report.Deleted)
+		assert.Empty(t, report.Failed)
+		assert.Equal(t, []string{
+			"images/1/abc/full.jpg", "images/1/abc/medium.jpg", "images/1/abc/thumbnail.jpg",
+			"images/1/old.jpg", "images/1/signed.jpg", "other/1/x.jpg", "tus/1/t/0-a", "uploads/1/p.png", "user_icons/1/a.png",
+		}, keys())
+
+		// 期限切れのアップロードで使用量に加えた分を減らす
+		assert.Equal(t, int64(7), report.ReleasedBytes)
+		usage, err := su.GetUsage(1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), usage.CuisineImageBytes)
+		assert.Equal(t, int64(0), usage.UserIconBytes)
+	})
+
+	t.Run("猶予期間内のオブジェクトは削除しない", func(t *testing.T) {
+		_, gc, keys, _ := setup(t)
+		report, err := gc.Run(ctx, StorageGCOptions{GracePeriod: time.Hour})
+		require.NoError(t, err)
+		assert.Equal(t, 6, report.Recent)
+		assert.Empty(t, report.Orphans)
+		assert.Len(t, keys(), 15)
+	})
+
+	t.Run("参照を取得できなければ削除しない", func(t *testing.T) {
+		st := newTestObjectStore()
+		require.NoError(t, st.Put(ctx, "images/1/a.jpg", "image/jpeg", strings.NewReader("data")))
+		rr := new(MockStorageReferenceRepository)
+		rr.On("CuisineImageKeys").Return([]string{}, errors.New("db error"))
+
+		_, err := NewStorageGCUsecase(st, rr, newTestStorageUsage(), "cookmeet").Run(ctx, StorageGCOptions{})
+		assert.Error(t, err)
+		assert.Len(t, storedKeys(t, st), 1)
+	})
+}
@@ -0,0 +1,96 @@
+package usecase
+
+// ユーザーごとの保存先の使用量と上限（料理画像のすべてのサイズ・アイコン）
+// 保存する前に大きさを使用量に加え（上限を超える場合はErrStorageQuotaExceeded）、削除した分は減らす
+// 直接アップロード・tusは作成時に申告された大きさを加え、使用・中止・期限切れで減らす（変換した画像は保存時に改めて加える）
+// 失敗などで実際の大きさとずれた場合はrecompute-storage-usage（storage_usage_recompute.go）で計算し直す
+
+import (
+	"backend/model"
+	"backend/repository"
+	"errors"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+var ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+
+type IStorageUsageUsecase interface {
+	GetUsage(userID uint) (model.StorageUsageResponse, error)
+	// Reserve は保存する前に用途kind（model.UploadPurpose*）の使用量にbytesを加える
+	Reserve(userID uint, kind string, bytes int64) error
+	// Release は削除した・保存できなかった分を使用量から減らす（失敗してもログに出力するのみ）
+	Release(userID uint, kind string, bytes int64)
+}
+
+type storageUsageUsecase struct {
+	sr    repository.IStorageUsageRepository
+	quota int64 // 0は無制限
+}
+
+func NewStorageUsageUsecase(sr repository.IStorageUsageRepository, quota int64) IStorageUsageUsecase {
+	return &storageUsageUsecase{sr, quota}
+}
+
+func (su *storageUsageUsecase) GetUsage(userID uint) (model.StorageUsageResponse, error) {
+	usage, err := su.usage(userID)
+	if err != nil {
+		return model.StorageUsageResponse{}, err
+	}
+	res := model.StorageUsageResponse{
+		UsedBytes:         usage.TotalBytes(),
+		CuisineImageBytes: usage.CuisineImageBytes,
+		UserIconBytes:     usage.UserIconBytes,
+		QuotaBytes:        su.quota,
+	}
+	if su.quota > 0 {
+		remaining := su.quota - usage.TotalBytes()
+		if remaining < 0 {
+			remaining = 0 // 上限を下げた場合
+		}
+		res.RemainingBytes = &remaining
+	}
+	return res, nil
+}
+
+func (su *storageUsageUsecase) Reserve(userID uint, kind string, bytes int64) error {
+	if bytes <= 0 {
+		return nil
+	}
+	err := su.sr.AddStorageUsage(userID, kind, bytes, su.quota)
+	if errors.Is(err, repository.ErrQuotaExceeded) {
+		usage, err := su.usage(userID)
+		if err != nil {
+			return err
+		}
+		return su.quotaError(usage.TotalBytes())
+	}
+	return err
+}
+
+func (su *storageUsageUsecase) Release(userID uint, kind string, bytes int64) {
+	if bytes <= 0 {
+		return
+	}
+	if err := su.sr.AddStorageUsage(userID, kind, -bytes, 0); err != nil {
+		log.Printf("failed to release storage usage of user %d (%s, %d bytes): %v", userID, kind, bytes, err)
+	}
+}
+
+// usage は使用量を返す（記録がない場合は0）
+func (su *storageUsageUsecase) usage(userID uint) (model.StorageUsage, error) {
+	usage := model.StorageUsage{}
+	if err := su.sr.GetStorageUsage(&usage, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.StorageUsage{UserID: userID}, nil
+		}
+		return model.StorageUsage{}, err
+	}
+	return usage, nil
+}
+
+func (su *storageUsageUsecase) quotaError(used int64) error {
+	return fmt.Errorf("%w: %d of %d bytes used", ErrStorageQuotaExceeded, used, su.quota)
+}
@@ -0,0 +1,139 @@
+package usecase
+
+// 保存先の使用量の計算し直し
+// データベースから参照されている料理画像（すべてのサイズ）・アイコンの大きさを保存先から取得して所有者ごとに合計し、記録と異なるものを置き換える
+// 参照されていないオブジェクト（使われていないオブジェクトの削除で消える）は数えない
+// 作成時に使用量に加えたまま使用・期限切れで減らしていないアップロードの分は加える
+// 実行中に保存・削除された分はずれる場合がある（もう一度実行すると直る）
+
+import (
+	"backend/model"
+	"backend/repository"
+	"backend/storage"
+	"context"
+	"sort"
+	"strings"
+)
+
+type StorageUsageRecomputeOptions struct {
+	DryRun bool // 置き換えずにずれを報告する
+}
+
+// StorageUsageDrift は記録されていた使用量と計算し直した使用量
+type StorageUsageDrift struct {
+	UserID   uint  `json:"user_id"`
+	Recorded int64 `json:"recorded"`
+	Actual   int64 `json:"actual"`
+}
+
+// StorageUsageRecomputeReport は実行結果
+type StorageUsageRecomputeReport struct {
+	DryRun  bool                `json:"dry_run"`
+	Users   int                 `json:"users"`   // 計算したユーザーの数
+	Drifted []StorageUsageDrift `json:"drifted"` // 記録と異なったユーザー（dry-runでは置き換えない）
+}
+
+type IStorageUsageRecomputeUsecase interface {
+	Run(ctx context.Context, opts StorageUsageRecomputeOptions) (StorageUsageRecomputeReport, error)
+}
+
+type storageUsageRecomputeUsecase struct {
+	sr repository.IStorageUsageRepository
+	st storage.ObjectStore
+}
+
+func NewStorageUsageRecomputeUsecase(sr repository.IStorageUsageRepository, st storage.ObjectStore) IStorageUsageRecomputeUsecase {
+	return &storageUsageRecomputeUsecase{sr, st}
+}
+
+func (ru *storageUsageRecomputeUsecase) Run(ctx context.Context, opts StorageUsageRecomputeOptions) (StorageUsageRecomputeReport, error) {
+	report := StorageUsageRecomputeReport{DryRun: opts.DryRun, Drifted: []StorageUsageDrift{}}
+
+	sizes := map[string]int64{}
+	for _, prefix := range []string{cuisineImageKeyPrefix, iconKeyPrefix} {
+		objects, err := ru.st.List(ctx, prefix)
+		if err != nil {
+			return report, err
+		}
+		for _, o := range objects {
+			sizes[o.Key] = o.Size
+		}
+	}
+
+	actual := map[uint]*model.StorageUsage{}
+	usageOf := func(userID uint) *model.StorageUsage {
+		if actual[userID] == nil {
+			actual[userID] = &model.StorageUsage{UserID: userID}
+		}
+		return actual[userID]
+	}
+	images, err := ru.sr.CuisineImageOwners()
+	if err != nil {
+		return report, err
+	}
+	for _, o := range images {
+		key, ok := imageKey(&o.Key)
+		if !ok {
+			continue
+		}
+		counted := map[string]bool{}
+		for _, k := range imageVariantKeys(key) {
+			if !counted[k] { // 変換していない以前の画像はすべてのサイズで同じキー
+				counted[k] = true
+				usageOf(o.UserID).CuisineImageBytes += sizes[k]
+			}
+		}
+	}
+	icons, err := ru.sr.UserIconOwners()
+	if err != nil {
+		return report, err
+	}
+	for _, o := range icons {
+		if strings.HasPrefix(o.Key, iconKeyPrefix) {
+			usageOf(o.UserID).UserIconBytes += sizes[o.Key]
+		}
+	}
+	reservations, err := ru.sr.UploadReservations()
+	if err != nil {
+		return report, err
+	}
+	for _, r := range reservations {
+		switch r.Purpose {
+		case model.UploadPurposeCuisineImage:
+			usageOf(r.UserID).CuisineImageBytes += r.Bytes
+		case model.UploadPurposeUserIcon:
+			usageOf(r.UserID).UserIconBytes += r.Bytes
+		}
+	}
+
+	recorded, err := ru.sr.ListStorageUsages()
+	if err != nil {
+		return report, err
+	}
+	recordedByUser := map[uint]model.StorageUsage{}
+	for _, u := range recorded {
+		recordedByUser[u.UserID] = u
+		usageOf(u.UserID) // 参照されているオブジェクトがなくなったユーザーは0にする
+	}
+
+	userIDs := make([]uint, 0, len(actual))
+	for id := range actual {
+		userIDs = append(userIDs, id)
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+	for _, id := range userIDs {
+		report.Users++
+		usage, before := actual[id], recordedByUser[id]
+		if usage.CuisineImageBytes == before.CuisineImageBytes && usage.UserIconBytes == before.UserIconBytes {
+			continue
+		}
+		report.Drifted = append(report.Drifted, StorageUsageDrift{UserID: id, Recorded: before.TotalBytes(), Actual: usage.TotalBytes()})
+		if opts.DryRun {
+			continue
+		}
+		if err := ru.sr.SetStorageUsage(usage); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
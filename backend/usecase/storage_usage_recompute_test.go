@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"backend/model"
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageUsageRecompute(t *testing.T) {
+	ctx := context.Background()
+	st := newTestObjectStore()
+	put := func(key string, size int) {
+		require.NoError(t, st.Put(ctx, key, "image/jpeg", bytes.NewReader(make([]byte, size))))
+	}
+	put("images/1/abc/thumbnail.jpg", 10)
+	put("images/1/abc/medium.jpg", 20)
+	put("images/1/abc/full.jpg", 30)
+	put("images/1/old.jpg", 40)         // 変換していない以前の画像は1回だけ数える
+	put("images/1/orphan/full.jpg", 50) // 参照されていないオブジェクトは数えない
+	put("user_icons/2/a.png", 5)
+	legacyURL := "https://example.com/curry.jpg"
+
+	setup := func() *memoryStorageUsageRepository {
+		sr := newMemoryStorageUsageRepository()
+		sr.images = []model.StoredObjectOwner{{UserID: 1, Key: "images/1/abc/full.jpg"}, {UserID: 1, Key: "images/1/old.jpg"}, {UserID: 1, Key: legacyURL}}
+		sr.icons = []model.StoredObjectOwner{{UserID: 2, Key: "user_icons/2/a.png"}, {UserID: 1, Key: "icons/legacy.png"}}
+		sr.usages[1] = model.StorageUsage{UserID: 1, CuisineImageBytes: 80}
+		sr.usages[2] = model.StorageUsage{UserID: 2, UserIconBytes: 5}
+		sr.usages[3] = model.StorageUsage{UserID: 3, CuisineImageBytes: 7} // 参照されているオブジェクトがなくなった
+		return sr
+	}
+
+	t.Run("記録と異なる使用量を置き換える", func(t *testing.T) {
+		sr := setup()
+		report, err := NewStorageUsageRecomputeUsecase(sr, st).Run(ctx, StorageUsageRecomputeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 3, report.Users)
+		assert.Equal(t, []StorageUsageDrift{{UserID: 1, Recorded: 80, Actual: 100}, {UserID: 3, Recorded: 7, Actual: 0}}, report.Drifted)
+		assert.Equal(t, int64(100), sr.usages[1].CuisineImageBytes)
+		assert.Equal(t, int64(0), sr.usages[3].TotalBytes())
+	})
+
+	t.Run("使用量に加えたままのアップロードの分を含める", func(t *testing.T) {
+		sr := setup()
+		sr.reservations = []model.UploadReservation{
+			{UserID: 1, Purpose: model.UploadPurposeCuisineImage, Bytes: 20},
+			{UserID: 2, Purpose: model.UploadPurposeUserIcon, Bytes: 3},
+		}
+		report, err := NewStorageUsageRecomputeUsecase(sr, st).Run(ctx, StorageUsageRecomputeOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []StorageUsageDrift{{UserID: 1, Recorded: 80, Actual: 120}, {UserID: 2, Recorded: 5, Actual: 8}, {UserID: 3, Recorded: 7, Actual: 0}}, report.Drifted)
+		assert.Equal(t, int64(120), sr.usages[1].CuisineImageBytes)
+		assert.Equal(t, int64(8), sr.usages[2].UserIconBytes)
+	})
+
+	t.Run("dry-runでは置き換えない", func(t *testing.T) {
+		sr := setup()
+		sr.usages[2] = model.StorageUsage{UserID: 2, UserIconBytes: 9}
+		report, err := NewStorageUsageRecomputeUsecase(sr, st).Run(ctx, StorageUsageRecomputeOptions{DryRun: true})
+		require.NoError(t, err)
+		assert.True(t, report.DryRun)
+		assert.Len(t, report.Drifted, 3)
+		assert.Equal(t, StorageUsageDrift{UserID: 2, Recorded: 9, Actual: 5}, report.Drifted[1])
+		assert.Equal(t, int64(80), sr.usages[1].CuisineImageBytes)
+		assert.Equal(t, int64(9), sr.usages[2].UserIconBytes)
+	})
+}
@@ -0,0 +1,123 @@
+package usecase
+
+import (
+	"backend/model"
+	"backend/repository"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryStorageUsageRepository は上限の確認を含めてデータベースと同じように振る舞う使用量の記録
+type memoryStorageUsageRepository struct {
+	mu           sync.Mutex
+	usages       map[uint]model.StorageUsage
+	images       []model.StoredObjectOwner
+	icons        []model.StoredObjectOwner
+	reservations []model.UploadReservation
+}
+
+func newMemoryStorageUsageRepository() *memoryStorageUsageRepository {
+	return &memoryStorageUsageRepository{usages: map[uint]model.StorageUsage{}}
+}
+
+func (m *memoryStorageUsageRepository) GetStorageUsage(usage *model.StorageUsage, userID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.usages[userID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	*usage = u
+	return nil
+}
+
+func (m *memoryStorageUsageRepository) ListStorageUsages() ([]model.StorageUsage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usages := []model.StorageUsage{}
+	for _, u := range m.usages {
+		usages = append(usages, u)
+	}
+	return usages, nil
+}
+
+func (m *memoryStorageUsageRepository) AddStorageUsage(userID uint, kind string, delta int64, limit int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u := m.usages[userID]
+	u.UserID = userID
+	if limit > 0 && delta > 0 && u.TotalBytes()+delta > limit {
+		return repository.ErrQuotaExceeded
+	}
+	switch kind {
+	case model.UploadPurposeCuisineImage:
+		u.CuisineImageBytes = max(u.CuisineImageBytes+delta, 0)
+	case model.UploadPurposeUserIcon:
+		u.UserIconBytes = max(u.UserIconBytes+delta, 0)
+	default:
+		return fmt.Errorf("unknown storage usage kind: %s", kind)
+	}
+	m.usages[userID] = u
+	return nil
+}
+
+func (m *memoryStorageUsageRepository) SetStorageUsage(usage *model.StorageUsage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usages[usage.UserID] = *usage
+	return nil
+}
+
+func (m *memoryStorageUsageRepository) CuisineImageOwners() ([]model.StoredObjectOwner, error) {
+	return m.images, nil
+}
+
+func (m *memoryStorageUsageRepository) UserIconOwners() ([]model.StoredObjectOwner, error) {
+	return m.icons, nil
+}
+
+func (m *memoryStorageUsageRepository) UploadReservations() ([]model.UploadReservation, error) {
+	return m.reservations, nil
+}
+
+// newTestStorageUsage は上限のない使用量を返す
+func newTestStorageUsage() IStorageUsageUsecase {
+	return NewStorageUsageUsecase(newMemoryStorageUsageRepository(), 0)
+}
+
+func TestStorageUsage(t *testing.T) {
+	t.Run("上限を超えない場合のみ加える", func(t *testing.T) {
+		su := NewStorageUsageUsecase(newMemoryStorageUsageRepository(), 100)
+		require.NoError(t, su.Reserve(1, model.UploadPurposeCuisineImage, 70))
+		require.NoError(t, su.Reserve(1, model.UploadPurposeUserIcon, 30))
+
+		err := su.Reserve(1, model.UploadPurposeCuisineImage, 1)
+		assert.ErrorIs(t, err, ErrStorageQuotaExceeded)
+		assert.Contains(t, err.Error(), "100 of 100 bytes used")
+		assert.NoError(t, su.Reserve(2, model.UploadPurposeCuisineImage, 1), "他のユーザーには影響しない")
+
+		su.Release(1, model.UploadPurposeUserIcon, 30)
+		res, err := su.GetUsage(1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(70), res.UsedBytes)
+		assert.Equal(t, int64(70), res.CuisineImageBytes)
+		assert.Equal(t, int64(0), res.UserIconBytes)
+		assert.Equal(t, int64(100), res.QuotaBytes)
+		require.NotNil(t, res.RemainingBytes)
+		assert.Equal(t, int64(30), *res.RemainingBytes)
+	})
+
+	t.Run("0は無制限", func(t *testing.T) {
+		su := NewStorageUsageUsecase(newMemoryStorageUsageRepository(), 0)
+		assert.NoError(t, su.Reserve(1, model.UploadPurposeCuisineImage, 1<<40))
+		res, err := su.GetUsage(1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1<<40), res.UsedBytes)
+		assert.Nil(t, res.RemainingBytes)
+	})
+}
@@ -0,0 +1,304 @@
+package usecase
+
+// tusプロトコル（1.0.0）による再開可能なアップロード
+// 作成（creation）・オフセットの確認（HEAD）・部分の追加（PATCH）・期限（expiration）・チェックサム（checksum）・削除（termination）に対応する
+// 部分ごとに tus/<ユーザーID>/<ID>/<開始位置>-<ランダムな値> に保存し、キーを順にデータベースへ記録する
+// 通信が途切れた場合も受け取った分は保存し（チェックサムの指定がない場合）、クライアントはHEADで確認したオフセットから再開できる
+// すべて受け取ると uploads/<ユーザーID>/<ID>.<拡張子> にまとめ、完了済みのアップロード（upload.go）として料理の追加・アイコンの変更で使える
+// 作成時にUpload-Lengthを使用量に加え、完了したアップロードに引き継ぐ（中止した場合は減らし、期限切れの場合は使われていないオブジェクトの削除で減らす）
+
+import (
+	"backend/model"
+	"backend/repository"
+	"backend/storage"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTusOffsetMismatch      = errors.New("Upload-Offset does not match the current offset")
+	ErrTusChecksumMismatch    = errors.New("checksum mismatch")
+	ErrTusUnsupportedChecksum = errors.New("unsupported checksum algorithm")
+	ErrTusExceedsLength       = errors.New("data exceeds Upload-Length")
+)
+
+const (
+	tusKeyPrefix = "tus/"
+	tusUploadTTL = 24 * time.Hour // 通信状況が悪くても再開できるよう、直接アップロードより長くする
+)
+
+// tusChecksums は対応するチェックサムのアルゴリズム
+var tusChecksums = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// TusChecksumAlgorithms は対応するチェックサムのアルゴリズムを返す（Tus-Checksum-Algorithm）
+func TusChecksumAlgorithms() []string {
+	algorithms := make([]string, 0, len(tusChecksums))
+	for name := range tusChecksums {
+		algorithms = append(algorithms, name)
+	}
+	sort.Strings(algorithms)
+	return algorithms
+}
+
+type ITusUsecase interface {
+	MaxSize() int64 // Tus-Max-Size
+	Create(userID uint, req model.TusCreateRequest) (model.TusUpload, error)
+	Get(userID uint, uploadID string) (model.TusUpload, error)
+	// WriteChunk はoffsetから部分を追加する（checksumはUpload-Checksumの値、指定がなければ空）
+	WriteChunk(userID uint, uploadID string, offset int64, checksum string, r io.Reader) (model.TusUpload, error)
+	Terminate(userID uint, uploadID string) error
+}
+
+type tusUsecase struct {
+	tr       repository.ITusUploadRepository
+	st       storage.ObjectStore
+	maxSizes map[string]int64
+	su       IStorageUsageUsecase
+	now      func() time.Time
+}
+
+// NewTusUsecase は料理画像の大きさの上限にmaxImageBytes（imageproc.Limits.MaxBytes）を使う
+func NewTusUsecase(tr repository.ITusUploadRepository, st storage.ObjectStore, maxImageBytes int64, su IStorageUsageUsecase) ITusUsecase {
+	return &tusUsecase{tr, st, uploadMaxSizes(maxImageBytes), su, time.Now}
+}
+
+func (tu *tusUsecase) MaxSize() int64 {
+	var maxSize int64
+	for _, size := range tu.maxSizes {
+		maxSize = max(maxSize, size)
+	}
+	return maxSize
+}
+
+func (tu *tusUsecase) Create(userID uint, req model.TusCreateRequest) (model.TusUpload, error) {
+	maxSize, ok := tu.maxSizes[req.Purpose]
+	if !ok {
+		return model.TusUpload{}, ErrInvalidUploadTarget
+	}
+	if req.Length <= 0 || req.Length > maxSize {
+		return model.TusUpload{}, fmt.Errorf("%w: must be at most %d bytes", ErrImageTooLarge, maxSize)
+	}
+	if err := tu.su.Reserve(userID, req.Purpose, req.Length); err != nil {
+		return model.TusUpload{}, err
+	}
+	upload := model.TusUpload{
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		Purpose:       req.Purpose,
+		Filename:      req.Filename,
+		Length:        req.Length,
+		ReservedBytes: req.Length,
+		ExpiresAt:     tu.now().Add(tusUploadTTL),
+	}
+	if err := tu.tr.CreateTusUpload(&upload); err != nil {
+		tu.su.Release(userID, req.Purpose, req.Length)
+		return model.TusUpload{}, err
+	}
+	return upload, nil
+}
+
+func (tu *tusUsecase) Get(userID uint, uploadID string) (model.TusUpload, error) {
+	upload := model.TusUpload{}
+	if err := tu.tr.GetTusUploadByID(&upload, userID, uploadID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.TusUpload{}, ErrUploadNotFound
+		}
+		return model.TusUpload{}, err
+	}
+	if upload.CompletedAt == nil && !tu.now().Before(upload.ExpiresAt) {
+		return model.TusUpload{}, ErrUploadExpired
+	}
+	return upload, nil
+}
+
+func (tu *tusUsecase) WriteChunk(userID uint, uploadID string, offset int64, checksum string, r io.Reader) (model.TusUpload, error) {
+	upload, err := tu.Get(userID, uploadID)
+	if err != nil {
+		return model.TusUpload{}, err
+	}
+	if offset != upload.Offset || upload.CompletedAt != nil {
+		return model.TusUpload{}, ErrTusOffsetMismatch
+	}
+	var h hash.Hash
+	var expected []byte
+	if checksum != "" {
+		algorithm, encoded, _ := strings.Cut(checksum, " ")
+		newHash, ok := tusChecksums[algorithm]
+		if !ok {
+			return model.TusUpload{}, ErrTusUnsupportedChecksum
+		}
+		if expected, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return model.TusUpload{}, ErrTusUnsupportedChecksum
+		}
+		h = newHash()
+	}
+
+	remaining := upload.Length - upload.Offset
+	data, readErr := io.ReadAll(io.LimitReader(r, remaining+1))
+	if int64(len(data)) > remaining {
+		return model.TusUpload{}, ErrTusExceedsLength
+	}
+	if readErr != nil {
+		// 途切れた場合は受け取った分を保存する（チェックサムを確認できない場合は破棄する）
+		if h != nil || len(data) == 0 {
+			return model.TusUpload{}, readErr
+		}
+		log.Printf("tus upload %s interrupted at %d bytes: %v", upload.ID, offset+int64(len(data)), readErr)
+	}
+	if h != nil {
+		h.Write(data)
+		if !bytes.Equal(h.Sum(nil), expected) {
+			return model.TusUpload{}, ErrTusChecksumMismatch
+		}
+	}
+
+	if len(data) > 0 {
+		if err := tu.appendChunk(&upload, data); err != nil {
+			return model.TusUpload{}, err
+		}
+	}
+	// すべて受け取った場合はまとめる（まとめるのに失敗した場合は、空のPATCHでやり直せる）
+	if upload.Offset == upload.Length {
+		if err := tu.finish(&upload); err != nil {
+			return model.TusUpload{}, err
+		}
+	}
+	return upload, nil
+}
+
+func (tu *tusUsecase) appendChunk(upload *model.TusUpload, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	key := fmt.Sprintf("%s%d/%s/%020d-%s", tusKeyPrefix, upload.UserID, upload.ID, upload.Offset, uuid.New().String()[:8])
+	if err := tu.st.Put(ctx, key, "application/octet-stream", bytes.NewReader(data)); err != nil {
+		return err
+	}
+	newOffset := upload.Offset + int64(len(data))
+	if err := tu.tr.AppendTusChunk(upload.ID, upload.Offset, newOffset, key); err != nil {
+		tu.deleteObjects([]string{key})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTusOffsetMismatch // 同じオフセットへの同時の書き込み
+		}
+		return err
+	}
+	upload.Offset = newOffset
+	upload.ChunkKeys += key + "\n"
+	return nil
+}
+
+// finish は部分をまとめて種類を確認し、完了済みのアップロードを作成する
+// 画像でない場合はアップロードを削除する
+func (tu *tusUsecase) finish(upload *model.TusUpload) error {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	chunkKeys := strings.Fields(upload.ChunkKeys)
+	data := make([]byte, 0, upload.Length)
+	for _, key := range chunkKeys {
+		r, _, err := tu.st.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		data, err = appendAll(data, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if int64(len(data)) != upload.Length {
+		return fmt.Errorf("tus upload %s has %d bytes, expected %d", upload.ID, len(data), upload.Length)
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		tu.deleteObjects(chunkKeys)
+		if err := tu.tr.DeleteTusUpload(upload.ID); err != nil {
+			// 使用量に加えた分は期限切れ後に使われていないオブジェクトの削除で減らす
+			log.Printf("failed to delete tus upload %s: %v", upload.ID, err)
+		} else {
+			tu.su.Release(upload.UserID, upload.Purpose, upload.ReservedBytes)
+		}
+		return ErrInvalidImage
+	}
+
+	now := tu.now()
+	completed := model.Upload{
+		ID:            upload.ID,
+		UserID:        upload.UserID,
+		Purpose:       upload.Purpose,
+		Key:           fmt.Sprintf("%s%d/%s%s", uploadKeyPrefix, upload.UserID, upload.ID, ext),
+		ContentType:   contentType,
+		Size:          upload.Length,
+		ReservedBytes: upload.ReservedBytes, // 使用量に加えた分は使用時（保存する前）に減らす
+		ExpiresAt:     now.Add(uploadTTL),
+		CompletedAt:   &now,
+	}
+	if err := tu.st.Put(ctx, completed.Key, contentType, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	if err := tu.tr.CompleteTusUpload(upload.ID, now, &completed); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTusOffsetMismatch // 同時に完了された
+		}
+		tu.deleteObjects([]string{completed.Key})
+		return err
+	}
+	tu.deleteObjects(chunkKeys)
+	upload.ReservedBytes = 0
+	upload.CompletedAt = &now
+	return nil
+}
+
+// Terminate はアップロードを中止し、受け取った部分を削除して使用量に加えた分を減らす
+// 完了済みの場合、まとめたファイル（と使用量に加えた分）は使われなければ期限切れ後に削除される
+func (tu *tusUsecase) Terminate(userID uint, uploadID string) error {
+	upload, err := tu.Get(userID, uploadID)
+	if err != nil {
+		return err
+	}
+	if err := tu.tr.DeleteTusUpload(upload.ID); err != nil {
+		return err
+	}
+	if upload.CompletedAt == nil {
+		tu.deleteObjects(strings.Fields(upload.ChunkKeys))
+		tu.su.Release(upload.UserID, upload.Purpose, upload.ReservedBytes)
+	}
+	return nil
+}
+
+// deleteObjects は部分などを削除する（失敗してもログに出力するのみ。残ったものは使われていないオブジェクトの削除で消える）
+func (tu *tusUsecase) deleteObjects(keys []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	for _, key := range keys {
+		if err := tu.st.Delete(ctx, key); err != nil {
+			log.Printf("failed to delete %s: %v", key, err)
+		}
+	}
+}
+
+func appendAll(dst []byte, r io.Reader) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	_, err := buf.ReadFrom(r)
+	return buf.Bytes(), err
+}
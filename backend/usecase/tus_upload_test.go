@@ -0,0 +1,295 @@
+package usecase
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type MockTusUploadRepository struct {
+	mock.Mock
+}
+
+func (m *MockTusUploadRepository) CreateTusUpload(upload *model.TusUpload) error {
+	args := m.Called(upload)
+	return args.Error(0)
+}
+
+func (m *MockTusUploadRepository) GetTusUploadByID(upload *model.TusUpload, userID uint, uploadID string) error {
+	args := m.Called(upload, userID, uploadID)
+	if u, ok := args.Get(0).(model.TusUpload); ok {
+		*upload = u
+		return nil
+	}
+	return args.Error(1)
+}
+
+func (m *MockTusUploadRepository) AppendTusChunk(uploadID string, offset int64, newOffset int64, chunkKey string) error {
+	args := m.Called(uploadID, offset, newOffset, chunkKey)
+	return args.Error(0)
+}
+
+func (m *MockTusUploadRepository) CompleteTusUpload(uploadID string, completedAt time.Time, upload *model.Upload) error {
+	args := m.Called(uploadID, completedAt, upload)
+	return args.Error(0)
+}
+
+func (m *MockTusUploadRepository) DeleteTusUpload(uploadID string) error {
+	args := m.Called(uploadID)
+	return args.Error(0)
+}
+
+// newTestTusUpload は受信中のアップロードを返すMockTusUploadRepositoryを用意する
+func newTestTusUpload(upload model.TusUpload) *MockTusUploadRepository {
+	tr := new(MockTusUploadRepository)
+	tr.On("GetTusUploadByID", mock.Anything, uint(1), upload.ID).Return(upload, nil).Once()
+	return tr
+}
+
+func testTusUpload(purpose string, length int64) model.TusUpload {
+	return model.TusUpload{ID: "tus-1", UserID: 1, Purpose: purpose, Length: length, ReservedBytes: length, ExpiresAt: time.Now().Add(time.Hour)}
+}
+
+// reservedStorageUsage はtusのアップロードの作成時に加えた分を含む使用量を返す
+func reservedStorageUsage(t *testing.T, upload model.TusUpload) (IStorageUsageUsecase, func() int64) {
+	t.Helper()
+	su := newTestStorageUsage()
+	require.NoError(t, su.Reserve(upload.UserID, upload.Purpose, upload.ReservedBytes))
+	return su, func() int64 {
+		usage, err := su.GetUsage(upload.UserID)
+		require.NoError(t, err)
+		return usage.UsedBytes
+	}
+}
+
+func TestCreateTusUpload(t *testing.T) {
+	t.Run("期限を設定して作成する", func(t *testing.T) {
+		tr := new(MockTusUploadRepository)
+		tr.On("CreateTusUpload", mock.Anything).Return(nil).Once()
+		su := newTestStorageUsage()
+		upload, err := NewTusUsecase(tr, newTestObjectStore(), 10<<20, su).Create(1, model.TusCreateRequest{Purpose: model.UploadPurposeCuisineImage, Filename: "a.jpg", Length: 1024})
+		require.NoError(t, err)
+		assert.NotEmpty(t, upload.ID)
+		assert.Equal(t, uint(1), upload.UserID)
+		assert.Zero(t, upload.Offset)
+		assert.Equal(t, int64(1024), upload.ReservedBytes)
+		assert.WithinDuration(t, time.Now().Add(tusUploadTTL), upload.ExpiresAt, time.Minute)
+		usage, err := su.GetUsage(1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1024), usage.CuisineImageBytes, "Upload-Lengthを使用量に加える")
+		tr.AssertExpectations(t)
+	})
+
+	t.Run("作成に失敗した場合は使用量に加えた分を減らす", func(t *testing.T) {
+		tr := new(MockTusUploadRepository)
+		tr.On("CreateTusUpload", mock.Anything).Return(errors.New("db error")).Once()
+		su := newTestStorageUsage()
+		_, err := NewTusUsecase(tr, newTestObjectStore(), 10<<20, su).Create(1, model.TusCreateRequest{Purpose: model.UploadPurposeUserIcon, Length: 100})
+		assert.Error(t, err)
+		usage, err := su.GetUsage(1)
+		require.NoError(t, err)
+		assert.Zero(t, usage.UsedBytes)
+	})
+
+	t.Run("用途・大きさを確認する", func(t *testing.T) {
+		tu := NewTusUsecase(new(MockTusUploadRepository), newTestObjectStore(), 10<<20, newTestStorageUsage())
+		assert.Equal(t, int64(10<<20), tu.MaxSize())
+		for _, tc := range []struct {
+			req model.TusCreateRequest
+			err error
+		}{
+			{model.TusCreateRequest{Purpose: "avatar", Length: 1}, ErrInvalidUploadTarget},
+			{model.TusCreateRequest{Purpose: model.UploadPurposeUserIcon, Length: 0}, ErrImageTooLarge},
+			{model.TusCreateRequest{Purpose: model.UploadPurposeUserIcon, Length: maxIconSize + 1}, ErrImageTooLarge},
+		} {
+			_, err := tu.Create(1, tc.req)
+			assert.ErrorIs(t, err, tc.err, tc.req)
+		}
+	})
+
+	t.Run("長さが使用量の上限を超える場合は作成しない", func(t *testing.T) {
+		su := NewStorageUsageUsecase(newMemoryStorageUsageRepository(), 1024)
+		_, err := NewTusUsecase(new(MockTusUploadRepository), newTestObjectStore(), 10<<20, su).Create(1, model.TusCreateRequest{Purpose: model.UploadPurposeCuisineImage, Length: 1025})
+		assert.ErrorIs(t, err, ErrStorageQuotaExceeded)
+	})
+}
+
+func TestWriteTusChunk(t *testing.T) {
+	length := int64(len(testPNG))
+	half := length / 2
+
+	t.Run("部分を保存してオフセットを進める", func(t *testing.T) {
+		st := newTestObjectStore()
+		tr := newTestTusUpload(testTusUpload(model.UploadPurposeUserIcon, length))
+		tr.On("AppendTusChunk", "tus-1", int64(0), half, mock.AnythingOfType("string")).Return(nil).Once()
+
+		upload, err := NewTusUsecase(tr, st, 10<<20, newTestStorageUsage()).WriteChunk(1, "tus-1", 0, "", bytes.NewReader(testPNG[:half]))
+		require.NoError(t, err)
+		assert.Equal(t, half, upload.Offset)
+		assert.Nil(t, upload.CompletedAt)
+		keys := storedKeys(t, st)
+		require.Len(t, keys, 1)
+		assert.True(t, strings.HasPrefix(keys[0], "tus/1/tus-1/00000000000000000000-"), keys[0])
+		tr.AssertExpectations(t)
+	})
+
+	t.Run("最後の部分でまとめて完了済みのアップロードを作成する", func(t *testing.T) {
+		st := newTestObjectStore()
+		tu := NewTusUsecase(nil, st, 10<<20, newTestStorageUsage())
+		first := testTusUpload(model.UploadPurposeUserIcon, length)
+		tr := newTestTusUpload(first)
+		tr.On("AppendTusChunk", "tus-1", int64(0), half, mock.AnythingOfType("string")).Return(nil).Once()
+		tu.(*tusUsecase).tr = tr
+		first, err := tu.WriteChunk(1, "tus-1", 0, "", bytes.NewReader(testPNG[:half]))
+		require.NoError(t, err)
+
+		tr = newTestTusUpload(first)
+		tr.On("AppendTusChunk", "tus-1", half, length, mock.AnythingOfType("string")).Return(nil).Once()
+		var completed *model.Upload
+		tr.On("CompleteTusUpload", "tus-1", mock.AnythingOfType("time.Time"), mock.Anything).Run(func(args mock.Arguments) {
+			completed = args.Get(2).(*model.Upload)
+		}).Return(nil).Once()
+		tu.(*tusUsecase).tr = tr
+
+		sum := sha256.Sum256(testPNG[half:])
+		upload, err := tu.WriteChunk(1, "tus-1", half, "sha256 "+base64.StdEncoding.EncodeToString(sum[:]), bytes.NewReader(testPNG[half:]))
+		require.NoError(t, err)
+		assert.Equal(t, length, upload.Offset)
+		assert.NotNil(t, upload.CompletedAt)
+		require.NotNil(t, completed)
+		assert.Equal(t, "tus-1", completed.ID)
+		assert.Equal(t, model.UploadPurposeUserIcon, completed.Purpose)
+		assert.Equal(t, "uploads/1/tus-1.png", completed.Key)
+		assert.Equal(t, "image/png", completed.ContentType)
+		assert.Equal(t, length, completed.ReservedBytes, "使用量に加えた分を引き継ぐ")
+		assert.Zero(t, upload.ReservedBytes)
+		assert.NotNil(t, completed.CompletedAt)
+		assert.Equal(t, []string{"uploads/1/tus-1.png"}, storedKeys(t, st)) // 部分は削除する
+		tr.AssertExpectations(t)
+	})
+
+	t.Run("オフセットが一致しない場合は保存しない", func(t *testing.T) {
+		st := newTestObjectStore()
+		tr := newTestTusUpload(testTusUpload(model.UploadPurposeUserIcon, length))
+		_, err := NewTusUsecase(tr, st, 10<<20, newTestStorageUsage()).WriteChunk(1, "tus-1", 10, "", bytes.NewReader(testPNG[10:]))
+		assert.ErrorIs(t, err, ErrTusOffsetMismatch)
+		assert.Empty(t, storedKeys(t, st))
+	})
+
+	t.Run("同時に書き込まれた場合は自分の部分を削除する", func(t *testing.T) {
+		st := newTestObjectStore()
+		tr := newTestTusUpload(testTusUpload(model.UploadPurposeUserIcon, length))
+		tr.On("AppendTusChunk", "tus-1", int64(0), half, mock.AnythingOfType("string")).Return(gorm.ErrRecordNotFound).Once()
+		_, err := NewTusUsecase(tr, st, 10<<20, newTestStorageUsage()).WriteChunk(1, "tus-1", 0, "", bytes.NewReader(testPNG[:half]))
+		assert.ErrorIs(t, err, ErrTusOffsetMismatch)
+		assert.Empty(t, storedKeys(t, st))
+	})
+
+	t.Run("チェックサムを確認する", func(t *testing.T) {
+		for _, tc := range []struct {
+			checksum string
+			err      error
+		}{
+			{"sha1 " + base64.StdEncoding.EncodeToString(make([]byte, 20)), ErrTusChecksumMismatch},
+			{"crc32 AAAAAA==", ErrTusUnsupportedChecksum},
+			{"sha256 !!!", ErrTusUnsupportedChecksum},
+		} {
+			st := newTestObjectStore()
+			tr := newTestTusUpload(testTusUpload(model.UploadPurposeUserIcon, length))
+			_, err := NewTusUsecase(tr, st, 10<<20, newTestStorageUsage()).WriteChunk(1, "tus-1", 0, tc.checksum, bytes.NewReader(testPNG[:half]))
+			assert.ErrorIs(t, err, tc.err, tc.checksum)
+			assert.Empty(t, storedKeys(t, st))
+		}
+	})
+
+	t.Run("Upload-Lengthを超える場合は保存しない", func(t *testing.T) {
+		st := newTestObjectStore()
+		tr := newTestTusUpload(testTusUpload(model.UploadPurposeUserIcon, 4))
+		_, err := NewTusUsecase(tr, st, 10<<20, newTestStorageUsage()).WriteChunk(1, "tus-1", 0, "", bytes.NewReader(testPNG))
+		assert.ErrorIs(t, err, ErrTusExceedsLength)
+		assert.Empty(t, storedKeys(t, st))
+	})
+
+	t.Run("途切れた場合は受け取った分を保存する", func(t *testing.T) {
+		st := newTestObjectStore()
+		tr := newTestTusUpload(testTusUpload(model.UploadPurposeUserIcon, length))
+		tr.On("AppendTusChunk", "tus-1", int64(0), half, mock.AnythingOfType("string")).Return(nil).Once()
+		body := &interruptedReader{data: testPNG[:half]}
+		upload, err := NewTusUsecase(tr, st, 10<<20, newTestStorageUsage()).WriteChunk(1, "tus-1", 0, "", body)
+		require.NoError(t, err)
+		assert.Equal(t, half, upload.Offset)
+		assert.Len(t, storedKeys(t, st), 1)
+	})
+
+	t.Run("画像でなければアップロードを削除する", func(t *testing.T) {
+		st := newTestObjectStore()
+		data := []byte("<html></html>")
+		upload := testTusUpload(model.UploadPurposeCuisineImage, int64(len(data)))
+		tr := newTestTusUpload(upload)
+		tr.On("AppendTusChunk", "tus-1", int64(0), int64(len(data)), mock.AnythingOfType("string")).Return(nil).Once()
+		tr.On("DeleteTusUpload", "tus-1").Return(nil).Once()
+		su, used := reservedStorageUsage(t, upload)
+		_, err := NewTusUsecase(tr, st, 10<<20, su).WriteChunk(1, "tus-1", 0, "", bytes.NewReader(data))
+		assert.ErrorIs(t, err, ErrInvalidImage)
+		assert.Empty(t, storedKeys(t, st))
+		assert.Zero(t, used(), "使用量に加えた分を減らす")
+		tr.AssertExpectations(t)
+	})
+
+	t.Run("期限切れ・存在しない場合", func(t *testing.T) {
+		expired := testTusUpload(model.UploadPurposeUserIcon, length)
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+		_, err := NewTusUsecase(newTestTusUpload(expired), newTestObjectStore(), 10<<20, newTestStorageUsage()).WriteChunk(1, "tus-1", 0, "", bytes.NewReader(testPNG))
+		assert.ErrorIs(t, err, ErrUploadExpired)
+
+		tr := new(MockTusUploadRepository)
+		tr.On("GetTusUploadByID", mock.Anything, uint(2), "tus-1").Return(nil, gorm.ErrRecordNotFound)
+		_, err = NewTusUsecase(tr, newTestObjectStore(), 10<<20, newTestStorageUsage()).Get(2, "tus-1")
+		assert.ErrorIs(t, err, ErrUploadNotFound)
+	})
+}
+
+func TestTerminateTusUpload(t *testing.T) {
+	st := newTestObjectStore()
+	first := testTusUpload(model.UploadPurposeUserIcon, int64(len(testPNG)))
+	su, used := reservedStorageUsage(t, first)
+	tu := NewTusUsecase(nil, st, 10<<20, su)
+	tr := newTestTusUpload(first)
+	tr.On("AppendTusChunk", "tus-1", int64(0), int64(10), mock.AnythingOfType("string")).Return(nil).Once()
+	tu.(*tusUsecase).tr = tr
+	upload, err := tu.WriteChunk(1, "tus-1", 0, "", bytes.NewReader(testPNG[:10]))
+	require.NoError(t, err)
+
+	tr = newTestTusUpload(upload)
+	tr.On("DeleteTusUpload", "tus-1").Return(nil).Once()
+	tu.(*tusUsecase).tr = tr
+	require.NoError(t, tu.Terminate(1, "tus-1"))
+	assert.Empty(t, storedKeys(t, st))
+	assert.Zero(t, used(), "使用量に加えた分を減らす")
+	tr.AssertExpectations(t)
+}
+
+// interruptedReader はdataを返した後にエラーを返す（通信が途切れた場合）
+type interruptedReader struct {
+	data []byte
+	done bool
+}
+
+func (r *interruptedReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, errors.New("connection reset")
+	}
+	r.done = true
+	return copy(p, r.data), nil
+}
@@ -0,0 +1,201 @@
+package usecase
+
+// TOTPによる二要素認証の登録・有効化と、ログイン時のコード検証を実装
+// Enroll:共有鍵を生成し、認証アプリ登録用のURIとQRコードを返す（この時点では無効のまま）
+// Confirm:最初のコードを検証して二要素認証を有効化し、リカバリーコードを発行する
+// VerifyLogin:パスワード認証後のMFAトークンとコード（またはリカバリーコード）を検証し、セッションを発行する
+
+import (
+	"backend/auth"
+	"backend/model"
+	"backend/repository"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled    = errors.New("two-factor authentication is not enrolled")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrInvalidMFAToken         = errors.New("invalid or expired mfa token")
+)
+
+const (
+	totpIssuer        = "CookMeet"
+	mfaTokenTTL       = 5 * time.Minute
+	recoveryCodeCount = 10
+	qrCodeSize        = 256
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type ITwoFactorUsecase interface {
+	Enroll(userID uint) (model.TwoFactorEnrollment, error)
+	Confirm(userID uint, code string) (model.TwoFactorConfirmation, error)
+	VerifyLogin(mfaToken string, code string, client model.ClientInfo) (string, error)
+}
+
+type twoFactorUsecase struct {
+	ur     repository.IUserRepository
+	rr     repository.IRecoveryCodeRepository
+	lg     ILoginGuard
+	sm     ISessionManager
+	al     IAuditLogger
+	secret string
+	now    func() time.Time
+}
+
+func NewTwoFactorUsecase(ur repository.IUserRepository, rr repository.IRecoveryCodeRepository, lg ILoginGuard, sm ISessionManager, al IAuditLogger, secret string) ITwoFactorUsecase {
+	return &twoFactorUsecase{ur, rr, lg, sm, al, secret, time.Now}
+}
+
+func (tu *twoFactorUsecase) Enroll(userID uint) (model.TwoFactorEnrollment, error) {
+	user, err := tu.ur.GetUserByID(userID)
+	if err != nil {
+		return model.TwoFactorEnrollment{}, err
+	}
+	if user.TOTPEnabled {
+		return model.TwoFactorEnrollment{}, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return model.TwoFactorEnrollment{}, err
+	}
+	user.TOTPSecret = &secret
+	user.TOTPLastStep = 0
+	if err := tu.ur.UpdateTwoFactor(user); err != nil {
+		return model.TwoFactorEnrollment{}, err
+	}
+
+	uri := auth.TOTPURI(totpIssuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, qrCodeSize)
+	if err != nil {
+		return model.TwoFactorEnrollment{}, err
+	}
+	return model.TwoFactorEnrollment{Secret: secret, URI: uri, QRCode: png}, nil
+}
+
+func (tu *twoFactorUsecase) Confirm(userID uint, code string) (model.TwoFactorConfirmation, error) {
+	user, err := tu.ur.GetUserByID(userID)
+	if err != nil {
+		return model.TwoFactorConfirmation{}, err
+	}
+	if user.TOTPEnabled {
+		return model.TwoFactorConfirmation{}, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == nil {
+		return model.TwoFactorConfirmation{}, ErrTwoFactorNotEnrolled
+	}
+
+	step, ok := auth.ValidateTOTP(*user.TOTPSecret, strings.TrimSpace(code), tu.now(), 0)
+	if !ok {
+		return model.TwoFactorConfirmation{}, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashed, err := generateRecoveryCodes(user.ID)
+	if err != nil {
+		return model.TwoFactorConfirmation{}, err
+	}
+	if err := tu.rr.ReplaceRecoveryCodes(user.ID, hashed); err != nil {
+		return model.TwoFactorConfirmation{}, err
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	if err := tu.ur.UpdateTwoFactor(user); err != nil {
+		return model.TwoFactorConfirmation{}, err
+	}
+	return model.TwoFactorConfirmation{RecoveryCodes: codes}, nil
+}
+
+func (tu *twoFactorUsecase) VerifyLogin(mfaToken string, code string, client model.ClientInfo) (string, error) {
+	claims, err := auth.Parse(mfaToken, tu.secret, auth.TokenTypeMFAPending)
+	if err != nil {
+		return "", ErrInvalidMFAToken
+	}
+	user, err := tu.ur.GetUserByID(claims.UserID)
+	if err != nil || !user.TOTPEnabled || user.TOTPSecret == nil {
+		return "", ErrInvalidMFAToken
+	}
+
+	// コードの総当たりもパスワードと同じロックの対象にする
+	if err := tu.lg.Check(user.Email, client.IP); err != nil {
+		logEvent(tu.al, AuditLoginFailed, nil, &user.ID, client, map[string]interface{}{"reason": "locked"})
+		return "", err
+	}
+	ok, err := tu.verifyCode(user, code)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		tu.lg.Fail(user.Email, &user.ID, client)
+		logEvent(tu.al, AuditLoginFailed, nil, &user.ID, client, map[string]interface{}{"reason": "invalid_two_factor_code"})
+		return "", ErrInvalidTwoFactorCode
+	}
+	tu.lg.Succeed(user.Email)
+
+	token, err := tu.sm.Issue(user, client)
+	if err != nil {
+		return "", err
+	}
+	logUserEvent(tu.al, AuditLogin, user.ID, client, map[string]interface{}{"method": "password", "two_factor": true})
+	return token, nil
+}
+
+// verifyCode は6桁のコードであればTOTPとして、それ以外はリカバリーコードとして検証する
+func (tu *twoFactorUsecase) verifyCode(user *model.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if isTOTPCode(code) {
+		step, ok := auth.ValidateTOTP(*user.TOTPSecret, code, tu.now(), user.TOTPLastStep)
+		if !ok {
+			return false, nil
+		}
+		return tu.ur.UpdateTOTPLastStep(user.ID, step)
+	}
+	return tu.rr.UseRecoveryCode(user.ID, hashRecoveryCode(code))
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != 6 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// generateRecoveryCodes は表示用のコードと、保存用のハッシュを生成する
+func generateRecoveryCodes(userID uint) ([]string, []model.RecoveryCode, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashed := make([]model.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		hashed = append(hashed, model.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)})
+	}
+	return codes, hashed, nil
+}
+
+// hashRecoveryCode は入力の揺れ（大文字小文字・区切り文字）を吸収してからハッシュ化する
+// コードは十分なエントロピーを持つため、高速なハッシュで問題ない
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,166 @@
+package usecase
+
+import (
+	"backend/auth"
+	"backend/model"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRecoveryCodeRepository struct {
+	mock.Mock
+}
+
+func (m *MockRecoveryCodeRepository) ReplaceRecoveryCodes(userID uint, codes []model.RecoveryCode) error {
+	args := m.Called(userID, codes)
+	return args.Error(0)
+}
+
+func (m *MockRecoveryCodeRepository) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	args := m.Called(userID, codeHash)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestTwoFactorEnroll(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockCodes := new(MockRecoveryCodeRepository)
+	tu := NewTwoFactorUsecase(mockRepo, mockCodes, newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), testSecret)
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo.On("GetUserByID", uint(1)).Return(&model.User{ID: 1, Email: "test@example.com"}, nil).Once()
+		mockRepo.On("UpdateTwoFactor", mock.MatchedBy(func(u *model.User) bool {
+			return u.ID == 1 && u.TOTPSecret != nil && !u.TOTPEnabled
+		})).Return(nil).Once()
+
+		enrollment, err := tu.Enroll(1)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, enrollment.Secret)
+		assert.Contains(t, enrollment.URI, "otpauth://totp/CookMeet:test@example.com")
+		assert.True(t, bytes.HasPrefix(enrollment.QRCode, []byte("\x89PNG")))
+	})
+
+	t.Run("already enabled", func(t *testing.T) {
+		mockRepo.On("GetUserByID", uint(2)).Return(&model.User{ID: 2, TOTPEnabled: true}, nil).Once()
+
+		_, err := tu.Enroll(2)
+		assert.ErrorIs(t, err, ErrTwoFactorAlreadyEnabled)
+	})
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTwoFactorConfirm(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockCodes := new(MockRecoveryCodeRepository)
+		tu := NewTwoFactorUsecase(mockRepo, mockCodes, newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), testSecret)
+
+		mockRepo.On("GetUserByID", uint(1)).Return(&model.User{ID: 1, TOTPSecret: &secret}, nil).Once()
+		mockCodes.On("ReplaceRecoveryCodes", uint(1), mock.MatchedBy(func(codes []model.RecoveryCode) bool {
+			return len(codes) == recoveryCodeCount
+		})).Return(nil).Once()
+		mockRepo.On("UpdateTwoFactor", mock.MatchedBy(func(u *model.User) bool {
+			return u.TOTPEnabled && u.TOTPLastStep > 0
+		})).Return(nil).Once()
+
+		code, err := auth.TOTPCode(secret, time.Now())
+		assert.NoError(t, err)
+		confirmation, err := tu.Confirm(1, code)
+		assert.NoError(t, err)
+		assert.Len(t, confirmation.RecoveryCodes, recoveryCodeCount)
+		mockRepo.AssertExpectations(t)
+		mockCodes.AssertExpectations(t)
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		tu := NewTwoFactorUsecase(mockRepo, new(MockRecoveryCodeRepository), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), testSecret)
+		mockRepo.On("GetUserByID", uint(1)).Return(&model.User{ID: 1, TOTPSecret: &secret}, nil).Once()
+
+		_, err := tu.Confirm(1, "abcdef")
+		assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+	})
+
+	t.Run("not enrolled", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		tu := NewTwoFactorUsecase(mockRepo, new(MockRecoveryCodeRepository), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), testSecret)
+		mockRepo.On("GetUserByID", uint(1)).Return(&model.User{ID: 1}, nil).Once()
+
+		_, err := tu.Confirm(1, "123456")
+		assert.ErrorIs(t, err, ErrTwoFactorNotEnrolled)
+	})
+}
+
+func TestTwoFactorVerifyLogin(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &model.User{ID: 1, Email: "test@example.com", TOTPSecret: &secret, TOTPEnabled: true}
+	mfaToken, err := auth.Sign(auth.NewClaims(1, auth.TokenTypeMFAPending, time.Minute), testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("totp code", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		al := newTestAuditLogger()
+		tu := NewTwoFactorUsecase(mockRepo, new(MockRecoveryCodeRepository), newTestLoginGuard(), newTestSessionManager(), al, testSecret)
+		mockRepo.On("GetUserByID", uint(1)).Return(user, nil).Once()
+		mockRepo.On("UpdateTOTPLastStep", uint(1), mock.AnythingOfType("int64")).Return(true, nil).Once()
+
+		code, err := auth.TOTPCode(secret, time.Now())
+		assert.NoError(t, err)
+		token, err := tu.VerifyLogin(mfaToken, code, testClient)
+		assert.NoError(t, err)
+
+		claims, err := auth.Parse(token, testSecret, auth.TokenTypeAccess)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), claims.UserID)
+		assert.Equal(t, []string{AuditLogin}, al.actions())
+	})
+
+	t.Run("replayed totp code", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		al := newTestAuditLogger()
+		tu := NewTwoFactorUsecase(mockRepo, new(MockRecoveryCodeRepository), newTestLoginGuard(), newTestSessionManager(), al, testSecret)
+		mockRepo.On("GetUserByID", uint(1)).Return(user, nil).Once()
+		mockRepo.On("UpdateTOTPLastStep", uint(1), mock.AnythingOfType("int64")).Return(false, nil).Once()
+
+		code, err := auth.TOTPCode(secret, time.Now())
+		assert.NoError(t, err)
+		_, err = tu.VerifyLogin(mfaToken, code, testClient)
+		assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+		assert.Equal(t, []string{AuditLoginFailed}, al.actions())
+	})
+
+	t.Run("recovery code", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockCodes := new(MockRecoveryCodeRepository)
+		tu := NewTwoFactorUsecase(mockRepo, mockCodes, newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), testSecret)
+		mockRepo.On("GetUserByID", uint(1)).Return(user, nil).Once()
+		mockCodes.On("UseRecoveryCode", uint(1), hashRecoveryCode("abcde-fghij")).Return(true, nil).Once()
+
+		_, err := tu.VerifyLogin(mfaToken, "ABCDE-FGHIJ", testClient)
+		assert.NoError(t, err)
+		mockCodes.AssertExpectations(t)
+	})
+
+	t.Run("access token instead of mfa token", func(t *testing.T) {
+		tu := NewTwoFactorUsecase(new(MockUserRepository), new(MockRecoveryCodeRepository), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), testSecret)
+		accessToken, err := auth.Sign(auth.NewAccessClaims(1, "sid", nil, time.Minute), testSecret)
+		assert.NoError(t, err)
+
+		_, err = tu.VerifyLogin(accessToken, "123456", testClient)
+		assert.ErrorIs(t, err, ErrInvalidMFAToken)
+	})
+}
@@ -0,0 +1,238 @@
+package usecase
+
+// 保存先への直接アップロード（サーバーを経由せずに画像をアップロードする）
+// 1. CreateUploadで uploads/<ユーザーID>/<ID>.<拡張子> へのPUTの署名付きURLを発行する
+// 2. クライアントが署名付きURLへアップロードし、CompleteUploadで大きさと種類（内容から判定）を確認する
+// 3. 料理の追加・アイコンの変更でupload_idを指定すると、内容を読み込んで変換・保存し、元のオブジェクトを削除する
+// 作成時に申告された大きさを保存先の使用量（storage_usage.go）に加え、使用した時に減らす（変換した画像は保存時に改めて加える）
+// 期限までに完了・使用されなかったアップロードは使えなくなり、オブジェクトと使用量に加えた分は使われていないオブジェクトの削除（storage_gc.go）で消える
+
+import (
+	"backend/model"
+	"backend/repository"
+	"backend/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUploadNotFound      = errors.New("upload not found")
+	ErrUploadExpired       = errors.New("upload has expired")
+	ErrUploadNotUploaded   = errors.New("file has not been uploaded yet")
+	ErrUploadNotCompleted  = errors.New("upload is not completed")
+	ErrUploadAlreadyUsed   = errors.New("upload has already been used")
+	ErrUploadMismatch      = errors.New("uploaded file does not match the declared size or content type")
+	ErrInvalidUploadTarget = errors.New("purpose must be cuisine_image or user_icon")
+)
+
+const (
+	uploadKeyPrefix = "uploads/"
+	uploadURLTTL    = 15 * time.Minute // アップロード用の署名付きURLの期限
+	uploadTTL       = time.Hour        // 完了・使用の期限
+	uploadSniffSize = 512              // 種類の判定に読む大きさ（http.DetectContentTypeと同じ）
+)
+
+type IUploadUsecase interface {
+	CreateUpload(userID uint, req model.UploadRequest) (model.UploadResponse, error)
+	CompleteUpload(userID uint, uploadID string) (model.UploadResponse, error)
+}
+
+type uploadUsecase struct {
+	ur       repository.IUploadRepository
+	st       storage.ObjectStore
+	maxSizes map[string]int64 // 用途ごとの大きさの上限
+	su       IStorageUsageUsecase
+	now      func() time.Time
+}
+
+// NewUploadUsecase は料理画像の大きさの上限にmaxImageBytes（imageproc.Limits.MaxBytes）を使う
+func NewUploadUsecase(ur repository.IUploadRepository, st storage.ObjectStore, maxImageBytes int64, su IStorageUsageUsecase) IUploadUsecase {
+	return &uploadUsecase{ur, st, uploadMaxSizes(maxImageBytes), su, time.Now}
+}
+
+// uploadMaxSizes は用途ごとの大きさの上限を返す
+func uploadMaxSizes(maxImageBytes int64) map[string]int64 {
+	return map[string]int64{
+		model.UploadPurposeCuisineImage: maxImageBytes,
+		model.UploadPurposeUserIcon:     maxIconSize,
+	}
+}
+
+func (uu *uploadUsecase) CreateUpload(userID uint, req model.UploadRequest) (model.UploadResponse, error) {
+	maxSize, ok := uu.maxSizes[req.Purpose]
+	if !ok {
+		return model.UploadResponse{}, ErrInvalidUploadTarget
+	}
+	ext, ok := imageExtensions[req.ContentType]
+	if !ok {
+		return model.UploadResponse{}, ErrInvalidImage
+	}
+	if req.Size <= 0 || req.Size > maxSize {
+		return model.UploadResponse{}, fmt.Errorf("%w: must be at most %d bytes", ErrImageTooLarge, maxSize)
+	}
+	if err := uu.su.Reserve(userID, req.Purpose, req.Size); err != nil {
+		return model.UploadResponse{}, err
+	}
+
+	id := uuid.New().String()
+	upload := model.Upload{
+		ID:            id,
+		UserID:        userID,
+		Purpose:       req.Purpose,
+		Key:           fmt.Sprintf("%s%d/%s%s", uploadKeyPrefix, userID, id, ext),
+		ContentType:   req.ContentType,
+		Size:          req.Size,
+		ReservedBytes: req.Size,
+		ExpiresAt:     uu.now().Add(uploadTTL),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	url, err := uu.st.SignPutURL(ctx, upload.Key, upload.ContentType, uploadURLTTL)
+	if err != nil {
+		uu.su.Release(userID, req.Purpose, req.Size)
+		return model.UploadResponse{}, err
+	}
+	if err := uu.ur.CreateUpload(&upload); err != nil {
+		uu.su.Release(userID, req.Purpose, req.Size)
+		return model.UploadResponse{}, err
+	}
+
+	res := uploadResponse(upload, uu.now())
+	res.UploadURL = url
+	res.Method = http.MethodPut
+	res.Headers = map[string]string{"Content-Type": upload.ContentType}
+	return res, nil
+}
+
+// CompleteUpload はアップロードされたオブジェクトの大きさと種類を確認し、使える状態にする
+// 一致しない場合はオブジェクトを削除する（期限内であれば同じURLでアップロードし直せる）
+func (uu *uploadUsecase) CompleteUpload(userID uint, uploadID string) (model.UploadResponse, error) {
+	upload, err := getUpload(uu.ur, userID, uploadID)
+	if err != nil {
+		return model.UploadResponse{}, err
+	}
+	now := uu.now()
+	if upload.CompletedAt != nil {
+		return uploadResponse(upload, now), nil // 完了済みの場合はそのまま返す
+	}
+	if !now.Before(upload.ExpiresAt) {
+		return model.UploadResponse{}, ErrUploadExpired
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	r, info, err := uu.st.Get(ctx, upload.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return model.UploadResponse{}, ErrUploadNotUploaded
+	}
+	if err != nil {
+		return model.UploadResponse{}, err
+	}
+	head, err := io.ReadAll(io.LimitReader(r, uploadSniffSize))
+	r.Close()
+	if err != nil {
+		return model.UploadResponse{}, err
+	}
+	if info.Size != upload.Size || http.DetectContentType(head) != upload.ContentType {
+		if err := uu.st.Delete(ctx, upload.Key); err != nil {
+			log.Printf("failed to delete mismatched upload %s: %v", upload.Key, err)
+		}
+		return model.UploadResponse{}, ErrUploadMismatch
+	}
+
+	if err := uu.ur.CompleteUpload(upload.ID, now); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.UploadResponse{}, err // 同時に完了された場合（ErrRecordNotFound）は成功として扱う
+	}
+	upload.CompletedAt = &now
+	return uploadResponse(upload, now), nil
+}
+
+func getUpload(ur repository.IUploadRepository, userID uint, uploadID string) (model.Upload, error) {
+	upload := model.Upload{}
+	if err := ur.GetUploadByID(&upload, userID, uploadID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.Upload{}, ErrUploadNotFound
+		}
+		return model.Upload{}, err
+	}
+	return upload, nil
+}
+
+// readUpload は料理の追加・アイコンの変更で指定された完了済みのアップロードの内容を返す
+func readUpload(ur repository.IUploadRepository, st storage.ObjectStore, userID uint, uploadID string, purpose string) (model.Upload, []byte, error) {
+	upload, err := getUpload(ur, userID, uploadID)
+	if err != nil {
+		return model.Upload{}, nil, err
+	}
+	switch {
+	case upload.Purpose != purpose:
+		return model.Upload{}, nil, ErrUploadNotFound
+	case upload.ConsumedAt != nil:
+		return model.Upload{}, nil, ErrUploadAlreadyUsed
+	case !time.Now().Before(upload.ExpiresAt):
+		return model.Upload{}, nil, ErrUploadExpired
+	case upload.CompletedAt == nil:
+		return model.Upload{}, nil, ErrUploadNotCompleted
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	r, _, err := st.Get(ctx, upload.Key)
+	if err != nil {
+		return model.Upload{}, nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, upload.Size+1)) // 完了後に上書きされた場合に備えて大きさを制限する
+	if err != nil {
+		return model.Upload{}, nil, err
+	}
+	if int64(len(data)) != upload.Size {
+		return model.Upload{}, nil, ErrUploadMismatch
+	}
+	return upload, data, nil
+}
+
+// consumeUpload はアップロードを使用済みにし、元のオブジェクトを削除して作成時に使用量に加えた分を減らす
+// 同時に同じアップロードが使われた場合は一方のみ成功する（使用済みにしたアップロードは保存に失敗しても再び使えない）
+func consumeUpload(ur repository.IUploadRepository, st storage.ObjectStore, su IStorageUsageUsecase, upload model.Upload) error {
+	if err := ur.ConsumeUpload(upload.ID, time.Now()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUploadAlreadyUsed
+		}
+		return err
+	}
+	su.Release(upload.UserID, upload.Purpose, upload.ReservedBytes)
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	if err := st.Delete(ctx, upload.Key); err != nil {
+		log.Printf("failed to delete consumed upload %s: %v", upload.Key, err)
+	}
+	return nil
+}
+
+func uploadResponse(upload model.Upload, now time.Time) model.UploadResponse {
+	status := model.UploadStatusPending
+	switch {
+	case upload.ConsumedAt != nil:
+		status = model.UploadStatusConsumed
+	case !now.Before(upload.ExpiresAt):
+		status = model.UploadStatusExpired
+	case upload.CompletedAt != nil:
+		status = model.UploadStatusCompleted
+	}
+	return model.UploadResponse{
+		ID:        upload.ID,
+		Purpose:   upload.Purpose,
+		Status:    status,
+		Size:      upload.Size,
+		ExpiresAt: upload.ExpiresAt,
+	}
+}
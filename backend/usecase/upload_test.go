@@ -0,0 +1,261 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/model"
+	"backend/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type MockUploadRepository struct {
+	mock.Mock
+}
+
+func (m *MockUploadRepository) CreateUpload(upload *model.Upload) error {
+	args := m.Called(upload)
+	return args.Error(0)
+}
+
+func (m *MockUploadRepository) GetUploadByID(upload *model.Upload, userID uint, uploadID string) error {
+	args := m.Called(upload, userID, uploadID)
+	if u, ok := args.Get(0).(model.Upload); ok {
+		*upload = u
+		return nil
+	}
+	return args.Error(1)
+}
+
+func (m *MockUploadRepository) CompleteUpload(uploadID string, completedAt time.Time) error {
+	args := m.Called(uploadID, completedAt)
+	return args.Error(0)
+}
+
+func (m *MockUploadRepository) ConsumeUpload(uploadID string, consumedAt time.Time) error {
+	args := m.Called(uploadID, consumedAt)
+	return args.Error(0)
+}
+
+// newTestUpload はアップロード済みのオブジェクトと、それを返すMockUploadRepositoryを用意する
+func newTestUpload(t *testing.T, st storage.ObjectStore, purpose string, contentType string, data []byte, completed bool) (*MockUploadRepository, model.Upload) {
+	t.Helper()
+	upload := model.Upload{
+		ID:          "upload-1",
+		UserID:      1,
+		Purpose:     purpose,
+		Key:         "uploads/1/upload-1" + imageExtensions[contentType],
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	if completed {
+		now := time.Now()
+		upload.CompletedAt = &now
+	}
+	require.NoError(t, st.Put(context.Background(), upload.Key, contentType, bytes.NewReader(data)))
+	return newTestUploadRepository(upload), upload
+}
+
+// newTestReservedUpload は作成時に大きさを使用量に加えた完了済みの直接アップロードを用意する
+func newTestReservedUpload(t *testing.T, st storage.ObjectStore, su IStorageUsageUsecase, purpose string, contentType string, data []byte) (*MockUploadRepository, model.Upload) {
+	t.Helper()
+	_, upload := newTestUpload(t, st, purpose, contentType, data, true)
+	upload.ReservedBytes = upload.Size
+	require.NoError(t, su.Reserve(upload.UserID, purpose, upload.ReservedBytes))
+	return newTestUploadRepository(upload), upload
+}
+
+// newTestUploadRepository はuploadを返すMockUploadRepositoryを用意する
+func newTestUploadRepository(upload model.Upload) *MockUploadRepository {
+	ur := new(MockUploadRepository)
+	ur.On("GetUploadByID", mock.Anything, upload.UserID, upload.ID).Return(upload, nil)
+	return ur
+}
+
+func TestCreateUpload(t *testing.T) {
+	t.Run("ユーザーの接頭辞のキーへの署名付きURLを返す", func(t *testing.T) {
+		ur := new(MockUploadRepository)
+		var saved *model.Upload
+		ur.On("CreateUpload", mock.Anything).Run(func(args mock.Arguments) {
+			saved = args.Get(0).(*model.Upload)
+		}).Return(nil).Once()
+		su := newTestStorageUsage()
+		uu := NewUploadUsecase(ur, newTestObjectStore(), 10<<20, su)
+
+		res, err := uu.CreateUpload(1, model.UploadRequest{Purpose: model.UploadPurposeCuisineImage, ContentType: "image/jpeg", Size: 1024})
+		require.NoError(t, err)
+		require.NotNil(t, saved)
+		assert.Equal(t, saved.ID, res.ID)
+		assert.Equal(t, "uploads/1/"+res.ID+".jpg", saved.Key)
+		assert.Equal(t, int64(1024), saved.Size)
+		assert.Equal(t, int64(1024), saved.ReservedBytes)
+		usage, err := su.GetUsage(1)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1024), usage.CuisineImageBytes, "申告された大きさを使用量に加える")
+		assert.WithinDuration(t, time.Now().Add(uploadTTL), saved.ExpiresAt, time.Minute)
+		assert.Equal(t, model.UploadStatusPending, res.Status)
+		assert.Equal(t, "PUT", res.Method)
+		assert.Equal(t, map[string]string{"Content-Type": "image/jpeg"}, res.Headers)
+		assert.True(t, strings.HasPrefix(res.UploadURL, "memory:///"+saved.Key+"?method=PUT"), res.UploadURL)
+	})
+
+	t.Run("用途・種類・大きさを確認する", func(t *testing.T) {
+		uu := NewUploadUsecase(new(MockUploadRepository), newTestObjectStore(), 10<<20, newTestStorageUsage())
+		for _, tc := range []struct {
+			req model.UploadRequest
+			err error
+		}{
+			{model.UploadRequest{Purpose: "avatar", ContentType: "image/png", Size: 1}, ErrInvalidUploadTarget},
+			{model.UploadRequest{Purpose: model.UploadPurposeUserIcon, ContentType: "image/svg+xml", Size: 1}, ErrInvalidImage},
+			{model.UploadRequest{Purpose: model.UploadPurposeUserIcon, ContentType: "image/png", Size: 0}, ErrImageTooLarge},
+			{model.UploadRequest{Purpose: model.UploadPurposeUserIcon, ContentType: "image/png", Size: maxIconSize + 1}, ErrImageTooLarge},
+			{model.UploadRequest{Purpose: model.UploadPurposeCuisineImage, ContentType: "image/png", Size: 10<<20 + 1}, ErrImageTooLarge},
+		} {
+			_, err := uu.CreateUpload(1, tc.req)
+			assert.ErrorIs(t, err, tc.err, tc.req)
+		}
+	})
+
+	t.Run("申告された大きさが使用量の上限を超える場合は作成しない", func(t *testing.T) {
+		su := NewStorageUsageUsecase(newMemoryStorageUsageRepository(), 2048)
+		require.NoError(t, su.Reserve(1, model.UploadPurposeCuisineImage, 1024))
+		uu := NewUploadUsecase(new(MockUploadRepository), newTestObjectStore(), 10<<20, su)
+
+		_, err := uu.CreateUpload(1, model.UploadRequest{Purpose: model.UploadPurposeCuisineImage, ContentType: "image/jpeg", Size: 1025})
+		assert.ErrorIs(t, err, ErrStorageQuotaExceeded)
+	})
+
+	t.Run("作成に失敗した場合は使用量に加えた分を減らす", func(t *testing.T) {
+		ur := new(MockUploadRepository)
+		ur.On("CreateUpload", mock.Anything).Return(errors.New("db error")).Once()
+		su := newTestStorageUsage()
+		uu := NewUploadUsecase(ur, newTestObjectStore(), 10<<20, su)
+
+		_, err := uu.CreateUpload(1, model.UploadRequest{Purpose: model.UploadPurposeUserIcon, ContentType: "image/png", Size: 100})
+		assert.Error(t, err)
+		usage, err := su.GetUsage(1)
+		require.NoError(t, err)
+		assert.Zero(t, usage.UsedBytes)
+	})
+}
+
+func TestCompleteUpload(t *testing.T) {
+	t.Run("大きさと種類が一致すれば完了する", func(t *testing.T) {
+		st := newTestObjectStore()
+		ur, upload := newTestUpload(t, st, model.UploadPurposeUserIcon, "image/png", testPNG, false)
+		ur.On("CompleteUpload", upload.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+		res, err := NewUploadUsecase(ur, st, 10<<20, newTestStorageUsage()).CompleteUpload(1, upload.ID)
+		require.NoError(t, err)
+		assert.Equal(t, model.UploadStatusCompleted, res.Status)
+		assert.Empty(t, res.UploadURL)
+		ur.AssertExpectations(t)
+	})
+
+	t.Run("一致しなければオブジェクトを削除する", func(t *testing.T) {
+		for name, data := range map[string][]byte{
+			"大きさ": append(testPNG, 0),
+			"種類":  append([]byte("<html>"), make([]byte, len(testPNG)-6)...),
+		} {
+			st := newTestObjectStore()
+			ur, upload := newTestUpload(t, st, model.UploadPurposeUserIcon, "image/png", testPNG, false)
+			require.NoError(t, st.Put(context.Background(), upload.Key, "image/png", bytes.NewReader(data)))
+
+			_, err := NewUploadUsecase(ur, st, 10<<20, newTestStorageUsage()).CompleteUpload(1, upload.ID)
+			assert.ErrorIs(t, err, ErrUploadMismatch, name)
+			assert.Empty(t, storedKeys(t, st), name)
+			ur.AssertNotCalled(t, "CompleteUpload", mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("アップロードされていない", func(t *testing.T) {
+		st := newTestObjectStore()
+		ur, upload := newTestUpload(t, st, model.UploadPurposeUserIcon, "image/png", testPNG, false)
+		require.NoError(t, st.Delete(context.Background(), upload.Key))
+
+		_, err := NewUploadUsecase(ur, st, 10<<20, newTestStorageUsage()).CompleteUpload(1, upload.ID)
+		assert.ErrorIs(t, err, ErrUploadNotUploaded)
+	})
+
+	t.Run("期限切れ", func(t *testing.T) {
+		st := newTestObjectStore()
+		_, upload := newTestUpload(t, st, model.UploadPurposeUserIcon, "image/png", testPNG, false)
+		upload.ExpiresAt = time.Now().Add(-time.Minute)
+		ur := new(MockUploadRepository)
+		ur.On("GetUploadByID", mock.Anything, uint(1), upload.ID).Return(upload, nil)
+
+		_, err := NewUploadUsecase(ur, st, 10<<20, newTestStorageUsage()).CompleteUpload(1, upload.ID)
+		assert.ErrorIs(t, err, ErrUploadExpired)
+	})
+
+	t.Run("他のユーザーのアップロード", func(t *testing.T) {
+		ur := new(MockUploadRepository)
+		ur.On("GetUploadByID", mock.Anything, uint(2), "upload-1").Return(nil, gorm.ErrRecordNotFound)
+
+		_, err := NewUploadUsecase(ur, newTestObjectStore(), 10<<20, newTestStorageUsage()).CompleteUpload(2, "upload-1")
+		assert.ErrorIs(t, err, ErrUploadNotFound)
+	})
+}
+
+func TestReadUpload(t *testing.T) {
+	t.Run("完了していない・用途が異なる", func(t *testing.T) {
+		st := newTestObjectStore()
+		ur, upload := newTestUpload(t, st, model.UploadPurposeUserIcon, "image/png", testPNG, false)
+		_, _, err := readUpload(ur, st, 1, upload.ID, model.UploadPurposeUserIcon)
+		assert.ErrorIs(t, err, ErrUploadNotCompleted)
+		_, _, err = readUpload(ur, st, 1, upload.ID, model.UploadPurposeCuisineImage)
+		assert.ErrorIs(t, err, ErrUploadNotFound)
+	})
+
+	t.Run("使用済み", func(t *testing.T) {
+		st := newTestObjectStore()
+		_, upload := newTestUpload(t, st, model.UploadPurposeUserIcon, "image/png", testPNG, true)
+		upload.ConsumedAt = upload.CompletedAt
+		ur := new(MockUploadRepository)
+		ur.On("GetUploadByID", mock.Anything, uint(1), upload.ID).Return(upload, nil)
+		_, _, err := readUpload(ur, st, 1, upload.ID, model.UploadPurposeUserIcon)
+		assert.ErrorIs(t, err, ErrUploadAlreadyUsed)
+	})
+
+	t.Run("完了後に上書きされた", func(t *testing.T) {
+		st := newTestObjectStore()
+		ur, upload := newTestUpload(t, st, model.UploadPurposeUserIcon, "image/png", testPNG, true)
+		require.NoError(t, st.Put(context.Background(), upload.Key, "image/png", bytes.NewReader(make([]byte, 1<<20))))
+		_, _, err := readUpload(ur, st, 1, upload.ID, model.UploadPurposeUserIcon)
+		assert.ErrorIs(t, err, ErrUploadMismatch)
+	})
+
+	t.Run("同時に使われた場合は一方のみ成功する", func(t *testing.T) {
+		st := newTestObjectStore()
+		ur, upload := newTestUpload(t, st, model.UploadPurposeUserIcon, "image/png", testPNG, true)
+		upload.ReservedBytes = upload.Size
+		su := newTestStorageUsage()
+		require.NoError(t, su.Reserve(1, model.UploadPurposeUserIcon, upload.Size))
+		used := func() int64 {
+			usage, err := su.GetUsage(1)
+			require.NoError(t, err)
+			return usage.UsedBytes
+		}
+		ur.On("ConsumeUpload", upload.ID, mock.Anything).Return(gorm.ErrRecordNotFound).Once()
+		assert.ErrorIs(t, consumeUpload(ur, st, su, upload), ErrUploadAlreadyUsed)
+		assert.Len(t, storedKeys(t, st), 1)
+		assert.Equal(t, upload.Size, used(), "使用した方が減らす")
+
+		ur.On("ConsumeUpload", upload.ID, mock.Anything).Return(errors.New("db error")).Once()
+		assert.Error(t, consumeUpload(ur, st, su, upload))
+
+		ur.On("ConsumeUpload", upload.ID, mock.Anything).Return(nil).Once()
+		assert.NoError(t, consumeUpload(ur, st, su, upload))
+		assert.Empty(t, storedKeys(t, st))
+		assert.Zero(t, used(), "作成時に使用量に加えた分を減らす")
+	})
+}
@@ -0,0 +1,133 @@
+package usecase
+
+// ユーザーアイコンの保存・削除と署名付きURLの発行
+// アイコンは user_icons/<ユーザーID>/<uuid>.<拡張子> に保存し、User.IconURLにはこのオブジェクト名を記録する
+// 以前のローカルファイルのパス（icons/...）は保存先に存在しないため、アイコン未設定として扱う
+// 直接アップロード（upload.go）したアイコンも同じように確認して保存し、使用済みにしてから元のオブジェクトを削除する
+// 保存したアイコンの大きさを保存先の使用量（storage_usage.go）に加え、削除した分は減らす
+
+import (
+	"backend/model"
+	"backend/storage"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidIcon  = errors.New("icon must be a JPEG, PNG, GIF or WebP image")
+	ErrIconTooLarge = errors.New("icon is too large")
+)
+
+const (
+	iconKeyPrefix = "user_icons/"
+	iconURLTTL    = 15 * time.Minute
+	maxIconSize   = 5 << 20 // 5MiB
+
+	storageTimeout = 30 * time.Second
+)
+
+// imageExtensions は受け付ける画像の種類と保存時の拡張子（直接アップロードでも使う）
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// uploadIcon はアイコンを保存し、オブジェクト名を返す
+// 種類はファイル名ではなく内容から判定する
+func (uu *userUsecase) uploadIcon(userID uint, iconFile *multipart.FileHeader) (string, error) {
+	src, err := iconFile.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, maxIconSize+1))
+	if err != nil {
+		return "", err
+	}
+	return uu.storeIcon(userID, data)
+}
+
+// uploadIconFromUpload は完了済みの直接アップロードをアイコンとして保存し、オブジェクト名を返す
+// 同じデータを使用量に二重に加えないよう、アップロードを使用済みにして作成時に加えた分を減らしてから保存する
+func (uu *userUsecase) uploadIconFromUpload(userID uint, uploadID string) (string, error) {
+	upload, data, err := readUpload(uu.up, uu.st, userID, uploadID, model.UploadPurposeUserIcon)
+	if err != nil {
+		return "", err
+	}
+	if err := consumeUpload(uu.up, uu.st, uu.su, upload); err != nil {
+		return "", err
+	}
+	return uu.storeIcon(userID, data)
+}
+
+func (uu *userUsecase) storeIcon(userID uint, data []byte) (string, error) {
+	if len(data) > maxIconSize {
+		return "", ErrIconTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return "", ErrInvalidIcon
+	}
+
+	if err := uu.su.Reserve(userID, model.UploadPurposeUserIcon, int64(len(data))); err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("%s%d/%s%s", iconKeyPrefix, userID, uuid.New().String(), ext)
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	if err := uu.st.Put(ctx, key, contentType, bytes.NewReader(data)); err != nil {
+		uu.su.Release(userID, model.UploadPurposeUserIcon, int64(len(data)))
+		return "", err
+	}
+	return key, nil
+}
+
+// iconURL はアイコンの署名付きURLを返す（未設定・発行に失敗した場合はnil）
+func (uu *userUsecase) iconURL(key *string) *string {
+	if key == nil || !strings.HasPrefix(*key, iconKeyPrefix) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	url, err := uu.st.SignURL(ctx, *key, iconURLTTL)
+	if err != nil {
+		log.Printf("failed to sign icon url %s: %v", *key, err)
+		return nil
+	}
+	return &url
+}
+
+// deleteIcon は置き換えたアイコンを削除し、使用量から減らす（失敗しても更新は取り消さない）
+func (uu *userUsecase) deleteIcon(userID uint, key string) {
+	if !strings.HasPrefix(key, iconKeyPrefix) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	info, err := uu.st.Stat(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		log.Printf("failed to stat icon %s: %v", key, err) // 削除は続ける（使用量のずれは計算し直しで直す）
+	}
+	if err := uu.st.Delete(ctx, key); err != nil {
+		log.Printf("failed to delete icon %s: %v", key, err)
+		return
+	}
+	uu.su.Release(userID, model.UploadPurposeUserIcon, info.Size)
+}
@@ -0,0 +1,240 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"backend/model"
+	"backend/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestObjectStore はメモリ上に保存するテスト用のObjectStoreを返す
+func newTestObjectStore() storage.ObjectStore {
+	return storage.NewMemoryStore()
+}
+
+// storedKeys は保存されているオブジェクトのキーを返す
+func storedKeys(t *testing.T, st storage.ObjectStore) []string {
+	t.Helper()
+	infos, err := st.List(context.Background(), "")
+	assert.NoError(t, err)
+	keys := []string{}
+	for _, info := range infos {
+		keys = append(keys, info.Key)
+	}
+	return keys
+}
+
+var testPNG = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+
+// newTestFileHeader はフォームでアップロードされたファイルを作成する
+func newTestFileHeader(t *testing.T, filename string, data []byte) *multipart.FileHeader {
+	t.Helper()
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	fw, err := w.CreateFormFile("icon", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	form, err := multipart.NewReader(body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return form.File["icon"][0]
+}
+
+func TestUpdateIcon(t *testing.T) {
+	t.Run("古いアイコンを削除して署名付きURLを返す", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		st := newTestObjectStore()
+		al := newTestAuditLogger()
+		usecase := NewUserUsecase(ur, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), al, newTestPasswordHasher(), newTestMailer(), st, new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		oldKey := "user_icons/1/old.png"
+		assert.NoError(t, st.Put(context.Background(), oldKey, "image/png", bytes.NewReader(testPNG)))
+		ur.On("GetUserByID", uint(1)).Return(&model.User{ID: 1, Email: "test@example.com", IconURL: &oldKey}, nil).Once()
+		var saved *model.User
+		ur.On("UpdateUser", mock.Anything).Run(func(args mock.Arguments) {
+			saved = args.Get(0).(*model.User)
+		}).Return(nil).Once()
+
+		// 拡張子ではなく内容から種類を判定する
+		res, err := usecase.Update(model.User{ID: 1}, "", "", newTestFileHeader(t, "icon.exe", testPNG), "", testClient)
+		assert.NoError(t, err)
+		keys := storedKeys(t, st)
+		if assert.Len(t, keys, 1, "置き換えたアイコンは削除する") {
+			assert.Regexp(t, `^user_icons/1/[0-9a-f-]{36}\.png$`, keys[0])
+			assert.Equal(t, keys[0], *saved.IconURL, "オブジェクト名を保存する")
+			info, err := st.Stat(context.Background(), keys[0])
+			assert.NoError(t, err)
+			assert.Equal(t, "image/png", info.ContentType)
+			assert.True(t, strings.HasPrefix(*res.IconURL, "memory:///"+keys[0]+"?expires="), *res.IconURL)
+		}
+		assert.Equal(t, []string{AuditIconChanged}, al.actions())
+	})
+
+	t.Run("画像以外は受け付けない", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		st := newTestObjectStore()
+		usecase := NewUserUsecase(ur, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), st, new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		ur.On("GetUserByID", uint(1)).Return(&model.User{ID: 1}, nil).Once()
+
+		_, err := usecase.Update(model.User{ID: 1}, "", "", newTestFileHeader(t, "icon.png", []byte("<html></html>")), "", testClient)
+		assert.ErrorIs(t, err, ErrInvalidIcon)
+		assert.Empty(t, storedKeys(t, st))
+		ur.AssertNotCalled(t, "UpdateUser", mock.Anything)
+	})
+
+	t.Run("更新に失敗したらアップロードしたアイコンを削除する", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		st := newTestObjectStore()
+		usecase := NewUserUsecase(ur, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), st, new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		ur.On("GetUserByID", uint(1)).Return(&model.User{ID: 1}, nil).Once()
+		ur.On("UpdateUser", mock.Anything).Return(errors.New("db error")).Once()
+
+		_, err := usecase.Update(model.User{ID: 1}, "", "", newTestFileHeader(t, "icon.png", testPNG), "", testClient)
+		assert.Error(t, err)
+		assert.Empty(t, storedKeys(t, st))
+	})
+
+	t.Run("置き換えたアイコンの大きさを使用量から減らす", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		st := newTestObjectStore()
+		su := NewStorageUsageUsecase(newMemoryStorageUsageRepository(), 0)
+		usecase := NewUserUsecase(ur, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), st, new(MockUploadRepository), su, testSecret, testFEURL)
+		oldKey := "user_icons/1/old.png"
+		assert.NoError(t, st.Put(context.Background(), oldKey, "image/png", bytes.NewReader(testPNG)))
+		assert.NoError(t, su.Reserve(1, model.UploadPurposeUserIcon, int64(len(testPNG))))
+		ur.On("GetUserByID", uint(1)).Return(&model.User{ID: 1, IconURL: &oldKey}, nil).Once()
+		ur.On("UpdateUser", mock.Anything).Return(nil).Once()
+
+		_, err := usecase.Update(model.User{ID: 1}, "", "", newTestFileHeader(t, "icon.png", testPNG), "", testClient)
+		assert.NoError(t, err)
+		usage, err := su.GetUsage(1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(testPNG)), usage.UserIconBytes)
+	})
+
+	t.Run("上限を超える場合は保存しない", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		st := newTestObjectStore()
+		su := NewStorageUsageUsecase(newMemoryStorageUsageRepository(), int64(len(testPNG))-1)
+		usecase := NewUserUsecase(ur, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), st, new(MockUploadRepository), su, testSecret, testFEURL)
+		ur.On("GetUserByID", uint(1)).Return(&model.User{ID: 1}, nil).Once()
+
+		_, err := usecase.Update(model.User{ID: 1}, "", "", newTestFileHeader(t, "icon.png", testPNG), "", testClient)
+		assert.ErrorIs(t, err, ErrStorageQuotaExceeded)
+		assert.Empty(t, storedKeys(t, st))
+		ur.AssertNotCalled(t, "UpdateUser", mock.Anything)
+	})
+
+	t.Run("以前のローカルファイルのパスはアイコン未設定として扱う", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		st := newTestObjectStore()
+		usecase := NewUserUsecase(ur, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), st, new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		legacy := "icons/abc.png"
+		ur.On("GetUserByID", uint(1)).Return(&model.User{ID: 1, IconURL: &legacy}, nil).Once()
+		ur.On("UpdateUser", mock.Anything).Return(nil).Once()
+
+		res, err := usecase.Update(model.User{ID: 1}, "", "", newTestFileHeader(t, "icon.png", testPNG), "", testClient)
+		assert.NoError(t, err)
+		assert.Len(t, storedKeys(t, st), 1)
+		assert.NotNil(t, res.IconURL)
+		assert.Nil(t, usecase.(*userUsecase).iconURL(&legacy))
+	})
+	t.Run("直接アップロードしたアイコンに変更する", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		st := newTestObjectStore()
+		up, upload := newTestUpload(t, st, model.UploadPurposeUserIcon, "image/png", testPNG, true)
+		up.On("ConsumeUpload", upload.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		usecase := NewUserUsecase(ur, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), st, up, newTestStorageUsage(), testSecret, testFEURL)
+		ur.On("GetUserByID", uint(1)).Return(&model.User{ID: 1}, nil).Once()
+		var saved *model.User
+		ur.On("UpdateUser", mock.Anything).Run(func(args mock.Arguments) {
+			saved = args.Get(0).(*model.User)
+		}).Return(nil).Once()
+
+		res, err := usecase.Update(model.User{ID: 1}, "", "", nil, upload.ID, testClient)
+		assert.NoError(t, err)
+		keys := storedKeys(t, st)
+		if assert.Len(t, keys, 1, "元のオブジェクトは削除する") {
+			assert.Regexp(t, `^user_icons/1/[0-9a-f-]{36}\.png$`, keys[0])
+			assert.Equal(t, keys[0], *saved.IconURL)
+		}
+		assert.NotNil(t, res.IconURL)
+		up.AssertExpectations(t)
+	})
+
+	t.Run("直接アップロードの分を減らしてから保存する", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		st := newTestObjectStore()
+		size := int64(len(testPNG))
+		su := NewStorageUsageUsecase(newMemoryStorageUsageRepository(), size*3/2) // アップロードと保存したアイコンの合計は上限を超える
+		up, upload := newTestReservedUpload(t, st, su, model.UploadPurposeUserIcon, "image/png", testPNG)
+		up.On("ConsumeUpload", upload.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		usecase := NewUserUsecase(ur, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), st, up, su, testSecret, testFEURL)
+		ur.On("GetUserByID", uint(1)).Return(&model.User{ID: 1}, nil).Once()
+		ur.On("UpdateUser", mock.Anything).Return(nil).Once()
+
+		_, err := usecase.Update(model.User{ID: 1}, "", "", nil, upload.ID, testClient)
+		require.NoError(t, err)
+		usage, err := su.GetUsage(1)
+		require.NoError(t, err)
+		assert.Equal(t, size, usage.UserIconBytes)
+	})
+
+	t.Run("tusでアップロードした分を減らしてから保存する", func(t *testing.T) {
+		st := newTestObjectStore()
+		size := int64(len(testPNG))
+		su := NewStorageUsageUsecase(newMemoryStorageUsageRepository(), size*3/2)
+		tusUpload := testTusUpload(model.UploadPurposeUserIcon, size)
+		require.NoError(t, su.Reserve(1, tusUpload.Purpose, tusUpload.ReservedBytes))
+		tr := newTestTusUpload(tusUpload)
+		tr.On("AppendTusChunk", "tus-1", int64(0), size, mock.AnythingOfType("string")).Return(nil).Once()
+		var completed *model.Upload
+		tr.On("CompleteTusUpload", "tus-1", mock.AnythingOfType("time.Time"), mock.Anything).Run(func(args mock.Arguments) {
+			completed = args.Get(2).(*model.Upload)
+		}).Return(nil).Once()
+		_, err := NewTusUsecase(tr, st, 10<<20, su).WriteChunk(1, "tus-1", 0, "", bytes.NewReader(testPNG))
+		require.NoError(t, err)
+		require.NotNil(t, completed)
+
+		ur := new(MockUserRepository)
+		up := newTestUploadRepository(*completed)
+		up.On("ConsumeUpload", completed.ID, mock.AnythingOfType("time.Time")).Return(nil).Once()
+		usecase := NewUserUsecase(ur, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), st, up, su, testSecret, testFEURL)
+		ur.On("GetUserByID", uint(1)).Return(&model.User{ID: 1}, nil).Once()
+		ur.On("UpdateUser", mock.Anything).Return(nil).Once()
+
+		_, err = usecase.Update(model.User{ID: 1}, "", "", nil, completed.ID, testClient)
+		require.NoError(t, err)
+		usage, err := su.GetUsage(1)
+		require.NoError(t, err)
+		assert.Equal(t, size, usage.UserIconBytes)
+	})
+
+	t.Run("完了していない直接アップロードは使えない", func(t *testing.T) {
+		ur := new(MockUserRepository)
+		st := newTestObjectStore()
+		up, upload := newTestUpload(t, st, model.UploadPurposeUserIcon, "image/png", testPNG, false)
+		usecase := NewUserUsecase(ur, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), st, up, newTestStorageUsage(), testSecret, testFEURL)
+		ur.On("GetUserByID", uint(1)).Return(&model.User{ID: 1}, nil).Once()
+
+		_, err := usecase.Update(model.User{ID: 1}, "", "", nil, upload.ID, testClient)
+		assert.ErrorIs(t, err, ErrUploadNotCompleted)
+		ur.AssertNotCalled(t, "UpdateUser", mock.Anything)
+	})
+}
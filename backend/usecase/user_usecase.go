@@ -3,52 +3,86 @@ package usecase
 // サインアップ、ログイン、更新処理を実装
 // サインアップでは、user_validatorを呼び出したのち、user_repositoryのユーザーテーブル作成メソッドを呼び出している
 // ログインでは、user_repositoryのemailでのユーザー検索メソッドを呼び出したのち、jwtトークンの検証を行っている
-// 更新処理では、更新情報があればデータの更新を行っている
+// 更新処理では、更新情報があればデータの更新を行っている（パスワードはChangePasswordでのみ変更できる）
+// メールアドレスは確認待ちとして保存し、確認リンクを開いた時に反映する（email_change.go）
+// アイコンはstorage.ObjectStoreに保存してオブジェクト名を記録し、レスポンスでは期限の短い署名付きURLを返す（user_icon.go）
+// パスワード変更では、現在のパスワードを確認したのち、他のセッションを失効させて通知メールを送る
+// ログイン・ログアウト・パスワードなどの変更はセキュリティイベントとして記録する
+// パスワードはauth.PasswordHasherでハッシュ化し、古い方式のハッシュはログイン成功時に再ハッシュする
 
 import (
+	"backend/auth"
+	"backend/mail"
 	"backend/model"
 	"backend/repository"
+	"backend/storage"
 	"backend/validator"
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
+	"log"
 	"mime/multipart"
-	"os"
-	"path/filepath"
 	"strings"
+	"sync"
 	"time"
-
-	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // エラー定義を追加
 var (
-	ErrUserNotFound      = errors.New("user not found")
-	ErrInvalidPassword   = errors.New("invalid password")
-	ErrUserAlreadyExists = errors.New("user already exists")
+	ErrUserNotFound          = errors.New("user not found")
+	ErrInvalidPassword       = errors.New("invalid password")
+	ErrUserAlreadyExists     = errors.New("user already exists")
 	ErrInvalidPasswordLength = errors.New("password must be at least 6 characters")
+	// アカウントの有無を推測されないよう、ログイン失敗はこのエラーに統一する
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrIncorrectPassword  = errors.New("current password is incorrect")
 )
 
 type IUserUsecase interface {
 	SignUp(user model.User) (model.UserResponse, error)
-	Login(user model.User) (string, error)
-	Update(user model.User, newEmail string, newName string, newPassword string, iconFile *multipart.FileHeader) (model.UserResponse, error)
+	Login(user model.User, client model.ClientInfo) (model.LoginResult, error)
+	Logout(userID uint, sessionID string, client model.ClientInfo) error
+	// アイコンはファイル（iconFile）か直接アップロードのID（iconUploadID）で指定する
+	Update(user model.User, newEmail string, newName string, iconFile *multipart.FileHeader, iconUploadID string, client model.ClientInfo) (model.UserResponse, error)
+	ChangePassword(userID uint, sessionID string, req model.PasswordChangeRequest, client model.ClientInfo) error
+	ConfirmEmailChange(token string, client model.ClientInfo) error
+	UndoEmailChange(token string, client model.ClientInfo) error
 }
 
 type userUsecase struct {
 	ur repository.IUserRepository
+	er repository.IEmailChangeRepository
 	uv validator.IUserValidator
+	lg ILoginGuard
+	sm ISessionManager
+	al IAuditLogger
+	ph auth.PasswordHasher
+	ml mail.Mailer
+	st storage.ObjectStore
+	up repository.IUploadRepository
+	su IStorageUsageUsecase
+
+	secret string // jwtの署名に使う鍵
+	feURL  string // メールに記載するリンクのフロントエンドのURL
+
+	dummyHashOnce sync.Once
+	dummyHash     string
+}
+
+func NewUserUsecase(ur repository.IUserRepository, er repository.IEmailChangeRepository, uv validator.IUserValidator, lg ILoginGuard, sm ISessionManager, al IAuditLogger, ph auth.PasswordHasher, ml mail.Mailer, st storage.ObjectStore, up repository.IUploadRepository, su IStorageUsageUsecase, secret string, feURL string) IUserUsecase {
+	return &userUsecase{ur: ur, er: er, uv: uv, lg: lg, sm: sm, al: al, ph: ph, ml: ml, st: st, up: up, su: su, secret: secret, feURL: feURL}
 }
 
-func NewUserUsecase(ur repository.IUserRepository, uv validator.IUserValidator) IUserUsecase {
-	return &userUsecase{ur, uv}
+// getDummyHash は存在しないアカウントでのログイン時に比較するハッシュを返す
+// 現在の方式・パラメーターで作成し、応答時間が実在するアカウントと変わらないようにする
+func (uu *userUsecase) getDummyHash() string {
+	uu.dummyHashOnce.Do(func() {
+		uu.dummyHash, _ = uu.ph.Hash("dummy-password")
+	})
+	return uu.dummyHash
 }
 
 func (uu *userUsecase) SignUp(user model.User) (model.UserResponse, error) {
-	if err := uu.uv.UserValidate(user); err != nil {
+	if err := uu.uv.SignUpValidate(user); err != nil {
 		return model.UserResponse{}, err
 	}
 
@@ -59,15 +93,14 @@ func (uu *userUsecase) SignUp(user model.User) (model.UserResponse, error) {
 		return model.UserResponse{}, ErrUserAlreadyExists
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), 10)
+	hash, err := uu.ph.Hash(user.Password)
 	if err != nil {
 		return model.UserResponse{}, err
 	}
-	newUser := model.User{Name: user.Name, Email: user.Email, Password: string(hash)}
+	newUser := model.User{Name: user.Name, Email: user.Email, Password: hash}
 	if err := uu.ur.CreateUser(&newUser); err != nil {
 		// データベースエラーの場合も、重複に関するエラーかどうかをチェック
-		if strings.Contains(strings.ToLower(err.Error()), "duplicate") ||
-			strings.Contains(strings.ToLower(err.Error()), "unique violation") {
+		if isUniqueViolation(err) {
 			return model.UserResponse{}, ErrUserAlreadyExists
 		}
 		return model.UserResponse{}, err
@@ -76,120 +109,199 @@ func (uu *userUsecase) SignUp(user model.User) (model.UserResponse, error) {
 		ID:      newUser.ID,
 		Name:    newUser.Name,
 		Email:   newUser.Email,
-		IconURL: newUser.IconURL,
+		IconURL: uu.iconURL(newUser.IconURL),
 	}
 	return resUser, nil
 }
 
-func (uu *userUsecase) Login(user model.User) (string, error) {
+func (uu *userUsecase) Login(user model.User, client model.ClientInfo) (model.LoginResult, error) {
 	if err := uu.uv.UserValidate(user); err != nil {
 		// パスワードの長さが不足している場合の特別なエラーハンドリング
-        if strings.Contains(err.Error(), "limited min 6") {
-            return "", ErrInvalidPasswordLength
-        }
-        return "", err
+		if strings.Contains(err.Error(), "limited min 6") {
+			return model.LoginResult{}, ErrInvalidPasswordLength
+		}
+		return model.LoginResult{}, err
+	}
+	// ロック中であればパスワードの検証を行わない
+	if err := uu.lg.Check(user.Email, client.IP); err != nil {
+		logEvent(uu.al, AuditLoginFailed, nil, nil, client, map[string]interface{}{"email": user.Email, "reason": "locked"})
+		return model.LoginResult{}, err
 	}
 	storedUser := model.User{} // 空のユーザーオブジェクト
 	if err := uu.ur.GetUserByEmail(&storedUser, user.Email); err != nil {
-		return "", ErrUserNotFound
+		// 存在しないアカウントでも応答時間が変わらないようにハッシュの比較を行う
+		_, _, _ = uu.ph.Verify(uu.getDummyHash(), user.Password)
+		uu.lg.Fail(user.Email, nil, client)
+		logEvent(uu.al, AuditLoginFailed, nil, nil, client, map[string]interface{}{"email": user.Email, "reason": "unknown_user"})
+		return model.LoginResult{}, fmt.Errorf("%w: %w", ErrInvalidCredentials, ErrUserNotFound)
 	}
-	err := bcrypt.CompareHashAndPassword([]byte(storedUser.Password), []byte(user.Password)) // パスワードの検証
-	if err != nil {
+	ok, needsRehash, err := uu.ph.Verify(storedUser.Password, user.Password) // パスワードの検証
+	if err != nil && !errors.Is(err, auth.ErrUnknownHashFormat) {
+		return model.LoginResult{}, err
+	}
+	if !ok {
+		uu.lg.Fail(user.Email, &storedUser.ID, client)
+		logEvent(uu.al, AuditLoginFailed, nil, &storedUser.ID, client, map[string]interface{}{"reason": "invalid_password"})
 		// エラーをラップすることで、errors.Isでの判定が成功するようにする
-		return "", fmt.Errorf("password mismatch: %w", ErrInvalidPassword)
+		return model.LoginResult{}, fmt.Errorf("%w: %w", ErrInvalidCredentials, ErrInvalidPassword)
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": storedUser.ID,
-		"exp":     time.Now().Add(time.Hour * 12).Unix(), // jwtの有効期限
-	})
-	tokenString, err := token.SignedString([]byte(os.Getenv("SECRET"))) // jwtトークンの生成
-	if err != nil {
-		return "", err
+	// 無効化されたアカウントはパスワードが正しい場合のみ通知する
+	if storedUser.DisabledAt != nil {
+		logEvent(uu.al, AuditLoginFailed, nil, &storedUser.ID, client, map[string]interface{}{"reason": "account_disabled"})
+		return model.LoginResult{}, ErrAccountDisabled
 	}
-	return tokenString, nil
-}
-
-func (uu *userUsecase) Update(user model.User, newEmail string, newName string, newPassword string, iconFile *multipart.FileHeader) (model.UserResponse, error) {
-
-	if newPassword != "" {
-		hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), 10)
-		if err != nil {
-			return model.UserResponse{}, err
-		}
-		user.Password = string(hash)
+	// 平文のパスワードが手元にあるのはこの時だけなので、古い方式のハッシュはここで置き換える
+	if needsRehash {
+		uu.rehash(storedUser.ID, user.Password)
 	}
 
-	if iconFile != nil {
-		src, err := iconFile.Open()
+	// 二要素認証が有効な場合はセッションを発行せず、コードの入力を待つ
+	// 失敗回数のリセットは二要素認証の成功時に行う
+	if storedUser.TOTPEnabled {
+		mfaToken, err := auth.Sign(auth.NewClaims(storedUser.ID, auth.TokenTypeMFAPending, mfaTokenTTL), uu.secret)
 		if err != nil {
-			return model.UserResponse{}, err
+			return model.LoginResult{}, err
 		}
-		defer src.Close()
+		return model.LoginResult{MFARequired: true, MFAToken: mfaToken}, nil
+	}
 
-		data, err := io.ReadAll(src)
-		if err != nil {
-			return model.UserResponse{}, err
-		}
+	uu.lg.Succeed(user.Email)
+	tokenString, err := uu.sm.Issue(&storedUser, client) // jwtトークンの生成
+	if err != nil {
+		return model.LoginResult{}, err
+	}
+	logUserEvent(uu.al, AuditLogin, storedUser.ID, client, map[string]interface{}{"method": "password"})
+	return model.LoginResult{Token: tokenString}, nil
+}
 
-		hasher := sha256.New()
-		hasher.Write(data)
-		hashValue := hex.EncodeToString(hasher.Sum(nil))
+// rehash は現在の方式でハッシュし直して保存する（失敗してもログインは続ける）
+func (uu *userUsecase) rehash(userID uint, password string) {
+	hash, err := uu.ph.Hash(password)
+	if err == nil {
+		err = uu.ur.UpdatePassword(userID, hash)
+	}
+	if err != nil {
+		log.Printf("failed to rehash password for user %d: %v", userID, err)
+	}
+}
 
-		ext := filepath.Ext(iconFile.Filename)
+func (uu *userUsecase) Logout(userID uint, sessionID string, client model.ClientInfo) error {
+	if err := uu.sm.Revoke(sessionID); err != nil {
+		return err
+	}
+	logUserEvent(uu.al, AuditLogout, userID, client, nil)
+	return nil
+}
 
-		IconURL := "icons/" + hashValue + ext
+func (uu *userUsecase) ChangePassword(userID uint, sessionID string, req model.PasswordChangeRequest, client model.ClientInfo) error {
+	user, err := uu.ur.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	// 新しいパスワードにメールアドレスや名前が含まれていないかも確認する
+	if err := uu.uv.PasswordChangeValidate(req, *user); err != nil {
+		return err
+	}
+	// 現在のパスワードの総当たりもログインと同じロックの対象にする
+	if err := uu.lg.Check(user.Email, client.IP); err != nil {
+		return err
+	}
+	ok, _, err := uu.ph.Verify(user.Password, req.CurrentPassword)
+	if err != nil && !errors.Is(err, auth.ErrUnknownHashFormat) {
+		return err
+	}
+	if !ok {
+		uu.lg.Fail(user.Email, &userID, client)
+		return ErrIncorrectPassword
+	}
 
-		const baseDir = "./user_images"
+	hash, err := uu.ph.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	if err := uu.ur.UpdatePassword(userID, hash); err != nil {
+		return err
+	}
+	// 漏えいしたパスワードで作られたセッションを残さないよう、この端末以外はログアウトさせる
+	if err := uu.sm.RevokeOthers(userID, sessionID); err != nil {
+		return err
+	}
+	logUserEvent(uu.al, AuditPasswordChanged, userID, client, nil)
+	sendNotification(uu.ml, passwordChangedMessage(user.Email, user.Name, time.Now(), client.IP, client.UserAgent))
+	return nil
+}
 
-		safeIconURL := filepath.Clean(IconURL)
-		if !strings.HasPrefix(filepath.Clean(filepath.Join(baseDir, safeIconURL)), baseDir) {
-			return model.UserResponse{}, fmt.Errorf("invalid path: potential directory traversal")
+func (uu *userUsecase) Update(user model.User, newEmail string, newName string, iconFile *multipart.FileHeader, iconUploadID string, client model.ClientInfo) (model.UserResponse, error) {
+	// メールアドレスの変更は確認待ちにするため、変更前の値を取得して使用中でないかを先に確認する
+	// アイコンの変更では、置き換える前のアイコンを削除するために取得する
+	var current *model.User
+	if newEmail != "" || iconFile != nil || iconUploadID != "" {
+		var err error
+		current, err = uu.ur.GetUserByID(user.ID)
+		if err != nil {
+			return model.UserResponse{}, err
 		}
-
-		if err := os.MkdirAll(baseDir, 0755); err != nil {
-			return model.UserResponse{}, fmt.Errorf("failed to create directory: %v", err)
+	}
+	if newEmail != "" {
+		if newEmail == current.Email {
+			newEmail = ""
+		} else if err := uu.checkEmailAvailable(newEmail); err != nil {
+			return model.UserResponse{}, err
 		}
+	}
 
-		fullPath := filepath.Join(baseDir, safeIconURL)
-		dst, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	var newIcon *string
+	if iconFile != nil || iconUploadID != "" {
+		var key string
+		var err error
+		if iconUploadID != "" {
+			key, err = uu.uploadIconFromUpload(user.ID, iconUploadID)
+		} else {
+			key, err = uu.uploadIcon(user.ID, iconFile)
+		}
 		if err != nil {
 			return model.UserResponse{}, err
 		}
-		defer func() {
-			if cerr := dst.Close(); cerr != nil && err == nil {
-				err = cerr
-			}
-		}()
-
-		if _, err := dst.Write(data); err != nil {
-			return model.UserResponse{}, nil
-		}
-
-		user.IconURL = &IconURL
-
+		newIcon = &key
 	}
 
 	updatedUser := model.User{
-		ID:       user.ID,
-		Name:     newName,
-		Email:    newEmail,
-		Password: newPassword,
-		IconURL:  user.IconURL,
+		ID:      user.ID,
+		Name:    newName,
+		IconURL: newIcon,
 	}
-	// log.Print("updateUser:", updatedUser)
 
 	if err := uu.ur.UpdateUser(&updatedUser); err != nil {
+		if newIcon != nil {
+			uu.deleteIcon(user.ID, *newIcon) // 保存できなかったアイコンは残さない
+		}
 		return model.UserResponse{}, err
 	}
 
 	resUser := model.UserResponse{
-		ID:      updatedUser.ID,
-		Name:    updatedUser.Name,
-		Email:   updatedUser.Email,
-		IconURL: updatedUser.IconURL,
+		ID:   updatedUser.ID,
+		Name: updatedUser.Name,
+	}
+	if current != nil {
+		resUser.Email = current.Email
+		resUser.IconURL = uu.iconURL(current.IconURL)
+	}
+	if newIcon != nil {
+		if current.IconURL != nil {
+			uu.deleteIcon(user.ID, *current.IconURL)
+		}
+		resUser.IconURL = uu.iconURL(newIcon)
+		logUserEvent(uu.al, AuditIconChanged, user.ID, client, nil)
+	}
+	if newEmail != "" {
+		if newName != "" {
+			current.Name = newName
+		}
+		if err := uu.requestEmailChange(current, newEmail, client); err != nil {
+			return model.UserResponse{}, err
+		}
+		resUser.PendingEmail = newEmail
 	}
-	// log.Print("resUser:", resUser)
 
 	return resUser, nil
-
 }
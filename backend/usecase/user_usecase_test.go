@@ -1,14 +1,20 @@
 package usecase
 
 import (
+	"backend/auth"
 	"backend/model"
+	"backend/repository"
 	"backend/validator"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
+	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // MockUserRepository はUserRepositoryのモック
@@ -39,6 +45,36 @@ func (m *MockUserRepository) UpdateUser(user *model.User) error {
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) UpdatePassword(userID uint, passwordHash string) error {
+	args := m.Called(userID, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdateTwoFactor(user *model.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdateTOTPLastStep(userID uint, step int64) (bool, error) {
+	args := m.Called(userID, step)
+	return args.Bool(0), args.Error(1)
+}
+
+var testClient = model.ClientInfo{IP: "192.0.2.1", UserAgent: "test"}
+
+// newTestPasswordHasher は計算量を抑えたパラメーターのハッシュ関数を返す
+func newTestPasswordHasher() auth.PasswordHasher {
+	return auth.NewArgon2Hasher(auth.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+}
+
+func isArgon2Hash(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func newTestLoginGuard() ILoginGuard {
+	return NewLoginGuard(repository.NewMemoryLoginAttemptRepository(), newTestAuditLogger(), DefaultLockoutPolicy())
+}
+
 type MockUserValidator struct {
 	mock.Mock
 }
@@ -48,6 +84,26 @@ func (m *MockUserValidator) UserValidate(user model.User) error {
 	return args.Error(0)
 }
 
+func (m *MockUserValidator) SignUpValidate(user model.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *MockUserValidator) PasswordChangeValidate(req model.PasswordChangeRequest, user model.User) error {
+	args := m.Called(req, user)
+	return args.Error(0)
+}
+
+// newTestUserValidator は既定のパスワードポリシーのバリデーター
+func newTestUserValidator() validator.IUserValidator {
+	return validator.NewUserValidator(validator.DefaultPasswordPolicy())
+}
+
+func (m *MockUserValidator) EmailValidate(email string) error {
+	args := m.Called(email)
+	return args.Error(0)
+}
+
 func TestSignUp(t *testing.T) {
 	// テストケース1: 正常なサインアップ（ユーザーが存在しない）
 	t.Run("success", func(t *testing.T) {
@@ -61,7 +117,7 @@ func TestSignUp(t *testing.T) {
 		}
 
 		// バリデーションが成功することを設定
-		mockValidator.On("UserValidate", mock.AnythingOfType("model.User")).Return(nil)
+		mockValidator.On("SignUpValidate", mock.AnythingOfType("model.User")).Return(nil)
 
 		// GetUserByEmailがユーザーが見つからないエラーを返すように設定（正常：既存ユーザーがいない）
 		mockRepo.On("GetUserByEmail", mock.AnythingOfType("*model.User"), "test@example.com").Return(errors.New("user not found"))
@@ -72,7 +128,7 @@ func TestSignUp(t *testing.T) {
 			userArg.ID = 1 // IDをセット
 		})
 
-		usecase := NewUserUsecase(mockRepo, mockValidator)
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), mockValidator, newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
 		res, err := usecase.SignUp(user)
 
 		assert.NoError(t, err)
@@ -95,12 +151,12 @@ func TestSignUp(t *testing.T) {
 		}
 
 		// バリデーションが成功することを設定
-		mockValidator.On("UserValidate", mock.AnythingOfType("model.User")).Return(nil)
+		mockValidator.On("SignUpValidate", mock.AnythingOfType("model.User")).Return(nil)
 
 		// GetUserByEmailがnilを返す（異常：ユーザーが既に存在する）
 		mockRepo.On("GetUserByEmail", mock.AnythingOfType("*model.User"), "existing@example.com").Return(nil)
 
-		usecase := NewUserUsecase(mockRepo, mockValidator)
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), mockValidator, newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
 		_, err := usecase.SignUp(user)
 
 		assert.Error(t, err)
@@ -121,9 +177,9 @@ func TestSignUp(t *testing.T) {
 		}
 
 		validationErr := errors.New("validation error")
-		mockValidator.On("UserValidate", mock.AnythingOfType("model.User")).Return(validationErr)
+		mockValidator.On("SignUpValidate", mock.AnythingOfType("model.User")).Return(validationErr)
 
-		usecase := NewUserUsecase(mockRepo, mockValidator)
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), mockValidator, newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
 		_, err := usecase.SignUp(user)
 
 		assert.Error(t, err)
@@ -135,8 +191,8 @@ func TestSignUp(t *testing.T) {
 func TestLogin(t *testing.T) {
 	// モックの準備
 	mockRepo := new(MockUserRepository)
-	validator := validator.NewUserValidator()
-	usecase := NewUserUsecase(mockRepo, validator)
+	validator := newTestUserValidator()
+	usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), validator, newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), newTestPasswordHasher(), newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
 
 	// 正しいケース
 	t.Run("valid login", func(t *testing.T) {
@@ -157,10 +213,13 @@ func TestLogin(t *testing.T) {
 				arg.Email = user.Email
 				arg.Password = string(hashedPassword)
 			}).Return(nil).Once()
+		// bcryptのハッシュはargon2idで再ハッシュされる
+		mockRepo.On("UpdatePassword", uint(1), mock.MatchedBy(isArgon2Hash)).Return(nil).Once()
 
-		tokenString, err := usecase.Login(user)
+		result, err := usecase.Login(user, testClient)
 		assert.NoError(t, err, "unexpected error in valid login: %v", err)
-		assert.NotEmpty(t, tokenString, "token must not be empty")
+		assert.NotEmpty(t, result.Token, "token must not be empty")
+		assert.False(t, result.MFARequired)
 	})
 
 	// 存在しないユーザーの場合
@@ -173,9 +232,10 @@ func TestLogin(t *testing.T) {
 		mockRepo.On("GetUserByEmail", mock.AnythingOfType("*model.User"), noexistuser.Email).
 			Return(errors.New("user not found")).Once()
 
-		_, err := usecase.Login(noexistuser)
+		_, err := usecase.Login(noexistuser, testClient)
 		assert.Error(t, err, "expected error for non-existent user")
 		assert.Truef(t, errors.Is(err, ErrUserNotFound), "expected ErrUserNotFound, but got: %v", err)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
 	})
 
 	// パスワードが間違っている場合
@@ -198,12 +258,381 @@ func TestLogin(t *testing.T) {
 				arg.Password = string(hashedPassword)
 			}).Return(nil).Once()
 
-		var token string                         // 新しい変数を宣言
-		token, err = usecase.Login(misspassuser) // := ではなく = を使用
+		var result model.LoginResult                          // 新しい変数を宣言
+		result, err = usecase.Login(misspassuser, testClient) // := ではなく = を使用
 		assert.Error(t, err, "expected error for invalid password")
-		assert.Empty(t, token, "token should be empty when login fails")
+		assert.Empty(t, result.Token, "token should be empty when login fails")
 		assert.Truef(t, errors.Is(err, ErrInvalidPassword), "expected ErrInvalidPassword, but got: %v", err)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	// 二要素認証が有効な場合はセッションの代わりにMFAトークンを返す
+	t.Run("mfa required", func(t *testing.T) {
+		mfaUser := model.User{
+			Email:    "mfa@example.com",
+			Password: "password123",
+		}
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte("password123"), 10)
+		if err != nil {
+			t.Fatal("failed to generate password hash:", err)
+		}
+		mockRepo.On("GetUserByEmail", mock.AnythingOfType("*model.User"), mfaUser.Email).
+			Run(func(args mock.Arguments) {
+				arg := args.Get(0).(*model.User)
+				arg.ID = 3
+				arg.Email = mfaUser.Email
+				arg.Password = string(hashedPassword)
+				arg.TOTPEnabled = true
+			}).Return(nil).Once()
+		mockRepo.On("UpdatePassword", uint(3), mock.MatchedBy(isArgon2Hash)).Return(nil).Once()
+
+		result, err := usecase.Login(mfaUser, testClient)
+		assert.NoError(t, err)
+		assert.True(t, result.MFARequired)
+		assert.Empty(t, result.Token)
+		assert.NotEmpty(t, result.MFAToken)
+	})
+
+	// 無効化されたアカウントはログインできない
+	t.Run("disabled account", func(t *testing.T) {
+		disabledUser := model.User{
+			Email:    "disabled@example.com",
+			Password: "password123",
+		}
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte("password123"), 10)
+		if err != nil {
+			t.Fatal("failed to generate password hash:", err)
+		}
+		disabledAt := time.Now()
+		mockRepo.On("GetUserByEmail", mock.AnythingOfType("*model.User"), disabledUser.Email).
+			Run(func(args mock.Arguments) {
+				arg := args.Get(0).(*model.User)
+				arg.ID = 4
+				arg.Email = disabledUser.Email
+				arg.Password = string(hashedPassword)
+				arg.DisabledAt = &disabledAt
+			}).Return(nil).Once()
+
+		result, err := usecase.Login(disabledUser, testClient)
+		assert.ErrorIs(t, err, ErrAccountDisabled)
+		assert.Empty(t, result.Token)
+	})
+
+	// 失敗が続いた場合はパスワードの検証前にロックされる
+	t.Run("locked out", func(t *testing.T) {
+		lockedUser := model.User{
+			Email:    "locked@example.com",
+			Password: "password123",
+		}
+		mockRepo.On("GetUserByEmail", mock.AnythingOfType("*model.User"), lockedUser.Email).
+			Return(errors.New("user not found")).Times(5)
+
+		for i := 0; i < 5; i++ {
+			_, err := usecase.Login(lockedUser, testClient)
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		}
+		_, err := usecase.Login(lockedUser, testClient)
+		assert.ErrorIs(t, err, ErrTooManyAttempts)
 	})
 
 	mockRepo.AssertExpectations(t)
 }
+
+func TestLoginSecurityEvents(t *testing.T) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("password123"), 10)
+	if err != nil {
+		t.Fatal("failed to generate password hash:", err)
+	}
+	stored := func(args mock.Arguments) {
+		arg := args.Get(0).(*model.User)
+		arg.ID = 1
+		arg.Email = "test@example.com"
+		arg.Password = string(hashedPassword)
+	}
+
+	t.Run("成功", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		al := newTestAuditLogger()
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), al, newTestPasswordHasher(), newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		mockRepo.On("GetUserByEmail", mock.Anything, "test@example.com").Run(stored).Return(nil).Once()
+		mockRepo.On("UpdatePassword", uint(1), mock.Anything).Return(nil).Once()
+
+		_, err := usecase.Login(model.User{Email: "test@example.com", Password: "password123"}, testClient)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{AuditLogin}, al.actions())
+		assert.Equal(t, uint(1), *al.events[0].ActorID)
+		assert.Equal(t, uint(1), *al.events[0].TargetUserID)
+		assert.Equal(t, testClient.IP, al.events[0].IP)
+		assert.Equal(t, testClient.UserAgent, al.events[0].UserAgent)
+		assert.JSONEq(t, `{"method":"password"}`, al.events[0].Metadata)
+	})
+
+	t.Run("パスワードの誤り", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		al := newTestAuditLogger()
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), al, newTestPasswordHasher(), newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		mockRepo.On("GetUserByEmail", mock.Anything, "test@example.com").Run(stored).Return(nil).Once()
+
+		_, err := usecase.Login(model.User{Email: "test@example.com", Password: "wrong-password"}, testClient)
+		assert.Error(t, err)
+		assert.Equal(t, []string{AuditLoginFailed}, al.actions())
+		assert.Nil(t, al.events[0].ActorID, "未認証の操作")
+		assert.Equal(t, uint(1), *al.events[0].TargetUserID, "本人のイベントとして表示する")
+	})
+
+	t.Run("ロック", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		al := newTestAuditLogger()
+		lg := NewLoginGuard(repository.NewMemoryLoginAttemptRepository(), al, DefaultLockoutPolicy())
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), newTestUserValidator(), lg, newTestSessionManager(), al, newTestPasswordHasher(), newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		mockRepo.On("GetUserByEmail", mock.Anything, "test@example.com").Run(stored).Return(nil).Times(5)
+
+		for i := 0; i < 5; i++ {
+			_, _ = usecase.Login(model.User{Email: "test@example.com", Password: "wrong-password"}, testClient)
+		}
+		_, err := usecase.Login(model.User{Email: "test@example.com", Password: "password123"}, testClient)
+		assert.ErrorIs(t, err, ErrTooManyAttempts)
+
+		actions := al.actions()
+		assert.Equal(t, AuditLoginLocked, actions[4], "5回目の失敗でロックを記録する")
+		assert.Equal(t, uint(1), *al.events[4].TargetUserID)
+		assert.Equal(t, AuditLoginFailed, actions[6], "ロック中の試行も失敗として記録する")
+		assert.JSONEq(t, `{"email":"test@example.com","reason":"locked"}`, al.events[6].Metadata)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestLogout(t *testing.T) {
+	sr := new(MockSessionRepository)
+	al := newTestAuditLogger()
+	usecase := NewUserUsecase(new(MockUserRepository), new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), NewSessionManager(new(MockUserRepository), sr, testSecret), al, newTestPasswordHasher(), newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+	sr.On("RevokeSession", "sid").Return(nil).Once()
+
+	assert.NoError(t, usecase.Logout(1, "sid", testClient))
+	assert.Equal(t, []string{AuditLogout}, al.actions())
+	sr.AssertExpectations(t)
+}
+
+func TestUpdateSecurityEvents(t *testing.T) {
+	t.Run("メールアドレスの変更は確認待ちにする", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		er := new(MockEmailChangeRepository)
+		al := newTestAuditLogger()
+		ml := newTestMailer()
+		usecase := NewUserUsecase(mockRepo, er, newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), al, newTestPasswordHasher(), ml, newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		mockRepo.On("GetUserByID", uint(1)).Return(&model.User{ID: 1, Name: "Test", Email: "old@example.com"}, nil).Once()
+		mockRepo.On("GetUserByEmail", mock.Anything, "new@example.com").Return(gorm.ErrRecordNotFound).Once()
+		var saved *model.User
+		mockRepo.On("UpdateUser", mock.Anything).Run(func(args mock.Arguments) {
+			saved = args.Get(0).(*model.User)
+		}).Return(nil).Once()
+		er.On("CreateEmailChange", mock.MatchedBy(func(c *model.EmailChange) bool {
+			return c.UserID == 1 && c.OldEmail == "old@example.com" && c.NewEmail == "new@example.com" && c.Nonce != ""
+		})).Return(nil).Once()
+
+		res, err := usecase.Update(model.User{ID: 1, Password: "smuggled"}, "new@example.com", "", nil, "", testClient)
+		assert.NoError(t, err)
+		assert.Empty(t, saved.Password, "パスワードは更新しない")
+		assert.Empty(t, saved.Email, "確認されるまでメールアドレスは更新しない")
+		assert.Equal(t, "old@example.com", res.Email)
+		assert.Equal(t, "new@example.com", res.PendingEmail)
+		assert.Equal(t, []string{AuditEmailChangeRequested}, al.actions())
+		assert.Len(t, ml.messages, 1)
+		assert.Equal(t, "new@example.com", ml.messages[0].To, "確認メールは新しいアドレスに送る")
+		er.AssertExpectations(t)
+	})
+
+	t.Run("使用中のメールアドレス", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		al := newTestAuditLogger()
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), al, newTestPasswordHasher(), newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		mockRepo.On("GetUserByID", uint(1)).Return(&model.User{ID: 1, Email: "old@example.com"}, nil).Once()
+		mockRepo.On("GetUserByEmail", mock.Anything, "taken@example.com").Return(nil).Once()
+
+		_, err := usecase.Update(model.User{ID: 1}, "taken@example.com", "new name", nil, "", testClient)
+		assert.ErrorIs(t, err, ErrEmailAlreadyInUse)
+		mockRepo.AssertNotCalled(t, "UpdateUser", mock.Anything)
+		assert.Empty(t, al.actions())
+	})
+
+	t.Run("名前のみの変更は記録しない", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		al := newTestAuditLogger()
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), al, newTestPasswordHasher(), newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		mockRepo.On("UpdateUser", mock.Anything).Return(nil).Once()
+
+		_, err := usecase.Update(model.User{ID: 1}, "", "new name", nil, "", testClient)
+		assert.NoError(t, err)
+		assert.Empty(t, al.actions())
+	})
+
+	t.Run("更新に失敗した場合は記録しない", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		er := new(MockEmailChangeRepository)
+		al := newTestAuditLogger()
+		usecase := NewUserUsecase(mockRepo, er, newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), al, newTestPasswordHasher(), newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		mockRepo.On("GetUserByID", uint(1)).Return(&model.User{ID: 1, Email: "old@example.com"}, nil).Once()
+		mockRepo.On("GetUserByEmail", mock.Anything, "new@example.com").Return(gorm.ErrRecordNotFound).Once()
+		mockRepo.On("UpdateUser", mock.Anything).Return(errors.New("db error")).Once()
+
+		_, err := usecase.Update(model.User{ID: 1}, "new@example.com", "", nil, "", testClient)
+		assert.Error(t, err)
+		assert.Empty(t, al.actions())
+		er.AssertNotCalled(t, "CreateEmailChange", mock.Anything)
+	})
+}
+
+func TestLoginRehash(t *testing.T) {
+	current := newTestPasswordHasher()
+	storedWith := func(hash string) func(mock.Arguments) {
+		return func(args mock.Arguments) {
+			arg := args.Get(0).(*model.User)
+			arg.ID = 1
+			arg.Email = "test@example.com"
+			arg.Password = hash
+		}
+	}
+
+	t.Run("現在のパラメーターのハッシュは再ハッシュしない", func(t *testing.T) {
+		hash, err := current.Hash("password123")
+		assert.NoError(t, err)
+		mockRepo := new(MockUserRepository)
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), current, newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		mockRepo.On("GetUserByEmail", mock.Anything, "test@example.com").Run(storedWith(hash)).Return(nil).Once()
+
+		_, err = usecase.Login(model.User{Email: "test@example.com", Password: "password123"}, testClient)
+		assert.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything)
+	})
+
+	t.Run("弱いパラメーターのハッシュは再ハッシュする", func(t *testing.T) {
+		weak := auth.NewArgon2Hasher(auth.Argon2Params{Memory: 512, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+		hash, err := weak.Hash("password123")
+		assert.NoError(t, err)
+		mockRepo := new(MockUserRepository)
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), current, newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		mockRepo.On("GetUserByEmail", mock.Anything, "test@example.com").Run(storedWith(hash)).Return(nil).Once()
+		var rehashed string
+		mockRepo.On("UpdatePassword", uint(1), mock.Anything).Run(func(args mock.Arguments) {
+			rehashed = args.String(1)
+		}).Return(nil).Once()
+
+		_, err = usecase.Login(model.User{Email: "test@example.com", Password: "password123"}, testClient)
+		assert.NoError(t, err)
+		ok, needsRehash, err := current.Verify(rehashed, "password123")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.False(t, needsRehash)
+	})
+
+	t.Run("再ハッシュに失敗してもログインできる", func(t *testing.T) {
+		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		assert.NoError(t, err)
+		mockRepo := new(MockUserRepository)
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), current, newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		mockRepo.On("GetUserByEmail", mock.Anything, "test@example.com").Run(storedWith(string(hash))).Return(nil).Once()
+		mockRepo.On("UpdatePassword", uint(1), mock.Anything).Return(errors.New("db error")).Once()
+
+		result, err := usecase.Login(model.User{Email: "test@example.com", Password: "password123"}, testClient)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, result.Token)
+	})
+
+	t.Run("パスワードが違えば再ハッシュしない", func(t *testing.T) {
+		hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+		assert.NoError(t, err)
+		mockRepo := new(MockUserRepository)
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), current, newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		mockRepo.On("GetUserByEmail", mock.Anything, "test@example.com").Run(storedWith(string(hash))).Return(nil).Once()
+
+		_, err = usecase.Login(model.User{Email: "test@example.com", Password: "password124"}, testClient)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		mockRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything)
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	hasher := newTestPasswordHasher()
+	currentHash, err := hasher.Hash("current-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &model.User{ID: 1, Name: "テスト", Email: "test@example.com", Password: currentHash}
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		sr := new(MockSessionRepository)
+		al := newTestAuditLogger()
+		ml := newTestMailer()
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), NewSessionManager(mockRepo, sr, testSecret), al, hasher, ml, newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+
+		mockRepo.On("GetUserByID", uint(1)).Return(user, nil).Once()
+		var saved string
+		mockRepo.On("UpdatePassword", uint(1), mock.Anything).Run(func(args mock.Arguments) {
+			saved = args.String(1)
+		}).Return(nil).Once()
+		sr.On("RevokeUserSessions", uint(1), "sid").Return(nil).Once()
+
+		err := usecase.ChangePassword(1, "sid", model.PasswordChangeRequest{CurrentPassword: "current-pass", NewPassword: "new-password"}, testClient)
+		assert.NoError(t, err)
+		ok, _, err := hasher.Verify(saved, "new-password")
+		assert.NoError(t, err)
+		assert.True(t, ok, "新しいパスワードをハッシュ化して保存する")
+		sr.AssertExpectations(t)
+		assert.Equal(t, []string{AuditPasswordChanged}, al.actions())
+		assert.Len(t, ml.messages, 1)
+		assert.Equal(t, "test@example.com", ml.messages[0].To)
+		assert.Contains(t, ml.messages[0].Body, testClient.IP)
+	})
+
+	t.Run("現在のパスワードが違う", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		ml := newTestMailer()
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), hasher, ml, newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		mockRepo.On("GetUserByID", uint(1)).Return(user, nil).Once()
+
+		err := usecase.ChangePassword(1, "sid", model.PasswordChangeRequest{CurrentPassword: "wrong-pass", NewPassword: "new-password"}, testClient)
+		assert.ErrorIs(t, err, ErrIncorrectPassword)
+		mockRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything)
+		assert.Empty(t, ml.messages)
+	})
+
+	t.Run("現在のパスワードの総当たりはロックされる", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), hasher, newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+		mockRepo.On("GetUserByID", uint(1)).Return(user, nil)
+
+		req := model.PasswordChangeRequest{CurrentPassword: "wrong-pass", NewPassword: "new-password"}
+		for i := 0; i < 5; i++ {
+			assert.ErrorIs(t, usecase.ChangePassword(1, "sid", req, testClient), ErrIncorrectPassword)
+		}
+		assert.ErrorIs(t, usecase.ChangePassword(1, "sid", req, testClient), ErrTooManyAttempts)
+	})
+
+	t.Run("新しいパスワードが条件を満たさない", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		usecase := NewUserUsecase(mockRepo, new(MockEmailChangeRepository), newTestUserValidator(), newTestLoginGuard(), newTestSessionManager(), newTestAuditLogger(), hasher, newTestMailer(), newTestObjectStore(), new(MockUploadRepository), newTestStorageUsage(), testSecret, testFEURL)
+
+		mockRepo.On("GetUserByID", uint(1)).Return(user, nil)
+
+		for _, tc := range []struct {
+			req  model.PasswordChangeRequest
+			code string
+		}{
+			{model.PasswordChangeRequest{CurrentPassword: "current-pass", NewPassword: "short"}, validator.CodePasswordTooShort},
+			{model.PasswordChangeRequest{CurrentPassword: "current-pass", NewPassword: "current-pass"}, validator.CodePasswordUnchanged},
+			{model.PasswordChangeRequest{CurrentPassword: "current-pass", NewPassword: "my-test-account"}, validator.CodePasswordPersonalInfo},
+			{model.PasswordChangeRequest{CurrentPassword: "current-pass", NewPassword: "Password123!"}, validator.CodePasswordCommon},
+			{model.PasswordChangeRequest{NewPassword: "new-password"}, "validation_required"},
+		} {
+			err := usecase.ChangePassword(1, "sid", tc.req, testClient)
+			var verrs validation.Errors
+			if assert.ErrorAs(t, err, &verrs) {
+				for _, fieldErr := range verrs {
+					assert.Equal(t, tc.code, fieldErr.(validation.Error).Code(), tc.req.NewPassword)
+				}
+			}
+		}
+		mockRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,276 @@
+package validator
+
+// パスワードの強度のポリシー
+// 長さ・推定エントロピーによる強度・メールアドレスや名前を含まないこと・よく使われるパスワードでないことを確認する
+// 条件は環境変数で変更でき、エラーはフロントエンドで翻訳できるようコードを付けて返す
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// パスワードのエラーコード
+const (
+	CodePasswordRequired     = "password_required"
+	CodePasswordTooShort     = "password_too_short"
+	CodePasswordTooLong      = "password_too_long"
+	CodePasswordTooWeak      = "password_too_weak"
+	CodePasswordPersonalInfo = "password_contains_personal_info"
+	CodePasswordCommon       = "password_too_common"
+	CodePasswordUnchanged    = "password_same_as_current"
+)
+
+// PasswordUserInfo はパスワードに含めてはいけない利用者の情報
+type PasswordUserInfo struct {
+	Email string
+	Name  string
+}
+
+// PasswordRule はパスワードの条件の1つ
+// 満たさない場合はコード付きのvalidation.Errorを返す
+type PasswordRule interface {
+	Check(password string, info PasswordUserInfo) error
+}
+
+// PasswordPolicy は条件を順に確認し、最初に満たさなかった条件のエラーを返す
+type PasswordPolicy struct {
+	rules []PasswordRule
+}
+
+func NewPasswordPolicy(rules ...PasswordRule) *PasswordPolicy {
+	return &PasswordPolicy{rules}
+}
+
+func (p *PasswordPolicy) Check(password string, info PasswordUserInfo) error {
+	for _, r := range p.rules {
+		if err := r.Check(password, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PasswordPolicyConfig はポリシーの設定
+type PasswordPolicyConfig struct {
+	MinLength          int  // 文字数（rune）の下限
+	MaxLength          int  // 文字数の上限（パスフレーズを使えるよう長めにする）
+	MinStrength        int  // 強度スコア（0〜4）の下限
+	RejectPersonalInfo bool // メールアドレスや名前を含むパスワードを拒否する
+	RejectCommon       bool // よく使われるパスワードを拒否する
+}
+
+// DefaultPasswordPolicyConfig はNIST SP 800-63Bを参考にした既定値
+func DefaultPasswordPolicyConfig() PasswordPolicyConfig {
+	return PasswordPolicyConfig{
+		MinLength:          8,
+		MaxLength:          128,
+		MinStrength:        2,
+		RejectPersonalInfo: true,
+		RejectCommon:       true,
+	}
+}
+
+// NewPasswordPolicyFromConfig は設定に応じた条件を組み合わせる
+// 利用者が直しやすいよう、長さ・個人情報・よく使われるもの・強度の順に確認する
+func NewPasswordPolicyFromConfig(cfg PasswordPolicyConfig) *PasswordPolicy {
+	rules := []PasswordRule{LengthRule{Min: cfg.MinLength, Max: cfg.MaxLength}}
+	if cfg.RejectPersonalInfo {
+		rules = append(rules, PersonalInfoRule{})
+	}
+	if cfg.RejectCommon {
+		rules = append(rules, CommonPasswordRule{})
+	}
+	if cfg.MinStrength > 0 {
+		rules = append(rules, StrengthRule{MinScore: cfg.MinStrength})
+	}
+	return NewPasswordPolicy(rules...)
+}
+
+// DefaultPasswordPolicy は既定の設定のポリシー
+func DefaultPasswordPolicy() *PasswordPolicy {
+	return NewPasswordPolicyFromConfig(DefaultPasswordPolicyConfig())
+}
+
+// LengthRule は文字数の条件
+type LengthRule struct {
+	Min int
+	Max int
+}
+
+func (r LengthRule) Check(password string, _ PasswordUserInfo) error {
+	n := utf8.RuneCountInString(password)
+	switch {
+	case n == 0:
+		return validation.NewError(CodePasswordRequired, "password is required")
+	case n < r.Min:
+		return validation.NewError(CodePasswordTooShort, "must be at least {{.min}} characters").
+			SetParams(map[string]interface{}{"min": r.Min})
+	case r.Max > 0 && n > r.Max:
+		return validation.NewError(CodePasswordTooLong, "must be at most {{.max}} characters").
+			SetParams(map[string]interface{}{"max": r.Max})
+	}
+	return nil
+}
+
+// PersonalInfoRule はメールアドレス（@より前）や名前を含むパスワードを拒否する
+type PersonalInfoRule struct{}
+
+// 短すぎる名前などは偶然含まれることが多いため対象にしない
+const minPersonalInfoLength = 3
+
+func (PersonalInfoRule) Check(password string, info PasswordUserInfo) error {
+	lower := strings.ToLower(password)
+	var parts []string
+	if local, _, ok := strings.Cut(info.Email, "@"); ok {
+		parts = append(parts, local)
+	}
+	parts = append(parts, info.Name)
+	parts = append(parts, strings.Fields(info.Name)...)
+	for _, p := range parts {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if utf8.RuneCountInString(p) >= minPersonalInfoLength && strings.Contains(lower, p) {
+			return validation.NewError(CodePasswordPersonalInfo, "must not contain your email address or name")
+		}
+	}
+	return nil
+}
+
+// CommonPasswordRule はよく使われるパスワードを拒否する
+type CommonPasswordRule struct{}
+
+func (CommonPasswordRule) Check(password string, _ PasswordUserInfo) error {
+	if IsCommonPassword(password) {
+		return validation.NewError(CodePasswordCommon, "is too common")
+	}
+	return nil
+}
+
+// StrengthRule は推定エントロピーから求めた強度スコアの条件
+type StrengthRule struct {
+	MinScore int
+}
+
+func (r StrengthRule) Check(password string, _ PasswordUserInfo) error {
+	if _, score := PasswordStrength(password); score < r.MinScore {
+		return validation.NewError(CodePasswordTooWeak, "is too weak").
+			SetParams(map[string]interface{}{"score": score, "min_score": r.MinScore})
+	}
+	return nil
+}
+
+const (
+	MaxPasswordLength   = 256 // ハッシュ化の負荷を抑えるための上限（設定でもこれ以上にはできない）
+	MaxPasswordStrength = 4
+)
+
+// strengthThresholds は強度スコア1〜4に必要なエントロピー（ビット）
+var strengthThresholds = []float64{20, 35, 50, 70}
+
+// PasswordStrength はパスワードの推定エントロピー（ビット）と強度スコア（0〜4）を返す
+// 使われている文字種から1文字あたりのビット数を求め、直前と同じ文字や連続する文字（aaa・abc・321）は1ビットとして数える
+func PasswordStrength(password string) (float64, int) {
+	pool := 0
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < utf8.RuneSelf && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+	for _, c := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if c.used {
+			pool += c.size
+		}
+	}
+	if pool == 0 {
+		return 0, 0
+	}
+
+	perRune := math.Log2(float64(pool))
+	bits := 0.0
+	prev := rune(-1)
+	for _, r := range password {
+		d := r - prev
+		if prev >= 0 && (d == 0 || d == 1 || d == -1) {
+			bits++
+		} else {
+			bits += perRune
+		}
+		prev = r
+	}
+
+	score := 0
+	for _, t := range strengthThresholds {
+		if bits >= t {
+			score++
+		}
+	}
+	return bits, score
+}
+
+//go:embed common_passwords.txt.gz
+var commonPasswordsGz []byte
+
+var (
+	commonPasswordsOnce sync.Once
+	commonPasswords     map[string]struct{}
+)
+
+// loadCommonPasswords は同梱したリストを初回の確認時に展開する
+func loadCommonPasswords() {
+	commonPasswords = map[string]struct{}{}
+	zr, err := gzip.NewReader(bytes.NewReader(commonPasswordsGz))
+	if err != nil {
+		log.Printf("failed to load common password list: %v", err)
+		return
+	}
+	defer zr.Close()
+	sc := bufio.NewScanner(zr)
+	for sc.Scan() {
+		if w := strings.TrimSpace(sc.Text()); w != "" {
+			commonPasswords[strings.ToLower(w)] = struct{}{}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		log.Printf("failed to load common password list: %v", err)
+	}
+}
+
+// IsCommonPassword はよく使われるパスワードかを返す
+// 大文字小文字は区別せず、末尾に数字や記号を付け足しただけのもの（Password123!など）も該当とする
+func IsCommonPassword(password string) bool {
+	commonPasswordsOnce.Do(loadCommonPasswords)
+	lower := strings.ToLower(password)
+	if _, ok := commonPasswords[lower]; ok {
+		return true
+	}
+	base := strings.TrimRightFunc(lower, func(r rune) bool {
+		return unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})
+	if utf8.RuneCountInString(base) >= 4 && base != lower {
+		_, ok := commonPasswords[base]
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package validator
+
+import (
+	"backend/model"
+	"strings"
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// codeOf はエラーのコードを返す（エラーがなければ空文字）
+func codeOf(t *testing.T, err error) string {
+	t.Helper()
+	if err == nil {
+		return ""
+	}
+	verr, ok := err.(validation.Error)
+	if !assert.True(t, ok, "コード付きのエラーを返す: %v", err) {
+		return ""
+	}
+	return verr.Code()
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+	info := PasswordUserInfo{Email: "hanako@example.com", Name: "Hanako Yamada"}
+
+	testCases := []struct {
+		password string
+		code     string
+	}{
+		{"", CodePasswordRequired},
+		{"Xk9#mQ2", CodePasswordTooShort},
+		{strings.Repeat("Xk9#mQ2z", 17), CodePasswordTooLong},
+		{"hanako-likes-curry", CodePasswordPersonalInfo},
+		{"i-am-YAMADA-2025", CodePasswordPersonalInfo},
+		{"qwertyuiop", CodePasswordCommon},
+		{"Sunshine2025!", CodePasswordCommon},
+		{"abcdefghijkl", CodePasswordTooWeak},
+		{"zzzzyyyy1", CodePasswordTooWeak},
+		{"correct horse battery staple", ""}, // 30文字を超えるパスフレーズも使える
+		{"Xk9#mQ2z", ""},
+		{"ふじさんにのぼりたい", ""},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.code, codeOf(t, policy.Check(tc.password, info)), tc.password)
+	}
+}
+
+func TestPasswordPolicyErrorParams(t *testing.T) {
+	err := DefaultPasswordPolicy().Check("short", PasswordUserInfo{})
+	verr := err.(validation.Error)
+	assert.Equal(t, map[string]interface{}{"min": 8}, verr.Params())
+	assert.Equal(t, "must be at least 8 characters", verr.Error())
+}
+
+func TestPasswordStrength(t *testing.T) {
+	_, weak := PasswordStrength("abcdefghijkl")
+	_, strong := PasswordStrength("T4v!qz#Lp9@w")
+	assert.Less(t, weak, strong)
+	assert.Equal(t, MaxPasswordStrength, strong)
+
+	bits, score := PasswordStrength("")
+	assert.Zero(t, bits)
+	assert.Zero(t, score)
+}
+
+func TestIsCommonPassword(t *testing.T) {
+	assert.True(t, IsCommonPassword("password"))
+	assert.True(t, IsCommonPassword("PASSWORD"))
+	assert.True(t, IsCommonPassword("Dragon2024!"), "末尾に数字や記号を付けただけのもの")
+	assert.False(t, IsCommonPassword("correct horse battery staple"))
+	assert.False(t, IsCommonPassword("2024!"))
+}
+
+func TestNewPasswordPolicyFromConfig(t *testing.T) {
+	// 無効にした条件は確認しない
+	policy := NewPasswordPolicyFromConfig(PasswordPolicyConfig{MinLength: 4, MaxLength: 64})
+	assert.NoError(t, policy.Check("password", PasswordUserInfo{}))
+}
+
+func TestUserValidatorPassword(t *testing.T) {
+	uv := NewUserValidator(DefaultPasswordPolicy())
+	user := model.User{Email: "hanako@example.com", Name: "Hanako"}
+
+	err := uv.PasswordChangeValidate(model.PasswordChangeRequest{CurrentPassword: "Xk9#mQ2z", NewPassword: "Xk9#mQ2z"}, user)
+	if verrs, ok := err.(validation.Errors); assert.True(t, ok) {
+		assert.Equal(t, CodePasswordUnchanged, codeOf(t, verrs["new_password"]))
+	}
+	assert.NoError(t, uv.PasswordChangeValidate(model.PasswordChangeRequest{CurrentPassword: "Xk9#mQ2z", NewPassword: "T4v!qz#Lp9@w"}, user))
+
+	err = uv.SignUpValidate(model.User{Email: "hanako@example.com", Name: "Hanako", Password: "hanako-2025!"})
+	if verrs, ok := err.(validation.Errors); assert.True(t, ok) {
+		assert.Equal(t, CodePasswordPersonalInfo, codeOf(t, verrs["password"]))
+	}
+
+	// ログインでは以前の条件で作成したパスワードも受け付ける
+	assert.NoError(t, uv.UserValidate(model.User{Email: "hanako@example.com", Password: "abc123"}))
+}
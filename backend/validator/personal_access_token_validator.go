@@ -0,0 +1,47 @@
+package validator
+
+// パーソナルアクセストークン作成時の名前・スコープ・有効期限のバリデーション
+
+import (
+	"backend/auth"
+	"backend/model"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// トークンの有効期限の上限（日）
+const MaxTokenExpiresInDays = 365
+
+type IPersonalAccessTokenValidator interface {
+	PersonalAccessTokenValidate(req model.PersonalAccessTokenRequest) error
+}
+
+type personalAccessTokenValidator struct{}
+
+func NewPersonalAccessTokenValidator() IPersonalAccessTokenValidator {
+	return &personalAccessTokenValidator{}
+}
+
+func (pv *personalAccessTokenValidator) PersonalAccessTokenValidate(req model.PersonalAccessTokenRequest) error {
+	scopes := make([]interface{}, 0, len(auth.Scopes))
+	for _, s := range auth.Scopes {
+		scopes = append(scopes, s)
+	}
+	return validation.ValidateStruct(&req,
+		validation.Field(
+			&req.Name,
+			validation.Required.Error("name is required"),
+			validation.RuneLength(1, 100).Error("limited max 100 char"),
+		),
+		validation.Field(
+			&req.Scopes,
+			validation.Required.Error("scopes is required"),
+			validation.Each(validation.In(scopes...).Error("unknown scope")),
+		),
+		validation.Field(
+			&req.ExpiresInDays,
+			validation.Min(0).Error("must not be negative"),
+			validation.Max(MaxTokenExpiresInDays).Error("limited max 365 days"),
+		),
+	)
+}
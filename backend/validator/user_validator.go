@@ -1,6 +1,8 @@
 package validator
 
 // ログイン等のフォームにemailまたはパスワードが入力されていないもしくは正しい形式でない場合のバリデーションを行っている
+// サインアップとパスワード変更では、新しいパスワードがPasswordPolicyを満たすことを確認する（変更時は現在のパスワードと異なることも確認する）
+// メールアドレス変更では、新しいアドレスがサインアップと同じ条件を満たすことを確認する
 
 import (
 	"backend/model"
@@ -10,27 +12,76 @@ import (
 )
 
 type IUserValidator interface {
-	UserValidate(user model.User) error
+	UserValidate(user model.User) error // ログイン
+	SignUpValidate(user model.User) error
+	PasswordChangeValidate(req model.PasswordChangeRequest, user model.User) error
+	EmailValidate(email string) error
 }
 
-type userValidator struct{}
+// emailRules はメールアドレスの条件（サインアップ・ログイン・変更で共通）
+var emailRules = []validation.Rule{
+	validation.Required.Error("email is required"),
+	validation.RuneLength(1, 30).Error("limited max 30 char"),
+	is.Email.Error("is not valid email format"), // 値がemailのフォーマットに準拠しているか
+}
+
+// loginPasswordRules はログイン時のパスワードの条件
+// 既存のアカウントは以前の条件（6文字以上）で作成されているため、ポリシーは適用しない
+var loginPasswordRules = []validation.Rule{
+	validation.Required.Error("password is required"),
+	validation.RuneLength(6, MaxPasswordLength).Error("limited min 6 max 256 char"),
+}
+
+type userValidator struct {
+	pp *PasswordPolicy
+}
 
-func NewUserValidator() IUserValidator {
-	return &userValidator{}
+func NewUserValidator(pp *PasswordPolicy) IUserValidator {
+	return &userValidator{pp}
 }
 
 func (uv *userValidator) UserValidate(user model.User) error {
 	return validation.ValidateStruct(&user,
+		validation.Field(&user.Email, emailRules...),
+		validation.Field(&user.Password, loginPasswordRules...),
+	)
+}
+
+func (uv *userValidator) SignUpValidate(user model.User) error {
+	info := PasswordUserInfo{Email: user.Email, Name: user.Name}
+	return validation.ValidateStruct(&user,
+		validation.Field(&user.Email, emailRules...),
+		validation.Field(&user.Password, uv.policyRule(info)),
+	)
+}
+
+func (uv *userValidator) PasswordChangeValidate(req model.PasswordChangeRequest, user model.User) error {
+	info := PasswordUserInfo{Email: user.Email, Name: user.Name}
+	return validation.ValidateStruct(&req,
 		validation.Field(
-			&user.Email,
-			validation.Required.Error("email is required"),
-			validation.RuneLength(1, 30).Error("limited max 30 char"),
-			is.Email.Error("is not valid email format"), // 値がemailのフォーマットに準拠しているか
+			&req.CurrentPassword,
+			validation.Required.Error("current password is required"),
 		),
 		validation.Field(
-			&user.Password,
-			validation.Required.Error("password is required"),
-			validation.RuneLength(6, 30).Error("limited min 6 max 30 char"),
+			&req.NewPassword,
+			uv.policyRule(info),
+			validation.NotIn(req.CurrentPassword).ErrorObject(
+				validation.NewError(CodePasswordUnchanged, "must be different from the current password"),
+			),
 		),
 	)
 }
+
+func (uv *userValidator) EmailValidate(email string) error {
+	return validation.Errors{
+		"email": validation.Validate(email, emailRules...),
+	}.Filter()
+}
+
+// policyRule はPasswordPolicyをozzo-validationのルールとして使えるようにする
+func (uv *userValidator) policyRule(info PasswordUserInfo) validation.Rule {
+	return validation.By(func(value interface{}) error {
+		password, _ := value.(string)
+		return uv.pp.Check(password, info)
+	})
+}